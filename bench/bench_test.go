@@ -0,0 +1,76 @@
+package bench
+
+import (
+	"testing"
+
+	"github.com/acolita/v8wire/pkg/v8serialize"
+)
+
+func BenchmarkSerialize(b *testing.B) {
+	for _, p := range Corpus() {
+		b.Run(p.Name, func(b *testing.B) {
+			data, err := v8serialize.Serialize(p.Value)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := v8serialize.Serialize(p.Value); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkDeserialize(b *testing.B) {
+	for _, p := range Corpus() {
+		b.Run(p.Name, func(b *testing.B) {
+			data, err := v8serialize.Serialize(p.Value)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := v8serialize.Deserialize(data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkToGo(b *testing.B) {
+	for _, p := range Corpus() {
+		b.Run(p.Name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				v8serialize.ToGo(p.Value)
+			}
+		})
+	}
+}
+
+// TestCorpusRoundTrip keeps the corpus decodable, so a broken payload
+// fails the test suite instead of silently skewing benchmark numbers.
+func TestCorpusRoundTrip(t *testing.T) {
+	for _, p := range Corpus() {
+		data, err := v8serialize.Serialize(p.Value)
+		if err != nil {
+			t.Fatalf("%s: Serialize failed: %v", p.Name, err)
+		}
+		v, err := v8serialize.Deserialize(data)
+		if err != nil {
+			t.Fatalf("%s: Deserialize failed: %v", p.Name, err)
+		}
+		k1, _ := v8serialize.Key(p.Value)
+		k2, _ := v8serialize.Key(v)
+		if k1 != k2 {
+			t.Errorf("%s: round trip changed the value", p.Name)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+// Package bench holds reproducible benchmarks over payloads shaped like real
+// traffic, so performance work and regressions can be measured:
+//
+//	go test -run=^$ -bench=. -benchmem ./bench/
+//
+// Every payload is generated from a fixed seed, so results are comparable
+// across runs and machines.
+package bench
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/acolita/v8wire/pkg/v8serialize"
+)
+
+// Payload is a named value in the corpus.
+type Payload struct {
+	Name  string
+	Value v8serialize.Value
+}
+
+// Corpus returns the benchmark payloads:
+//   - config: a deeply nested configuration object with mixed scalars
+//   - telemetry: a long array of numeric samples (ints and doubles)
+//   - i18n: a message catalogue of many strings, mostly non-Latin
+//   - image: an object wrapping a 256 KiB binary buffer
+func Corpus() []Payload {
+	rng := rand.New(rand.NewSource(1))
+	return []Payload{
+		{"config", config(rng, 6)},
+		{"telemetry", telemetry(rng, 20000)},
+		{"i18n", i18n(rng, 2000)},
+		{"image", image(rng, 256<<10)},
+	}
+}
+
+func config(rng *rand.Rand, depth int) v8serialize.Value {
+	props := map[string]v8serialize.Value{
+		"enabled": v8serialize.Bool(rng.Intn(2) == 0),
+		"retries": v8serialize.Int32(int32(rng.Intn(10))),
+		"timeout": v8serialize.Double(rng.Float64() * 30),
+		"name":    v8serialize.String(fmt.Sprintf("service-%d", rng.Intn(1000))),
+		"tags": v8serialize.Array([]v8serialize.Value{
+			v8serialize.String("prod"), v8serialize.String("eu-west-1"),
+		}),
+	}
+	if depth > 0 {
+		for i := 0; i < 3; i++ {
+			props[fmt.Sprintf("child%d", i)] = config(rng, depth-1)
+		}
+	}
+	return v8serialize.Object(props)
+}
+
+func telemetry(rng *rand.Rand, n int) v8serialize.Value {
+	samples := make([]v8serialize.Value, n)
+	for i := range samples {
+		if i%2 == 0 {
+			samples[i] = v8serialize.Int32(int32(rng.Intn(1 << 20)))
+		} else {
+			samples[i] = v8serialize.Double(rng.NormFloat64() * 100)
+		}
+	}
+	return v8serialize.Object(map[string]v8serialize.Value{
+		"device":  v8serialize.String("sensor-42"),
+		"samples": v8serialize.Array(samples),
+	})
+}
+
+var scripts = [][2]rune{
+	{'a', 'z'},         // Latin
+	{0x0430, 0x044F},   // Cyrillic
+	{0x4E00, 0x9FFF},   // CJK
+	{0x0627, 0x064A},   // Arabic
+	{0x1F600, 0x1F64F}, // emoji (surrogate pairs)
+}
+
+func i18n(rng *rand.Rand, n int) v8serialize.Value {
+	messages := make(map[string]v8serialize.Value, n)
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		script := scripts[rng.Intn(len(scripts))]
+		b.Reset()
+		for j := 10 + rng.Intn(60); j > 0; j-- {
+			b.WriteRune(script[0] + rune(rng.Intn(int(script[1]-script[0]+1))))
+		}
+		messages[fmt.Sprintf("msg.%d", i)] = v8serialize.String(b.String())
+	}
+	return v8serialize.Object(messages)
+}
+
+func image(rng *rand.Rand, size int) v8serialize.Value {
+	pixels := make([]byte, size)
+	rng.Read(pixels)
+	return v8serialize.Object(map[string]v8serialize.Value{
+		"width":  v8serialize.Int32(512),
+		"height": v8serialize.Int32(int32(size / 512)),
+		"format": v8serialize.String("gray8"),
+		"data":   v8serialize.ArrayBuffer(pixels),
+	})
+}
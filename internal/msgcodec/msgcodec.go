@@ -0,0 +1,99 @@
+// Package msgcodec implements what the message transports (buscodec and
+// wsclone) share: serialization with per-transport options, compression
+// of large payloads and size-limited decompression. Each transport keeps
+// its own framing, option functions and error values.
+package msgcodec
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/acolita/v8wire/pkg/v8serialize"
+)
+
+// DefaultMaxSize is the default limit on a message body, as received and
+// after decompression.
+const DefaultMaxSize = 64 << 20
+
+// Compression is a compression scheme, such as gzip or zlib.
+type Compression struct {
+	NewWriter func(w io.Writer) io.WriteCloser
+	NewReader func(r io.Reader) (io.ReadCloser, error)
+}
+
+// Codec holds a transport's settings. The zero value compresses nothing
+// and has no size limit; transports start from DefaultMaxSize.
+type Codec struct {
+	Compression Compression
+	CompressMin int // compress payloads of at least this many bytes; 0 disables
+	MaxSize     int // limit on message bodies; 0 or less is unlimited
+	Decode      []v8serialize.Option
+	Encode      []v8serialize.SerializerOption
+
+	// ErrTooLarge and ErrCorrupt are the transport's errors for bodies
+	// over MaxSize and for bodies that do not decompress.
+	ErrTooLarge error
+	ErrCorrupt  error
+}
+
+// Serialize serializes v with the encode options.
+func (c *Codec) Serialize(v v8serialize.Value) ([]byte, error) {
+	return v8serialize.Serialize(v, c.Encode...)
+}
+
+// SerializeGo serializes a Go value with the encode options.
+func (c *Codec) SerializeGo(v interface{}) ([]byte, error) {
+	return v8serialize.SerializeGo(v, c.Encode...)
+}
+
+// Deserialize decodes a payload with the decode options.
+func (c *Codec) Deserialize(data []byte) (v8serialize.Value, error) {
+	return v8serialize.Deserialize(data, c.Decode...)
+}
+
+// Compress returns data compressed, and true, if it is at least
+// CompressMin bytes, and data unchanged otherwise.
+func (c *Codec) Compress(data []byte) ([]byte, bool, error) {
+	if c.CompressMin <= 0 || len(data) < c.CompressMin {
+		return data, false, nil
+	}
+	var buf bytes.Buffer
+	zw := c.Compression.NewWriter(&buf)
+	zw.Write(data) // writes to a bytes.Buffer do not fail
+	if err := zw.Close(); err != nil {
+		return nil, false, err
+	}
+	return buf.Bytes(), true, nil
+}
+
+// CheckSize fails with ErrTooLarge if a body of n bytes exceeds MaxSize.
+func (c *Codec) CheckSize(n int) error {
+	if c.MaxSize > 0 && n > c.MaxSize {
+		return fmt.Errorf("%w: %d bytes, limit %d", c.ErrTooLarge, n, c.MaxSize)
+	}
+	return nil
+}
+
+// Decompress inflates body, failing with ErrTooLarge as soon as the output
+// exceeds MaxSize, so a small compressed bomb cannot exhaust memory.
+func (c *Codec) Decompress(body []byte) ([]byte, error) {
+	zr, err := c.Compression.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", c.ErrCorrupt, err)
+	}
+	defer zr.Close()
+
+	var r io.Reader = zr
+	if c.MaxSize > 0 {
+		r = io.LimitReader(zr, int64(c.MaxSize)+1)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", c.ErrCorrupt, err)
+	}
+	if c.MaxSize > 0 && len(data) > c.MaxSize {
+		return nil, fmt.Errorf("%w: decompressed payload exceeds %d bytes", c.ErrTooLarge, c.MaxSize)
+	}
+	return data, nil
+}
@@ -0,0 +1,204 @@
+package wire
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// The fuzz targets below start reading at a fuzzer-chosen position, in a
+// sub-reader at a fuzzer-chosen offset so alignment is relative to a parent
+// input, as it is for nested payloads.
+
+// fuzzReader returns a Reader over data positioned at pos, within a
+// sub-reader starting at off, or nil if data is too short.
+func fuzzReader(data []byte, off, pos uint16) *Reader {
+	if len(data) == 0 {
+		return nil
+	}
+	o := int(off) % len(data)
+	parent := NewReader(data)
+	parent.Skip(o)
+	r, err := parent.Slice(len(data) - o)
+	if err != nil {
+		return nil
+	}
+	r.Skip(int(pos) % (r.Len() + 1))
+	return r
+}
+
+func checkVarint(t *testing.T, r *Reader) {
+	start := r.Pos()
+	want, n := binary.Uvarint(r.Data()[start:])
+	if n == 0 && r.Len()-start >= maxVarintLen {
+		n = -1 // a continuation bit on the tenth byte overflows, not truncates
+	}
+	got, err := r.ReadVarint()
+	switch {
+	case n > 0:
+		if err != nil || got != want || r.Pos() != start+n {
+			t.Fatalf("ReadVarint = %d, %v at %d; want %d at %d", got, err, r.Pos(), want, start+n)
+		}
+	case n == 0:
+		if !errors.Is(err, ErrUnexpectedEOF) {
+			t.Fatalf("truncated varint: got %d, %v", got, err)
+		}
+	default:
+		if !errors.Is(err, ErrVarintOverflow) {
+			t.Fatalf("overlong varint: got %d, %v", got, err)
+		}
+	}
+}
+
+func checkZigZag(t *testing.T, r *Reader) {
+	start := r.Pos()
+	v, err := r.ReadZigZag()
+	r.pos = start
+	u, uerr := r.ReadVarint()
+	if (err == nil) != (uerr == nil) {
+		t.Fatalf("ReadZigZag error %v, ReadVarint error %v", err, uerr)
+	}
+	if err == nil && ZigZagEncode(v) != u {
+		t.Fatalf("ReadZigZag = %d does not re-encode to %d", v, u)
+	}
+
+	r.pos = start
+	v32, err := r.ReadZigZag32()
+	if err == nil && (uint64(ZigZagEncode32(v32)) != u || int64(v32) != v) {
+		t.Fatalf("ReadZigZag32 = %d, ReadZigZag = %d, varint %d", v32, v, u)
+	}
+	if err == nil && u > 0xFFFFFFFF || err != nil && uerr == nil && u <= 0xFFFFFFFF {
+		t.Fatalf("ReadZigZag32 error %v for varint %d", err, u)
+	}
+}
+
+func checkTwoByteString(t *testing.T, r *Reader, length int) {
+	start := r.Pos()
+	aligned := start
+	if (r.base+start)%2 != 0 && start < r.Len() {
+		aligned++
+	}
+	s, err := r.ReadTwoByteString(length)
+	if err != nil {
+		if length > 0 && aligned+length*2 <= r.Len() {
+			t.Fatalf("ReadTwoByteString(%d) failed with %d bytes available: %v", length, r.Len()-aligned, err)
+		}
+		return
+	}
+	if !utf8.ValidString(s) {
+		t.Fatalf("ReadTwoByteString returned invalid UTF-8 %q", s)
+	}
+	if length == 0 {
+		return
+	}
+	if r.Pos() != aligned+length*2 {
+		t.Fatalf("position %d after reading %d units from %d", r.Pos(), length, aligned)
+	}
+	units := make([]uint16, length)
+	for i := range units {
+		units[i] = binary.LittleEndian.Uint16(r.Data()[aligned+i*2:])
+	}
+	if want := string(utf16.Decode(units)); s != want {
+		t.Fatalf("ReadTwoByteString = %q, want %q", s, want)
+	}
+}
+
+func checkAlignTo(t *testing.T, r *Reader, boundary int) {
+	start := r.Pos()
+	r.AlignTo(boundary)
+	pos := r.Pos()
+	switch {
+	case pos < start || pos > r.Len():
+		t.Fatalf("AlignTo(%d) moved from %d to %d of %d", boundary, start, pos, r.Len())
+	case boundary <= 0 || boundary&(boundary-1) != 0:
+		if pos != start {
+			t.Fatalf("AlignTo(%d) moved for an invalid boundary", boundary)
+		}
+	case pos-start >= boundary:
+		t.Fatalf("AlignTo(%d) skipped %d bytes", boundary, pos-start)
+	case (r.base+pos)%boundary != 0 && pos != start:
+		t.Fatalf("AlignTo(%d) stopped unaligned at %d", boundary, r.base+pos)
+	}
+}
+
+func FuzzReadVarint(f *testing.F) {
+	f.Add([]byte{0x00}, uint16(0), uint16(0))
+	f.Add([]byte{0xac, 0x02}, uint16(0), uint16(0))
+	f.Add([]byte{0x80, 0x80, 0x80}, uint16(1), uint16(0))
+	f.Add([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}, uint16(0), uint16(0))
+	f.Add([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x02}, uint16(0), uint16(0))
+	f.Fuzz(func(t *testing.T, data []byte, off, pos uint16) {
+		if r := fuzzReader(data, off, pos); r != nil {
+			checkVarint(t, r)
+		}
+	})
+}
+
+func FuzzReadZigZag(f *testing.F) {
+	f.Add([]byte{0x01}, uint16(0), uint16(0))
+	f.Add([]byte{0xfe, 0xff, 0xff, 0xff, 0x0f}, uint16(0), uint16(0))
+	f.Add([]byte{0xff, 0xff, 0xff, 0xff, 0x1f}, uint16(0), uint16(0))
+	f.Fuzz(func(t *testing.T, data []byte, off, pos uint16) {
+		if r := fuzzReader(data, off, pos); r != nil {
+			checkZigZag(t, r)
+		}
+	})
+}
+
+func FuzzReadTwoByteString(f *testing.F) {
+	f.Add([]byte{0x00, 'h', 0x00, 'i', 0x00}, uint16(0), uint16(1), uint16(2))
+	f.Add([]byte{0x3d, 0xd8, 0x00, 0xde}, uint16(0), uint16(0), uint16(2)) // surrogate pair
+	f.Add([]byte{0x3d, 0xd8, 0x41, 0x00}, uint16(0), uint16(0), uint16(2)) // unpaired high surrogate
+	f.Add([]byte{0x00, 0x3d, 0xd8}, uint16(1), uint16(0), uint16(1))       // truncated
+	f.Fuzz(func(t *testing.T, data []byte, off, pos, length uint16) {
+		if r := fuzzReader(data, off, pos); r != nil {
+			checkTwoByteString(t, r, int(length))
+		}
+	})
+}
+
+func FuzzAlignTo(f *testing.F) {
+	f.Add([]byte{0, 0, 0, 0}, uint16(1), uint16(0), 2)
+	f.Add([]byte{0, 0, 0}, uint16(0), uint16(1), 8)
+	f.Add([]byte{0, 0}, uint16(0), uint16(0), 3)
+	f.Fuzz(func(t *testing.T, data []byte, off, pos uint16, boundary int) {
+		if r := fuzzReader(data, off, pos); r != nil {
+			checkAlignTo(t, r, boundary)
+		}
+	})
+}
+
+// FuzzReader runs every check above on one input, choosing the primitive
+// from the first byte and the position, sub-reader offset and argument
+// from the next six. It is the single entrypoint for OSS-Fuzz:
+//
+//	compile_native_go_fuzzer github.com/acolita/v8wire/internal/wire FuzzReader fuzz_wire_reader
+func FuzzReader(f *testing.F) {
+	f.Add([]byte{0, 0, 0, 0, 0, 0, 0, 0xac, 0x02})
+	f.Add([]byte{1, 0, 0, 0, 0, 0, 0, 0xfe, 0xff, 0xff, 0xff, 0x0f})
+	f.Add([]byte{2, 1, 0, 0, 0, 2, 0, 0x00, 'h', 0x00, 'i', 0x00})
+	f.Add([]byte{3, 1, 0, 0, 0, 4, 0, 0, 0, 0, 0})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if len(data) < 7 {
+			return
+		}
+		le := binary.LittleEndian
+		off, pos, arg := le.Uint16(data[1:]), le.Uint16(data[3:]), int(le.Uint16(data[5:]))
+		r := fuzzReader(data[7:], off, pos)
+		if r == nil {
+			return
+		}
+		switch data[0] % 4 {
+		case 0:
+			checkVarint(t, r)
+		case 1:
+			checkZigZag(t, r)
+		case 2:
+			checkTwoByteString(t, r, arg)
+		default:
+			checkAlignTo(t, r, arg)
+		}
+	})
+}
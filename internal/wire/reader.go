@@ -8,7 +8,9 @@ import (
 	"encoding/binary"
 	"errors"
 	"math"
+	"strings"
 	"unicode/utf16"
+	"unicode/utf8"
 )
 
 // Common errors returned by Reader methods.
@@ -16,6 +18,7 @@ var (
 	ErrUnexpectedEOF  = errors.New("wire: unexpected end of input")
 	ErrVarintOverflow = errors.New("wire: varint overflow")
 	ErrInvalidUTF16   = errors.New("wire: invalid UTF-16 sequence")
+	ErrReadLimit      = errors.New("wire: read limit exceeded")
 )
 
 // Reader reads V8 serialized data from a byte buffer.
@@ -23,6 +26,10 @@ var (
 type Reader struct {
 	data []byte
 	pos  int
+	base int // offset of data within the parent reader's input, for alignment
+
+	read  int // bytes consumed, including across Reset
+	limit int // cap on read; 0 means none
 }
 
 // NewReader creates a Reader from the given byte slice.
@@ -46,6 +53,45 @@ func (r *Reader) Remaining() int {
 	return len(r.data) - r.pos
 }
 
+// BytesRead returns the number of bytes consumed so far. Unlike Pos it is
+// not rewound by Reset, so it accounts for every byte actually read.
+func (r *Reader) BytesRead() int {
+	return r.read
+}
+
+// ResetBytesRead returns BytesRead and restarts the count from zero, for
+// per-call accounting. The read limit then applies to the new count.
+func (r *Reader) ResetBytesRead() int {
+	n := r.read
+	r.read = 0
+	return n
+}
+
+// SetReadLimit makes reads fail with ErrReadLimit once they would take
+// BytesRead past n, so a size cap can be enforced as data is consumed
+// rather than up front. 0 removes the limit.
+func (r *Reader) SetReadLimit(n int) {
+	r.limit = n
+}
+
+// need returns the error for consuming n more bytes, or nil if they are
+// available and within the read limit.
+func (r *Reader) need(n int) error {
+	if n < 0 || n > len(r.data)-r.pos {
+		return ErrUnexpectedEOF
+	}
+	if r.limit > 0 && r.read+n > r.limit {
+		return ErrReadLimit
+	}
+	return nil
+}
+
+// advance consumes n bytes that need has approved.
+func (r *Reader) advance(n int) {
+	r.pos += n
+	r.read += n
+}
+
 // EOF returns true if all bytes have been consumed.
 func (r *Reader) EOF() bool {
 	return r.pos >= len(r.data)
@@ -62,22 +108,22 @@ func (r *Reader) Peek() (byte, error) {
 
 // ReadByte reads a single byte and advances the position.
 func (r *Reader) ReadByte() (byte, error) {
-	if r.pos >= len(r.data) {
-		return 0, ErrUnexpectedEOF
+	if err := r.need(1); err != nil {
+		return 0, err
 	}
 	b := r.data[r.pos]
-	r.pos++
+	r.advance(1)
 	return b, nil
 }
 
 // ReadBytes reads exactly n bytes and advances the position.
 // Returns ErrUnexpectedEOF if fewer than n bytes remain.
 func (r *Reader) ReadBytes(n int) ([]byte, error) {
-	if r.pos+n > len(r.data) {
-		return nil, ErrUnexpectedEOF
+	if err := r.need(n); err != nil {
+		return nil, err
 	}
 	result := r.data[r.pos : r.pos+n]
-	r.pos += n
+	r.advance(n)
 	return result, nil
 }
 
@@ -85,16 +131,46 @@ func (r *Reader) ReadBytes(n int) ([]byte, error) {
 // V8 uses standard protobuf-style varints: 7 bits per byte,
 // high bit indicates continuation.
 func (r *Reader) ReadVarint() (uint64, error) {
+	// Fast path: with a full-length varint's worth of input available, decode
+	// from a fixed-size window without per-byte bounds or limit checks.
+	if len(r.data)-r.pos >= maxVarintLen && (r.limit == 0 || r.read+maxVarintLen <= r.limit) {
+		buf := r.data[r.pos : r.pos+maxVarintLen]
+		b := buf[0]
+		if b < 0x80 {
+			r.advance(1)
+			return uint64(b), nil
+		}
+		result := uint64(b & 0x7F)
+		for i := 1; i < maxVarintLen; i++ {
+			b = buf[i]
+			if i == maxVarintLen-1 && b > 1 {
+				return 0, ErrVarintOverflow
+			}
+			result |= uint64(b&0x7F) << (7 * i)
+			if b < 0x80 {
+				r.advance(i + 1)
+				return result, nil
+			}
+		}
+	}
+	return r.readVarintSlow()
+}
+
+// maxVarintLen is the longest encoding of a 64-bit varint.
+const maxVarintLen = 10
+
+// readVarintSlow is ReadVarint near the end of the input or read limit.
+func (r *Reader) readVarintSlow() (uint64, error) {
 	var result uint64
 	var shift uint
 
 	for {
-		if r.pos >= len(r.data) {
-			return 0, ErrUnexpectedEOF
+		if err := r.need(1); err != nil {
+			return 0, err
 		}
 
 		b := r.data[r.pos]
-		r.pos++
+		r.advance(1)
 
 		// Check for overflow before shifting
 		if shift >= 64 || (shift == 63 && b > 1) {
@@ -109,6 +185,23 @@ func (r *Reader) ReadVarint() (uint64, error) {
 	}
 }
 
+// ReadVarints reads consecutive varints into dst until it is full or the
+// input ends cleanly, returning how many were read. A varint cut off by the
+// end of input or the read limit is an error.
+func (r *Reader) ReadVarints(dst []uint64) (int, error) {
+	for i := range dst {
+		if r.EOF() {
+			return i, nil
+		}
+		v, err := r.ReadVarint()
+		if err != nil {
+			return i, err
+		}
+		dst[i] = v
+	}
+	return len(dst), nil
+}
+
 // ReadVarint32 reads a varint and returns it as uint32.
 // Returns error if the value exceeds uint32 range.
 func (r *Reader) ReadVarint32() (uint32, error) {
@@ -158,11 +251,11 @@ func (r *Reader) ReadZigZag32() (int32, error) {
 
 // ReadDouble reads an IEEE 754 double in little-endian byte order.
 func (r *Reader) ReadDouble() (float64, error) {
-	if r.pos+8 > len(r.data) {
-		return 0, ErrUnexpectedEOF
+	if err := r.need(8); err != nil {
+		return 0, err
 	}
 	bits := binary.LittleEndian.Uint64(r.data[r.pos:])
-	r.pos += 8
+	r.advance(8)
 	return math.Float64frombits(bits), nil
 }
 
@@ -173,11 +266,11 @@ func (r *Reader) AlignTo(boundary int) {
 	if boundary <= 0 || (boundary&(boundary-1)) != 0 {
 		return // invalid boundary, do nothing
 	}
-	remainder := r.pos % boundary
+	remainder := (r.base + r.pos) % boundary
 	if remainder != 0 {
 		skip := boundary - remainder
 		if r.pos+skip <= len(r.data) {
-			r.pos += skip
+			r.advance(skip)
 		}
 	}
 }
@@ -197,13 +290,26 @@ func (r *Reader) ReadOneByteString(length int) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	// Latin-1 maps directly to Unicode code points 0-255.
-	// Convert to proper UTF-8 encoding.
-	runes := make([]rune, length)
-	for i, b := range bytes {
-		runes[i] = rune(b)
+	// ASCII is already UTF-8. Otherwise Latin-1 maps directly to code
+	// points 0-255, of which 0x80-0xFF take two UTF-8 bytes.
+	high := 0
+	for _, b := range bytes {
+		if b >= utf8.RuneSelf {
+			high++
+		}
+	}
+	if high == 0 {
+		return string(bytes), nil
+	}
+	buf := make([]byte, 0, length+high)
+	for _, b := range bytes {
+		if b < utf8.RuneSelf {
+			buf = append(buf, b)
+		} else {
+			buf = append(buf, 0xC0|b>>6, 0x80|b&0x3F)
+		}
 	}
-	return string(runes), nil
+	return string(buf), nil
 }
 
 // ReadTwoByteString reads a UTF-16LE encoded string.
@@ -221,31 +327,103 @@ func (r *Reader) ReadTwoByteString(length int) (string, error) {
 	r.AlignTo(2)
 
 	byteLen := length * 2
-	if r.pos+byteLen > len(r.data) {
-		return "", ErrUnexpectedEOF
+	if err := r.need(byteLen); err != nil {
+		return "", err
 	}
 
-	// Read UTF-16LE code units
-	u16 := make([]uint16, length)
-	for i := 0; i < length; i++ {
-		u16[i] = binary.LittleEndian.Uint16(r.data[r.pos:])
-		r.pos += 2
+	// Decode UTF-16LE straight to UTF-8. Each code unit takes at most three
+	// UTF-8 bytes (a surrogate pair takes four for two units), so one Grow
+	// covers the whole string.
+	units := r.data[r.pos : r.pos+byteLen]
+	r.advance(byteLen)
+	var b strings.Builder
+	b.Grow(length * 3)
+	for i := 0; i < len(units); i += 2 {
+		u := rune(binary.LittleEndian.Uint16(units[i:]))
+		switch {
+		case u < utf8.RuneSelf:
+			b.WriteByte(byte(u))
+		case u < 0xD800 || u >= 0xE000:
+			b.WriteRune(u)
+		case u < 0xDC00 && i+3 < len(units):
+			next := rune(binary.LittleEndian.Uint16(units[i+2:]))
+			if next >= 0xDC00 && next < 0xE000 {
+				b.WriteRune(utf16.DecodeRune(u, next))
+				i += 2
+				continue
+			}
+			b.WriteRune(utf8.RuneError)
+		default: // unpaired surrogate, as utf16.Decode would report
+			b.WriteRune(utf8.RuneError)
+		}
 	}
+	return b.String(), nil
+}
 
-	// Decode UTF-16 to Go string (UTF-8)
-	runes := utf16.Decode(u16)
-	return string(runes), nil
+// HasUnpairedSurrogate reports whether the UTF-16LE data contains a
+// surrogate code unit without its partner. Such units cannot be represented
+// in UTF-8 and are replaced with U+FFFD by ReadTwoByteString.
+func HasUnpairedSurrogate(data []byte) bool {
+	n := len(data) / 2
+	for i := 0; i < n; i++ {
+		u := binary.LittleEndian.Uint16(data[i*2:])
+		switch {
+		case u >= 0xD800 && u < 0xDC00:
+			if i+1 >= n {
+				return true
+			}
+			next := binary.LittleEndian.Uint16(data[(i+1)*2:])
+			if next < 0xDC00 || next >= 0xE000 {
+				return true
+			}
+			i++ // skip the low half of the pair
+		case u >= 0xDC00 && u < 0xE000:
+			return true
+		}
+	}
+	return false
 }
 
 // Skip advances the position by n bytes without reading.
 func (r *Reader) Skip(n int) error {
-	if r.pos+n > len(r.data) {
-		return ErrUnexpectedEOF
+	if err := r.need(n); err != nil {
+		return err
 	}
-	r.pos += n
+	r.advance(n)
 	return nil
 }
 
+// Slice returns a Reader over the next n bytes and advances r past them.
+// The sub-reader cannot read beyond those n bytes, so a length-prefixed
+// section can be parsed without risk of overrunning into what follows.
+// Its positions start at 0, but alignment stays relative to the start of
+// r's input. Returns ErrUnexpectedEOF if fewer than n bytes remain, or
+// ErrReadLimit if taking them would pass r's read limit.
+func (r *Reader) Slice(n int) (*Reader, error) {
+	if err := r.need(n); err != nil {
+		return nil, err
+	}
+	sub := &Reader{data: r.data[r.pos : r.pos+n : r.pos+n], base: r.base + r.pos}
+	r.advance(n)
+	return sub, nil
+}
+
+// Fork returns an independent cursor at r's position over the same data,
+// with r's byte count and read limit, for speculative parsing: reads from
+// the fork leave r unchanged, so a failed probe is abandoned by dropping
+// the fork. To keep what a successful probe consumed, assign it back:
+//
+//	probe := r.Fork()
+//	if v, err := parseHostObject(probe); err == nil {
+//		*r = *probe
+//	}
+//
+// The data is shared, not copied, and neither reader modifies it.
+func (r *Reader) Fork() *Reader {
+	f := *r
+	return &f
+}
+
 // Reset resets the reader to the beginning of the data.
 func (r *Reader) Reset() {
 	r.pos = 0
@@ -1,12 +1,15 @@
 package wire
 
 import (
+	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"math"
 	"os"
 	"path/filepath"
 	"testing"
+	"unicode/utf16"
 )
 
 // fixtureMetadata represents the JSON metadata generated by Node.js
@@ -88,20 +91,43 @@ func TestReadVarint(t *testing.T) {
 		{"v8_version_15", []byte{0x0f}, 15, false},
 		// Multi-byte varint for larger numbers
 		{"large", []byte{0xff, 0xff, 0xff, 0xff, 0x0f}, 0xffffffff, false},
+		{"max-uint64", []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}, math.MaxUint64, false},
+		{"overflow", []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x02}, 0, true},
 	}
 
 	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			r := NewReader(tt.input)
-			got, err := r.ReadVarint()
-			if (err != nil) != tt.wantErr {
-				t.Errorf("ReadVarint() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if got != tt.expected {
-				t.Errorf("ReadVarint() = %d, expected %d", got, tt.expected)
-			}
-		})
+		// Trailing bytes put short varints on the fast path.
+		for _, pad := range []int{0, 10} {
+			t.Run(fmt.Sprintf("%s/pad%d", tt.name, pad), func(t *testing.T) {
+				r := NewReader(append(append([]byte(nil), tt.input...), make([]byte, pad)...))
+				got, err := r.ReadVarint()
+				if (err != nil) != tt.wantErr {
+					t.Errorf("ReadVarint() error = %v, wantErr %v", err, tt.wantErr)
+					return
+				}
+				if got != tt.expected {
+					t.Errorf("ReadVarint() = %d, expected %d", got, tt.expected)
+				}
+				if !tt.wantErr && r.Pos() != len(tt.input) {
+					t.Errorf("ReadVarint() consumed %d bytes, expected %d", r.Pos(), len(tt.input))
+				}
+			})
+		}
+	}
+}
+
+func TestReadVarints(t *testing.T) {
+	r := NewReader([]byte{0x01, 0xac, 0x02, 0x7f})
+	dst := make([]uint64, 2)
+	if n, err := r.ReadVarints(dst); n != 2 || err != nil || dst[0] != 1 || dst[1] != 300 {
+		t.Errorf("ReadVarints = %d, %v, %v; want 2, nil, [1 300]", n, err, dst)
+	}
+	if n, err := r.ReadVarints(dst); n != 1 || err != nil || dst[0] != 127 {
+		t.Errorf("ReadVarints at end = %d, %v, %v; want 1, nil, [127 ...]", n, err, dst)
+	}
+	r = NewReader([]byte{0x05, 0x80})
+	if n, err := r.ReadVarints(dst); n != 1 || err != ErrUnexpectedEOF {
+		t.Errorf("ReadVarints truncated = %d, %v; want 1, ErrUnexpectedEOF", n, err)
 	}
 }
 
@@ -323,6 +349,30 @@ func TestReadOneByteStringFromFixtures(t *testing.T) {
 	}
 }
 
+func TestReadOneByteStringAllLatin1(t *testing.T) {
+	data := make([]byte, 256)
+	runes := make([]rune, 256)
+	for i := range data {
+		data[i] = byte(i)
+		runes[i] = rune(i)
+	}
+	got, err := NewReader(data).ReadOneByteString(len(data))
+	if err != nil {
+		t.Fatalf("ReadOneByteString failed: %v", err)
+	}
+	if got != string(runes) {
+		t.Errorf("ReadOneByteString = %q, want %q", got, string(runes))
+	}
+
+	ascii := []byte("The quick brown fox jumps over the lazy dog")
+	allocs := testing.AllocsPerRun(100, func() {
+		NewReader(ascii).ReadOneByteString(len(ascii))
+	})
+	if allocs > 1 {
+		t.Errorf("ASCII ReadOneByteString made %v allocations, want 1", allocs)
+	}
+}
+
 func TestReadTwoByteStringFromFixtures(t *testing.T) {
 	tests := []struct {
 		fixture  string
@@ -465,6 +515,170 @@ func TestAlignment(t *testing.T) {
 	}
 }
 
+func TestSlice(t *testing.T) {
+	data := []byte{0x03, 0xAA, 0xBB, 0xCC, 0x7F}
+	r := NewReader(data)
+	r.ReadByte()
+
+	sub, err := r.Slice(3)
+	if err != nil {
+		t.Fatalf("Slice failed: %v", err)
+	}
+	if r.Pos() != 4 {
+		t.Errorf("parent pos = %d, want 4", r.Pos())
+	}
+	if sub.Len() != 3 || sub.Pos() != 0 {
+		t.Errorf("sub Len = %d, Pos = %d, want 3, 0", sub.Len(), sub.Pos())
+	}
+	if _, err := sub.ReadBytes(4); err != ErrUnexpectedEOF {
+		t.Errorf("reading past the slice: got %v, want ErrUnexpectedEOF", err)
+	}
+
+	// Alignment follows the parent's offsets: the sub-reader starts at 1.
+	sub.AlignTo(2)
+	if sub.Pos() != 1 {
+		t.Errorf("after AlignTo(2), sub pos = %d, want 1", sub.Pos())
+	}
+
+	if b, _ := r.ReadByte(); b != 0x7F {
+		t.Errorf("parent read 0x%02X after slice, want 0x7F", b)
+	}
+	if _, err := r.Slice(1); err != ErrUnexpectedEOF {
+		t.Errorf("Slice past end: got %v, want ErrUnexpectedEOF", err)
+	}
+	if _, err := r.Slice(-1); err != ErrUnexpectedEOF {
+		t.Errorf("Slice(-1): got %v, want ErrUnexpectedEOF", err)
+	}
+}
+
+func TestFork(t *testing.T) {
+	data := []byte{0x00, 0x5C, 0x01, 0x02, 0xAA, 0xBB}
+	r := NewReader(data)
+	r.ReadByte()
+	r.SetReadLimit(5)
+
+	probe := r.Fork()
+	if b, _ := probe.ReadByte(); b != 0x5C {
+		t.Fatalf("fork read 0x%02X, want 0x5C", b)
+	}
+	probe.ReadBytes(2)
+	if r.Pos() != 1 || r.BytesRead() != 1 {
+		t.Errorf("parent moved: Pos = %d, BytesRead = %d", r.Pos(), r.BytesRead())
+	}
+	if _, err := probe.ReadBytes(2); err != ErrReadLimit {
+		t.Errorf("fork past the parent's limit: got %v, want ErrReadLimit", err)
+	}
+
+	// Alignment is preserved for forks of sub-readers.
+	parent := NewReader(data)
+	parent.ReadByte()
+	sub, _ := parent.Slice(3) // starts at offset 1
+	fork := sub.Fork()
+	fork.AlignTo(2)
+	if fork.Pos() != 1 {
+		t.Errorf("fork of sub-reader: Pos after AlignTo(2) = %d, want 1", fork.Pos())
+	}
+
+	*r = *probe
+	if r.Pos() != 4 || r.BytesRead() != 4 {
+		t.Errorf("after adopting the fork: Pos = %d, BytesRead = %d, want 4, 4", r.Pos(), r.BytesRead())
+	}
+}
+
+func TestBytesReadAndLimit(t *testing.T) {
+	data := []byte{0xAC, 0x02, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	r := NewReader(data)
+	r.SetReadLimit(5)
+
+	if _, err := r.ReadVarint(); err != nil {
+		t.Fatalf("ReadVarint failed: %v", err)
+	}
+	if _, err := r.Peek(); err != nil || r.BytesRead() != 2 {
+		t.Errorf("BytesRead = %d after varint and Peek, want 2", r.BytesRead())
+	}
+	if _, err := r.ReadBytes(3); err != nil {
+		t.Fatalf("ReadBytes within limit failed: %v", err)
+	}
+	if _, err := r.ReadByte(); err != ErrReadLimit {
+		t.Errorf("read past limit: got %v, want ErrReadLimit", err)
+	}
+	if r.Pos() != 5 {
+		t.Errorf("failed read moved pos to %d", r.Pos())
+	}
+
+	if n := r.ResetBytesRead(); n != 5 || r.BytesRead() != 0 {
+		t.Errorf("ResetBytesRead = %d, then BytesRead = %d; want 5, 0", n, r.BytesRead())
+	}
+	r.Reset()
+	if _, err := r.ReadBytes(2); err != nil || r.BytesRead() != 2 {
+		t.Errorf("after Reset: err %v, BytesRead %d; want nil, 2", err, r.BytesRead())
+	}
+
+	r.SetReadLimit(0)
+	if err := r.Skip(6); err != nil {
+		t.Errorf("Skip without limit failed: %v", err)
+	}
+}
+
+func TestReadTwoByteStringMatchesUTF16Decode(t *testing.T) {
+	cases := [][]uint16{
+		{'h', 'i', 0x00E9, 0x4E2D, 0x6587},
+		{0xD83D, 0xDE00, 'x'},                 // surrogate pair
+		{'a', 0xD83D},                         // high surrogate at end
+		{0xDE00, 'b'},                         // lone low surrogate
+		{0xD83D, 'c', 0xD83D, 0xD83D, 0xDE00}, // high followed by non-low
+		{0xFFFF, 0x0080, 0x07FF, 0x0800},
+	}
+	for _, units := range cases {
+		data := make([]byte, len(units)*2)
+		for i, u := range units {
+			binary.LittleEndian.PutUint16(data[i*2:], u)
+		}
+		got, err := NewReader(data).ReadTwoByteString(len(units))
+		if err != nil {
+			t.Fatalf("ReadTwoByteString(%04X) failed: %v", units, err)
+		}
+		if want := string(utf16.Decode(units)); got != want {
+			t.Errorf("ReadTwoByteString(%04X) = %q, want %q", units, got, want)
+		}
+	}
+
+	data := make([]byte, 512)
+	for i := 0; i < len(data); i += 2 {
+		binary.LittleEndian.PutUint16(data[i:], 0x4E00+uint16(i))
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		NewReader(data).ReadTwoByteString(len(data) / 2)
+	})
+	if allocs > 1 {
+		t.Errorf("ReadTwoByteString made %v allocations, want 1", allocs)
+	}
+}
+
+func TestHasUnpairedSurrogate(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{"empty", nil, false},
+		{"bmp", []byte{0x41, 0x00, 0x60, 0x4f}, false},
+		{"pair", []byte{0x3c, 0xd8, 0x0d, 0xdf}, false},
+		{"lone-high", []byte{0x00, 0xd8}, true},
+		{"lone-low", []byte{0x00, 0xdc}, true},
+		{"high-then-bmp", []byte{0x00, 0xd8, 0x41, 0x00}, true},
+		{"reversed-pair", []byte{0x0d, 0xdf, 0x3c, 0xd8}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HasUnpairedSurrogate(tt.data); got != tt.want {
+				t.Errorf("HasUnpairedSurrogate(%x) = %v, want %v", tt.data, got, tt.want)
+			}
+		})
+	}
+}
+
 func TestExplainInt32ByteLayout(t *testing.T) {
 	// Explain the byte layout of int32 42
 	binData, _ := loadFixture(t, "int32-positive")
@@ -618,3 +832,39 @@ func TestPrimitiveTagsFromFixtures(t *testing.T) {
 		})
 	}
 }
+
+// varintStream returns n varints of mixed widths (1 to 5 bytes), like the
+// elements of an int32 array.
+func varintStream(n int) []byte {
+	w := NewWriter(n * 3)
+	for i := 0; i < n; i++ {
+		w.WriteVarint(uint64(i*i) % (1 << 32))
+	}
+	return w.Bytes()
+}
+
+func BenchmarkReadVarint(b *testing.B) {
+	data := varintStream(4096)
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := NewReader(data)
+		for !r.EOF() {
+			if _, err := r.ReadVarint(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkReadVarints(b *testing.B) {
+	data := varintStream(4096)
+	dst := make([]uint64, 4096)
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewReader(data).ReadVarints(dst); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package wire
 import (
 	"encoding/binary"
 	"math"
+	"slices"
 	"unicode/utf8"
 )
 
@@ -26,6 +27,13 @@ func (w *Writer) Len() int {
 	return len(w.buf)
 }
 
+// Grow ensures room for another n bytes without reallocating.
+func (w *Writer) Grow(n int) {
+	if n > 0 {
+		w.buf = slices.Grow(w.buf, n)
+	}
+}
+
 // Reset clears the buffer for reuse.
 func (w *Writer) Reset() {
 	w.buf = w.buf[:0]
@@ -103,27 +111,33 @@ func (w *Writer) WriteOneByteString(s string) {
 // WriteTwoByteString writes a UTF-16LE string.
 // Handles alignment by padding if necessary.
 func (w *Writer) WriteTwoByteString(s string) {
+	w.WriteTwoByteStringN(s, UTF16Length(s))
+}
+
+// WriteTwoByteStringN is WriteTwoByteString for callers that already know
+// units, the UTF-16 length of s as returned by UTF16Length. The buffer is
+// grown once and the code units are encoded directly into it.
+func (w *Writer) WriteTwoByteStringN(s string, units int) {
 	// Align to 2-byte boundary
 	if len(w.buf)%2 != 0 {
 		w.buf = append(w.buf, 0x00)
 	}
 
-	// Convert to UTF-16
+	start := len(w.buf)
+	w.buf = slices.Grow(w.buf, units*2)[:start+units*2]
+	out := w.buf[start:]
+	i := 0
 	for _, r := range s {
 		if r <= 0xFFFF {
 			// BMP character
-			var buf [2]byte
-			binary.LittleEndian.PutUint16(buf[:], uint16(r))
-			w.buf = append(w.buf, buf[:]...)
+			binary.LittleEndian.PutUint16(out[i:], uint16(r))
+			i += 2
 		} else {
 			// Surrogate pair for characters outside BMP
 			r -= 0x10000
-			high := uint16(0xD800 + (r >> 10))
-			low := uint16(0xDC00 + (r & 0x3FF))
-			var buf [4]byte
-			binary.LittleEndian.PutUint16(buf[:2], high)
-			binary.LittleEndian.PutUint16(buf[2:], low)
-			w.buf = append(w.buf, buf[:]...)
+			binary.LittleEndian.PutUint16(out[i:], uint16(0xD800+(r>>10)))
+			binary.LittleEndian.PutUint16(out[i+2:], uint16(0xDC00+(r&0x3FF)))
+			i += 4
 		}
 	}
 }
@@ -168,3 +182,38 @@ func NeedsUTF16(s string) bool {
 	}
 	return false
 }
+
+// AnalyzeString reports in a single pass over s what NeedsUTF16, UTF16Length
+// and OneByteStringLength would return, so a serializer can choose an
+// encoding and write its length without walking the string three times.
+func AnalyzeString(s string) (needsUTF16 bool, utf16Len, latin1Len int) {
+	valid := true
+	runes := 0
+	for i := 0; i < len(s); {
+		if s[i] < utf8.RuneSelf {
+			i++
+			runes++
+			utf16Len++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			valid = false
+		}
+		i += size
+		runes++
+		if r > 0xFFFF {
+			utf16Len += 2 // surrogate pair
+		} else {
+			utf16Len++
+		}
+		if r > 255 {
+			needsUTF16 = true
+		}
+	}
+	if !valid {
+		// Invalid UTF-8 is written as raw Latin-1 bytes.
+		return false, utf16Len, len(s)
+	}
+	return needsUTF16, utf16Len, runes
+}
@@ -3,6 +3,7 @@ package wire
 import (
 	"bytes"
 	"math"
+	"strings"
 	"testing"
 )
 
@@ -255,6 +256,25 @@ func TestWriteTwoByteString(t *testing.T) {
 	}
 }
 
+func TestWriteTwoByteStringN(t *testing.T) {
+	w := NewWriter(0)
+	w.WriteByte(0x63) // odd offset forces a padding byte
+	w.WriteTwoByteStringN("a你🌍", 4)
+	want := []byte{0x63, 0x00, 'a', 0x00, 0x60, 0x4F, 0x3C, 0xD8, 0x0D, 0xDF}
+	if !bytes.Equal(w.Bytes(), want) {
+		t.Errorf("got % X, want % X", w.Bytes(), want)
+	}
+
+	s := strings.Repeat("中文", 64)
+	allocs := testing.AllocsPerRun(100, func() {
+		w := NewWriter(0)
+		w.WriteTwoByteStringN(s, 128)
+	})
+	if allocs > 2 { // the Writer and one buffer growth
+		t.Errorf("WriteTwoByteStringN made %v allocations, want at most 2", allocs)
+	}
+}
+
 func TestUTF16Length(t *testing.T) {
 	tests := []struct {
 		value    string
@@ -321,6 +341,20 @@ func TestNeedsUTF16(t *testing.T) {
 	}
 }
 
+func TestAnalyzeString(t *testing.T) {
+	inputs := []string{
+		"", "hello", "café", "你好", "🌍 world", "ÿ\u0100",
+		"\xff\xfe", "abc\x80", "你\xff", // invalid UTF-8
+	}
+	for _, s := range inputs {
+		needs, units, latin1 := AnalyzeString(s)
+		if needs != NeedsUTF16(s) || units != UTF16Length(s) || latin1 != OneByteStringLength(s) {
+			t.Errorf("AnalyzeString(%q) = %v, %d, %d; want %v, %d, %d", s, needs, units, latin1,
+				NeedsUTF16(s), UTF16Length(s), OneByteStringLength(s))
+		}
+	}
+}
+
 func TestOneByteStringLength(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -0,0 +1,234 @@
+package v8serialize
+
+import (
+	"math"
+	"math/big"
+	"math/bits"
+	"math/rand"
+	"reflect"
+	"strings"
+)
+
+// Anonymize returns a copy of v with its data replaced and its structure
+// kept, so payloads can be shared in bug reports without leaking user data:
+//   - strings, including Error messages and stacks, RegExp patterns and
+//     Map keys, become placeholders of the same length and encoding (one-
+//     or two-byte, with surrogate pairs where the original had them);
+//     equal strings get equal placeholders
+//   - numbers, BigInts and Dates are perturbed at random, keeping their
+//     type, sign and magnitude: integers keep their bit length, doubles
+//     their exponent, Dates stay within 30 days; 0, ±1, NaN and infinities
+//     are kept
+//   - ArrayBuffer and view bytes are zeroed, keeping lengths and sharing
+//
+// Object property names, Error names and RegExp flags are kept, as they
+// describe structure. Shared and cyclic structure is preserved.
+func Anonymize(v Value) Value {
+	a := &anonymizer{
+		done:    make(map[uintptr]Value),
+		strings: make(map[string]string),
+		buffers: make(map[bufferKey][]byte),
+		rng:     rand.New(rand.NewSource(rand.Int63())),
+	}
+	return a.value(v)
+}
+
+type anonymizer struct {
+	done    map[uintptr]Value    // anonymized containers, by input identity
+	strings map[string]string    // placeholders by original
+	buffers map[bufferKey][]byte // zeroed copies, by original memory
+	rng     *rand.Rand
+}
+
+func (a *anonymizer) value(v Value) Value {
+	switch v.typ {
+	case TypeInt32:
+		return Int32(int32(perturbInt(a.rng, int64(v.AsInt32()), math.MaxInt32)))
+	case TypeUint32:
+		return Uint32(uint32(perturbInt(a.rng, int64(v.AsUint32()), math.MaxUint32)))
+	case TypeDouble:
+		return Double(perturbDouble(a.rng, v.AsNumber()))
+	case TypeBigInt:
+		return BigInt(perturbBigInt(a.rng, v.AsBigInt()))
+	case TypeDate:
+		if v.IsInvalidDate() {
+			return v
+		}
+		const month = 30 * 24 * 60 * 60 * 1000
+		return DateFromMillis(v.data.(float64) + float64(a.rng.Int63n(2*month+1)-month))
+	case TypeString:
+		return String(a.string(v.AsString()))
+	case TypeRegExp:
+		re := v.data.(*RegExp)
+		return Value{typ: TypeRegExp, data: &RegExp{Pattern: a.string(re.Pattern), Flags: re.Flags}}
+	case TypeArrayBuffer:
+		return Value{typ: TypeArrayBuffer, data: a.buffer(v.data.([]byte))}
+	case TypeTypedArray:
+		view := *v.data.(*ArrayBufferView)
+		view.Buffer = a.buffer(view.Buffer)
+		return Value{typ: TypeTypedArray, data: &view}
+	case TypeObject, TypeArray, TypeMap, TypeSet, TypeError, TypeBoxedPrimitive:
+	default:
+		return v
+	}
+
+	// Empty arrays have no storage of their own to identify them by (and
+	// may sit at the start of another array's), so each gets a fresh copy.
+	if v.typ == TypeArray && len(v.data.([]Value)) == 0 {
+		return Value{typ: TypeArray, data: []Value{}}
+	}
+
+	// Containers are registered before their children are visited, so
+	// back-references resolve to the copy under construction.
+	id := reflect.ValueOf(v.data).Pointer()
+	if out, ok := a.done[id]; ok && id != 0 {
+		return out
+	}
+	switch v.typ {
+	case TypeObject:
+		in := v.data.(*object)
+		out := &object{props: make(map[string]Value, len(in.props)), order: in.keys()}
+		a.done[id] = Value{typ: TypeObject, data: out}
+		for k, prop := range in.props {
+			out.props[k] = a.value(prop)
+		}
+		return a.done[id]
+
+	case TypeArray:
+		in := v.data.([]Value)
+		out := make([]Value, len(in))
+		a.done[id] = Value{typ: TypeArray, data: out}
+		for i, e := range in {
+			out[i] = a.value(e)
+		}
+		return a.done[id]
+
+	case TypeMap:
+		in := v.data.(*JSMap)
+		out := &JSMap{Entries: make([]MapEntry, len(in.Entries))}
+		a.done[id] = Value{typ: TypeMap, data: out}
+		for i, e := range in.Entries {
+			out.Entries[i] = MapEntry{Key: a.value(e.Key), Value: a.value(e.Value)}
+		}
+		return a.done[id]
+
+	case TypeSet:
+		in := v.data.(*JSSet)
+		out := &JSSet{Values: make([]Value, len(in.Values))}
+		a.done[id] = Value{typ: TypeSet, data: out}
+		for i, e := range in.Values {
+			out.Values[i] = a.value(e)
+		}
+		return a.done[id]
+
+	case TypeError:
+		in := v.data.(*JSError)
+		out := &JSError{Name: in.Name, Message: a.string(in.Message), Stack: a.string(in.Stack)}
+		a.done[id] = Value{typ: TypeError, data: out}
+		if in.Cause != nil {
+			cause := a.value(*in.Cause)
+			out.Cause = &cause
+		}
+		return a.done[id]
+
+	default: // TypeBoxedPrimitive
+		in := v.data.(*BoxedPrimitive)
+		out := &BoxedPrimitive{PrimitiveType: in.PrimitiveType, Value: a.value(in.Value)}
+		a.done[id] = Value{typ: TypeBoxedPrimitive, data: out}
+		return a.done[id]
+	}
+}
+
+// string returns the placeholder for s. Placeholders spell a counter in
+// letters of the same encoding class as each original character: ASCII
+// for one-byte characters, Cyrillic for the rest of the BMP and an emoji
+// for astral ones, so distinct strings stay distinct whenever their length
+// allows.
+func (a *anonymizer) string(s string) string {
+	if p, ok := a.strings[s]; ok {
+		return p
+	}
+	runes := []rune(s)
+	n := len(a.strings)
+	for i := len(runes) - 1; i >= 0; i-- {
+		digit := rune(n % 26)
+		n /= 26
+		switch r := runes[i]; {
+		case r <= 0xff:
+			runes[i] = 'a' + digit
+		case r <= 0xffff:
+			runes[i] = 'а' + digit // Cyrillic a
+		default:
+			runes[i] = '😀'
+		}
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range runes {
+		b.WriteRune(r)
+	}
+	a.strings[s] = b.String()
+	return a.strings[s]
+}
+
+// buffer returns a zeroed buffer of the same length as buf, the same one
+// for every value sharing buf's memory.
+func (a *anonymizer) buffer(buf []byte) []byte {
+	if len(buf) == 0 {
+		return []byte{}
+	}
+	key := bufferKey{&buf[0], len(buf)}
+	if out, ok := a.buffers[key]; ok {
+		return out
+	}
+	a.buffers[key] = make([]byte, len(buf))
+	return a.buffers[key]
+}
+
+// perturbInt returns a random integer with the sign and bit length of n
+// whose magnitude is at most limit, or n's own magnitude if that is larger.
+// Values with magnitude 0 or 1 are returned unchanged.
+func perturbInt(rng *rand.Rand, n int64, limit uint64) int64 {
+	m := uint64(n)
+	if n < 0 {
+		m = -m
+	}
+	if m <= 1 {
+		return n
+	}
+	lo := uint64(1) << (bits.Len64(m) - 1)
+	hi := min(lo<<1-1, max(limit, m))
+	r := int64(lo + uint64(rng.Int63n(int64(hi-lo+1))))
+	if n < 0 {
+		return -r
+	}
+	return r
+}
+
+// perturbDouble keeps integers integral, as perturbInt does, and gives
+// other finite values a random mantissa with the same sign and exponent.
+func perturbDouble(rng *rand.Rand, f float64) float64 {
+	switch {
+	case math.IsNaN(f) || math.IsInf(f, 0) || f == 0:
+		return f
+	case f == math.Trunc(f) && math.Abs(f) < 1<<53:
+		return float64(perturbInt(rng, int64(f), 1<<53-1))
+	}
+	const mantissa = 1<<52 - 1
+	return math.Float64frombits(math.Float64bits(f)&^mantissa | uint64(rng.Int63n(mantissa+1)))
+}
+
+// perturbBigInt returns a random BigInt with the sign and bit length of n.
+func perturbBigInt(rng *rand.Rand, n *big.Int) *big.Int {
+	m := new(big.Int).Abs(n)
+	if m.BitLen() <= 1 {
+		return n
+	}
+	lo := new(big.Int).Lsh(big.NewInt(1), uint(m.BitLen()-1))
+	r := new(big.Int).Rand(rng, lo)
+	r.Add(r, lo)
+	if n.Sign() < 0 {
+		r.Neg(r)
+	}
+	return r
+}
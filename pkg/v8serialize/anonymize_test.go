@@ -0,0 +1,131 @@
+package v8serialize
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math"
+	"math/big"
+	"math/bits"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/acolita/v8wire/internal/wire"
+)
+
+func TestAnonymizeStrings(t *testing.T) {
+	in := Array([]Value{String("Alice Smith"), String("Grüße"), String("東京 🎉"), String("Alice Smith"), String("")})
+	out := Anonymize(in).AsArray()
+	for i, orig := range in.AsArray() {
+		s, o := out[i].AsString(), orig.AsString()
+		if s == o && o != "" {
+			t.Errorf("%q was not replaced", o)
+		}
+		if len(utf16.Encode([]rune(s))) != len(utf16.Encode([]rune(o))) {
+			t.Errorf("%q -> %q changed length", o, s)
+		}
+		origTwoByte, _, _ := wire.AnalyzeString(o)
+		twoByte, _, _ := wire.AnalyzeString(s)
+		if twoByte != origTwoByte {
+			t.Errorf("%q -> %q changed encoding", o, s)
+		}
+	}
+	if out[0].AsString() != out[3].AsString() {
+		t.Error("equal strings got different placeholders")
+	}
+	if out[0].AsString() == out[1].AsString() {
+		t.Error("different strings got equal placeholders")
+	}
+}
+
+func TestAnonymizeNumbers(t *testing.T) {
+	bigN, _ := new(big.Int).SetString("-123456789012345678901234567890", 10)
+	in := []Value{
+		Int32(0), Int32(1), Int32(-1), Int32(1234), Int32(-5), Int32(math.MinInt32), Int32(math.MaxInt32),
+		Uint32(3000000000), Double(3.75), Double(-1e10), Double(math.Inf(-1)), Double(math.Copysign(0, -1)),
+		BigInt(bigN), BigInt(big.NewInt(1)),
+	}
+	for range 20 { // perturbation is random; check the invariants repeatedly
+		out := Anonymize(Array(in)).AsArray()
+		for i, orig := range in {
+			got := out[i]
+			if got.Type() != orig.Type() {
+				t.Fatalf("%v -> %v changed type", orig, got)
+			}
+			switch orig.Type() {
+			case TypeBigInt:
+				a, b := orig.AsBigInt(), got.AsBigInt()
+				if a.Sign() != b.Sign() || a.BitLen() != b.BitLen() || a.BitLen() <= 1 && a.Cmp(b) != 0 {
+					t.Errorf("%v -> %v", a, b)
+				}
+			default:
+				a, b := orig.AsNumber(), got.AsNumber()
+				if math.Signbit(a) != math.Signbit(b) || math.Abs(a) <= 1 && a != b || math.IsInf(a, 0) && a != b {
+					t.Errorf("%v -> %v", a, b)
+				}
+				if a == math.Trunc(a) && !math.IsInf(a, 0) {
+					if b != math.Trunc(b) || bits.Len64(uint64(math.Abs(a))) != bits.Len64(uint64(math.Abs(b))) {
+						t.Errorf("%v -> %v changed magnitude", a, b)
+					}
+				} else if _, ea := math.Frexp(a); !math.IsInf(a, 0) {
+					if _, eb := math.Frexp(b); ea != eb {
+						t.Errorf("%v -> %v changed exponent", a, b)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestAnonymizeEmptyArrays(t *testing.T) {
+	elems := []Value{Null()}
+	in := Array([]Value{Array(elems), Array(elems[:0]), Array(nil)})
+	want, _ := Serialize(in)
+
+	out := Anonymize(in)
+	if got, _ := Serialize(out); !bytes.Equal(got, want) {
+		t.Errorf("Serialize(Anonymize(v)) = %x, want %x", got, want)
+	}
+}
+
+func TestAnonymizeStructure(t *testing.T) {
+	// const o = {a: "secret"}; o.self = o
+	data, _ := hex.DecodeString("ff0f6f2201612206736563726574220473656c665e007b02")
+	cyclic, err := Deserialize(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := ArrayBuffer([]byte{1, 2, 3, 4, 5, 6, 7, 8})
+	u8, _ := NewView(buf, "Uint8Array", 0, 4)
+	u16, _ := NewView(buf, "Uint16Array", 4, 4)
+	date := DateFromMillis(1.7e12)
+	in := OrderedObject([]string{"cyclic", "u8", "u16", "date", "invalid"}, map[string]Value{
+		"cyclic": cyclic, "u8": u8, "u16": u16, "date": date, "invalid": DateFromMillis(math.NaN()),
+	})
+
+	out := Anonymize(in)
+	if keys := out.Keys(); strings.Join(keys, ",") != "cyclic,u8,u16,date,invalid" {
+		t.Errorf("keys = %v", keys)
+	}
+	obj := out.AsObject()
+	c := obj["cyclic"].AsObject()
+	if c["a"].AsString() == "secret" || c["self"].data != obj["cyclic"].data {
+		t.Errorf("cyclic object = %v", obj["cyclic"])
+	}
+	a, b := obj["u8"].Interface().(*ArrayBufferView), obj["u16"].Interface().(*ArrayBufferView)
+	if &a.Buffer[0] != &b.Buffer[0] || a.Buffer[0] != 0 || len(a.Buffer) != 8 || b.ByteOffset != 4 {
+		t.Errorf("views = %+v, %+v; want zeroed shared buffer", a, b)
+	}
+	if u8.Interface().(*ArrayBufferView).Buffer[0] != 1 {
+		t.Error("input buffer was modified")
+	}
+	if d := math.Abs(float64(obj["date"].AsUnixMilli() - date.AsUnixMilli())); d > 30*24*3600*1000 {
+		t.Errorf("date moved by %vms", d)
+	}
+	if !obj["invalid"].IsInvalidDate() {
+		t.Error("invalid date became valid")
+	}
+	if _, err := Serialize(Array([]Value{obj["u8"], obj["u16"], obj["date"]})); err != nil {
+		t.Errorf("Serialize failed: %v", err)
+	}
+}
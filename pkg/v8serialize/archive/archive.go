@@ -0,0 +1,276 @@
+// Package archive bundles many named serialized values into one file with
+// random access, so snapshot tools can store every IndexedDB object store
+// or every worker state of an application as a single artifact:
+//
+//	w := archive.NewWriter(f)
+//	for _, store := range stores {
+//		if err := w.Add(store.Name, store.Payload); err != nil { ... }
+//	}
+//	err := w.Close() // writes the index; does not close f
+//
+//	r, err := archive.NewReader(f, size)
+//	v, err := r.Value("users")
+//
+// The layout is a header, the payloads back to back, an index and a
+// trailer pointing at the index, so writers stream payloads without
+// seeking and readers load only the index and the payloads they ask for:
+//
+//	header   "V8AR" magic, uint32 format version (1)
+//	payloads raw V8 payloads, in the order added
+//	index    uvarint entry count, then per entry: uvarint name length,
+//	         name, uvarint offset, uvarint size, uint32 CRC-32 (IEEE)
+//	trailer  uint64 index offset, "V8AR" magic
+//
+// Fixed-width integers are little-endian.
+package archive
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"github.com/acolita/v8wire/pkg/v8serialize"
+)
+
+const (
+	magic         = "V8AR"
+	formatVersion = 1
+	headerSize    = 8
+	trailerSize   = 12
+)
+
+var (
+	// ErrFormat is returned for files that are not archives or whose index
+	// is damaged.
+	ErrFormat = errors.New("archive: invalid archive")
+	// ErrChecksum is returned when a payload does not match its checksum.
+	ErrChecksum = errors.New("archive: checksum mismatch")
+	// ErrNotFound is returned for names an archive does not contain.
+	ErrNotFound = errors.New("archive: entry not found")
+	// ErrDuplicateName is returned by Writer.Add for a name added before.
+	ErrDuplicateName = errors.New("archive: duplicate entry name")
+)
+
+// Entry describes one payload of an archive.
+type Entry struct {
+	Name   string
+	Offset int64  // position of the payload in the archive
+	Size   int64  // payload length in bytes
+	CRC32  uint32 // CRC-32 (IEEE) of the payload
+}
+
+// Writer writes an archive to an io.Writer.
+type Writer struct {
+	w       io.Writer
+	offset  int64
+	entries []Entry
+	names   map[string]bool
+	err     error
+}
+
+// NewWriter returns a Writer that writes an archive to w. The header is
+// written with the first entry, or by Close for an empty archive.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w, names: make(map[string]bool)}
+}
+
+// Add appends a serialized payload under name. The payload is written as
+// is; it is not checked to be a valid V8 payload.
+func (w *Writer) Add(name string, payload []byte) error {
+	if w.names[name] {
+		return fmt.Errorf("%w: %q", ErrDuplicateName, name)
+	}
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	if err := w.write(payload); err != nil {
+		return err
+	}
+	w.names[name] = true
+	w.entries = append(w.entries, Entry{
+		Name:   name,
+		Offset: w.offset - int64(len(payload)),
+		Size:   int64(len(payload)),
+		CRC32:  crc32.ChecksumIEEE(payload),
+	})
+	return nil
+}
+
+// AddValue serializes v and appends it under name.
+func (w *Writer) AddValue(name string, v v8serialize.Value, opts ...v8serialize.SerializerOption) error {
+	payload, err := v8serialize.Serialize(v, opts...)
+	if err != nil {
+		return err
+	}
+	return w.Add(name, payload)
+}
+
+// Close writes the index and trailer. It does not close the underlying
+// writer. The archive is incomplete, and unreadable, until Close returns
+// without error.
+func (w *Writer) Close() error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	indexOffset := w.offset
+	var index []byte
+	index = binary.AppendUvarint(index, uint64(len(w.entries)))
+	for _, e := range w.entries {
+		index = binary.AppendUvarint(index, uint64(len(e.Name)))
+		index = append(index, e.Name...)
+		index = binary.AppendUvarint(index, uint64(e.Offset))
+		index = binary.AppendUvarint(index, uint64(e.Size))
+		index = binary.LittleEndian.AppendUint32(index, e.CRC32)
+	}
+	index = binary.LittleEndian.AppendUint64(index, uint64(indexOffset))
+	index = append(index, magic...)
+	return w.write(index)
+}
+
+// writeHeader writes the header before the first entry.
+func (w *Writer) writeHeader() error {
+	if w.offset > 0 || w.err != nil {
+		return w.err
+	}
+	header := binary.LittleEndian.AppendUint32([]byte(magic), formatVersion)
+	return w.write(header)
+}
+
+// write writes p, remembering the first error so a failed archive stays
+// failed.
+func (w *Writer) write(p []byte) error {
+	if w.err != nil {
+		return w.err
+	}
+	n, err := w.w.Write(p)
+	w.offset += int64(n)
+	w.err = err
+	return err
+}
+
+// Reader reads entries of an archive on demand.
+type Reader struct {
+	r       io.ReaderAt
+	entries []Entry
+	byName  map[string]int
+}
+
+// NewReader reads the index of the archive of size bytes in r.
+func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	if size < headerSize+trailerSize {
+		return nil, fmt.Errorf("%w: %d bytes is too short", ErrFormat, size)
+	}
+	var header [headerSize]byte
+	if _, err := r.ReadAt(header[:], 0); err != nil {
+		return nil, err
+	}
+	if string(header[:4]) != magic {
+		return nil, fmt.Errorf("%w: bad magic", ErrFormat)
+	}
+	if v := binary.LittleEndian.Uint32(header[4:]); v != formatVersion {
+		return nil, fmt.Errorf("%w: unsupported format version %d", ErrFormat, v)
+	}
+
+	var trailer [trailerSize]byte
+	if _, err := r.ReadAt(trailer[:], size-trailerSize); err != nil {
+		return nil, err
+	}
+	if string(trailer[8:]) != magic {
+		return nil, fmt.Errorf("%w: bad trailer (archive not closed?)", ErrFormat)
+	}
+	indexOffset := binary.LittleEndian.Uint64(trailer[:8])
+	indexEnd := uint64(size - trailerSize)
+	if indexOffset < headerSize || indexOffset > indexEnd {
+		return nil, fmt.Errorf("%w: index offset %d out of range", ErrFormat, indexOffset)
+	}
+	index := make([]byte, indexEnd-indexOffset)
+	if _, err := r.ReadAt(index, int64(indexOffset)); err != nil {
+		return nil, err
+	}
+
+	entries, err := parseIndex(index, int64(indexOffset))
+	if err != nil {
+		return nil, err
+	}
+	ar := &Reader{r: r, entries: entries, byName: make(map[string]int, len(entries))}
+	for i, e := range entries {
+		if _, ok := ar.byName[e.Name]; ok {
+			return nil, fmt.Errorf("%w: duplicate name %q", ErrFormat, e.Name)
+		}
+		ar.byName[e.Name] = i
+	}
+	return ar, nil
+}
+
+// parseIndex decodes the index, checking that every payload lies between
+// the header and payloadEnd.
+func parseIndex(index []byte, payloadEnd int64) ([]Entry, error) {
+	buf := bytes.NewReader(index)
+	count, err := binary.ReadUvarint(buf)
+	if err != nil || count > uint64(len(index)) {
+		return nil, fmt.Errorf("%w: bad entry count", ErrFormat)
+	}
+	entries := make([]Entry, 0, count)
+	for i := uint64(0); i < count; i++ {
+		nameLen, err := binary.ReadUvarint(buf)
+		if err != nil || nameLen > uint64(buf.Len()) {
+			return nil, fmt.Errorf("%w: entry %d: bad name", ErrFormat, i)
+		}
+		name := make([]byte, nameLen)
+		_, _ = buf.Read(name)
+		offset, err1 := binary.ReadUvarint(buf)
+		size, err2 := binary.ReadUvarint(buf)
+		var sum [4]byte
+		_, err3 := io.ReadFull(buf, sum[:])
+		if err := errors.Join(err1, err2, err3); err != nil {
+			return nil, fmt.Errorf("%w: entry %d: truncated", ErrFormat, i)
+		}
+		if offset < headerSize || offset > uint64(payloadEnd) || size > uint64(payloadEnd)-offset {
+			return nil, fmt.Errorf("%w: entry %q: payload out of range", ErrFormat, name)
+		}
+		entries = append(entries, Entry{
+			Name:   string(name),
+			Offset: int64(offset),
+			Size:   int64(size),
+			CRC32:  binary.LittleEndian.Uint32(sum[:]),
+		})
+	}
+	if buf.Len() != 0 {
+		return nil, fmt.Errorf("%w: trailing data in index", ErrFormat)
+	}
+	return entries, nil
+}
+
+// Entries returns the entries in the order they were added.
+func (r *Reader) Entries() []Entry {
+	return append([]Entry(nil), r.entries...)
+}
+
+// Payload reads the payload stored under name and verifies its checksum.
+func (r *Reader) Payload(name string) ([]byte, error) {
+	i, ok := r.byName[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrNotFound, name)
+	}
+	e := r.entries[i]
+	payload := make([]byte, e.Size)
+	if _, err := r.r.ReadAt(payload, e.Offset); err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(payload) != e.CRC32 {
+		return nil, fmt.Errorf("%w: %q", ErrChecksum, name)
+	}
+	return payload, nil
+}
+
+// Value reads and decodes the payload stored under name.
+func (r *Reader) Value(name string, opts ...v8serialize.Option) (v8serialize.Value, error) {
+	payload, err := r.Payload(name)
+	if err != nil {
+		return v8serialize.Value{}, err
+	}
+	return v8serialize.Deserialize(payload, opts...)
+}
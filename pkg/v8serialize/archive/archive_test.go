@@ -0,0 +1,113 @@
+package archive
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/acolita/v8wire/pkg/v8serialize"
+)
+
+func TestRoundTrip(t *testing.T) {
+	// Node: v8.serialize({id: 1}) and v8.serialize("worker-2")
+	users, _ := hex.DecodeString("ff0f6f220269644902" + "7b01")
+	state, _ := hex.DecodeString("ff0f2208776f726b65722d32")
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if err := w.Add("users", users); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := w.Add("empty", nil); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := w.AddValue("workers/2", v8serialize.String("worker-2")); err != nil {
+		t.Fatalf("AddValue failed: %v", err)
+	}
+	if err := w.Add("users", users); !errors.Is(err, ErrDuplicateName) {
+		t.Errorf("expected ErrDuplicateName, got %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	data := buf.Bytes()
+	r, err := NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	entries := r.Entries()
+	if len(entries) != 3 || entries[0].Name != "users" || entries[1].Name != "empty" || entries[2].Name != "workers/2" {
+		t.Fatalf("entries = %+v", entries)
+	}
+	if entries[0].Offset != headerSize || entries[0].Size != int64(len(users)) {
+		t.Errorf("users entry = %+v", entries[0])
+	}
+
+	got, err := r.Payload("workers/2")
+	if err != nil || !bytes.Equal(got, state) {
+		t.Errorf("Payload = %x, %v; want %x", got, err, state)
+	}
+	if got, err := r.Payload("empty"); err != nil || len(got) != 0 {
+		t.Errorf("empty Payload = %x, %v", got, err)
+	}
+	v, err := r.Value("users")
+	if err != nil || v.Field("id").AsInt32() != 1 {
+		t.Errorf("Value = %v, %v", v.GoString(), err)
+	}
+	if _, err := r.Payload("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestEmptyArchive(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewWriter(&buf).Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	if len(r.Entries()) != 0 {
+		t.Errorf("entries = %+v", r.Entries())
+	}
+}
+
+func TestCorruption(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	_ = w.AddValue("a", v8serialize.String("hello"))
+	_ = w.Close()
+	archive := buf.Bytes()
+
+	t.Run("payload-byte", func(t *testing.T) {
+		data := bytes.Clone(archive)
+		data[headerSize+3] ^= 0xff
+		r, err := NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("NewReader failed: %v", err)
+		}
+		if _, err := r.Payload("a"); !errors.Is(err, ErrChecksum) {
+			t.Errorf("expected ErrChecksum, got %v", err)
+		}
+	})
+
+	for name, data := range map[string][]byte{
+		"not-an-archive": []byte("ff0f2205hello--------"),
+		"unclosed":       archive[:len(archive)-trailerSize],
+		"truncated":      archive[:headerSize+trailerSize-1],
+		"bad-index-offset": func() []byte {
+			data := bytes.Clone(archive)
+			data[len(data)-trailerSize] = 0xff
+			return data
+		}(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewReader(bytes.NewReader(data), int64(len(data))); !errors.Is(err, ErrFormat) {
+				t.Errorf("expected ErrFormat, got %v", err)
+			}
+		})
+	}
+}
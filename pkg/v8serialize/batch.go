@@ -0,0 +1,44 @@
+package v8serialize
+
+import (
+	"runtime"
+	"sync"
+)
+
+// DecodeBatch decodes many independent payloads concurrently, for bulk jobs
+// such as decoding stored structured-clone rows. values[i] and errs[i] hold
+// the result for payloads[i]. parallelism bounds the number of workers; 0
+// or less uses GOMAXPROCS. Each worker reuses one Deserializer across its
+// payloads.
+//
+// opts are applied to every decode and are shared by the workers, so they
+// must be safe for concurrent use: a shared PositionIndex or trace writer
+// is not.
+func DecodeBatch(payloads [][]byte, parallelism int, opts ...Option) ([]Value, []error) {
+	values := make([]Value, len(payloads))
+	errs := make([]error, len(payloads))
+	if parallelism <= 0 {
+		parallelism = runtime.GOMAXPROCS(0)
+	}
+	parallelism = min(parallelism, len(payloads))
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < parallelism; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d := NewDeserializer(nil, opts...)
+			for i := range next {
+				d.Reset(payloads[i])
+				values[i], errs[i] = d.Deserialize()
+			}
+		}()
+	}
+	for i := range payloads {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return values, errs
+}
@@ -0,0 +1,62 @@
+package v8serialize
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestDecodeBatch(t *testing.T) {
+	payloads := make([][]byte, 100)
+	for i := range payloads {
+		data, err := Serialize(Object(map[string]Value{
+			"id":   Int32(int32(i)),
+			"tags": Array([]Value{String(fmt.Sprint("t", i))}),
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		payloads[i] = data
+	}
+	payloads[42] = []byte{0xFF, 0x0F, 'o'} // truncated
+
+	for _, parallelism := range []int{0, 1, 7} {
+		values, errs := DecodeBatch(payloads, parallelism)
+		if len(values) != len(payloads) || len(errs) != len(payloads) {
+			t.Fatalf("got %d values, %d errors for %d payloads", len(values), len(errs), len(payloads))
+		}
+		for i, v := range values {
+			if i == 42 {
+				if errs[i] == nil {
+					t.Errorf("parallelism %d: expected error for truncated payload", parallelism)
+				}
+				continue
+			}
+			if errs[i] != nil {
+				t.Fatalf("parallelism %d: payload %d: %v", parallelism, i, errs[i])
+			}
+			if got := v.Field("id").AsInt32(); got != int32(i) {
+				t.Errorf("parallelism %d: payload %d decoded id %d", parallelism, i, got)
+			}
+		}
+	}
+
+	if values, errs := DecodeBatch(nil, 4); len(values) != 0 || len(errs) != 0 {
+		t.Errorf("empty batch returned %d values", len(values))
+	}
+}
+
+func TestDeserializerReset(t *testing.T) {
+	d := NewDeserializer([]byte{0xFF, 0x0F, 'o', '{', 0x00}, WithMaxDepth(1))
+	if _, err := d.Deserialize(); err != nil {
+		t.Fatalf("first decode: %v", err)
+	}
+	d.Reset([]byte{0xFF, 0x0F, 'o', '"', 0x01, 'a', 'o', '{', 0x00, '{', 0x01})
+	if _, err := d.Deserialize(); !errors.Is(err, ErrMaxDepthExceeded) {
+		t.Errorf("options lost after Reset: got %v, want ErrMaxDepthExceeded", err)
+	}
+	d.Reset([]byte{0xFF, 0x0F, '^', 0x00})
+	if _, err := d.Deserialize(); !errors.Is(err, ErrInvalidReference) {
+		t.Errorf("references leaked across Reset: got %v", err)
+	}
+}
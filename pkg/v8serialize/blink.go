@@ -0,0 +1,89 @@
+package v8serialize
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Blink extensions: helpers for payloads that originate in browsers, where
+// some platform objects arrive as plain objects of a well-known shape.
+
+// ErrInvalidImageData is returned by NewImageData for inconsistent sizes.
+var ErrInvalidImageData = errors.New("v8serialize: invalid ImageData")
+
+// ImageData is the Go form of a canvas ImageData: RGBA pixels, 4 bytes per
+// pixel, row after row.
+type ImageData struct {
+	Width, Height int
+	Stride        int    // bytes per row, Width*4
+	ColorSpace    string // "srgb" unless the payload says otherwise
+	Pixels        []byte // Height*Stride bytes, sharing the decoded buffer
+}
+
+// AsImageData recognizes an object shaped like a canvas ImageData,
+// {width, height, data} with data a Uint8ClampedArray of width*height*4
+// bytes and an optional colorSpace string. It reports false for anything
+// else.
+func (v Value) AsImageData() (ImageData, bool) {
+	if v.typ != TypeObject {
+		return ImageData{}, false
+	}
+	width, ok := imageDimension(v.Field("width"))
+	if !ok {
+		return ImageData{}, false
+	}
+	height, ok := imageDimension(v.Field("height"))
+	if !ok {
+		return ImageData{}, false
+	}
+	data := v.Field("data")
+	if data.typ != TypeTypedArray {
+		return ImageData{}, false
+	}
+	view := data.data.(*ArrayBufferView)
+	pixels := view.Slice()
+	if view.Type != "Uint8ClampedArray" || len(pixels) != width*height*4 {
+		return ImageData{}, false
+	}
+
+	img := ImageData{Width: width, Height: height, Stride: width * 4, ColorSpace: "srgb", Pixels: pixels}
+	switch cs := v.Field("colorSpace"); cs.typ {
+	case TypeString:
+		img.ColorSpace = cs.AsString()
+	case TypeUndefined:
+	default:
+		return ImageData{}, false
+	}
+	return img, true
+}
+
+// imageDimension returns n if it is a non-negative integer number.
+func imageDimension(n Value) (int, bool) {
+	if !n.IsNumber() {
+		return 0, false
+	}
+	f := n.AsNumber()
+	if f < 0 || f != float64(int(f)) {
+		return 0, false
+	}
+	return int(f), true
+}
+
+// NewImageData returns an object that AsImageData recognizes, with pixels
+// as its Uint8ClampedArray data. pixels must hold width*height*4 bytes and
+// is shared, not copied.
+func NewImageData(width, height int, pixels []byte) (Value, error) {
+	if width < 0 || height < 0 || len(pixels) != width*height*4 {
+		return Value{}, fmt.Errorf("%w: %dx%d needs %d bytes, got %d", ErrInvalidImageData, width, height, width*height*4, len(pixels))
+	}
+	data := Value{typ: TypeTypedArray, data: &ArrayBufferView{
+		Buffer:     pixels,
+		ByteLength: len(pixels),
+		Type:       "Uint8ClampedArray",
+	}}
+	return OrderedObject([]string{"width", "height", "data"}, map[string]Value{
+		"width":  Int32(int32(width)),
+		"height": Int32(int32(height)),
+		"data":   data,
+	}), nil
+}
@@ -0,0 +1,59 @@
+package v8serialize
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestAsImageData(t *testing.T) {
+	// Node: v8.serialize({width: 2, height: 1, data: new Uint8ClampedArray([1, ..., 8])})
+	data, _ := hex.DecodeString("ff0f6f220577696474684904220668656967687449022204646174615c020801020304050607087b03")
+	v, err := Deserialize(data)
+	if err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	img, ok := v.AsImageData()
+	if !ok {
+		t.Fatal("AsImageData did not recognize the payload")
+	}
+	if img.Width != 2 || img.Height != 1 || img.Stride != 8 || img.ColorSpace != "srgb" {
+		t.Errorf("got %+v", img)
+	}
+	if !bytes.Equal(img.Pixels, []byte{1, 2, 3, 4, 5, 6, 7, 8}) {
+		t.Errorf("Pixels = %v", img.Pixels)
+	}
+
+	for name, bad := range map[string]Value{
+		"not-object":   Int32(1),
+		"short-data":   Object(map[string]Value{"width": Int32(2), "height": Int32(2), "data": v.Field("data")}),
+		"wrong-kind":   Object(map[string]Value{"width": Int32(2), "height": Int32(1), "data": Value{typ: TypeTypedArray, data: &ArrayBufferView{Buffer: make([]byte, 8), Type: "Uint8Array"}}}),
+		"fractional":   Object(map[string]Value{"width": Double(0.5), "height": Int32(1), "data": v.Field("data")}),
+		"color-number": Object(map[string]Value{"width": Int32(2), "height": Int32(1), "data": v.Field("data"), "colorSpace": Int32(1)}),
+	} {
+		if _, ok := bad.AsImageData(); ok {
+			t.Errorf("%s: recognized as ImageData", name)
+		}
+	}
+}
+
+func TestNewImageData(t *testing.T) {
+	pixels := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	v, err := NewImageData(2, 1, pixels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := Serialize(v)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	// Same bytes Node produces for the equivalent object literal.
+	if got, want := bytesToHex(data), "ff0f6f220577696474684904220668656967687449022204646174615c020801020304050607087b03"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	if _, err := NewImageData(2, 2, pixels); !errors.Is(err, ErrInvalidImageData) {
+		t.Errorf("short pixels: got %v, want ErrInvalidImageData", err)
+	}
+}
@@ -0,0 +1,220 @@
+// Package buscodec encodes Values as message-bus messages (Kafka, NATS,
+// AMQP and the like) with a fixed header convention, so Node and Go
+// producers and consumers in one group agree on how payloads are labeled
+// and compressed:
+//
+//	content-type       application/x-v8-serialized
+//	v8-format-version  15
+//	content-encoding   gzip  (only when compressed)
+//
+// Message.Headers is a plain map; copy it to and from the client library's
+// header type:
+//
+//	codec := buscodec.New(buscodec.WithCompression(4096))
+//	msg, err := codec.Encode(v)
+//	for k, val := range msg.Headers {
+//		headers = append(headers, kafka.Header{Key: k, Value: []byte(val)})
+//	}
+//
+// A Node consumer reads the same messages with:
+//
+//	let body = msg.value;
+//	if (header(msg, 'content-encoding') === 'gzip') body = zlib.gunzipSync(body);
+//	const value = v8.deserialize(body);
+package buscodec
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/acolita/v8wire/internal/msgcodec"
+	"github.com/acolita/v8wire/pkg/v8serialize"
+)
+
+// ContentTypeV8Serialized is the content type of a serialized payload.
+const ContentTypeV8Serialized = "application/x-v8-serialized"
+
+// Header names set by Encode.
+const (
+	HeaderContentType     = "content-type"
+	HeaderFormatVersion   = "v8-format-version"
+	HeaderContentEncoding = "content-encoding"
+)
+
+// EncodingGzip is the content-encoding of gzip-compressed payloads.
+const EncodingGzip = "gzip"
+
+// DefaultMaxMessageSize is the size limit Decode applies unless
+// WithMaxMessageSize sets another.
+const DefaultMaxMessageSize = msgcodec.DefaultMaxSize
+
+var (
+	// ErrContentType is returned by Decode for messages labeled with
+	// another content type.
+	ErrContentType = errors.New("buscodec: not a serialized payload")
+
+	// ErrContentEncoding is returned by Decode for compression schemes
+	// other than gzip, or bodies that do not decompress.
+	ErrContentEncoding = errors.New("buscodec: unsupported content encoding")
+
+	// ErrMessageTooLarge is returned by Decode when a body exceeds the size
+	// set by WithMaxMessageSize, before or after decompression.
+	ErrMessageTooLarge = errors.New("buscodec: message too large")
+)
+
+// Message is an encoded payload with its headers.
+type Message struct {
+	Headers map[string]string
+	Body    []byte
+}
+
+// Header returns the value of the named header, matching names case
+// insensitively as some clients canonicalize them.
+func (m Message) Header(name string) (string, bool) {
+	if v, ok := m.Headers[name]; ok {
+		return v, true
+	}
+	for k, v := range m.Headers {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// Codec encodes and decodes messages. It is safe for concurrent use.
+type Codec struct {
+	codec msgcodec.Codec
+}
+
+var gzipCompression = msgcodec.Compression{
+	NewWriter: func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+	NewReader: func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+}
+
+// Option configures a Codec.
+type Option func(*Codec)
+
+// WithCompression gzip-compresses payloads of at least minSize bytes. Zero
+// or less disables compression (the default). Decode accepts compressed
+// messages regardless.
+func WithCompression(minSize int) Option {
+	return func(c *Codec) {
+		c.codec.CompressMin = minSize
+	}
+}
+
+// WithMaxMessageSize makes Decode reject bodies larger than n bytes, both
+// as received and after decompression (default DefaultMaxMessageSize).
+// Zero or less means unlimited.
+func WithMaxMessageSize(n int) Option {
+	return func(c *Codec) {
+		c.codec.MaxSize = n
+	}
+}
+
+// WithDecodeOptions sets the options each payload is decoded with.
+func WithDecodeOptions(opts ...v8serialize.Option) Option {
+	return func(c *Codec) {
+		c.codec.Decode = opts
+	}
+}
+
+// WithEncodeOptions sets the options each value is serialized with.
+func WithEncodeOptions(opts ...v8serialize.SerializerOption) Option {
+	return func(c *Codec) {
+		c.codec.Encode = opts
+	}
+}
+
+// New returns a Codec.
+func New(opts ...Option) *Codec {
+	c := &Codec{codec: msgcodec.Codec{
+		Compression: gzipCompression,
+		MaxSize:     DefaultMaxMessageSize,
+		ErrTooLarge: ErrMessageTooLarge,
+		ErrCorrupt:  ErrContentEncoding,
+	}}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Encode serializes v into a message.
+func (c *Codec) Encode(v v8serialize.Value) (Message, error) {
+	data, err := c.codec.Serialize(v)
+	if err != nil {
+		return Message{}, err
+	}
+	return c.EncodePayload(data)
+}
+
+// EncodeGo serializes a Go value, as SerializeGo does, into a message.
+func (c *Codec) EncodeGo(v interface{}) (Message, error) {
+	data, err := c.codec.SerializeGo(v)
+	if err != nil {
+		return Message{}, err
+	}
+	return c.EncodePayload(data)
+}
+
+// EncodePayload labels an already serialized payload, compressing it if
+// it is large enough.
+func (c *Codec) EncodePayload(data []byte) (Message, error) {
+	version, err := v8serialize.DetectVersion(data)
+	if err != nil {
+		return Message{}, err
+	}
+	m := Message{
+		Headers: map[string]string{
+			HeaderContentType:   ContentTypeV8Serialized,
+			HeaderFormatVersion: strconv.FormatUint(uint64(version), 10),
+		},
+		Body: data,
+	}
+	body, compressed, err := c.codec.Compress(data)
+	if err != nil {
+		return Message{}, err
+	}
+	if compressed {
+		m.Headers[HeaderContentEncoding] = EncodingGzip
+		m.Body = body
+	}
+	return m, nil
+}
+
+// Decode decodes a message's payload.
+func (c *Codec) Decode(m Message) (v8serialize.Value, error) {
+	data, err := c.DecodePayload(m)
+	if err != nil {
+		return v8serialize.Value{}, err
+	}
+	return c.codec.Deserialize(data)
+}
+
+// DecodePayload checks a message's headers and returns its payload,
+// decompressed but not decoded. Messages without a content type are
+// accepted, for producers that send bare payloads. The format version
+// header is informational, for consumers that route by version without
+// decoding; the payload's own header is authoritative.
+func (c *Codec) DecodePayload(m Message) ([]byte, error) {
+	if ct, ok := m.Header(HeaderContentType); ok && ct != ContentTypeV8Serialized {
+		return nil, fmt.Errorf("%w: content type %q", ErrContentType, ct)
+	}
+	if err := c.codec.CheckSize(len(m.Body)); err != nil {
+		return nil, err
+	}
+	switch enc, _ := m.Header(HeaderContentEncoding); enc {
+	case "", "identity":
+		return m.Body, nil
+	case EncodingGzip:
+		return c.codec.Decompress(m.Body)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrContentEncoding, enc)
+	}
+}
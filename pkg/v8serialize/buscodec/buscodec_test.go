@@ -0,0 +1,101 @@
+package buscodec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/acolita/v8wire/pkg/v8serialize"
+)
+
+func TestRoundTrip(t *testing.T) {
+	c := New(WithCompression(256))
+	tests := []struct {
+		name       string
+		value      v8serialize.Value
+		compressed bool
+	}{
+		{"small", v8serialize.Object(map[string]v8serialize.Value{"id": v8serialize.Int32(1)}), false},
+		{"large", v8serialize.String(strings.Repeat("event ", 200)), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := c.Encode(tt.value)
+			if err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+			if m.Headers[HeaderContentType] != ContentTypeV8Serialized || m.Headers[HeaderFormatVersion] != "15" {
+				t.Errorf("headers = %v", m.Headers)
+			}
+			if got := m.Headers[HeaderContentEncoding] == EncodingGzip; got != tt.compressed {
+				t.Errorf("compressed = %v, want %v", got, tt.compressed)
+			}
+
+			// Decoding does not depend on the consumer's compression setting.
+			v, err := New().Decode(m)
+			if err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+			got, _ := v8serialize.Key(v)
+			want, _ := v8serialize.Key(tt.value)
+			if got != want {
+				t.Errorf("got %v, want %v", v, tt.value)
+			}
+		})
+	}
+}
+
+func TestDecodeHeaders(t *testing.T) {
+	payload, _ := v8serialize.SerializeGo(strings.Repeat("x", 100))
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(payload)
+	zw.Close()
+
+	// Inflates to one byte over the default limit.
+	var big bytes.Buffer
+	zw, _ = gzip.NewWriterLevel(&big, gzip.BestSpeed)
+	zw.Write(make([]byte, DefaultMaxMessageSize+1))
+	zw.Close()
+	gzipped := map[string]string{"content-encoding": "gzip"}
+
+	tests := []struct {
+		name string
+		msg  Message
+		opts []Option
+		want error
+	}{
+		{"bare payload", Message{Body: payload}, nil, nil},
+		{"canonicalized names", Message{Headers: map[string]string{"Content-Type": ContentTypeV8Serialized, "Content-Encoding": "gzip"}, Body: gz.Bytes()}, nil, nil},
+		{"json", Message{Headers: map[string]string{"content-type": "application/json"}, Body: payload}, nil, ErrContentType},
+		{"brotli", Message{Headers: map[string]string{"content-encoding": "br"}, Body: payload}, nil, ErrContentEncoding},
+		{"bad gzip", Message{Headers: map[string]string{"content-encoding": "gzip"}, Body: payload}, nil, ErrContentEncoding},
+		{"too large", Message{Body: payload}, []Option{WithMaxMessageSize(50)}, ErrMessageTooLarge},
+		{"inflates too large", Message{Headers: map[string]string{"content-encoding": "gzip"}, Body: gz.Bytes()}, []Option{WithMaxMessageSize(90)}, ErrMessageTooLarge},
+		{"default limit", Message{Headers: gzipped, Body: big.Bytes()}, nil, ErrMessageTooLarge},
+		{"decode limits", Message{Body: payload}, []Option{WithDecodeOptions(v8serialize.WithMaxSize(10))}, v8serialize.ErrMaxSizeExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.opts...).Decode(tt.msg)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodePayload(t *testing.T) {
+	if _, err := New().EncodePayload([]byte("{}")); !errors.Is(err, v8serialize.ErrInvalidHeader) {
+		t.Errorf("non-payload: got %v, want ErrInvalidHeader", err)
+	}
+	m, err := New(WithEncodeOptions(v8serialize.WithSerializeVersion(13))).EncodeGo([]interface{}{1, "a"})
+	if err != nil {
+		t.Fatalf("EncodeGo failed: %v", err)
+	}
+	if m.Headers[HeaderFormatVersion] != "13" {
+		t.Errorf("version header = %q, want 13", m.Headers[HeaderFormatVersion])
+	}
+}
@@ -0,0 +1,106 @@
+package v8serialize
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+)
+
+// DecoderCache memoizes Deserialize for workloads that receive identical
+// payloads over and over, such as configuration blobs pushed to every
+// worker. Entries are keyed by the SHA-256 of the payload and evicted in
+// least-recently-used order. Failed decodes are not cached.
+//
+// Cached Values are shared between callers and must be treated as frozen:
+// do not modify the maps, slices or structs reachable from them.
+//
+// A DecoderCache is safe for concurrent use.
+type DecoderCache struct {
+	mu       sync.Mutex
+	capacity int
+	opts     []Option
+	order    *list.List // front is most recently used
+	entries  map[[sha256.Size]byte]*list.Element
+	hits     uint64
+	misses   uint64
+}
+
+type cacheEntry struct {
+	key   [sha256.Size]byte
+	value Value
+}
+
+// NewDecoderCache creates a cache holding up to capacity decoded payloads.
+// opts are applied to every decode; results for different options must not
+// share a cache.
+func NewDecoderCache(capacity int, opts ...Option) *DecoderCache {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &DecoderCache{
+		capacity: capacity,
+		opts:     opts,
+		order:    list.New(),
+		entries:  make(map[[sha256.Size]byte]*list.Element),
+	}
+}
+
+// Deserialize returns the cached Value for data, decoding and caching it on
+// a miss.
+func (c *DecoderCache) Deserialize(data []byte) (Value, error) {
+	key := sha256.Sum256(data)
+
+	c.mu.Lock()
+	if el, ok := c.entries[key]; ok {
+		c.order.MoveToFront(el)
+		c.hits++
+		v := el.Value.(*cacheEntry).value
+		c.mu.Unlock()
+		return v, nil
+	}
+	c.misses++
+	c.mu.Unlock()
+
+	// Decode outside the lock; concurrent misses for the same payload may
+	// both decode, and the later one wins.
+	v, err := Deserialize(data, c.opts...)
+	if err != nil {
+		return Value{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.order.MoveToFront(el)
+		return el.Value.(*cacheEntry).value, nil
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, value: v})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+	return v, nil
+}
+
+// Len returns the number of cached payloads.
+func (c *DecoderCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Stats returns the number of cache hits and misses so far.
+func (c *DecoderCache) Stats() (hits, misses uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
+
+// Purge removes all cached payloads.
+func (c *DecoderCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	c.entries = make(map[[sha256.Size]byte]*list.Element)
+}
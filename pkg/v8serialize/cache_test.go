@@ -0,0 +1,94 @@
+package v8serialize
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestDecoderCache(t *testing.T) {
+	c := NewDecoderCache(2)
+	a := []byte{0xff, 0x0f, 0x6f, 0x22, 0x01, 0x61, 0x49, 0x02, 0x7b, 0x01} // {a: 1}
+	b := []byte{0xff, 0x0f, 0x49, 0x54}                                     // 42
+	d := []byte{0xff, 0x0f, 0x54}                                           // true
+
+	v1, err := c.Deserialize(a)
+	if err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	v2, err := c.Deserialize(append([]byte(nil), a...))
+	if err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	// Same payload returns the very same decoded object.
+	v1.AsObject()["marker"] = Null()
+	if _, ok := v2.AsObject()["marker"]; !ok {
+		t.Error("identical payload should return the cached Value")
+	}
+	if hits, misses := c.Stats(); hits != 1 || misses != 1 {
+		t.Errorf("Stats = %d hits, %d misses, want 1, 1", hits, misses)
+	}
+
+	// b and d push a out (a was used before b).
+	for _, p := range [][]byte{b, d} {
+		if _, err := c.Deserialize(p); err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len = %d, want 2", c.Len())
+	}
+	v3, _ := c.Deserialize(a)
+	if _, ok := v3.AsObject()["marker"]; ok {
+		t.Error("evicted payload should be decoded again")
+	}
+
+	// Errors are returned and not cached.
+	bad := []byte{0xff, 0x0f, 0x49}
+	for i := 0; i < 2; i++ {
+		if _, err := c.Deserialize(bad); err == nil {
+			t.Error("expected error for truncated payload")
+		}
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len = %d after errors, want 2", c.Len())
+	}
+
+	c.Purge()
+	if c.Len() != 0 {
+		t.Errorf("Len = %d after Purge, want 0", c.Len())
+	}
+}
+
+func TestDecoderCacheConcurrent(t *testing.T) {
+	c := NewDecoderCache(4, WithMaxDepth(10))
+	payloads := [][]byte{
+		{0xff, 0x0f, 0x49, 0x02},
+		{0xff, 0x0f, 0x49, 0x04},
+		{0xff, 0x0f, 0x49, 0x06},
+		{0xff, 0x0f, 0x49, 0x08},
+		{0xff, 0x0f, 0x49, 0x0a},
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				p := payloads[(g+i)%len(payloads)]
+				v, err := c.Deserialize(p)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if want := int32(p[3] / 2); v.AsInt32() != want {
+					t.Errorf("got %d, want %d", v.AsInt32(), want)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if c.Len() > 4 {
+		t.Errorf("Len = %d, exceeds capacity", c.Len())
+	}
+}
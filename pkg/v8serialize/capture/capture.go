@@ -0,0 +1,233 @@
+// Package capture archives serialized payloads from production traffic,
+// with the outcome of decoding each one, so real inputs can become
+// regression corpora for differential tests:
+//
+//	func handler(w http.ResponseWriter, r *http.Request) {
+//		body := capture.Tee(r.Body, "/var/lib/app/corpus", capture.WithSampleRate(0.01))
+//		data, err := io.ReadAll(body)
+//		body.Close()
+//		v, err := v8serialize.Deserialize(data)
+//		...
+//	}
+//
+// Each archived payload is written as <name>.bin with a <name>.json
+// Outcome next to it.
+package capture
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/acolita/v8wire/pkg/v8serialize"
+)
+
+// DefaultMaxSize is the default size above which payloads are not
+// archived.
+const DefaultMaxSize = 1 << 20
+
+// Outcome records how an archived payload decoded.
+type Outcome struct {
+	Time    time.Time `json:"time"`
+	Size    int       `json:"size"`
+	Version uint32    `json:"version,omitempty"`
+	Type    string    `json:"type,omitempty"`  // type of the decoded root value
+	Error   string    `json:"error,omitempty"` // decode error, if any
+}
+
+type config struct {
+	maxSize int
+	rate    float64
+	decode  []v8serialize.Option
+}
+
+// Option configures Tee.
+type Option func(*config)
+
+// WithMaxSize skips payloads larger than n bytes (default DefaultMaxSize).
+// Bytes beyond n are not buffered.
+func WithMaxSize(n int) Option {
+	return func(c *config) {
+		c.maxSize = n
+	}
+}
+
+// WithSampleRate archives a random fraction p of payloads, from 0 (none)
+// to 1 (all, the default). Readers that are not sampled pass data through
+// without buffering.
+func WithSampleRate(p float64) Option {
+	return func(c *config) {
+		c.rate = p
+	}
+}
+
+// WithDecodeOptions sets the options used to decode payloads for their
+// Outcome, typically the ones the application decodes with.
+func WithDecodeOptions(opts ...v8serialize.Option) Option {
+	return func(c *config) {
+		c.decode = opts
+	}
+}
+
+// Tee returns a reader that reads from r and, on Close, archives the bytes
+// read to dir. Close also closes r if it is an io.Closer. The payload is
+// decoded again for its Outcome, independently of what the caller did with
+// it, so an archive entry is the same whichever way the application reads.
+// Errors writing the archive are returned by Close, joined with any error
+// closing r; callers that must not fail on archiving can ignore them.
+func Tee(r io.Reader, dir string, opts ...Option) io.ReadCloser {
+	c := config{maxSize: DefaultMaxSize, rate: 1}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	t := &tee{r: r, dir: dir, cfg: c}
+	t.sampled = c.rate >= 1 || c.rate > 0 && rand.Float64() < c.rate
+	return t
+}
+
+type tee struct {
+	r        io.Reader
+	dir      string
+	cfg      config
+	sampled  bool
+	overflow bool // more than maxSize bytes were read
+	buf      bytes.Buffer
+	closed   bool
+}
+
+func (t *tee) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if t.sampled && !t.overflow && n > 0 {
+		if t.buf.Len()+n > t.cfg.maxSize {
+			t.overflow = true
+			t.buf = bytes.Buffer{}
+		} else {
+			t.buf.Write(p[:n])
+		}
+	}
+	return n, err
+}
+
+func (t *tee) Close() error {
+	if t.closed {
+		return nil
+	}
+	t.closed = true
+	var errs []error
+	if c, ok := t.r.(io.Closer); ok {
+		errs = append(errs, c.Close())
+	}
+	if t.sampled && !t.overflow && t.buf.Len() > 0 {
+		errs = append(errs, archive(t.dir, t.buf.Bytes(), t.cfg.decode))
+	}
+	return errors.Join(errs...)
+}
+
+// seq distinguishes entries archived in the same nanosecond.
+var seq atomic.Uint64
+
+// archive decodes data for its outcome and writes both to dir.
+func archive(dir string, data []byte, opts []v8serialize.Option) error {
+	now := time.Now().UTC()
+	name := fmt.Sprintf("%s-%06d", now.Format("20060102T150405.000000000"), seq.Add(1)%1000000)
+
+	out := Outcome{Time: now, Size: len(data)}
+	out.Version, _ = v8serialize.DetectVersion(data)
+	if v, err := v8serialize.Deserialize(data, opts...); err != nil {
+		out.Error = err.Error()
+	} else {
+		out.Type = v.Type().String()
+	}
+	return writeEntry(dir, Entry{Name: name, Data: data, Outcome: out})
+}
+
+// writeEntry writes the payload and then the outcome of e, so a reader of
+// the directory that finds an outcome also finds its payload.
+func writeEntry(dir string, e Entry) error {
+	meta, err := json.MarshalIndent(e.Outcome, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, e.Name+".bin"), e.Data, 0o644); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, e.Name+".json"), append(meta, '\n'), 0o644)
+}
+
+// Entry is an archived payload with its outcome.
+type Entry struct {
+	Name    string // file name without extension
+	Data    []byte
+	Outcome Outcome
+}
+
+// ReadDir reads the entries archived in dir, in the order they were
+// captured. Outcomes whose payload has been removed are skipped.
+func ReadDir(dir string) ([]Entry, error) {
+	metas, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for _, meta := range metas { // Glob sorts, and names start with the time
+		name := filepath.Base(meta[:len(meta)-len(".json")])
+		raw, err := os.ReadFile(meta)
+		if err != nil {
+			return nil, err
+		}
+		e := Entry{Name: name}
+		if err := json.Unmarshal(raw, &e.Outcome); err != nil {
+			return nil, fmt.Errorf("capture: %s: %w", meta, err)
+		}
+		if e.Data, err = os.ReadFile(filepath.Join(dir, name+".bin")); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// Export writes anonymized copies of entries to dir, for sharing a corpus
+// without its user data. Each payload is decoded, passed through
+// v8serialize.Anonymize and serialized again with its original format
+// version; its outcome is kept, with the new size. Entries that cannot be
+// anonymized this way, because they fail to decode or are cyclic, are
+// skipped and counted; such payloads must be reduced by hand before they
+// are shared.
+func Export(dir string, entries []Entry, opts ...v8serialize.Option) (skipped int, err error) {
+	for _, e := range entries {
+		v, err := v8serialize.Deserialize(e.Data, opts...)
+		if err != nil {
+			skipped++
+			continue
+		}
+		var enc []v8serialize.SerializerOption
+		if e.Outcome.Version != 0 {
+			enc = append(enc, v8serialize.WithSerializeVersion(e.Outcome.Version))
+		}
+		data, err := v8serialize.Serialize(v8serialize.Anonymize(v), enc...)
+		if err != nil {
+			skipped++
+			continue
+		}
+		e.Data = data
+		e.Outcome.Size = len(data)
+		if err := writeEntry(dir, e); err != nil {
+			return skipped, err
+		}
+	}
+	return skipped, nil
+}
@@ -0,0 +1,143 @@
+package capture
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/acolita/v8wire/pkg/v8serialize"
+)
+
+type closer struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closer) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestTee(t *testing.T) {
+	dir := t.TempDir()
+	good, _ := v8serialize.SerializeGo(map[string]interface{}{"a": 1})
+	bad := []byte{0xff, 0x0f, 0x6f, 0x22}
+
+	for _, payload := range [][]byte{good, bad} {
+		src := &closer{Reader: bytes.NewReader(payload)}
+		r := Tee(src, dir)
+		got, err := io.ReadAll(r)
+		if err != nil || !bytes.Equal(got, payload) {
+			t.Fatalf("read %x, %v; want %x", got, err, payload)
+		}
+		if err := r.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+		if !src.closed {
+			t.Error("underlying reader not closed")
+		}
+	}
+
+	entries, err := ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	if e := entries[0]; !bytes.Equal(e.Data, good) || e.Outcome.Type != "object" || e.Outcome.Error != "" || e.Outcome.Version != 15 || e.Outcome.Size != len(good) {
+		t.Errorf("good entry = %+v", e.Outcome)
+	}
+	if e := entries[1]; !bytes.Equal(e.Data, bad) || e.Outcome.Type != "" || e.Outcome.Error == "" {
+		t.Errorf("bad entry = %+v", e.Outcome)
+	}
+}
+
+func TestTeeSkips(t *testing.T) {
+	payload, _ := v8serialize.SerializeGo(strings.Repeat("x", 100))
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"too large", []Option{WithMaxSize(50)}},
+		{"not sampled", []Option{WithSampleRate(0)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			r := Tee(bytes.NewReader(payload), dir, tt.opts...)
+			if got, _ := io.ReadAll(r); !bytes.Equal(got, payload) {
+				t.Fatal("payload not passed through")
+			}
+			r.Close()
+			if files, _ := os.ReadDir(dir); len(files) != 0 {
+				t.Errorf("archived %d files, want none", len(files))
+			}
+		})
+	}
+}
+
+func TestTeeDecodeOptions(t *testing.T) {
+	dir := t.TempDir()
+	payload, _ := v8serialize.SerializeGo([]interface{}{[]interface{}{1}})
+	r := Tee(bytes.NewReader(payload), dir, WithDecodeOptions(v8serialize.WithMaxDepth(1)))
+	io.ReadAll(r)
+	r.Close()
+
+	entries, err := ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("ReadDir = %d entries, %v", len(entries), err)
+	}
+	if !strings.Contains(entries[0].Outcome.Error, "depth") {
+		t.Errorf("outcome error = %q, want depth limit", entries[0].Outcome.Error)
+	}
+}
+
+func TestTeeArchiveError(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(file, nil, 0o644)
+	payload, _ := v8serialize.SerializeGo(1)
+	r := Tee(bytes.NewReader(payload), filepath.Join(file, "corpus"))
+	io.ReadAll(r)
+	if err := r.Close(); err == nil || errors.Is(err, io.EOF) {
+		t.Errorf("Close = %v, want archive error", err)
+	}
+}
+
+func TestExport(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	secret, _ := v8serialize.SerializeGo(map[string]interface{}{"email": "alice@example.com"})
+	for _, payload := range [][]byte{secret, {0xff, 0x0f, 0x6f}} {
+		r := Tee(bytes.NewReader(payload), src)
+		io.ReadAll(r)
+		r.Close()
+	}
+	entries, err := ReadDir(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	skipped, err := Export(dst, entries)
+	if err != nil || skipped != 1 {
+		t.Fatalf("Export = %d skipped, %v; want 1 skipped", skipped, err)
+	}
+	exported, err := ReadDir(dst)
+	if err != nil || len(exported) != 1 {
+		t.Fatalf("ReadDir = %d entries, %v", len(exported), err)
+	}
+	e := exported[0]
+	if e.Name != entries[0].Name || e.Outcome.Type != "object" || bytes.Contains(e.Data, []byte("alice")) {
+		t.Errorf("exported %s %+v %q", e.Name, e.Outcome, e.Data)
+	}
+	v, err := v8serialize.Deserialize(e.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if email := v.AsObject()["email"].AsString(); len(email) != len("alice@example.com") {
+		t.Errorf("email placeholder %q", email)
+	}
+}
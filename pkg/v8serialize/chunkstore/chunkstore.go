@@ -0,0 +1,327 @@
+// Package chunkstore stores serialized payloads as content-defined chunks
+// addressed by their SHA-256, so periodic state snapshots that repeat
+// large binary fields share storage for them:
+//
+//	cs := chunkstore.New(chunkstore.NewDirStore("/var/lib/app/chunks"))
+//	manifest, err := cs.Put(payload) // keep the manifest, e.g. in a database row
+//	payload, err = cs.Get(manifest)
+//
+// Chunk boundaries depend only on nearby content (a Gear rolling hash), so
+// an inserted or changed field moves the boundaries around it and leaves
+// the chunks of identical regions, such as an unchanged ArrayBuffer,
+// unchanged. The manifest is itself a V8 payload, an object
+//
+//	{version: 1, size: <payload length>, sha256: <hex>, chunks: [<hex IDs>]}
+//
+// so Node tools can read it with v8.deserialize.
+package chunkstore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/acolita/v8wire/pkg/v8serialize"
+)
+
+// Default chunk sizes.
+const (
+	DefaultMinSize = 2 << 10
+	DefaultAvgSize = 8 << 10
+	DefaultMaxSize = 64 << 10
+)
+
+const manifestVersion = 1
+
+var (
+	// ErrNotFound is returned by a Store for chunks it does not hold.
+	ErrNotFound = errors.New("chunkstore: chunk not found")
+	// ErrCorrupt is returned when a chunk or a reassembled payload does
+	// not match its hash.
+	ErrCorrupt = errors.New("chunkstore: content does not match hash")
+	// ErrManifest is returned for manifests that cannot be parsed.
+	ErrManifest = errors.New("chunkstore: invalid manifest")
+)
+
+// Store holds chunks by ID, the hex SHA-256 of their content. Put may be
+// called again for a chunk already stored.
+type Store interface {
+	Put(id string, data []byte) error
+	Get(id string) ([]byte, error) // ErrNotFound if missing
+}
+
+// ChunkStore splits payloads into chunks kept in a Store.
+type ChunkStore struct {
+	store   Store
+	minSize int
+	maxSize int
+	shift   uint // boundary when the top 64-shift bits of the hash are zero
+}
+
+// Option configures New.
+type Option func(*ChunkStore)
+
+// WithChunkSize sets the minimum, average and maximum chunk size (default
+// 2, 8 and 64 KiB). avgSize is rounded down to a power of two. Smaller
+// chunks find more duplicates at the cost of longer manifests. Payloads
+// chunked with different sizes share few chunks.
+func WithChunkSize(minSize, avgSize, maxSize int) Option {
+	return func(c *ChunkStore) {
+		c.minSize, c.maxSize = minSize, maxSize
+		c.shift = 64
+		for n := avgSize; n > 1; n >>= 1 {
+			c.shift--
+		}
+	}
+}
+
+// New returns a ChunkStore keeping chunks in store.
+func New(store Store, opts ...Option) *ChunkStore {
+	c := &ChunkStore{store: store}
+	WithChunkSize(DefaultMinSize, DefaultAvgSize, DefaultMaxSize)(c)
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// gear holds the random values the rolling hash adds per byte. They are
+// fixed, since changing them would move every chunk boundary.
+var gear = func() (t [256]uint64) {
+	state := uint64(0x76387769726521) // splitmix64
+	for i := range t {
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+		z = (z ^ z>>27) * 0x94d049bb133111eb
+		t[i] = z ^ z>>31
+	}
+	return t
+}()
+
+// Split returns the content-defined chunks of payload, which share its
+// memory.
+func (c *ChunkStore) Split(payload []byte) [][]byte {
+	var chunks [][]byte
+	for len(payload) > 0 {
+		n := c.cut(payload)
+		chunks = append(chunks, payload[:n])
+		payload = payload[n:]
+	}
+	return chunks
+}
+
+// cut returns the length of the first chunk of data.
+func (c *ChunkStore) cut(data []byte) int {
+	if len(data) <= c.minSize {
+		return len(data)
+	}
+	end := min(len(data), c.maxSize)
+	var h uint64
+	for i := c.minSize; i < end; i++ {
+		h = h<<1 + gear[data[i]]
+		if h>>c.shift == 0 {
+			return i + 1
+		}
+	}
+	return end
+}
+
+// Put stores the chunks of payload and returns the manifest for it. Chunks
+// that recur, within the payload or across payloads, are stored once.
+func (c *ChunkStore) Put(payload []byte) ([]byte, error) {
+	chunks := c.Split(payload)
+	ids := make([]v8serialize.Value, len(chunks))
+	for i, chunk := range chunks {
+		sum := sha256.Sum256(chunk)
+		id := hex.EncodeToString(sum[:])
+		if err := c.store.Put(id, chunk); err != nil {
+			return nil, err
+		}
+		ids[i] = v8serialize.String(id)
+	}
+	sum := sha256.Sum256(payload)
+	manifest := v8serialize.OrderedObject([]string{"version", "size", "sha256", "chunks"}, map[string]v8serialize.Value{
+		"version": v8serialize.Int32(manifestVersion),
+		"size":    v8serialize.Double(float64(len(payload))),
+		"sha256":  v8serialize.String(hex.EncodeToString(sum[:])),
+		"chunks":  v8serialize.Array(ids),
+	})
+	return v8serialize.Serialize(manifest)
+}
+
+// Manifest is the decoded form of a manifest.
+type Manifest struct {
+	Size   int
+	SHA256 string
+	Chunks []string
+}
+
+// ParseManifest decodes a manifest returned by Put.
+func ParseManifest(manifest []byte) (*Manifest, error) {
+	v, err := v8serialize.Deserialize(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrManifest, err)
+	}
+	if version := v.Field("version"); !version.IsNumber() || version.AsNumber() != manifestVersion {
+		return nil, fmt.Errorf("%w: unsupported version %v", ErrManifest, version)
+	}
+	size, sum, chunks := v.Field("size"), v.Field("sha256"), v.Field("chunks")
+	if !size.IsNumber() || !sum.IsString() || !chunks.IsArray() {
+		return nil, fmt.Errorf("%w: missing or mistyped fields", ErrManifest)
+	}
+	if n := size.AsNumber(); !(n >= 0 && n <= math.MaxInt) || n != math.Trunc(n) {
+		return nil, fmt.Errorf("%w: invalid size %v", ErrManifest, n)
+	}
+	m := &Manifest{Size: int(size.AsNumber()), SHA256: sum.AsString()}
+	for _, id := range chunks.AsArray() {
+		if !id.IsString() {
+			return nil, fmt.Errorf("%w: chunk ID is %s", ErrManifest, id.Type())
+		}
+		m.Chunks = append(m.Chunks, id.AsString())
+	}
+	return m, nil
+}
+
+// Get reassembles the payload described by manifest, verifying every
+// chunk and the whole payload against their hashes.
+func (c *ChunkStore) Get(manifest []byte) ([]byte, error) {
+	m, err := ParseManifest(manifest)
+	if err != nil {
+		return nil, err
+	}
+	// The manifest's size is not trusted for allocation until the chunks
+	// it names add up to it.
+	chunks := make([][]byte, len(m.Chunks))
+	total := 0
+	for i, id := range m.Chunks {
+		chunk, err := c.store.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		if sum := sha256.Sum256(chunk); hex.EncodeToString(sum[:]) != id {
+			return nil, fmt.Errorf("%w: chunk %s", ErrCorrupt, id)
+		}
+		chunks[i] = chunk
+		total += len(chunk)
+	}
+	if total != m.Size {
+		return nil, fmt.Errorf("%w: chunks hold %d bytes, manifest says %d", ErrCorrupt, total, m.Size)
+	}
+	payload := make([]byte, 0, total)
+	for _, chunk := range chunks {
+		payload = append(payload, chunk...)
+	}
+	if sum := sha256.Sum256(payload); hex.EncodeToString(sum[:]) != m.SHA256 {
+		return nil, fmt.Errorf("%w: reassembled payload", ErrCorrupt)
+	}
+	return payload, nil
+}
+
+// MemoryStore is a Store in memory, safe for concurrent use.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	chunks map[string][]byte
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{chunks: make(map[string][]byte)}
+}
+
+// Put stores a copy of data under id.
+func (s *MemoryStore) Put(id string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.chunks[id]; !ok {
+		s.chunks[id] = append([]byte(nil), data...)
+	}
+	return nil
+}
+
+// Get returns the chunk stored under id.
+func (s *MemoryStore) Get(id string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	data, ok := s.chunks[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return data, nil
+}
+
+// Len returns the number of chunks stored.
+func (s *MemoryStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.chunks)
+}
+
+// DirStore is a Store keeping each chunk in a file under a directory,
+// named by its ID and fanned out by the first two hex digits.
+type DirStore struct {
+	dir string
+}
+
+// NewDirStore returns a DirStore in dir, which is created as needed.
+func NewDirStore(dir string) *DirStore {
+	return &DirStore{dir: dir}
+}
+
+func (s *DirStore) path(id string) (string, error) {
+	if len(id) != sha256.Size*2 {
+		return "", fmt.Errorf("%w: bad chunk ID %q", ErrNotFound, id)
+	}
+	if _, err := hex.DecodeString(id); err != nil {
+		return "", fmt.Errorf("%w: bad chunk ID %q", ErrNotFound, id)
+	}
+	return filepath.Join(s.dir, id[:2], id), nil
+}
+
+// Put writes data to the file for id, unless it exists. The file appears
+// atomically, so concurrent readers never see a partial chunk.
+func (s *DirStore) Put(id string, data []byte) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), id+".tmp*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// Get reads the file for id.
+func (s *DirStore) Get(id string) ([]byte, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return data, err
+}
@@ -0,0 +1,183 @@
+package chunkstore
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/acolita/v8wire/pkg/v8serialize"
+)
+
+// snapshot serializes a state object with a small changing field and a
+// large buffer that stays the same across snapshots.
+func snapshot(t *testing.T, tick int, blob []byte) []byte {
+	t.Helper()
+	data, err := v8serialize.SerializeGo(map[string]interface{}{"tick": tick, "blob": blob})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDeduplication(t *testing.T) {
+	blob := make([]byte, 256<<10)
+	rand.New(rand.NewSource(1)).Read(blob)
+	store := NewMemoryStore()
+	cs := New(store)
+
+	first := snapshot(t, 1, blob)
+	m1, err := cs.Put(first)
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	chunks := store.Len()
+	second := snapshot(t, 1000000, blob)
+	m2, err := cs.Put(second)
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if added := store.Len() - chunks; added > 2 {
+		t.Errorf("second snapshot added %d of %d chunks", added, chunks)
+	}
+
+	for _, tc := range []struct {
+		manifest, want []byte
+	}{{m1, first}, {m2, second}} {
+		got, err := cs.Get(tc.manifest)
+		if err != nil || !bytes.Equal(got, tc.want) {
+			t.Fatalf("Get = %d bytes, %v; want %d bytes", len(got), err, len(tc.want))
+		}
+	}
+
+	m, err := ParseManifest(m1)
+	if err != nil || m.Size != len(first) || len(m.Chunks) != chunks {
+		t.Errorf("ParseManifest = %+v, %v", m, err)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	data := make([]byte, 100<<10)
+	rand.New(rand.NewSource(2)).Read(data)
+	cs := New(nil, WithChunkSize(256, 1024, 4096))
+	chunks := cs.Split(data)
+	var joined []byte
+	for i, c := range chunks {
+		if len(c) > 4096 || len(c) < 256 && i < len(chunks)-1 {
+			t.Errorf("chunk %d has %d bytes", i, len(c))
+		}
+		joined = append(joined, c...)
+	}
+	if !bytes.Equal(joined, data) {
+		t.Error("chunks do not reassemble the input")
+	}
+	if len(chunks) < 50 || len(chunks) > 200 {
+		t.Errorf("%d chunks for 100 KiB at 1 KiB average", len(chunks))
+	}
+	if cs.Split(nil) != nil {
+		t.Error("Split(nil) returned chunks")
+	}
+}
+
+func TestCorruption(t *testing.T) {
+	store := NewMemoryStore()
+	cs := New(store)
+	payload := snapshot(t, 1, bytes.Repeat([]byte("state"), 1000))
+	manifest, err := cs.Put(payload)
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	m, _ := ParseManifest(manifest)
+
+	store.chunks[m.Chunks[0]][0] ^= 0xff
+	if _, err := cs.Get(manifest); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("expected ErrCorrupt, got %v", err)
+	}
+	delete(store.chunks, m.Chunks[0])
+	if _, err := cs.Get(manifest); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := cs.Get(payload); !errors.Is(err, ErrManifest) {
+		t.Errorf("expected ErrManifest, got %v", err)
+	}
+}
+
+func TestManifestSize(t *testing.T) {
+	cs := New(NewMemoryStore())
+	manifest, err := cs.Put(snapshot(t, 1, bytes.Repeat([]byte("state"), 1000)))
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	withSize := func(size v8serialize.Value) []byte {
+		v, _ := v8serialize.Deserialize(manifest)
+		v.AsObject()["size"] = size
+		data, err := v8serialize.Serialize(v)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		return data
+	}
+
+	for _, size := range []float64{1e300, math.Inf(1), math.NaN(), -1, 1.5} {
+		if _, err := cs.Get(withSize(v8serialize.Double(size))); !errors.Is(err, ErrManifest) {
+			t.Errorf("size %v: expected ErrManifest, got %v", size, err)
+		}
+	}
+	// A huge size that fits in an int is rejected before it is allocated.
+	if _, err := cs.Get(withSize(v8serialize.Double(1 << 50))); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("expected ErrCorrupt, got %v", err)
+	}
+}
+
+func TestDirStore(t *testing.T) {
+	dir := t.TempDir()
+	cs := New(NewDirStore(dir))
+	payload := snapshot(t, 7, bytes.Repeat([]byte{1, 2, 3}, 20000))
+	manifest, err := cs.Put(payload)
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if _, err := cs.Put(payload); err != nil {
+		t.Fatalf("second Put failed: %v", err)
+	}
+	got, err := New(NewDirStore(dir)).Get(manifest)
+	if err != nil || !bytes.Equal(got, payload) {
+		t.Fatalf("Get = %d bytes, %v", len(got), err)
+	}
+
+	m, _ := ParseManifest(manifest)
+	if _, err := os.Stat(filepath.Join(dir, m.Chunks[0][:2], m.Chunks[0])); err != nil {
+		t.Errorf("chunk file missing: %v", err)
+	}
+	if _, err := NewDirStore(dir).Get("../escape"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a bad ID, got %v", err)
+	}
+}
+
+func TestManifestInNode(t *testing.T) {
+	if _, err := exec.LookPath("node"); err != nil {
+		t.Skip("Node.js not available")
+	}
+	manifest, err := New(NewMemoryStore()).Put(snapshot(t, 1, make([]byte, 100<<10)))
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	m, _ := ParseManifest(manifest)
+
+	const script = `
+const m = require('v8').deserialize(require('fs').readFileSync(0));
+if (m.version !== 1 || m.size !== +process.argv[1] || m.chunks.length !== +process.argv[2]) {
+  console.error(m);
+  process.exitCode = 1;
+}`
+	cmd := exec.Command("node", "-e", script, strconv.Itoa(m.Size), strconv.Itoa(len(m.Chunks)))
+	cmd.Stdin = bytes.NewReader(manifest)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("Node.js verification failed: %v\n%s", err, output)
+	}
+}
@@ -2,6 +2,7 @@ package v8serialize
 
 import (
 	"encoding/json"
+	"fmt"
 	"math"
 	"os"
 	"os/exec"
@@ -282,6 +283,62 @@ func TestGoToNodeRoundTrip(t *testing.T) {
 	}
 }
 
+// TestUint32ToNode checks that Node.js reads Uint32-tagged values from 2^31
+// up as numbers with the exact integer value.
+func TestUint32ToNode(t *testing.T) {
+	if _, err := exec.LookPath("node"); err != nil {
+		t.Skip("Node.js not available")
+	}
+
+	const script = `
+const v8 = require('v8');
+for (const [hex, want] of JSON.parse(process.argv[1])) {
+  const v = v8.deserialize(Buffer.from(hex, 'hex'));
+  if (typeof v !== 'number' || !Number.isInteger(v) || v !== want) {
+    console.error(hex + ': got ' + typeof v + ' ' + v + ', want ' + want);
+    process.exitCode = 1;
+  }
+}`
+	var cases [][2]interface{}
+	for _, n := range []uint32{1 << 31, 1<<31 + 1, 3_000_000_000, math.MaxUint32 - 1, math.MaxUint32} {
+		data, err := SerializeGo(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data[2] != tagUint32 {
+			t.Fatalf("%d: expected the Uint32 tag, got %s", n, TagName(data[2]))
+		}
+		cases = append(cases, [2]interface{}{fmt.Sprintf("%x", data), n})
+	}
+	arg, _ := json.Marshal(cases)
+	if output, err := exec.Command("node", "-e", script, string(arg)).CombinedOutput(); err != nil {
+		t.Errorf("Node.js verification failed: %v\n%s", err, output)
+	}
+}
+
+// TestSkippedPropertiesToNode checks that V8, which rejects objects whose
+// end tag declares the wrong property count, reads objects with skipped
+// properties.
+func TestSkippedPropertiesToNode(t *testing.T) {
+	if _, err := exec.LookPath("node"); err != nil {
+		t.Skip("Node.js not available")
+	}
+
+	data, err := SerializeGo(map[string]interface{}{
+		"fn":    func() {},
+		"outer": map[string]interface{}{"ch": make(chan int), "inner": map[string]interface{}{"fn": func() {}, "x": 1}},
+	}, WithUnsupportedValuePolicy(UnsupportedSkip), WithSortedKeys())
+	if err != nil {
+		t.Fatal(err)
+	}
+	const script = `
+const v = require('v8').deserialize(Buffer.from(process.argv[1], 'hex'));
+if (JSON.stringify(v) !== '{"outer":{"inner":{"x":1}}}') throw new Error(JSON.stringify(v));`
+	if output, err := exec.Command("node", "-e", script, fmt.Sprintf("%x", data)).CombinedOutput(); err != nil {
+		t.Errorf("Node.js verification failed: %v\n%s", err, output)
+	}
+}
+
 // TestGoToNodeRoundTripWithDocker tests Go→Node deserialization using Docker
 // containers for specific Node.js versions. Requires Docker.
 func TestGoToNodeRoundTripWithDocker(t *testing.T) {
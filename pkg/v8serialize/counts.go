@@ -0,0 +1,67 @@
+package v8serialize
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CountMismatch records a container whose end tag declares a different
+// count than the decoder actually read. V8 checks these counts and rejects
+// such a payload (Node's v8.deserialize throws "Unable to deserialize
+// cloned data"); this package decodes it anyway and records the mismatch,
+// so output from a buggy producer can still be inspected.
+type CountMismatch struct {
+	Path     string // location of the container, as in PositionIndex
+	Tag      string // container kind, e.g. "BeginJSObject"
+	Field    string // "properties", "length" or "entries"
+	Declared uint32 // count written after the end tag
+	Actual   uint32 // count the decoder read
+}
+
+func (m CountMismatch) String() string {
+	path := m.Path
+	if path == "" {
+		path = "<root>"
+	}
+	return fmt.Sprintf("%s at %s: %s declared %d, read %d", m.Tag, path, m.Field, m.Declared, m.Actual)
+}
+
+// CountMismatches returns the end-tag count mismatches seen so far. Decoding
+// never fails because of them.
+func (d *Deserializer) CountMismatches() []CountMismatch {
+	return append([]CountMismatch(nil), d.mismatches...)
+}
+
+// ValidateCounts reports the end-tag count mismatches seen so far as an
+// error wrapping ErrMalformedData, or nil if every count matched. Call it
+// after decoding to reject payloads that V8 would reject.
+func (d *Deserializer) ValidateCounts() error {
+	if len(d.mismatches) == 0 {
+		return nil
+	}
+	msgs := make([]string, len(d.mismatches))
+	for i, m := range d.mismatches {
+		msgs[i] = m.String()
+	}
+	return fmt.Errorf("%w: end-tag counts do not match: %s", ErrMalformedData, strings.Join(msgs, "; "))
+}
+
+// checkCount records a mismatch between a count read after the end tag of
+// f, the innermost frame, and what was actually decoded.
+func (d *Deserializer) checkCount(f *decodeFrame, field string, declared uint32, actual int) {
+	if declared == uint32(actual) {
+		return
+	}
+	var b strings.Builder
+	for i := 0; i < len(d.stack)-1; i++ {
+		d.childSegment(&b, &d.stack[i])
+	}
+	d.mismatches = append(d.mismatches, CountMismatch{
+		Path:     b.String(),
+		Tag:      TagName(f.tag),
+		Field:    field,
+		Declared: declared,
+		Actual:   uint32(actual),
+	})
+	d.logAnomaly("end-tag count mismatch", "tag", TagName(f.tag), "field", field, "declared", declared, "actual", actual)
+}
@@ -0,0 +1,69 @@
+package v8serialize
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateCounts(t *testing.T) {
+	// Node's v8.deserialize rejects every payload here but "consistent".
+	tests := []struct {
+		name string
+		data []byte
+		want []CountMismatch
+	}{
+		{"object", []byte{0xFF, 0x0F, 'o', '"', 0x01, 'a', 'I', 0x02, '{', 0x02},
+			[]CountMismatch{{Tag: "BeginJSObject", Field: "properties", Declared: 2, Actual: 1}}},
+		{"nested-array-length", []byte{0xFF, 0x0F, 'o', '"', 0x01, 'a', 'A', 0x01, 'I', 0x02, '$', 0x00, 0x05, '{', 0x01},
+			[]CountMismatch{{Path: "a", Tag: "BeginDenseArray", Field: "length", Declared: 5, Actual: 1}}},
+		{"set", []byte{0xFF, 0x0F, '\'', 'I', 0x02, ',', 0x03},
+			[]CountMismatch{{Tag: "BeginSet", Field: "entries", Declared: 3, Actual: 1}}},
+		{"consistent", []byte{0xFF, 0x0F, ';', 'I', 0x02, 'I', 0x04, ':', 0x02}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDeserializer(tt.data)
+			if _, err := d.Deserialize(); err != nil {
+				t.Fatalf("Deserialize failed: %v", err)
+			}
+			got := d.CountMismatches()
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("mismatch %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+			err := d.ValidateCounts()
+			if (err != nil) != (len(tt.want) > 0) {
+				t.Errorf("ValidateCounts() = %v", err)
+			}
+			if err != nil && !errors.Is(err, ErrMalformedData) {
+				t.Errorf("expected ErrMalformedData, got %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateCountsFixtures(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "..", "testdata", "fixtures", "*.bin"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no fixtures found: %v", err)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := NewDeserializer(data)
+		if _, err := d.Deserialize(); err != nil {
+			continue // unsupported fixtures are covered elsewhere
+		}
+		if err := d.ValidateCounts(); err != nil {
+			t.Errorf("%s: %v", filepath.Base(file), err)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package v8serialize
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// ErrNotTabular is returned by ExportCSV for values that are not arrays of
+// objects.
+var ErrNotTabular = errors.New("v8serialize: value is not an array of objects")
+
+// ColumnOrder controls the order of the columns ExportCSV derives from the
+// objects it writes.
+type ColumnOrder uint8
+
+const (
+	// ColumnsFirstSeen orders columns as their property names first appear
+	// across the elements, in JavaScript property order (default).
+	ColumnsFirstSeen ColumnOrder = iota
+	// ColumnsSorted orders columns lexicographically.
+	ColumnsSorted
+)
+
+// CSVOption configures ExportCSV.
+type CSVOption func(*csvExporter)
+
+// WithColumns fixes the columns ExportCSV writes, in order. Properties not
+// listed are dropped and listed properties an object lacks are undefined.
+func WithColumns(columns ...string) CSVOption {
+	return func(e *csvExporter) {
+		e.columns = columns
+	}
+}
+
+// WithColumnOrder sets the order of derived columns. It has no effect
+// with WithColumns.
+func WithColumnOrder(o ColumnOrder) CSVOption {
+	return func(e *csvExporter) {
+		e.order = o
+	}
+}
+
+// WithNullText sets the cell text for null (default empty), for example
+// "NULL" for database loaders.
+func WithNullText(text string) CSVOption {
+	return func(e *csvExporter) {
+		e.null = text
+	}
+}
+
+// WithUndefinedText sets the cell text for undefined and for properties an
+// object lacks (default empty).
+func WithUndefinedText(text string) CSVOption {
+	return func(e *csvExporter) {
+		e.undefined = text
+	}
+}
+
+// WithCSVComma sets the field delimiter (default ',').
+func WithCSVComma(comma rune) CSVOption {
+	return func(e *csvExporter) {
+		e.comma = comma
+	}
+}
+
+// csvExporter holds the settings of a single ExportCSV call.
+type csvExporter struct {
+	columns   []string
+	order     ColumnOrder
+	comma     rune
+	null      string
+	undefined string
+}
+
+// ExportCSV writes v, an array of objects such as a telemetry dump, as CSV
+// for spreadsheets and analytics tools: a header row of property names,
+// then one row per element. Unless WithColumns is given, the columns are
+// every property name across the elements, ordered per WithColumnOrder.
+// Cells hold:
+//   - null → WithNullText; undefined and missing properties →
+//     WithUndefinedText (both empty by default)
+//   - strings, numbers, BigInts, booleans, Dates and RegExps → their String
+//     form
+//   - objects, arrays, Maps, Sets, Errors and binary data → their ToJSON
+//     encoding, with non-finite numbers as strings
+//
+// Holes and elements that are not objects yield ErrNotTabular.
+func ExportCSV(v Value, w io.Writer, opts ...CSVOption) error {
+	e := &csvExporter{comma: ','}
+	for _, opt := range opts {
+		opt(e)
+	}
+	if v.Type() != TypeArray {
+		return fmt.Errorf("%w: root value is %s", ErrNotTabular, v.Type())
+	}
+	rows := v.AsArray()
+	for i, row := range rows {
+		if row.Type() != TypeObject {
+			return fmt.Errorf("%w: element %d is %s", ErrNotTabular, i, row.Type())
+		}
+	}
+
+	columns := e.columns
+	if columns == nil {
+		seen := make(map[string]bool)
+		for _, row := range rows {
+			for _, k := range row.Keys() {
+				if !seen[k] {
+					seen[k] = true
+					columns = append(columns, k)
+				}
+			}
+		}
+		if e.order == ColumnsSorted {
+			sort.Strings(columns)
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Comma = e.comma
+	if err := cw.Write(columns); err != nil {
+		return err
+	}
+	record := make([]string, len(columns))
+	for i, row := range rows {
+		props := row.AsObject()
+		for j, col := range columns {
+			cell, err := e.cell(props[col])
+			if err != nil {
+				return fmt.Errorf("v8serialize: element %d, column %q: %w", i, col, err)
+			}
+			record[j] = cell
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// cell formats a property value as a CSV cell.
+func (e *csvExporter) cell(v Value) (string, error) {
+	switch v.Type() {
+	case TypeNull:
+		return e.null, nil
+	case TypeUndefined, TypeHole:
+		return e.undefined, nil
+	case TypeBool, TypeInt32, TypeUint32, TypeDouble, TypeBigInt, TypeString, TypeDate, TypeRegExp:
+		return v.String(), nil
+	case TypeBoxedPrimitive:
+		return e.cell(v.Interface().(*BoxedPrimitive).Value)
+	}
+	data, err := ToJSON(v, WithNonFinite(NonFiniteString))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
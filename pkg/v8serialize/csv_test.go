@@ -0,0 +1,69 @@
+package v8serialize
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestExportCSV(t *testing.T) {
+	// Node: v8.serialize([{id: 1, name: "a,b", tags: ["x"]},
+	//   {id: 2.5, ok: true, name: null}, {id: 10n, at: new Date(0)}])
+	data, _ := hex.DecodeString("ff0f41036f220269644e000000000000f03f22046e616d652203612c6222047461677341012201782400017b036f220269644e000000000000044022026f6b5422046e616d65307b036f220269645a100a00000000000000220261744400000000000000007b02240003")
+	v, err := Deserialize(data)
+	if err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+
+	t.Run("default-columns", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := ExportCSV(v, &buf); err != nil {
+			t.Fatalf("ExportCSV failed: %v", err)
+		}
+		want := "id,name,tags,ok,at\n" +
+			"1,\"a,b\",\"[\"\"x\"\"]\",,\n" +
+			"2.5,,,true,\n" +
+			"10,,,,1970-01-01T00:00:00.000Z\n"
+		if got := buf.String(); got != want {
+			t.Errorf("got\n%s\nwant\n%s", got, want)
+		}
+	})
+
+	t.Run("columns-and-comma", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := ExportCSV(v, &buf, WithColumns("ok", "id", "missing"), WithCSVComma('\t')); err != nil {
+			t.Fatalf("ExportCSV failed: %v", err)
+		}
+		want := "ok\tid\tmissing\n\t1\t\ntrue\t2.5\t\n\t10\t\n"
+		if got := buf.String(); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("sorted-with-null-policy", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := ExportCSV(v, &buf, WithColumnOrder(ColumnsSorted), WithNullText("NULL"), WithUndefinedText("-"))
+		if err != nil {
+			t.Fatalf("ExportCSV failed: %v", err)
+		}
+		want := "at,id,name,ok,tags\n" +
+			"-,1,\"a,b\",-,\"[\"\"x\"\"]\"\n" +
+			"-,2.5,NULL,true,-\n" +
+			"1970-01-01T00:00:00.000Z,10,-,-,-\n"
+		if got := buf.String(); got != want {
+			t.Errorf("got\n%s\nwant\n%s", got, want)
+		}
+	})
+
+	t.Run("not-tabular", func(t *testing.T) {
+		for _, v := range []Value{
+			Object(map[string]Value{"a": Int32(1)}),
+			Array([]Value{Object(nil), Int32(1)}),
+		} {
+			if err := ExportCSV(v, &bytes.Buffer{}); !errors.Is(err, ErrNotTabular) {
+				t.Errorf("%s: expected ErrNotTabular, got %v", v.GoString(), err)
+			}
+		}
+	})
+}
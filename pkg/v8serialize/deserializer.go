@@ -1,8 +1,11 @@
 package v8serialize
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"math/big"
 	"time"
 
@@ -18,17 +21,64 @@ var (
 	ErrMaxDepthExceeded   = errors.New("v8serialize: max depth exceeded")
 	ErrMaxSizeExceeded    = errors.New("v8serialize: max size exceeded")
 	ErrInvalidReference   = errors.New("v8serialize: invalid object reference")
+	ErrLimitExceeded      = errors.New("v8serialize: limit exceeded")
+	ErrDisabledType       = errors.New("v8serialize: disabled type")
 )
 
+// FutureFeatureError reports a tag from a format feature that this package
+// recognizes but does not decode yet, in a payload whose version has that
+// feature. It means a newer release of this package is needed, as opposed
+// to an unknown tag, which points at corrupted data. With
+// WithAcceptFutureVersions, unknown tags in payloads newer than MaxVersion
+// are reported the same way, with the payload's version. It matches
+// ErrUnexpectedTag with errors.Is.
+type FutureFeatureError struct {
+	Tag          byte
+	IntroducedIn uint32 // format version that introduced the tag
+	Offset       int    // position of the tag in the payload
+}
+
+func (e *FutureFeatureError) Error() string {
+	return fmt.Sprintf("v8serialize: %s tag 0x%02X at position %d is a format version %d feature not supported by this package; a newer release is needed",
+		TagName(e.Tag), e.Tag, e.Offset, e.IntroducedIn)
+}
+
+func (e *FutureFeatureError) Unwrap() error {
+	return ErrUnexpectedTag
+}
+
 // Deserializer deserializes V8 Structured Clone format data.
 type Deserializer struct {
-	reader        *wire.Reader
-	version       uint32
-	maxDepth      int
-	maxSize       int
-	maxArrayLen   int
-	maxObjectKeys int
-	depth         int
+	reader     *wire.Reader
+	version    uint32
+	limits     Limits
+	depth      int
+	values     int
+	refs       int // back-references read, for MaxReferenceUses
+	headerRead bool
+	stack      []decodeFrame
+
+	profile         Profile
+	setDuplicates   DuplicatePolicy
+	logger          *slog.Logger
+	trace           io.Writer
+	positions       *PositionIndex
+	metrics         MetricsSink
+	tagCounts       map[byte]int
+	mismatches      []CountMismatch
+	acceptFuture    bool
+	warnings        []string
+	collectionCap   int
+	disabledTypes   uint64 // bit t set when Type t is rejected
+	keyFilter       func(path, key string) bool
+	objectHook      func(path string, obj Value) (Value, error)
+	allocBuffer     func(n int) []byte
+	detachedBuffers bool
+	sharedBuffers   bool
+	progress        func(Progress)
+	reported        int // input position of the last progress report
+	ctx             context.Context
+	hostObjects     func(h *HostObjectReader) (Value, error)
 
 	// Object reference table for circular references
 	objects []Value
@@ -42,47 +92,262 @@ const DefaultMaxArrayLen = 10_000_000
 // This prevents memory exhaustion from malicious input.
 const DefaultMaxObjectKeys = 1_000_000
 
+// DefaultMaxDepth is the default maximum nesting depth.
+const DefaultMaxDepth = 1000
+
+// Unlimited disables a limit. It is the only way to disable MaxDepth and
+// MaxArrayLen, for which 0 is a limit like any other.
+const Unlimited = -1
+
+// Limits bounds the resources a single decode may use. A field set to
+// Unlimited disables that limit, and so does 0, except for MaxDepth (0
+// rejects every value) and MaxArrayLen (0 rejects every non-empty array).
+// Start from DefaultLimits and tighten fields as needed, so one vetted
+// policy can be shared by every call site:
+//
+//	var policy = func() v8serialize.Limits {
+//	    l := v8serialize.DefaultLimits()
+//	    l.MaxSize = 1 << 20
+//	    return l
+//	}()
+//
+//	v, err := v8serialize.Deserialize(data, v8serialize.WithLimits(policy))
+type Limits struct {
+	MaxDepth       int // nesting depth
+	MaxSize        int // payload size in bytes, counted as it is read
+	MaxArrayLen    int // declared length of a single array
+	MaxObjectKeys  int // properties of a single object
+	MaxStringLen   int // encoded length of a single string in bytes
+	MaxTotalValues int // values decoded from the whole input
+
+	// MaxReferenceUses bounds the back-references in the whole input. A
+	// payload can encode a large array once and refer to it thousands of
+	// times for a few bytes each; the decoded tree shares it, but code
+	// that walks the tree without tracking identity (JSON encoding, naive
+	// copies) expands every use.
+	MaxReferenceUses int
+}
+
+// DefaultLimits returns the limits a Deserializer uses when no options are
+// given: depth 1000, 10 million array elements and 1 million object keys.
+// Size, string length, total value count and reference uses are
+// unlimited.
+func DefaultLimits() Limits {
+	return Limits{
+		MaxDepth:      DefaultMaxDepth,
+		MaxArrayLen:   DefaultMaxArrayLen,
+		MaxObjectKeys: DefaultMaxObjectKeys,
+	}
+}
+
 // Option configures the deserializer.
 type Option func(*Deserializer)
 
-// WithMaxDepth sets the maximum nesting depth (default 1000).
+// DuplicatePolicy controls how Deserialize treats Sets that contain
+// SameValueZero-equal values, which V8 never produces but hostile payloads
+// can encode.
+type DuplicatePolicy uint8
+
+const (
+	// DuplicatesKeep keeps every value as encoded (default).
+	DuplicatesKeep DuplicatePolicy = iota
+	// DuplicatesDrop keeps the first occurrence and drops later duplicates.
+	DuplicatesDrop
+	// DuplicatesError fails with ErrMalformedData.
+	DuplicatesError
+)
+
+// WithSetDuplicates sets the policy for duplicate Set values. Values are
+// compared with SameValueZero.
+func WithSetDuplicates(p DuplicatePolicy) Option {
+	return func(d *Deserializer) {
+		d.setDuplicates = p
+	}
+}
+
+// WithDisabledTypes rejects payloads containing values of the given types
+// with ErrDisabledType, so ingestion endpoints can enforce policies such as
+// "numbers, strings, objects and arrays only" at the format level. The
+// check happens when a value's tag is read, before any of its contents.
+// Note that V8 writes integers outside the int32 range, and all
+// non-integers, as doubles, and that a natively encoded view is also an
+// ArrayBuffer; TypedArrays and DataViews are told apart by TypeTypedArray
+// and TypeDataView.
+func WithDisabledTypes(types ...Type) Option {
+	return func(d *Deserializer) {
+		for _, t := range types {
+			d.disabledTypes |= 1 << t
+		}
+	}
+}
+
+// checkType fails with ErrDisabledType if t is disabled. pos is the
+// position of the value's tag.
+func (d *Deserializer) checkType(t Type, pos int) error {
+	if d.disabledTypes&(1<<t) != 0 {
+		return fmt.Errorf("%w: %s at position %d", ErrDisabledType, t, pos)
+	}
+	return nil
+}
+
+// WithKeyFilter keeps only the object properties for which keep returns
+// true and silently drops the rest, so callers that need a handful of
+// fields from large untrusted payloads do not hold on to the others. path
+// is the path of the object, as in PositionIndex ("" for the root), and
+// key the property name. Dropped values are still decoded, to stay in step
+// with the input and resolve later references to them, and objects among
+// them stay in the reference table until decoding ends; limits apply to
+// them as usual. Only plain object properties are filtered, not array
+// elements, Map entries or Error fields.
+func WithKeyFilter(keep func(path, key string) bool) Option {
+	return func(d *Deserializer) {
+		d.keyFilter = keep
+	}
+}
+
+// WithObjectHook calls hook for each plain object once all its properties
+// are decoded, with its path as in PositionIndex, and decodes hook's result
+// in its place, so payload shapes can be adapted while decoding (see
+// package transform). Later back-references resolve to the result; ones
+// from inside the object, in cyclic payloads, still see the original.
+// Objects that are not values in their own right, such as non-index
+// properties of arrays, are not passed to hook. An error from hook stops
+// decoding.
+func WithObjectHook(hook func(path string, obj Value) (Value, error)) Option {
+	return func(d *Deserializer) {
+		d.objectHook = hook
+	}
+}
+
+// WithCollectionSizeHint pre-sizes the entry storage of every Map and Set
+// for n members. V8 writes a collection's size after its entries, so
+// decoding otherwise grows the slices as entries arrive; producers that
+// know their collections hold millions of entries can avoid the repeated
+// copying. The reservation is capped by what the remaining input could
+// encode, so a large hint cannot be used to exhaust memory.
+func WithCollectionSizeHint(n int) Option {
+	return func(d *Deserializer) {
+		d.collectionCap = n
+	}
+}
+
+// WithBufferAllocator routes the backing memory of ArrayBuffers and
+// TypedArrays through alloc, so applications can place large buffers in
+// pooled or off-heap memory, for example to hand them to an embedded V8
+// isolate through cgo. alloc is called with the byte length of each buffer
+// and must return a slice of that length; returning nil or a shorter slice
+// falls back to the Go heap, so an allocator can handle only the sizes it
+// cares about. The decoded bytes are copied into the returned slice, which
+// the caller owns from then on.
+func WithBufferAllocator(alloc func(n int) []byte) Option {
+	return func(d *Deserializer) {
+		d.allocBuffer = alloc
+	}
+}
+
+// WithLimits replaces all limits at once. Options that set a single limit,
+// such as WithMaxDepth, override the corresponding field when given after
+// WithLimits.
+func WithLimits(l Limits) Option {
+	return func(d *Deserializer) {
+		d.limits = l
+	}
+}
+
+// WithMaxDepth sets the maximum nesting depth (default 1000). Pass
+// Unlimited to disable the limit; 0 rejects every value.
 func WithMaxDepth(depth int) Option {
 	return func(d *Deserializer) {
-		d.maxDepth = depth
+		d.limits.MaxDepth = depth
 	}
 }
 
-// WithMaxSize sets the maximum input size in bytes (default unlimited).
-// Use this to prevent denial-of-service attacks from large inputs.
+// WithMaxSize sets the maximum payload size in bytes (default unlimited).
+// Use this to prevent denial-of-service attacks from large inputs. The
+// limit is enforced as bytes are read, counting from the version header,
+// so a Tokenizer fails on reaching it and each payload embedded after its
+// own header (see ReadHeader) is limited separately.
 func WithMaxSize(size int) Option {
 	return func(d *Deserializer) {
-		d.maxSize = size
+		d.limits.MaxSize = size
 	}
 }
 
-// WithMaxArrayLen sets the maximum array length (default 10 million).
+// WithMaxArrayLen sets the maximum array length (default 10 million). Pass
+// Unlimited to disable the limit; 0 rejects every non-empty array.
 func WithMaxArrayLen(length int) Option {
 	return func(d *Deserializer) {
-		d.maxArrayLen = length
+		d.limits.MaxArrayLen = length
 	}
 }
 
 // WithMaxObjectKeys sets the maximum number of object keys (default 1 million).
 func WithMaxObjectKeys(keys int) Option {
 	return func(d *Deserializer) {
-		d.maxObjectKeys = keys
+		d.limits.MaxObjectKeys = keys
+	}
+}
+
+// WithMaxReferenceUses sets the maximum number of back-references in the
+// input (default unlimited); see Limits.MaxReferenceUses.
+func WithMaxReferenceUses(n int) Option {
+	return func(d *Deserializer) {
+		d.limits.MaxReferenceUses = n
+	}
+}
+
+// WithLogger sets a logger that records non-fatal decode anomalies at debug
+// level: ignored array properties, dropped unknown RegExp flags, Error fields
+// that were skipped, and strings that had to be normalized during decoding.
+// Nothing is logged when no logger is set (the default).
+func WithLogger(logger *slog.Logger) Option {
+	return func(d *Deserializer) {
+		d.logger = logger
+	}
+}
+
+// WithTrace writes one line to w for every tag read, with its offset,
+// nesting depth and decoded value, plus a line when each composite value
+// completes and one for the error that stops a failed decode:
+//
+//	@2 depth=1 BeginJSObject
+//	@3 depth=2 OneByteString "a"
+//	@6 depth=2 Int32 1
+//	@8 depth=1 end BeginJSObject Object{1 properties}
+//
+// It is meant for pinpointing where a malformed payload diverges; write
+// errors on w are ignored.
+func WithTrace(w io.Writer) Option {
+	return func(d *Deserializer) {
+		d.trace = w
+	}
+}
+
+// WithAcceptFutureVersions decodes payloads whose version is newer than
+// MaxVersion instead of rejecting them with ErrUnsupportedVersion.
+// Newer versions mostly add tags rather than change existing ones, so such
+// payloads usually decode fine; tags this package does not know fail with
+// a FutureFeatureError. Each accepted newer version is noted in Warnings.
+func WithAcceptFutureVersions() Option {
+	return func(d *Deserializer) {
+		d.acceptFuture = true
+	}
+}
+
+// WithMetrics reports per-call decode statistics and limit violations to m.
+func WithMetrics(m MetricsSink) Option {
+	return func(d *Deserializer) {
+		d.metrics = m
 	}
 }
 
 // NewDeserializer creates a new deserializer for the given data.
 func NewDeserializer(data []byte, opts ...Option) *Deserializer {
 	d := &Deserializer{
-		reader:        wire.NewReader(data),
-		maxDepth:      1000,
-		maxSize:       0, // 0 means unlimited
-		maxArrayLen:   DefaultMaxArrayLen,
-		maxObjectKeys: DefaultMaxObjectKeys,
-		objects:       make([]Value, 0, 16),
+		reader:  wire.NewReader(data),
+		limits:  DefaultLimits(),
+		profile: ProfileNode,
+		objects: make([]Value, 0, 16),
 	}
 	for _, opt := range opts {
 		opt(d)
@@ -90,6 +355,23 @@ func NewDeserializer(data []byte, opts ...Option) *Deserializer {
 	return d
 }
 
+// Reset prepares d to decode data, keeping its options and reusing its
+// internal buffers, so one Deserializer can decode many payloads in turn.
+func (d *Deserializer) Reset(data []byte) {
+	d.reader = wire.NewReader(data)
+	d.version = 0
+	d.depth = 0
+	d.values = 0
+	d.refs = 0
+	d.headerRead = false
+	d.stack = d.stack[:0]
+	d.mismatches = nil
+	d.warnings = nil
+	d.reported = 0
+	clear(d.objects) // drop references to the previous payload's values
+	d.objects = d.objects[:0]
+}
+
 // Deserialize deserializes the data and returns the root value.
 func Deserialize(data []byte, opts ...Option) (Value, error) {
 	d := NewDeserializer(data, opts...)
@@ -98,15 +380,147 @@ func Deserialize(data []byte, opts ...Option) (Value, error) {
 
 // Deserialize reads the header and deserializes the root value.
 func (d *Deserializer) Deserialize() (Value, error) {
-	// Check max size limit
-	if d.maxSize > 0 && d.reader.Len() > d.maxSize {
-		return Value{}, fmt.Errorf("%w: input size %d exceeds limit %d", ErrMaxSizeExceeded, d.reader.Len(), d.maxSize)
-	}
+	if d.metrics == nil {
+		return d.deserialize()
+	}
+
+	start := time.Now()
+	d.tagCounts = make(map[byte]int)
+	v, err := d.deserialize()
+	d.metrics.ObserveDecode(Stats{
+		Bytes:    d.reader.Len(),
+		Duration: time.Since(start),
+		Tags:     d.tagCounts,
+		Err:      err,
+	})
+	return v, err
+}
 
+func (d *Deserializer) deserialize() (Value, error) {
+	return d.ReadValue()
+}
+
+// ReadHeader reads and validates a version header at the current position.
+// Deserialize and ReadValue call it implicitly when no header has been read
+// yet; call it directly when a payload is embedded in custom framing and the
+// header must be consumed at a specific point. Each header starts a new
+// payload, so references never resolve to objects from an earlier one.
+func (d *Deserializer) ReadHeader() error {
+	// The size limit counts this payload's bytes as they are read.
+	d.reader.ResetBytesRead()
+	d.reader.SetReadLimit(d.limits.MaxSize)
 	if err := d.readHeader(); err != nil {
+		return d.sizeError(err)
+	}
+	d.headerRead = true
+	clear(d.objects)
+	d.objects = d.objects[:0]
+	return nil
+}
+
+// ReadValue reads the next root value. The header is read on first use, so
+// ReadValue can be called repeatedly to consume payloads that carry several
+// values after a single header, as produced by Serializer.WriteValue or
+// Node's v8.Serializer. Later values may reference objects from earlier ones.
+func (d *Deserializer) ReadValue() (Value, error) {
+	if err := d.begin(); err != nil {
 		return Value{}, err
 	}
-	return d.readValue()
+	v, err := d.readValue()
+	if err != nil {
+		return Value{}, d.sizeError(err)
+	}
+	d.finishProgress()
+	return v, nil
+}
+
+// More reports whether unread data remains in the input.
+func (d *Deserializer) More() bool {
+	return !d.reader.EOF()
+}
+
+// begin reads the header unless one has already been read.
+func (d *Deserializer) begin() error {
+	if d.headerRead {
+		return nil
+	}
+	return d.ReadHeader()
+}
+
+// sizeError reports a read stopped by the max size limit, which the
+// reader enforces as bytes are consumed, as ErrMaxSizeExceeded.
+func (d *Deserializer) sizeError(err error) error {
+	if !errors.Is(err, wire.ErrReadLimit) {
+		return err
+	}
+	d.limitExceeded("max_size")
+	return fmt.Errorf("%w: payload exceeds limit %d at position %d", ErrMaxSizeExceeded, d.limits.MaxSize, d.reader.Pos())
+}
+
+// checkArrayLen enforces the max array length limit.
+func (d *Deserializer) checkArrayLen(length uint32) error {
+	if exceedsStrict(int(length), d.limits.MaxArrayLen) {
+		d.limitExceeded("max_array_len")
+		return fmt.Errorf("%w: %w: array length %d exceeds limit %d", ErrMalformedData, ErrLimitExceeded, length, d.limits.MaxArrayLen)
+	}
+	return nil
+}
+
+// checkObjectKeys enforces the max object keys limit.
+func (d *Deserializer) checkObjectKeys(keys int) error {
+	if exceeds(keys, d.limits.MaxObjectKeys) {
+		d.limitExceeded("max_object_keys")
+		return fmt.Errorf("%w: object has more than %d keys", ErrLimitExceeded, d.limits.MaxObjectKeys)
+	}
+	return nil
+}
+
+// checkStringLen enforces the max string length limit.
+func (d *Deserializer) checkStringLen(byteLength uint32) error {
+	if exceeds(int(byteLength), d.limits.MaxStringLen) {
+		d.limitExceeded("max_string_len")
+		return fmt.Errorf("%w: string length %d exceeds limit %d", ErrLimitExceeded, byteLength, d.limits.MaxStringLen)
+	}
+	return nil
+}
+
+// countValue counts one decoded value against the max total values limit.
+func (d *Deserializer) countValue() error {
+	d.values++
+	if exceeds(d.values, d.limits.MaxTotalValues) {
+		d.limitExceeded("max_total_values")
+		return fmt.Errorf("%w: more than %d values", ErrLimitExceeded, d.limits.MaxTotalValues)
+	}
+	return d.checkProgress()
+}
+
+// countReference counts one back-reference against the max reference uses
+// limit.
+func (d *Deserializer) countReference() error {
+	d.refs++
+	if exceeds(d.refs, d.limits.MaxReferenceUses) {
+		d.limitExceeded("max_reference_uses")
+		return fmt.Errorf("%w: more than %d object references", ErrLimitExceeded, d.limits.MaxReferenceUses)
+	}
+	return nil
+}
+
+// exceeds reports whether n is over limit; a zero or negative limit is
+// unlimited.
+func exceeds(n, limit int) bool {
+	return limit > 0 && n > limit
+}
+
+// exceedsStrict is exceeds for MaxDepth and MaxArrayLen, where 0 is a real
+// limit and only a negative one, such as Unlimited, is unlimited.
+func exceedsStrict(n, limit int) bool {
+	return limit >= 0 && n > limit
+}
+
+// Warnings returns the notes recorded while decoding in permissive modes,
+// such as a format version accepted by WithAcceptFutureVersions.
+func (d *Deserializer) Warnings() []string {
+	return append([]string(nil), d.warnings...)
 }
 
 // Version returns the serialization format version (valid after Deserialize).
@@ -116,44 +530,225 @@ func (d *Deserializer) Version() uint32 {
 
 // readHeader reads and validates the version header.
 func (d *Deserializer) readHeader() error {
-	// Read version tag
-	tag, err := d.reader.ReadByte()
+	version, err := readVersionHeader(d.reader)
 	if err != nil {
-		return fmt.Errorf("%w: %v", ErrInvalidHeader, err)
+		return err
 	}
 
-	if tag != tagVersion {
-		return fmt.Errorf("%w: expected version tag 0xFF, got 0x%02X", ErrInvalidHeader, tag)
+	future := version > MaxVersion && d.acceptFuture
+	if version < MinVersion || version > MaxVersion && !future {
+		return fmt.Errorf("%w: version %d (supported: %d-%d)", ErrUnsupportedVersion, version, MinVersion, MaxVersion)
+	}
+	if future {
+		d.warnings = append(d.warnings, fmt.Sprintf("format version %d is newer than the supported %d; decoded best-effort", version, MaxVersion))
+		d.logAnomaly("accepted newer format version", "version", version)
 	}
 
-	// Read version number
-	version, err := d.reader.ReadVarint32()
+	d.version = version
+	d.tracef(0, 0, "Version %d", version)
+	return nil
+}
+
+// tracef writes a WithTrace line for the tag at offset pos.
+func (d *Deserializer) tracef(pos, depth int, format string, args ...any) {
+	if d.trace == nil {
+		return
+	}
+	fmt.Fprintf(d.trace, "@%d depth=%d %s\n", pos, depth, fmt.Sprintf(format, args...))
+}
+
+// limitExceeded reports a limit violation to the metrics sink, if any.
+func (d *Deserializer) limitExceeded(limit string) {
+	if d.metrics != nil {
+		d.metrics.LimitExceeded(limit)
+	}
+}
+
+// logAnomaly records a non-fatal anomaly if a logger is configured.
+func (d *Deserializer) logAnomaly(msg string, args ...any) {
+	if d.logger == nil {
+		return
+	}
+	d.logger.Debug("v8serialize: "+msg, append(args, "pos", d.reader.Pos())...)
+}
+
+// readVersionHeader reads the version tag and version number without
+// checking whether the version is supported.
+func readVersionHeader(r *wire.Reader) (uint32, error) {
+	tag, err := r.ReadByte()
 	if err != nil {
-		return fmt.Errorf("%w: %v", ErrInvalidHeader, err)
+		return 0, fmt.Errorf("%w: %w", ErrInvalidHeader, err)
 	}
 
-	if version < MinVersion || version > MaxVersion {
-		return fmt.Errorf("%w: version %d (supported: %d-%d)", ErrUnsupportedVersion, version, MinVersion, MaxVersion)
+	if tag != tagVersion {
+		return 0, fmt.Errorf("%w: expected version tag 0xFF, got 0x%02X", ErrInvalidHeader, tag)
 	}
 
-	d.version = version
-	return nil
+	version, err := r.ReadVarint32()
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrInvalidHeader, err)
+	}
+	return version, nil
+}
+
+// decodeFrame is a composite value whose children are still being read.
+// readValue keeps frames on an explicit stack instead of recursing, so the
+// nesting depth of a payload is bounded by Limits.MaxDepth rather than by
+// the Go stack.
+type decodeFrame struct {
+	tag    byte   // tag that opened the value
+	value  Value  // the value under construction
+	index  int    // position in the reference table
+	length uint32 // declared array length
+	count  int    // dense elements, object keys or children read so far
+	extra  int    // array properties (or sparse entries) read
+	hasKey bool   // a key (or Error sub-tag) was read and its value is next
+	drop   bool   // the pending property is dropped by the key filter
+	key    Value  // pending key, or the child of a RegExp or boxed primitive
+	name   string // pending object property name
+	subTag byte   // pending Error sub-tag
+	lead   bool   // the message of a generic Error is next (no sub-tag)
+	start  int    // offset of the opening tag (only tracked for WithPositionIndex)
+
+	elems   []Value
+	seen    map[svzKey]struct{} // Set members, when checking duplicates
+	entries []MapEntry
+	jsErr   *JSError
 }
 
 // readValue reads a single value from the stream.
 func (d *Deserializer) readValue() (Value, error) {
+	base, depth := len(d.stack), d.depth
+
+	v, done, err := d.beginValue()
+	for err == nil {
+		if !done {
+			// The innermost frame either needs its next child or is complete.
+			v, done, err = d.stepFrame()
+			continue
+		}
+		if len(d.stack) == base {
+			return v, nil
+		}
+		err = d.deliver(&d.stack[len(d.stack)-1], v)
+		done = false
+	}
+
+	d.tracef(d.reader.Pos(), d.depth, "error: %v", err)
+	d.stack = d.stack[:base]
+	d.depth = depth
+	return Value{}, err
+}
+
+// stepFrame advances the innermost frame. It returns the frame's value with
+// done set once the frame is complete, and otherwise begins the next child.
+func (d *Deserializer) stepFrame() (Value, bool, error) {
+	f := &d.stack[len(d.stack)-1]
+	pos := d.reader.Pos()
+	complete, err := d.advance(f)
+	if err != nil {
+		return Value{}, false, err
+	}
+	if !complete {
+		return d.beginValue()
+	}
+
+	v, start := f.value, f.start
+	d.tracef(pos, d.depth, "end %s %s", TagName(f.tag), dumpValue(v))
+	if f.tag == tagBeginJSObject && d.objectHook != nil {
+		if v, err = d.runObjectHook(v, f.index); err != nil {
+			return Value{}, false, err
+		}
+	}
+	d.stack = d.stack[:len(d.stack)-1]
+	if d.positions != nil {
+		d.recordPosition(start)
+	}
+	d.depth--
+	return v, true, nil
+}
+
+// runObjectHook passes the completed object on top of the stack, stored
+// at index in the reference table, through the object hook.
+func (d *Deserializer) runObjectHook(obj Value, index int) (Value, error) {
+	path, ok := d.childPath(d.stack[:len(d.stack)-1])
+	if !ok {
+		return obj, nil
+	}
+	v, err := d.objectHook(path, obj)
+	if err != nil {
+		return Value{}, fmt.Errorf("object hook at %q: %w", path, err)
+	}
+	d.objects[index] = v
+	return v, nil
+}
+
+// advance reads the framing between children of f (end tags, Error
+// sub-tags, RegExp flags) and reports whether f is complete.
+func (d *Deserializer) advance(f *decodeFrame) (bool, error) {
+	switch f.tag {
+	case tagBeginJSObject:
+		return d.advanceObject(f)
+	case tagBeginDenseArray:
+		return d.advanceDenseArray(f)
+	case tagBeginSparseArray:
+		return d.advanceSparseArray(f)
+	case tagBeginMap:
+		return d.advanceMap(f)
+	case tagBeginSet:
+		return d.advanceSet(f)
+	case tagRegExp:
+		return d.advanceRegExp(f)
+	case tagStringObject, tagBigIntObject:
+		return d.advanceBoxed(f)
+	default: // tagError
+		return d.advanceError(f)
+	}
+}
+
+// deliver stores a completed child value in f.
+func (d *Deserializer) deliver(f *decodeFrame, v Value) error {
+	switch f.tag {
+	case tagBeginJSObject:
+		return d.deliverObject(f, v)
+	case tagBeginDenseArray:
+		d.deliverDenseArray(f, v)
+	case tagBeginSparseArray:
+		d.deliverSparseArray(f, v)
+	case tagBeginMap:
+		d.deliverMap(f, v)
+	case tagBeginSet:
+		return d.deliverSet(f, v)
+	case tagRegExp:
+		return d.deliverRegExp(f, v)
+	case tagStringObject, tagBigIntObject:
+		return d.deliverBoxed(f, v)
+	default: // tagError
+		d.deliverError(f, v)
+	}
+	return nil
+}
+
+// push opens a frame for a composite value.
+func (d *Deserializer) push(f decodeFrame) {
+	d.stack = append(d.stack, f)
+}
+
+// beginValue reads the tag of the next value. Scalars are read completely
+// and returned with done set; composite values push a frame instead.
+func (d *Deserializer) beginValue() (v Value, done bool, err error) {
 	// Check depth limit
 	d.depth++
-	if d.depth > d.maxDepth {
-		return Value{}, ErrMaxDepthExceeded
+	if exceedsStrict(d.depth, d.limits.MaxDepth) {
+		d.limitExceeded("max_depth")
+		return Value{}, false, ErrMaxDepthExceeded
 	}
-	defer func() { d.depth-- }()
 
 	// Skip any padding bytes
 	for {
 		tag, err := d.reader.Peek()
 		if err != nil {
-			return Value{}, fmt.Errorf("%w: %v", ErrMalformedData, err)
+			return Value{}, false, fmt.Errorf("%w: %w", ErrMalformedData, err)
 		}
 		if tag != tagPadding {
 			break
@@ -161,92 +756,140 @@ func (d *Deserializer) readValue() (Value, error) {
 		_, _ = d.reader.ReadByte() // consume padding (already peeked)
 	}
 
+	pos := d.reader.Pos()
 	tag, err := d.reader.ReadByte()
 	if err != nil {
-		return Value{}, fmt.Errorf("%w: %v", ErrMalformedData, err)
+		return Value{}, false, fmt.Errorf("%w: %w", ErrMalformedData, err)
+	}
+	if d.tagCounts != nil {
+		d.tagCounts[tag]++
+	}
+	if d.trace != nil || d.positions != nil {
+		defer func() {
+			switch {
+			case err != nil:
+			case done:
+				d.tracef(pos, d.depth+1, "%s %s", TagName(tag), dumpValue(v))
+				if d.positions != nil {
+					d.recordPosition(pos)
+				}
+			default:
+				d.tracef(pos, d.depth, "%s", TagName(tag))
+				d.stack[len(d.stack)-1].start = pos
+			}
+		}()
+	}
+	if err := d.countValue(); err != nil {
+		return Value{}, false, err
+	}
+	if t, ok := tagTypes[tag]; ok && d.disabledTypes != 0 {
+		if err := d.checkType(t, pos); err != nil {
+			return Value{}, false, err
+		}
 	}
 
 	switch tag {
 	// Primitives (no additional data)
 	case tagNull:
-		return Null(), nil
+		v = Null()
 	case tagUndefined:
-		return Undefined(), nil
+		v = Undefined()
 	case tagTrue:
-		return Bool(true), nil
+		v = Bool(true)
 	case tagFalse:
-		return Bool(false), nil
+		v = Bool(false)
 	case tagHole:
-		return Hole(), nil
+		v = Hole()
 
 	// Numbers
 	case tagInt32:
-		return d.readInt32()
+		v, err = d.readInt32()
 	case tagUint32:
-		return d.readUint32()
+		v, err = d.readUint32()
 	case tagDouble:
-		return d.readDouble()
+		v, err = d.readDouble()
 	case tagBigInt:
-		return d.readBigInt()
+		v, err = d.readBigInt()
 
 	// Strings
 	case tagOneByteString:
-		return d.readOneByteString()
+		v, err = d.readOneByteString()
 	case tagTwoByteString:
-		return d.readTwoByteString()
+		v, err = d.readTwoByteString()
 
 	// Date
 	case tagDate:
-		return d.readDate()
+		v, err = d.readDate()
 
 	// Objects and arrays
 	case tagBeginJSObject:
-		return d.readObject()
+		return Value{}, false, d.beginObject()
 	case tagBeginDenseArray:
-		return d.readDenseArray()
+		return Value{}, false, d.beginDenseArray()
 	case tagBeginSparseArray:
-		return d.readSparseArray()
+		return Value{}, false, d.beginSparseArray()
 
 	// References
 	case tagObjectReference:
-		return d.readObjectReference()
+		v, err = d.readObjectReference()
 
 	// Collections
 	case tagBeginMap:
-		return d.readMap()
+		return Value{}, false, d.beginMap()
 	case tagBeginSet:
-		return d.readSet()
+		return Value{}, false, d.beginSet()
 
 	// Binary data
 	case tagArrayBuffer:
-		return d.readArrayBuffer()
+		v, err = d.readArrayBuffer()
+	case tagArrayBufferTransfer:
+		if !d.detachedBuffers {
+			return Value{}, false, fmt.Errorf("%w: transferred ArrayBuffer at position %d (see WithDetachedArrayBuffers)",
+				ErrUnexpectedTag, d.reader.Pos()-1)
+		}
+		v, err = d.readTransferredArrayBuffer()
+	case tagSharedArrayBuffer:
+		if !d.sharedBuffers {
+			return Value{}, false, fmt.Errorf("%w: SharedArrayBuffer at position %d (see WithSharedArrayBuffers)",
+				ErrUnexpectedTag, d.reader.Pos()-1)
+		}
+		v, err = d.readSharedArrayBuffer()
 
 	// TypedArrays
-	case tagTypedArray:
-		return d.readTypedArray()
+	case tagHostObject:
+		v, err = d.readHostObject()
 
 	// Special objects
-	case tagRegExp:
-		return d.readRegExp()
+	case tagRegExp, tagStringObject, tagBigIntObject:
+		d.push(decodeFrame{tag: tag})
+		return Value{}, false, nil
 	case tagNumberObject:
-		return d.readNumberObject()
+		v, err = d.readNumberObject()
 	case tagTrueObject:
-		return d.readTrueObject()
+		v, err = d.readTrueObject()
 	case tagFalseObject:
-		return d.readFalseObject()
-	case tagStringObject:
-		return d.readStringObject()
-	case tagBigIntObject:
-		return d.readBigIntObject()
+		v, err = d.readFalseObject()
 
 	// Error objects
 	case tagError:
-		return d.readError()
+		return Value{}, false, d.beginError()
 
 	default:
-		return Value{}, fmt.Errorf("%w: unknown tag 0x%02X ('%c') at position %d",
+		if since, ok := futureTags[tag]; ok && d.version >= since {
+			return Value{}, false, &FutureFeatureError{Tag: tag, IntroducedIn: since, Offset: d.reader.Pos() - 1}
+		}
+		if d.version > MaxVersion {
+			return Value{}, false, &FutureFeatureError{Tag: tag, IntroducedIn: d.version, Offset: d.reader.Pos() - 1}
+		}
+		return Value{}, false, fmt.Errorf("%w: unknown tag 0x%02X ('%c') at position %d",
 			ErrUnexpectedTag, tag, tag, d.reader.Pos()-1)
 	}
+
+	if err != nil {
+		return Value{}, false, err
+	}
+	d.depth--
+	return v, true, nil
 }
 
 // readInt32 reads a ZigZag-encoded int32.
@@ -320,13 +963,14 @@ func (d *Deserializer) readOneByteString() (Value, error) {
 	if err != nil {
 		return Value{}, err
 	}
+	if err := d.checkStringLen(length); err != nil {
+		return Value{}, err
+	}
 	s, err := d.reader.ReadOneByteString(int(length))
 	if err != nil {
 		return Value{}, err
 	}
-	v := String(s)
-	d.objects = append(d.objects, v) // strings are added to reference table
-	return v, nil
+	return String(s), nil
 }
 
 // readTwoByteString reads a UTF-16LE encoded string.
@@ -335,15 +979,22 @@ func (d *Deserializer) readTwoByteString() (Value, error) {
 	if err != nil {
 		return Value{}, err
 	}
+	if err := d.checkStringLen(byteLength); err != nil {
+		return Value{}, err
+	}
 	// Length is in bytes, convert to UTF-16 code units
 	utf16Length := int(byteLength) / 2
 	s, err := d.reader.ReadTwoByteString(utf16Length)
 	if err != nil {
 		return Value{}, err
 	}
-	v := String(s)
-	d.objects = append(d.objects, v) // strings are added to reference table
-	return v, nil
+	if d.logger != nil && utf16Length > 0 {
+		end := d.reader.Pos()
+		if wire.HasUnpairedSurrogate(d.reader.Data()[end-utf16Length*2 : end]) {
+			d.logAnomaly("unpaired surrogate replaced with U+FFFD", "length", utf16Length)
+		}
+	}
+	return String(s), nil
 }
 
 // readDate reads a JavaScript Date (ms since epoch as double).
@@ -352,151 +1003,167 @@ func (d *Deserializer) readDate() (Value, error) {
 	if err != nil {
 		return Value{}, err
 	}
-	// Convert milliseconds to time.Time
-	sec := int64(ms / 1000)
-	nsec := int64((ms - float64(sec)*1000) * 1e6)
-	t := time.Unix(sec, nsec).UTC()
-	v := Date(t)
+	v := DateFromMillis(ms)
 	d.objects = append(d.objects, v) // dates are added to reference table
 	return v, nil
 }
 
-// readObject reads a JavaScript object.
-func (d *Deserializer) readObject() (Value, error) {
-	obj := make(map[string]Value)
-	v := Value{typ: TypeObject, data: obj}
+// beginObject starts a JavaScript object.
+func (d *Deserializer) beginObject() error {
+	v := Value{typ: TypeObject, data: &object{props: make(map[string]Value), order: []string{}}}
 
 	// Add to reference table immediately (for self-reference support)
-	objIndex := len(d.objects)
+	d.push(decodeFrame{tag: tagBeginJSObject, value: v, index: len(d.objects)})
 	d.objects = append(d.objects, v)
+	return nil
+}
 
-	// Read properties until we see EndJSObject
-	for {
-		tag, err := d.reader.Peek()
-		if err != nil {
-			return Value{}, err
-		}
+// advanceObject reads properties until it sees EndJSObject.
+func (d *Deserializer) advanceObject(f *decodeFrame) (bool, error) {
+	if f.hasKey {
+		return false, nil // property value is next
+	}
 
-		if tag == tagEndJSObject {
-			_, _ = d.reader.ReadByte() // consume end tag (already peeked)
-			// Read property count (for validation)
-			_, err := d.reader.ReadVarint32()
-			if err != nil {
-				return Value{}, err
-			}
-			break
-		}
+	tag, err := d.reader.Peek()
+	if err != nil {
+		return false, err
+	}
 
-		// Read key (can be string or number for integer keys)
-		key, err := d.readValue()
+	if tag == tagEndJSObject {
+		_, _ = d.reader.ReadByte() // consume end tag (already peeked)
+		props, err := d.reader.ReadVarint32()
 		if err != nil {
-			return Value{}, err
+			return false, err
 		}
+		d.checkCount(f, "properties", props, f.count)
+		// Update the stored reference with populated object
+		d.objects[f.index] = f.value
+		return true, nil
+	}
 
-		// Convert key to string
-		var keyStr string
-		switch key.Type() {
-		case TypeString:
-			keyStr = key.AsString()
-		case TypeInt32:
-			keyStr = fmt.Sprintf("%d", key.AsInt32())
-		case TypeUint32:
-			keyStr = fmt.Sprintf("%d", key.AsUint32())
-		case TypeDouble:
-			keyStr = fmt.Sprintf("%g", key.AsDouble())
-		default:
-			return Value{}, fmt.Errorf("%w: object key must be string or number, got %s", ErrMalformedData, key.Type())
-		}
+	// Key (can be string or number for integer keys) is next
+	return false, d.checkObjectKeys(f.count + 1)
+}
 
-		// Read value
-		val, err := d.readValue()
-		if err != nil {
-			return Value{}, err
+func (d *Deserializer) deliverObject(f *decodeFrame, v Value) error {
+	if f.hasKey {
+		if !f.drop {
+			f.value.data.(*object).set(f.name, v)
 		}
+		f.hasKey = false
+		return nil
+	}
 
-		obj[keyStr] = val
+	name, ok := propertyName(v)
+	if !ok {
+		return fmt.Errorf("%w: object key must be string or number, got %s", ErrMalformedData, v.Type())
+	}
+	f.name = name
+	f.hasKey = true
+	f.count++
+	if d.keyFilter != nil {
+		path, ok := d.childPath(d.stack[:len(d.stack)-1])
+		f.drop = ok && !d.keyFilter(path, name)
 	}
+	return nil
+}
 
-	// Update the stored reference with populated object
-	d.objects[objIndex] = v
-	return v, nil
+// propertyName converts a decoded object key to the property name it sets.
+func propertyName(v Value) (string, bool) {
+	switch v.Type() {
+	case TypeString:
+		return v.AsString(), true
+	case TypeInt32:
+		return fmt.Sprintf("%d", v.AsInt32()), true
+	case TypeUint32:
+		return fmt.Sprintf("%d", v.AsUint32()), true
+	case TypeDouble:
+		return fmt.Sprintf("%g", v.AsDouble()), true
+	}
+	return "", false
 }
 
-// readDenseArray reads a dense JavaScript array.
-func (d *Deserializer) readDenseArray() (Value, error) {
+// beginDenseArray starts a dense JavaScript array.
+func (d *Deserializer) beginDenseArray() error {
 	length, err := d.reader.ReadVarint32()
 	if err != nil {
-		return Value{}, err
+		return err
 	}
 
 	// Check array length limit
-	if int(length) > d.maxArrayLen {
-		return Value{}, fmt.Errorf("%w: array length %d exceeds limit %d", ErrMalformedData, length, d.maxArrayLen)
+	if err := d.checkArrayLen(length); err != nil {
+		return err
 	}
 
-	arr := make([]Value, 0, length)
+	// Elements are stored in place, so back-references to the array from
+	// its own elements share its full length and backing memory.
+	arr := make([]Value, length)
 	v := Value{typ: TypeArray, data: arr}
 
 	// Add to reference table immediately
-	arrIndex := len(d.objects)
+	d.push(decodeFrame{tag: tagBeginDenseArray, value: v, index: len(d.objects), length: length, elems: arr})
 	d.objects = append(d.objects, v)
+	return nil
+}
 
-	// Read elements
-	for i := uint32(0); i < length; i++ {
-		elem, err := d.readValue()
-		if err != nil {
-			return Value{}, err
-		}
-		arr = append(arr, elem)
+// advanceDenseArray reads the elements, then any additional properties
+// (arrays can have properties in JS) until it sees EndDenseArray.
+func (d *Deserializer) advanceDenseArray(f *decodeFrame) (bool, error) {
+	if uint32(f.count) < f.length || f.hasKey {
+		return false, nil // element or property value is next
 	}
 
-	// Read any additional properties (arrays can have properties in JS)
-	for {
-		tag, err := d.reader.Peek()
-		if err != nil {
-			return Value{}, err
-		}
-
-		if tag == tagEndDenseArray {
-			_, _ = d.reader.ReadByte() // consume end tag (already peeked)
-			// Read property count and length
-			_, err := d.reader.ReadVarint32() // properties
-			if err != nil {
-				return Value{}, err
-			}
-			_, err = d.reader.ReadVarint32() // length
-			if err != nil {
-				return Value{}, err
-			}
-			break
-		}
+	tag, err := d.reader.Peek()
+	if err != nil {
+		return false, err
+	}
 
-		// Skip property (key + value)
-		_, err = d.readValue() // key
+	if tag == tagEndDenseArray {
+		_, _ = d.reader.ReadByte() // consume end tag (already peeked)
+		props, err := d.reader.ReadVarint32()
 		if err != nil {
-			return Value{}, err
+			return false, err
 		}
-		_, err = d.readValue() // value
+		length, err := d.reader.ReadVarint32()
 		if err != nil {
-			return Value{}, err
+			return false, err
 		}
+		d.checkCount(f, "properties", props, f.extra)
+		d.checkCount(f, "length", length, int(f.length))
+		f.value.data = f.elems
+		d.objects[f.index] = f.value
+		return true, nil
 	}
 
-	v.data = arr
-	d.objects[arrIndex] = v
-	return v, nil
+	return false, nil // property key is next
 }
 
-// readSparseArray reads a sparse JavaScript array.
-func (d *Deserializer) readSparseArray() (Value, error) {
+func (d *Deserializer) deliverDenseArray(f *decodeFrame, v Value) {
+	switch {
+	case uint32(f.count) < f.length:
+		f.elems[f.count] = v
+		f.count++
+	case !f.hasKey:
+		f.key = v
+		f.hasKey = true
+	default:
+		// Skip property (key + value)
+		d.logAnomaly("ignored array property", "key", f.key.GoString())
+		f.hasKey = false
+		f.extra++
+	}
+}
+
+// beginSparseArray starts a sparse JavaScript array.
+func (d *Deserializer) beginSparseArray() error {
 	length, err := d.reader.ReadVarint32()
 	if err != nil {
-		return Value{}, err
+		return err
 	}
 
 	// Check array length limit
-	if int(length) > d.maxArrayLen {
-		return Value{}, fmt.Errorf("%w: array length %d exceeds limit %d", ErrMalformedData, length, d.maxArrayLen)
+	if err := d.checkArrayLen(length); err != nil {
+		return err
 	}
 
 	// Create array filled with holes
@@ -508,55 +1175,63 @@ func (d *Deserializer) readSparseArray() (Value, error) {
 	v := Value{typ: TypeArray, data: arr}
 
 	// Add to reference table immediately
-	arrIndex := len(d.objects)
+	d.push(decodeFrame{tag: tagBeginSparseArray, value: v, index: len(d.objects), length: length, elems: arr})
 	d.objects = append(d.objects, v)
+	return nil
+}
 
-	// Read index-value pairs until end tag
-	for {
-		tag, err := d.reader.Peek()
-		if err != nil {
-			return Value{}, err
-		}
+// advanceSparseArray reads index-value pairs until it sees EndSparseArray.
+func (d *Deserializer) advanceSparseArray(f *decodeFrame) (bool, error) {
+	if f.hasKey {
+		return false, nil // value is next
+	}
 
-		if tag == tagEndSparseArray {
-			_, _ = d.reader.ReadByte() // consume end tag (already peeked)
-			// Read property count and length
-			_, err := d.reader.ReadVarint32() // properties
-			if err != nil {
-				return Value{}, err
-			}
-			_, err = d.reader.ReadVarint32() // length
-			if err != nil {
-				return Value{}, err
-			}
-			break
-		}
+	tag, err := d.reader.Peek()
+	if err != nil {
+		return false, err
+	}
 
-		// Read index (could be number or string)
-		key, err := d.readValue()
+	if tag == tagEndSparseArray {
+		_, _ = d.reader.ReadByte() // consume end tag (already peeked)
+		props, err := d.reader.ReadVarint32()
 		if err != nil {
-			return Value{}, err
+			return false, err
 		}
-
-		// Read value
-		val, err := d.readValue()
+		length, err := d.reader.ReadVarint32()
 		if err != nil {
-			return Value{}, err
+			return false, err
 		}
+		d.checkCount(f, "properties", props, f.extra)
+		d.checkCount(f, "length", length, int(f.length))
+		f.value.data = f.elems
+		d.objects[f.index] = f.value
+		return true, nil
+	}
 
-		// If key is a number in range, set the array element
-		if key.IsNumber() {
-			idx := int(key.AsNumber())
-			if idx >= 0 && idx < len(arr) {
-				arr[idx] = val
-			}
+	return false, nil // index (could be number or string) is next
+}
+
+func (d *Deserializer) deliverSparseArray(f *decodeFrame, v Value) {
+	if !f.hasKey {
+		f.key = v
+		f.hasKey = true
+		return
+	}
+	f.hasKey = false
+	f.extra++
+
+	// If key is a number in range, set the array element
+	if f.key.IsNumber() {
+		idx := int(f.key.AsNumber())
+		if idx >= 0 && idx < len(f.elems) {
+			f.elems[idx] = v
+		} else {
+			d.logAnomaly("ignored out-of-range sparse array index", "key", f.key.GoString(), "length", f.length)
 		}
-		// Non-numeric keys are array properties (ignored for now)
+		return
 	}
-
-	v.data = arr
-	d.objects[arrIndex] = v
-	return v, nil
+	// Non-numeric keys are array properties (ignored for now)
+	d.logAnomaly("ignored array property", "key", f.key.GoString())
 }
 
 // readObjectReference reads a back-reference to a previously seen object.
@@ -569,93 +1244,98 @@ func (d *Deserializer) readObjectReference() (Value, error) {
 	if int(id) >= len(d.objects) {
 		return Value{}, fmt.Errorf("%w: reference %d (only %d objects seen)", ErrInvalidReference, id, len(d.objects))
 	}
+	if err := d.countReference(); err != nil {
+		return Value{}, err
+	}
 
-	return d.objects[id], nil
+	return d.viewOf(d.objects[id])
 }
 
-// readMap reads a JavaScript Map.
-func (d *Deserializer) readMap() (Value, error) {
-	entries := make([]MapEntry, 0)
-	jsMap := &JSMap{Entries: entries}
+// beginMap starts a JavaScript Map.
+func (d *Deserializer) beginMap() error {
+	jsMap := &JSMap{Entries: make([]MapEntry, 0, d.collectionHint(2))}
 	v := Value{typ: TypeMap, data: jsMap}
 
 	// Add to reference table immediately
-	mapIndex := len(d.objects)
+	d.push(decodeFrame{tag: tagBeginMap, value: v, index: len(d.objects), entries: jsMap.Entries})
 	d.objects = append(d.objects, v)
+	return nil
+}
 
-	// Read key-value pairs until end tag
-	for {
-		tag, err := d.reader.Peek()
-		if err != nil {
-			return Value{}, err
-		}
+// advanceMap reads key-value pairs until it sees EndMap.
+func (d *Deserializer) advanceMap(f *decodeFrame) (bool, error) {
+	if f.hasKey {
+		return false, nil // value is next
+	}
 
-		if tag == tagEndMap {
-			_, _ = d.reader.ReadByte() // consume end tag (already peeked)
-			// Read entry count * 2
-			_, err := d.reader.ReadVarint32()
-			if err != nil {
-				return Value{}, err
-			}
-			break
-		}
+	tag, err := d.reader.Peek()
+	if err != nil {
+		return false, err
+	}
 
-		key, err := d.readValue()
+	if tag == tagEndMap {
+		_, _ = d.reader.ReadByte() // consume end tag (already peeked)
+		// Entry count * 2
+		n, err := d.reader.ReadVarint32()
 		if err != nil {
-			return Value{}, err
+			return false, err
 		}
+		d.checkCount(f, "entries", n, 2*len(f.entries))
+		f.value.data.(*JSMap).Entries = f.entries
+		d.objects[f.index] = f.value
+		return true, nil
+	}
 
-		val, err := d.readValue()
-		if err != nil {
-			return Value{}, err
-		}
+	return false, nil // key is next
+}
 
-		entries = append(entries, MapEntry{Key: key, Value: val})
+func (d *Deserializer) deliverMap(f *decodeFrame, v Value) {
+	if !f.hasKey {
+		f.key = v
+		f.hasKey = true
+		return
 	}
+	f.entries = append(f.entries, MapEntry{Key: f.key, Value: v})
+	f.hasKey = false
+}
 
-	jsMap.Entries = entries
-	d.objects[mapIndex] = v
-	return v, nil
+// collectionHint returns the capacity to reserve for a Map or Set whose
+// members take at least memberSize bytes each.
+func (d *Deserializer) collectionHint(memberSize int) int {
+	return max(0, min(d.collectionCap, d.reader.Remaining()/memberSize))
 }
 
-// readSet reads a JavaScript Set.
-func (d *Deserializer) readSet() (Value, error) {
-	values := make([]Value, 0)
-	jsSet := &JSSet{Values: values}
+// beginSet starts a JavaScript Set.
+func (d *Deserializer) beginSet() error {
+	jsSet := &JSSet{Values: make([]Value, 0, d.collectionHint(1))}
 	v := Value{typ: TypeSet, data: jsSet}
 
 	// Add to reference table immediately
-	setIndex := len(d.objects)
+	d.push(decodeFrame{tag: tagBeginSet, value: v, index: len(d.objects), elems: jsSet.Values})
 	d.objects = append(d.objects, v)
+	return nil
+}
 
-	// Read values until end tag
-	for {
-		tag, err := d.reader.Peek()
-		if err != nil {
-			return Value{}, err
-		}
-
-		if tag == tagEndSet {
-			_, _ = d.reader.ReadByte() // consume end tag (already peeked)
-			// Read entry count
-			_, err := d.reader.ReadVarint32()
-			if err != nil {
-				return Value{}, err
-			}
-			break
-		}
+// advanceSet reads values until it sees EndSet.
+func (d *Deserializer) advanceSet(f *decodeFrame) (bool, error) {
+	tag, err := d.reader.Peek()
+	if err != nil {
+		return false, err
+	}
 
-		val, err := d.readValue()
+	if tag == tagEndSet {
+		_, _ = d.reader.ReadByte() // consume end tag (already peeked)
+		n, err := d.reader.ReadVarint32()
 		if err != nil {
-			return Value{}, err
+			return false, err
 		}
-
-		values = append(values, val)
+		d.checkCount(f, "entries", n, f.count)
+		f.value.data.(*JSSet).Values = f.elems
+		d.objects[f.index] = f.value
+		return true, nil
 	}
 
-	jsSet.Values = values
-	d.objects[setIndex] = v
-	return v, nil
+	return false, nil // value is next
 }
 
 // readArrayBuffer reads an ArrayBuffer.
@@ -670,30 +1350,129 @@ func (d *Deserializer) readArrayBuffer() (Value, error) {
 		return Value{}, err
 	}
 
-	// Copy the data to avoid referencing the original buffer
-	buf := make([]byte, len(data))
+	v := Value{typ: TypeArrayBuffer, data: d.copyBuffer(data)}
+	d.objects = append(d.objects, v)
+	return d.viewOf(v)
+}
+
+// copyBuffer copies buffer contents out of the input, so decoded values do
+// not reference the caller's data, into memory from the allocator if any.
+func (d *Deserializer) copyBuffer(data []byte) []byte {
+	var buf []byte
+	if d.allocBuffer != nil {
+		buf = d.allocBuffer(len(data))
+	}
+	if buf == nil || len(buf) < len(data) {
+		buf = make([]byte, len(data))
+	}
+	buf = buf[:len(data)]
 	copy(buf, data)
+	return buf
+}
+
+// viewOf reads the native view that follows buf, if any. V8 writes a
+// natively encoded TypedArray or DataView as its buffer, or a reference to
+// a buffer written earlier, followed immediately by the view.
+func (d *Deserializer) viewOf(buf Value) (Value, error) {
+	detached, _ := buf.data.(*DetachedArrayBuffer)
+	shared, _ := buf.data.(*SharedArrayBufferRef)
+	if buf.typ != TypeArrayBuffer && (detached == nil || detached.View != "") && (shared == nil || shared.View != "") {
+		return buf, nil
+	}
+	if tag, err := d.reader.Peek(); err != nil || tag != tagArrayBufferView {
+		return buf, nil
+	}
+	_, _ = d.reader.ReadByte()
+	if detached != nil {
+		return d.readDetachedView(detached)
+	}
+	if shared != nil {
+		return d.readSharedView(shared)
+	}
+	return d.readArrayBufferView(buf.data.([]byte))
+}
 
-	v := Value{typ: TypeArrayBuffer, data: buf}
+// readArrayBufferView reads a native view over buf: sub-tag, byte offset,
+// byte length and (from version 14) flags.
+func (d *Deserializer) readArrayBufferView(buf []byte) (Value, error) {
+	typeName, offset, length, err := d.readViewRecord()
+	if err != nil {
+		return Value{}, err
+	}
+	if uint64(offset)+uint64(length) > uint64(len(buf)) {
+		return Value{}, fmt.Errorf("%w: view [%d, %d) outside %d-byte buffer", ErrMalformedData, offset, uint64(offset)+uint64(length), len(buf))
+	}
+	v := Value{typ: TypeTypedArray, data: &ArrayBufferView{
+		Buffer:     buf,
+		ByteOffset: int(offset),
+		ByteLength: int(length),
+		Type:       typeName,
+	}}
 	d.objects = append(d.objects, v)
 	return v, nil
 }
 
-// readRegExp reads a JavaScript RegExp.
-func (d *Deserializer) readRegExp() (Value, error) {
-	// Read pattern (string)
-	pattern, err := d.readValue()
+// readViewRecord reads the fields of a native view after its 'V' tag.
+func (d *Deserializer) readViewRecord() (typeName string, offset, length uint32, err error) {
+	pos := d.reader.Pos() - 1
+	subTag, err := d.reader.ReadByte()
 	if err != nil {
-		return Value{}, err
+		return "", 0, 0, err
+	}
+	if offset, err = d.reader.ReadVarint32(); err != nil {
+		return "", 0, 0, err
 	}
-	if !pattern.IsString() {
-		return Value{}, fmt.Errorf("%w: regexp pattern must be string", ErrMalformedData)
+	if length, err = d.reader.ReadVarint32(); err != nil {
+		return "", 0, 0, err
+	}
+	if d.version >= 14 {
+		if _, err := d.reader.ReadVarint32(); err != nil { // flags
+			return "", 0, 0, err
+		}
+	}
+	typeName, ok := viewTypeNames[subTag]
+	if !ok {
+		return "", 0, 0, fmt.Errorf("%w: unknown ArrayBufferView sub-tag 0x%02X", ErrMalformedData, subTag)
+	}
+	if err := d.checkType(viewType(typeName), pos); err != nil {
+		return "", 0, 0, err
+	}
+	return typeName, offset, length, nil
+}
+
+// deliverSet appends a Set value, applying the duplicate policy.
+func (d *Deserializer) deliverSet(f *decodeFrame, v Value) error {
+	f.count++
+	if d.setDuplicates != DuplicatesKeep {
+		if key, ok := sameValueZeroKey(v); ok {
+			if _, dup := f.seen[key]; dup {
+				if d.setDuplicates == DuplicatesError {
+					return fmt.Errorf("%w: duplicate Set value %s", ErrMalformedData, v.GoString())
+				}
+				d.logAnomaly("dropped duplicate Set value", "value", v.GoString())
+				return nil
+			}
+			if f.seen == nil {
+				f.seen = make(map[svzKey]struct{})
+			}
+			f.seen[key] = struct{}{}
+		}
+	}
+	f.elems = append(f.elems, v)
+	return nil
+}
+
+// advanceRegExp reads the pattern (a string child) and then the flags of a
+// JavaScript RegExp.
+func (d *Deserializer) advanceRegExp(f *decodeFrame) (bool, error) {
+	if f.count == 0 {
+		return false, nil // pattern is next
 	}
 
 	// Read flags as varint (bitfield)
 	flagBits, err := d.reader.ReadVarint32()
 	if err != nil {
-		return Value{}, err
+		return false, err
 	}
 
 	// Convert flag bits to string
@@ -716,73 +1495,56 @@ func (d *Deserializer) readRegExp() (Value, error) {
 	if flagBits&32 != 0 {
 		flags += "y" // sticky
 	}
+	if unknown := flagBits &^ 63; unknown != 0 {
+		d.logAnomaly("dropped unknown RegExp flags", "flags", unknown)
+	}
 
 	re := &RegExp{
-		Pattern: pattern.AsString(),
+		Pattern: f.key.AsString(),
 		Flags:   flags,
 	}
 
-	v := Value{typ: TypeRegExp, data: re}
-	d.objects = append(d.objects, v)
-	return v, nil
+	f.value = Value{typ: TypeRegExp, data: re}
+	d.objects = append(d.objects, f.value)
+	return true, nil
 }
 
-// readTypedArray reads a TypedArray (Uint8Array, Int32Array, etc.)
-func (d *Deserializer) readTypedArray() (Value, error) {
-	// Read TypedArray type
-	arrayType, err := d.reader.ReadByte()
+func (d *Deserializer) deliverRegExp(f *decodeFrame, v Value) error {
+	if !v.IsString() {
+		return fmt.Errorf("%w: regexp pattern must be string", ErrMalformedData)
+	}
+	f.key = v
+	f.count++
+	return nil
+}
+
+// readNodeView reads the body of a host object in Node's encoding of
+// TypedArrays, DataViews and Buffers: a view type index, a byte length and
+// the bytes. The view's position is pos.
+func (d *Deserializer) readNodeView(pos int) (Value, error) {
+	arrayType, err := d.reader.ReadVarint32()
 	if err != nil {
 		return Value{}, err
 	}
+	typeName, ok := nodeViewTypeNames[arrayType]
+	if !ok {
+		return Value{}, fmt.Errorf("%w: unknown Node view type %d at position %d (see WithHostObjectReader)",
+			ErrHostObject, arrayType, pos)
+	}
+	if err := d.checkType(viewType(typeName), pos); err != nil {
+		return Value{}, err
+	}
 
-	// Read byte length
 	byteLength, err := d.reader.ReadVarint32()
 	if err != nil {
 		return Value{}, err
 	}
-
-	// Read raw data
-	data, err := d.reader.ReadBytes(int(byteLength))
+	// The body is length-prefixed; a sub-reader keeps it within bounds.
+	body, err := d.reader.Slice(int(byteLength))
 	if err != nil {
 		return Value{}, err
 	}
-
-	// Copy data
-	buf := make([]byte, len(data))
-	copy(buf, data)
-
-	// Determine type name
-	var typeName string
-	switch arrayType {
-	case typedArrayInt8:
-		typeName = "Int8Array"
-	case typedArrayUint8:
-		typeName = "Uint8Array"
-	case typedArrayUint8Clamped:
-		typeName = "Uint8ClampedArray"
-	case typedArrayInt16:
-		typeName = "Int16Array"
-	case typedArrayUint16:
-		typeName = "Uint16Array"
-	case typedArrayInt32:
-		typeName = "Int32Array"
-	case typedArrayUint32:
-		typeName = "Uint32Array"
-	case typedArrayFloat32:
-		typeName = "Float32Array"
-	case typedArrayFloat64:
-		typeName = "Float64Array"
-	case typedArrayDataView:
-		typeName = "DataView"
-	case typedArrayFloat16:
-		typeName = "Float16Array"
-	case typedArrayBigInt64:
-		typeName = "BigInt64Array"
-	case typedArrayBigUint64:
-		typeName = "BigUint64Array"
-	default:
-		typeName = fmt.Sprintf("TypedArray(%d)", arrayType)
-	}
+	buf := d.copyBuffer(body.Data())
 
 	view := &ArrayBufferView{
 		Buffer:     buf,
@@ -790,10 +1552,7 @@ func (d *Deserializer) readTypedArray() (Value, error) {
 		ByteLength: len(buf),
 		Type:       typeName,
 	}
-
-	v := Value{typ: TypeTypedArray, data: view}
-	d.objects = append(d.objects, v)
-	return v, nil
+	return Value{typ: TypeTypedArray, data: view}, nil
 }
 
 // readNumberObject reads a boxed Number (contains double directly).
@@ -834,46 +1593,34 @@ func (d *Deserializer) readFalseObject() (Value, error) {
 	return v, nil
 }
 
-// readStringObject reads a boxed String.
-func (d *Deserializer) readStringObject() (Value, error) {
-	inner, err := d.readValue()
-	if err != nil {
-		return Value{}, err
-	}
-
-	// Validate that inner is actually a String
-	if inner.Type() != TypeString {
-		return Value{}, fmt.Errorf("%w: boxed String contains %s, not String", ErrMalformedData, inner.Type())
+// advanceBoxed completes a boxed String or BigInt once its inner value has
+// been read.
+func (d *Deserializer) advanceBoxed(f *decodeFrame) (bool, error) {
+	if f.count == 0 {
+		return false, nil // inner value is next
 	}
 
 	boxed := &BoxedPrimitive{
-		PrimitiveType: TypeString,
-		Value:         inner,
+		PrimitiveType: f.key.Type(),
+		Value:         f.key,
 	}
-	v := Value{typ: TypeBoxedPrimitive, data: boxed}
-	d.objects = append(d.objects, v)
-	return v, nil
+	f.value = Value{typ: TypeBoxedPrimitive, data: boxed}
+	d.objects = append(d.objects, f.value)
+	return true, nil
 }
 
-// readBigIntObject reads a boxed BigInt.
-func (d *Deserializer) readBigIntObject() (Value, error) {
-	inner, err := d.readValue()
-	if err != nil {
-		return Value{}, err
-	}
-
-	// Validate that inner is actually a BigInt
-	if inner.Type() != TypeBigInt {
-		return Value{}, fmt.Errorf("%w: boxed BigInt contains %s, not BigInt", ErrMalformedData, inner.Type())
+// deliverBoxed validates the inner value of a boxed String or BigInt.
+func (d *Deserializer) deliverBoxed(f *decodeFrame, inner Value) error {
+	want, name := TypeString, "String"
+	if f.tag == tagBigIntObject {
+		want, name = TypeBigInt, "BigInt"
 	}
-
-	boxed := &BoxedPrimitive{
-		PrimitiveType: TypeBigInt,
-		Value:         inner,
+	if inner.Type() != want {
+		return fmt.Errorf("%w: boxed %s contains %s, not %s", ErrMalformedData, name, inner.Type(), name)
 	}
-	v := Value{typ: TypeBoxedPrimitive, data: boxed}
-	d.objects = append(d.objects, v)
-	return v, nil
+	f.key = inner
+	f.count++
+	return nil
 }
 
 // Error sub-tags
@@ -897,84 +1644,101 @@ const (
 	errorTypeURIError         byte = 'U' // 0x55
 )
 
-// readError reads a JavaScript Error object.
+// beginError starts a JavaScript Error object.
 // Format varies:
 // - Generic Error with message: 'r' + 'm' + message_string + ('s' + stack_string)? + '.'
 // - Typed errors: 'r' + type + 'm' + message_string + ('s' + stack_string)? + '.'
-func (d *Deserializer) readError() (Value, error) {
+func (d *Deserializer) beginError() error {
 	// Read error type indicator
 	errType, err := d.reader.ReadByte()
 	if err != nil {
-		return Value{}, err
+		return err
 	}
 
-	jsErr := &JSError{}
+	f := decodeFrame{tag: tagError, jsErr: &JSError{}}
 
 	// Handle the special case where 'm' is both type (generic Error) AND
 	// indicates that message follows directly
 	if errType == errorTypeErrorWithMessage {
-		jsErr.Name = "Error"
+		f.jsErr.Name = "Error"
 		// Message string follows directly (no sub-tag)
-		val, err := d.readValue()
-		if err != nil {
-			return Value{}, err
-		}
-		if val.IsString() {
-			jsErr.Message = val.AsString()
-		}
+		f.hasKey, f.lead = true, true
 	} else {
 		// Map error type to name
 		switch errType {
 		case errorTypeEvalError:
-			jsErr.Name = "EvalError"
+			f.jsErr.Name = "EvalError"
 		case errorTypeRangeError:
-			jsErr.Name = "RangeError"
+			f.jsErr.Name = "RangeError"
 		case errorTypeReferenceError:
-			jsErr.Name = "ReferenceError"
+			f.jsErr.Name = "ReferenceError"
 		case errorTypeSyntaxError:
-			jsErr.Name = "SyntaxError"
+			f.jsErr.Name = "SyntaxError"
 		case errorTypeTypeError:
-			jsErr.Name = "TypeError"
+			f.jsErr.Name = "TypeError"
 		case errorTypeURIError:
-			jsErr.Name = "URIError"
+			f.jsErr.Name = "URIError"
 		default:
-			jsErr.Name = "Error"
+			f.jsErr.Name = "Error"
+			d.logAnomaly("unknown Error type treated as Error", "type", errType)
 		}
 	}
 
-	// Read remaining sub-tags until we hit the end tag
-	for {
-		subTag, err := d.reader.ReadByte()
-		if err != nil {
-			return Value{}, err
-		}
+	// Like every object, the Error takes its reference ID before anything
+	// nested in it, such as an object cause.
+	f.value = Value{typ: TypeError, data: f.jsErr}
+	f.index = len(d.objects)
+	d.objects = append(d.objects, f.value)
+	d.push(f)
+	return nil
+}
 
-		if subTag == errorTagEnd {
-			break
-		}
+// advanceError reads sub-tags until it hits the end tag.
+func (d *Deserializer) advanceError(f *decodeFrame) (bool, error) {
+	if f.hasKey {
+		return false, nil // value following the sub-tag is next
+	}
 
-		// Read the value following the sub-tag
-		val, err := d.readValue()
-		if err != nil {
-			return Value{}, err
-		}
+	subTag, err := d.reader.ReadByte()
+	if err != nil {
+		return false, err
+	}
 
-		switch subTag {
-		case errorTagMessage:
-			if val.IsString() {
-				jsErr.Message = val.AsString()
-			}
-		case errorTagStack:
-			if val.IsString() {
-				jsErr.Stack = val.AsString()
-			}
-		case errorTagCause:
-			// Cause is another value (usually an Error)
-			jsErr.Cause = &val
+	if subTag == errorTagEnd {
+		return true, nil
+	}
+
+	f.subTag = subTag
+	f.hasKey = true
+	return false, nil
+}
+
+func (d *Deserializer) deliverError(f *decodeFrame, val Value) {
+	jsErr := f.jsErr
+	f.hasKey = false
+	if f.lead {
+		f.lead = false
+		if val.IsString() {
+			jsErr.Message = val.AsString()
+		} else {
+			d.logAnomaly("ignored non-string Error message", "type", val.Type().String())
 		}
+		return
 	}
 
-	v := Value{typ: TypeError, data: jsErr}
-	d.objects = append(d.objects, v)
-	return v, nil
+	switch f.subTag {
+	case errorTagMessage:
+		if val.IsString() {
+			jsErr.Message = val.AsString()
+		}
+	case errorTagStack:
+		if val.IsString() {
+			jsErr.Stack = val.AsString()
+		}
+	case errorTagCause:
+		// Cause is another value (usually an Error)
+		jsErr.Cause = &val
+	default:
+		d.logAnomaly("ignored unknown Error field", "tag", f.subTag)
+	}
 }
@@ -1,12 +1,18 @@
 package v8serialize
 
 import (
+	"bytes"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"math"
 	"math/big"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -669,6 +675,9 @@ func TestDeserializeCircularReferences(t *testing.T) {
 		if arr[3].Type() != TypeArray {
 			t.Fatalf("arr[3]: expected array, got %s", arr[3].Type())
 		}
+		if len(arr[3].AsArray()) != 4 {
+			t.Errorf("arr[3]: expected the full array, got %d elements", len(arr[3].AsArray()))
+		}
 	})
 }
 
@@ -955,6 +964,104 @@ func TestDeserializeErrors(t *testing.T) {
 	}
 }
 
+func TestFutureFeatureError(t *testing.T) {
+	// A shared object in a version 15 payload needs a newer package.
+	_, err := Deserialize([]byte{0xFF, 0x0F, 'p', 0x00})
+	var future *FutureFeatureError
+	if !errors.As(err, &future) {
+		t.Fatalf("got %v, want FutureFeatureError", err)
+	}
+	if future.Tag != 'p' || future.IntroducedIn != 15 || future.Offset != 2 {
+		t.Errorf("got %+v", future)
+	}
+	if !errors.Is(err, ErrUnexpectedTag) {
+		t.Error("FutureFeatureError should match ErrUnexpectedTag")
+	}
+	if !strings.Contains(err.Error(), "SharedObject") {
+		t.Errorf("message %q does not name the tag", err)
+	}
+
+	// Before version 15 the same byte cannot be a shared object.
+	_, err = Deserialize([]byte{0xFF, 0x0E, 'p', 0x00})
+	if errors.As(err, &future) || !errors.Is(err, ErrUnexpectedTag) {
+		t.Errorf("version 14: got %v, want plain unknown tag error", err)
+	}
+}
+
+func TestWithAcceptFutureVersions(t *testing.T) {
+	data := []byte{0xFF, 0x10, 'I', 0x54} // version 16, Int32 42
+	if _, err := Deserialize(data); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Fatalf("default: got %v, want ErrUnsupportedVersion", err)
+	}
+
+	d := NewDeserializer(data, WithAcceptFutureVersions())
+	v, err := d.Deserialize()
+	if err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	if v.AsInt32() != 42 || d.Version() != 16 {
+		t.Errorf("got %v version %d", v, d.Version())
+	}
+	if w := d.Warnings(); len(w) != 1 || !strings.Contains(w[0], "version 16") {
+		t.Errorf("Warnings() = %q", w)
+	}
+
+	// Tags unknown to this package are blamed on the newer version.
+	_, err = Deserialize([]byte{0xFF, 0x10, 0x01}, WithAcceptFutureVersions())
+	var future *FutureFeatureError
+	if !errors.As(err, &future) || future.IntroducedIn != 16 {
+		t.Errorf("unknown tag: got %v, want FutureFeatureError for version 16", err)
+	}
+
+	d.Reset([]byte{0xFF, 0x0F, 'I', 0x54})
+	if _, err := d.Deserialize(); err != nil || len(d.Warnings()) != 0 {
+		t.Errorf("supported version: err %v, warnings %q", err, d.Warnings())
+	}
+}
+
+func TestReferenceIDs(t *testing.T) {
+	// Produced by Node: strings take no reference ID, and an Error takes
+	// its ID before its cause.
+	tests := []struct {
+		name string
+		hex  string // v8.serialize output; the last element references an earlier object
+		ref  func(elems []Value) Value
+	}{
+		{"after-string", "ff0f41032201616f7b005e01240003", func(e []Value) Value { return e[1] }},                                      // ["a", o, o]
+		{"error-cause", "ff0f4102726d220178636f7b002e5e02240002", func(e []Value) Value { return *e[0].Interface().(*JSError).Cause }}, // [new Error("x", {cause: o}), o]
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, _ := hex.DecodeString(tt.hex)
+			v, err := Deserialize(data)
+			if err != nil {
+				t.Fatalf("Deserialize failed: %v", err)
+			}
+			elems := v.AsArray()
+			want := tt.ref(elems)
+			if got := elems[len(elems)-1]; got.Type() != TypeObject || got.data != want.data {
+				t.Errorf("reference resolved to %#v, want %#v", got, want)
+			}
+		})
+	}
+
+	t.Run("view-after-reference", func(t *testing.T) {
+		// Node: [new Uint8Array(b, 0, 2), new Uint8Array(b, 2, 2)] written by
+		// the base v8.Serializer: the second view refers to the buffer.
+		data, _ := hex.DecodeString("ff0f410242040000000056420002005e015642020200240002")
+		v, err := Deserialize(data)
+		if err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+		elems := v.AsArray()
+		first, ok1 := elems[0].Interface().(*ArrayBufferView)
+		second, ok2 := elems[1].Interface().(*ArrayBufferView)
+		if !ok1 || !ok2 || &first.Buffer[0] != &second.Buffer[0] || second.ByteOffset != 2 || second.ByteLength != 2 {
+			t.Errorf("got %#v, want two views over one buffer", elems)
+		}
+	})
+}
+
 func TestToGo(t *testing.T) {
 	t.Run("primitives", func(t *testing.T) {
 		if ToGo(Null()) != nil {
@@ -997,6 +1104,232 @@ func TestToGo(t *testing.T) {
 			t.Errorf("arr[0]: expected 1, got %v", result[0])
 		}
 	})
+
+	t.Run("nullish sentinels", func(t *testing.T) {
+		v := Array([]Value{Null(), Undefined(), Hole()})
+
+		plain := ToGo(v).([]interface{})
+		for i, got := range plain {
+			if got != nil {
+				t.Errorf("arr[%d]: expected nil without option, got %v", i, got)
+			}
+		}
+
+		result := ToGo(v, WithNullishSentinels()).([]interface{})
+		want := []interface{}{nil, JSUndefined, JSHole}
+		for i := range want {
+			if result[i] != want[i] {
+				t.Errorf("arr[%d]: expected %v, got %v", i, want[i], result[i])
+			}
+		}
+	})
+}
+
+func TestToGoSharesReferences(t *testing.T) {
+	// const b = [1, 2]; [b, b, b]
+	data, _ := hex.DecodeString("ff0f41034102490249042400025e015e01240003")
+	v, err := Deserialize(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := ToGo(v).([]interface{})
+	first := got[0].([]interface{})
+	for i := 1; i < len(got); i++ {
+		if &got[i].([]interface{})[0] != &first[0] {
+			t.Errorf("element %d was converted again instead of shared", i)
+		}
+	}
+}
+
+func TestToGoCycles(t *testing.T) {
+	binData, _ := loadFixture(t, "circular-self")
+	v, err := Deserialize(binData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("share", func(t *testing.T) {
+		got := ToGo(v).(map[string]interface{})
+		self, ok := got["self"].(map[string]interface{})
+		if !ok || reflect.ValueOf(self).Pointer() != reflect.ValueOf(got).Pointer() {
+			t.Errorf("self: expected the converted object, got %v", got["self"])
+		}
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		got := ToGo(v, WithCyclePolicy(CycleNil)).(map[string]interface{})
+		if got["self"] != nil || got["name"] != "self" {
+			t.Errorf("got %v", got)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		if _, err := ToGoSafe(v, WithCyclePolicy(CycleError)); !errors.Is(err, ErrCyclic) {
+			t.Errorf("ToGoSafe: expected ErrCyclic, got %v", err)
+		}
+		if got := ToGo(v, WithCyclePolicy(CycleError)).(map[string]interface{}); got["self"] != nil {
+			t.Errorf("ToGo: expected nil back-reference, got %v", got["self"])
+		}
+	})
+
+	t.Run("array", func(t *testing.T) {
+		binData, _ := loadFixture(t, "circular-array")
+		v, err := Deserialize(binData)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ToGo(v).([]interface{})
+		if inner := got[3].([]interface{}); &inner[0] != &got[0] {
+			t.Error("arr[3]: expected the converted array")
+		}
+	})
+
+	t.Run("map func", func(t *testing.T) {
+		// const m = new Map(); m.set('m', m)
+		data, _ := hex.DecodeString("ff0f3b22016d5e003a02")
+		v, err := Deserialize(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ToGo(v, WithOrderedMaps()).([]MapEntryGo)
+		if len(got) != 1 || got[0].Value != nil {
+			t.Errorf("got %v", got)
+		}
+	})
+}
+
+func TestWithMaxReferenceUses(t *testing.T) {
+	// const b = [1, 2]; [b, b, b]
+	data, _ := hex.DecodeString("ff0f41034102490249042400025e015e01240003")
+	if _, err := Deserialize(data, WithMaxReferenceUses(2)); err != nil {
+		t.Errorf("two references: %v", err)
+	}
+	if _, err := Deserialize(data, WithMaxReferenceUses(1)); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Deserialize: expected ErrLimitExceeded, got %v", err)
+	}
+	tok := NewTokenizer(data, WithMaxReferenceUses(1))
+	var err error
+	for err == nil {
+		_, err = tok.Next()
+	}
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Tokenizer: expected ErrLimitExceeded, got %v", err)
+	}
+}
+
+func TestToGoTypedArraySlices(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    interface{}
+	}{
+		{"uint8array", []uint8{255, 0, 128}},
+		{"uint8clampedarray", []uint8{0, 128, 255}},
+		{"int8array", []int8{-128, 0, 127}},
+		{"uint16array", []uint16{0, 65535}},
+		{"int16array", []int16{-32768, 32767}},
+		{"uint32array", []uint32{0, 4294967295}},
+		{"int32array", []int32{-2147483648, 2147483647}},
+		{"float32array", []float32{1.5, -2.5}},
+		{"bigint64array", []int64{0, -1, math.MaxInt64, math.MinInt64}},
+		{"biguint64array", []uint64{0, 1, math.MaxUint64}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			binData, _ := loadFixture(t, tt.fixture)
+			v, err := Deserialize(binData)
+			if err != nil {
+				t.Fatalf("Deserialize failed: %v", err)
+			}
+
+			if _, ok := ToGo(v).(*ArrayBufferView); !ok {
+				t.Errorf("without option: expected *ArrayBufferView, got %T", ToGo(v))
+			}
+
+			got := ToGo(v, WithTypedArraySlices())
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToGoOrderedMaps(t *testing.T) {
+	binData, _ := loadFixture(t, "map-non-string-keys")
+	v, err := Deserialize(binData)
+	if err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+
+	got := ToGo(v, WithOrderedMaps())
+	want := []MapEntryGo{{int32(1), "one"}, {true, "bool"}, {nil, "null"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+
+	// Object keys cannot be Go map keys but survive as entries.
+	objKey := Value{typ: TypeMap, data: &JSMap{Entries: []MapEntry{
+		{Key: Object(map[string]Value{"id": Int32(1)}), Value: String("x")},
+	}}}
+	entries := ToGo(objKey, WithOrderedMaps()).([]MapEntryGo)
+	if len(entries) != 1 || entries[0].Value != "x" {
+		t.Fatalf("unexpected entries: %#v", entries)
+	}
+	if key, ok := entries[0].Key.(map[string]interface{}); !ok || key["id"] != int32(1) {
+		t.Errorf("unexpected key: %#v", entries[0].Key)
+	}
+
+	t.Run("custom type", func(t *testing.T) {
+		type pairs [][2]interface{}
+		got := ToGo(v, WithMapFunc(func(entries []MapEntryGo) interface{} {
+			out := make(pairs, len(entries))
+			for i, e := range entries {
+				out[i] = [2]interface{}{e.Key, e.Value}
+			}
+			return out
+		}))
+		if p, ok := got.(pairs); !ok || len(p) != 3 || p[1][0] != true {
+			t.Errorf("unexpected result: %#v", got)
+		}
+	})
+}
+
+func TestToGoUnhashableMapKeys(t *testing.T) {
+	m := Value{typ: TypeMap, data: &JSMap{Entries: []MapEntry{
+		{Key: String("plain"), Value: Int32(1)},
+		{Key: Object(map[string]Value{"id": Int32(1)}), Value: String("x")},
+		{Key: ArrayBuffer([]byte{1}), Value: String("y")},
+	}}}
+	root := Array([]Value{m})
+
+	// ToGo falls back to an entry list instead of panicking.
+	got := ToGo(root).([]interface{})[0]
+	entries, ok := got.([]MapEntryGo)
+	if !ok || len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %#v", got)
+	}
+	if entries[0].Key != "plain" || entries[2].Value != "y" {
+		t.Errorf("unexpected entries: %#v", entries)
+	}
+
+	// ToGoSafe reports the problem instead.
+	if _, err := ToGoSafe(root); !errors.Is(err, ErrUnhashable) {
+		t.Errorf("expected ErrUnhashable, got %v", err)
+	}
+
+	// Hashable keys still give a Go map.
+	binData, _ := loadFixture(t, "map-non-string-keys")
+	v, err := Deserialize(binData)
+	if err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	res, err := ToGoSafe(v)
+	if err != nil {
+		t.Fatalf("ToGoSafe failed: %v", err)
+	}
+	if gm, ok := res.(map[interface{}]interface{}); !ok || gm[int32(1)] != "one" {
+		t.Errorf("unexpected result: %#v", res)
+	}
 }
 
 func TestMustDeserialize(t *testing.T) {
@@ -1041,6 +1374,135 @@ func TestIsValidV8Data(t *testing.T) {
 	}
 }
 
+func TestDetectVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		want    uint32
+		wantErr bool
+	}{
+		{"v15", []byte{0xFF, 0x0F, 0x30}, 15, false},
+		{"v13", []byte{0xFF, 0x0D}, 13, false},
+		{"legacy v12", []byte{0xFF, 0x0C, 0x30}, 12, false},
+		{"future multi-byte", []byte{0xFF, 0x80, 0x01}, 128, false},
+		{"empty", []byte{}, 0, true},
+		{"wrong tag", []byte{0xFE, 0x0F}, 0, true},
+		{"truncated varint", []byte{0xFF, 0x80}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DetectVersion(tt.data)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidHeader) {
+					t.Fatalf("expected ErrInvalidHeader, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DetectVersion failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got version %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want Format
+	}{
+		{"v8", []byte{0xff, 0x0f, 0x49, 0x54}, FormatV8},
+		{"v8-legacy-version", []byte{0xff, 0x09, 0x30}, FormatV8},
+		{"v8-truncated-header", []byte{0xff}, FormatUnknown},
+		{"json-object", []byte(`{"a": [1, 2]}`), FormatJSON},
+		{"json-pretty", []byte("{\n  \"a\": 1\n}\n"), FormatJSON},
+		{"json-scalar", []byte(` 42 `), FormatJSON},
+		{"ipc-single", []byte("{\"cmd\":\"NODE_HANDLE\"}\n"), FormatNodeIPC},
+		{"ipc-multiple", []byte("{\"a\":1}\n[2]\r\n\"three\"\n"), FormatNodeIPC},
+		{"ipc-partial", []byte("{\"a\":1}\n{\"b\":"), FormatUnknown},
+		{"empty", nil, FormatUnknown},
+		{"text", []byte("hello"), FormatUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectFormat(tt.data); got != tt.want {
+				t.Errorf("DetectFormat = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSupportedVersions(t *testing.T) {
+	versions := SupportedVersions()
+	if len(versions) == 0 || versions[0] != MinVersion || versions[len(versions)-1] != MaxVersion {
+		t.Fatalf("got %v, want %d..%d", versions, MinVersion, MaxVersion)
+	}
+	for _, v := range versions {
+		data := []byte{0xFF, byte(v), 0x30}
+		if !IsValidV8Data(data) {
+			t.Errorf("version %d reported as supported but rejected by IsValidV8Data", v)
+		}
+	}
+}
+
+func TestNegotiateVersion(t *testing.T) {
+	tests := []struct {
+		mine, theirs []uint32
+		want         uint32
+	}{
+		{SupportedVersions(), []uint32{13, 14}, 14},
+		{[]uint32{15, 13}, []uint32{13, 15, 16}, 15},
+		{[]uint32{13}, []uint32{13}, 13},
+	}
+	for _, tt := range tests {
+		got, err := NegotiateVersion(tt.mine, tt.theirs)
+		if err != nil || got != tt.want {
+			t.Errorf("NegotiateVersion(%v, %v) = %d, %v; want %d", tt.mine, tt.theirs, got, err, tt.want)
+		}
+	}
+	if _, err := NegotiateVersion([]uint32{13, 14}, []uint32{15}); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("disjoint lists: got %v, want ErrUnsupportedVersion", err)
+	}
+}
+
+func TestReadValueMultiRoot(t *testing.T) {
+	binData, _ := loadFixture(t, "multi-root")
+	d := NewDeserializer(binData)
+
+	var values []Value
+	for d.More() {
+		v, err := d.ReadValue()
+		if err != nil {
+			t.Fatalf("ReadValue failed after %d values: %v", len(values), err)
+		}
+		values = append(values, v)
+	}
+
+	if len(values) != 3 {
+		t.Fatalf("expected 3 values, got %d", len(values))
+	}
+	if values[0].AsInt32() != 42 {
+		t.Errorf("values[0]: got %#v, want 42", values[0])
+	}
+	if values[1].AsString() != "second" {
+		t.Errorf("values[1]: got %#v, want \"second\"", values[1])
+	}
+	if !values[2].AsObject()["third"].AsBool() {
+		t.Errorf("values[2]: got %#v, want {third: true}", values[2])
+	}
+	if d.Version() != 15 {
+		t.Errorf("version: got %d, want 15", d.Version())
+	}
+
+	if _, err := d.ReadValue(); !errors.Is(err, ErrMalformedData) {
+		t.Errorf("reading past the last value: expected ErrMalformedData, got %v", err)
+	}
+}
+
 // Benchmark deserialization
 func BenchmarkDeserializeInt32(b *testing.B) {
 	binData, _ := os.ReadFile(filepath.Join("..", "..", "testdata", "fixtures", "int32-positive.bin"))
@@ -1392,6 +1854,38 @@ func TestMaxDepthLimit(t *testing.T) {
 	}
 }
 
+func TestDeserializeVeryDeepNesting(t *testing.T) {
+	// [[[...[undefined]...]]] nested 100,000 levels, like a long linked list.
+	const depth = 100_000
+	data := []byte{0xff, 0x0f}
+	for i := 0; i < depth; i++ {
+		data = append(data, tagBeginDenseArray, 0x01)
+	}
+	data = append(data, tagUndefined)
+	for i := 0; i < depth; i++ {
+		data = append(data, tagEndDenseArray, 0x00, 0x01)
+	}
+
+	if _, err := Deserialize(data); !errors.Is(err, ErrMaxDepthExceeded) {
+		t.Fatalf("expected ErrMaxDepthExceeded with default limit, got %v", err)
+	}
+
+	v, err := Deserialize(data, WithMaxDepth(depth+1))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i := 0; i < depth; i++ {
+		arr := v.AsArray()
+		if len(arr) != 1 {
+			t.Fatalf("level %d: expected 1 element, got %d", i, len(arr))
+		}
+		v = arr[0]
+	}
+	if !v.IsUndefined() {
+		t.Errorf("innermost value: expected undefined, got %s", v.Type())
+	}
+}
+
 func TestMaxSizeLimit(t *testing.T) {
 	binData, _ := loadFixture(t, "string-10k")
 
@@ -1409,6 +1903,382 @@ func TestMaxSizeLimit(t *testing.T) {
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
+
+	// The limit is enforced as the payload is read: a Tokenizer returns
+	// the tokens before it and fails on reaching it.
+	arrData, _ := loadFixture(t, "array-dense")
+	tok := NewTokenizer(arrData, WithMaxSize(len(arrData)-4))
+	tokens := 0
+	for err == nil {
+		if _, err = tok.Next(); err == nil {
+			tokens++
+		}
+	}
+	if !errors.Is(err, ErrMaxSizeExceeded) || tokens == 0 {
+		t.Errorf("Tokenizer: got %d tokens, then %v; want some tokens, then ErrMaxSizeExceeded", tokens, err)
+	}
+
+	// Each embedded payload is counted from its own header.
+	d := NewDeserializer([]byte{0xff, 0x0f, 0x49, 0x02, 0xff, 0x0f, 0x49, 0x04}, WithMaxSize(4))
+	for i := range 2 {
+		if err := d.ReadHeader(); err != nil {
+			t.Fatalf("ReadHeader %d failed: %v", i, err)
+		}
+		if _, err := d.ReadValue(); err != nil {
+			t.Fatalf("ReadValue %d failed: %v", i, err)
+		}
+	}
+}
+
+func TestZeroDepthAndArrayLenLimits(t *testing.T) {
+	// 0 is a real limit for these two options, as it always was; only
+	// Unlimited disables them.
+	binData, _ := loadFixture(t, "array-dense")
+	if _, err := Deserialize(binData, WithMaxDepth(0)); !errors.Is(err, ErrMaxDepthExceeded) {
+		t.Errorf("WithMaxDepth(0): expected ErrMaxDepthExceeded, got %v", err)
+	}
+	if _, err := Deserialize(binData, WithMaxArrayLen(0)); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("WithMaxArrayLen(0): expected ErrLimitExceeded, got %v", err)
+	}
+	if _, err := Deserialize(binData, WithMaxDepth(Unlimited), WithMaxArrayLen(Unlimited)); err != nil {
+		t.Errorf("Unlimited: unexpected error: %v", err)
+	}
+	if _, err := Deserialize([]byte{0xff, 0x0f, 0x41, 0x00, 0x24, 0x00, 0x00}, WithMaxArrayLen(0)); err != nil {
+		t.Errorf("WithMaxArrayLen(0) rejected an empty array: %v", err)
+	}
+}
+
+func TestWithLimits(t *testing.T) {
+	if got := DefaultLimits(); got.MaxDepth != 1000 || got.MaxArrayLen != DefaultMaxArrayLen ||
+		got.MaxObjectKeys != DefaultMaxObjectKeys || got.MaxSize != 0 {
+		t.Errorf("unexpected DefaultLimits: %+v", got)
+	}
+
+	tests := []struct {
+		fixture string
+		limits  func(*Limits)
+	}{
+		{"object-simple", func(l *Limits) { l.MaxObjectKeys = 1 }},
+		{"string-10k", func(l *Limits) { l.MaxStringLen = 100 }},
+		{"array-dense", func(l *Limits) { l.MaxTotalValues = 3 }},
+		{"array-dense", func(l *Limits) { l.MaxArrayLen = 2 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			binData, _ := loadFixture(t, tt.fixture)
+			if _, err := Deserialize(binData, WithLimits(DefaultLimits())); err != nil {
+				t.Fatalf("unexpected error with default limits: %v", err)
+			}
+
+			limits := DefaultLimits()
+			tt.limits(&limits)
+			if _, err := Deserialize(binData, WithLimits(limits)); !errors.Is(err, ErrLimitExceeded) {
+				t.Errorf("Deserialize: expected ErrLimitExceeded, got %v", err)
+			}
+
+			tok := NewTokenizer(binData, WithLimits(limits))
+			var err error
+			for err == nil {
+				_, err = tok.Next()
+			}
+			if !errors.Is(err, ErrLimitExceeded) {
+				t.Errorf("Tokenizer: expected ErrLimitExceeded, got %v", err)
+			}
+		})
+	}
+
+	t.Run("single option overrides", func(t *testing.T) {
+		binData, _ := loadFixture(t, "object-simple")
+		limits := DefaultLimits()
+		limits.MaxObjectKeys = 1
+		if _, err := Deserialize(binData, WithLimits(limits), WithMaxObjectKeys(10)); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestWithSetDuplicates(t *testing.T) {
+	// new Set([1, 1.0, 2]) with the duplicate encoded as a double.
+	data := []byte{
+		0xff, 0x0f, 0x27,
+		0x49, 0x02,
+		0x4e, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf0, 0x3f,
+		0x49, 0x04,
+		0x2c, 0x03,
+	}
+
+	tests := []struct {
+		policy DuplicatePolicy
+		want   int
+		err    error
+	}{
+		{DuplicatesKeep, 3, nil},
+		{DuplicatesDrop, 2, nil},
+		{DuplicatesError, 0, ErrMalformedData},
+	}
+
+	for _, tt := range tests {
+		v, err := Deserialize(data, WithSetDuplicates(tt.policy))
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("policy %d: expected %v, got %v", tt.policy, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("policy %d: unexpected error: %v", tt.policy, err)
+		}
+		if got := len(v.Interface().(*JSSet).Values); got != tt.want {
+			t.Errorf("policy %d: got %d values, want %d", tt.policy, got, tt.want)
+		}
+	}
+}
+
+func TestWithCollectionSizeHint(t *testing.T) {
+	// new Set([1, 1.0, 2]) and new Map([[1, 2]]) in an array.
+	data := []byte{
+		0xff, 0x0f, 0x41, 0x02,
+		0x27, 0x49, 0x02, 0x4e, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf0, 0x3f, 0x49, 0x04, 0x2c, 0x03,
+		0x3b, 0x49, 0x02, 0x49, 0x04, 0x3a, 0x02,
+		0x24, 0x00, 0x02,
+	}
+
+	for _, hint := range []int{-1, 0, 2, 1 << 30} {
+		v, err := Deserialize(data, WithCollectionSizeHint(hint))
+		if err != nil {
+			t.Fatalf("hint %d: Deserialize failed: %v", hint, err)
+		}
+		set := v.AsArray()[0].Interface().(*JSSet)
+		jsMap := v.AsArray()[1].Interface().(*JSMap)
+		if len(set.Values) != 3 || len(jsMap.Entries) != 1 {
+			t.Fatalf("hint %d: got %d set values and %d map entries", hint, len(set.Values), len(jsMap.Entries))
+		}
+		// The reservation never exceeds what the input could hold.
+		if cap(set.Values) > len(data) || cap(jsMap.Entries) > len(data)/2 {
+			t.Errorf("hint %d: capacities %d and %d exceed the input", hint, cap(set.Values), cap(jsMap.Entries))
+		}
+		if hint == 2 && (cap(set.Values) < 2 || cap(jsMap.Entries) != 2) {
+			t.Errorf("hint 2: capacities %d and %d", cap(set.Values), cap(jsMap.Entries))
+		}
+	}
+}
+
+func TestWithDisabledTypes(t *testing.T) {
+	buf := ArrayBuffer(make([]byte, 8))
+	u8, _ := NewView(buf, "Uint8Array", 0, 4)
+	dv, _ := NewView(buf, "DataView", 4, 4)
+	// /a/g, inside an object
+	regexp := []byte{0xff, 0x0f, 0x6f, 0x22, 0x01, 0x72, 0x52, 0x22, 0x01, 0x61, 0x01, 0x7b, 0x01}
+
+	tests := []struct {
+		name     string
+		value    Value
+		data     []byte
+		disabled []Type
+		wantErr  bool
+	}{
+		{"allowed", Array([]Value{Int32(1), String("a")}), nil, []Type{TypeRegExp, TypeError}, false},
+		{"regexp", Value{}, regexp, []Type{TypeRegExp}, true},
+		{"nested double", Object(map[string]Value{"x": Array([]Value{Double(1.5)})}), nil, []Type{TypeDouble}, true},
+		{"buffer", Array([]Value{u8}), nil, []Type{TypeArrayBuffer}, true},
+		{"typed array", Array([]Value{u8}), nil, []Type{TypeTypedArray}, true},
+		{"data view only", Array([]Value{u8}), nil, []Type{TypeDataView}, false},
+		{"data view", Array([]Value{u8, dv}), nil, []Type{TypeDataView}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.data
+			if data == nil {
+				var err error
+				if data, err = Serialize(tt.value); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err := Deserialize(data); err != nil {
+				t.Fatalf("without the option: %v", err)
+			}
+			_, err := Deserialize(data, WithDisabledTypes(tt.disabled...))
+			if tt.wantErr != errors.Is(err, ErrDisabledType) {
+				t.Errorf("got %v, want ErrDisabledType: %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	_, err := Deserialize(regexp, WithDisabledTypes(TypeRegExp))
+	if err == nil || !strings.Contains(err.Error(), "RegExp at position 6") {
+		t.Errorf("error %v does not name the type and position", err)
+	}
+}
+
+func TestWithKeyFilter(t *testing.T) {
+	data, err := SerializeGo(map[string]interface{}{
+		"id":    1,
+		"name":  "Alice",
+		"items": []interface{}{map[string]interface{}{"id": 2, "secret": "s"}},
+		"meta":  map[string]interface{}{"id": 3},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls []string
+	keep := func(path, key string) bool {
+		calls = append(calls, path+":"+key)
+		return path == "" && (key == "id" || key == "items") || strings.HasPrefix(path, "items[") && key == "id"
+	}
+	v, err := Deserialize(data, WithKeyFilter(keep))
+	if err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	want := Object(map[string]Value{
+		"id":    Int32(1),
+		"items": Array([]Value{Object(map[string]Value{"id": Int32(2)})}),
+	})
+	if got, want := mustKey(t, v), mustKey(t, want); got != want {
+		t.Errorf("got %s, want %s", got.Form, want.Form)
+	}
+	for _, c := range []string{":name", "items[0]:secret", "meta:id"} {
+		if !slices.Contains(calls, c) {
+			t.Errorf("filter not called with %q; calls %v", c, calls)
+		}
+	}
+
+	// const o = {a: 1}; {drop: o, keep: o}: the dropped object still
+	// resolves the later reference.
+	data, _ = hex.DecodeString("ff0f6f220464726f706f22016149027b0122046b6565705e017b02")
+	v, err = Deserialize(data, WithKeyFilter(func(path, key string) bool { return key != "drop" }))
+	if err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	if got := v.Keys(); len(got) != 1 || got[0] != "keep" || v.AsObject()["keep"].AsObject()["a"].AsInt32() != 1 {
+		t.Errorf("got %v", v)
+	}
+}
+
+func TestWithObjectHook(t *testing.T) {
+	// const o = {a: 1}; {drop: o, keep: o}
+	data, _ := hex.DecodeString("ff0f6f220464726f706f22016149027b0122046b6565705e017b02")
+	var paths []string
+	hook := func(path string, obj Value) (Value, error) {
+		paths = append(paths, path)
+		if path == "drop" {
+			return Object(map[string]Value{"a": Int32(2)}), nil
+		}
+		return obj, nil
+	}
+	v, err := Deserialize(data, WithObjectHook(hook))
+	if err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	if !slices.Equal(paths, []string{"drop", ""}) {
+		t.Errorf("hook called at %q, want [drop \"\"]", paths)
+	}
+	obj := v.AsObject()
+	if !Same(obj["drop"], obj["keep"]) || obj["keep"].Field("a").AsInt32() != 2 {
+		t.Errorf("back-reference does not see the hook's result: %v", v.GoString())
+	}
+
+	errHook := errors.New("hook failed")
+	_, err = Deserialize(data, WithObjectHook(func(string, Value) (Value, error) { return Value{}, errHook }))
+	if !errors.Is(err, errHook) {
+		t.Errorf("expected errHook, got %v", err)
+	}
+}
+
+func mustKey(t *testing.T, v Value) ValueKey {
+	t.Helper()
+	k, err := Key(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestWithBufferAllocator(t *testing.T) {
+	big := ArrayBuffer([]byte{1, 2, 3, 4, 5, 6, 7, 8})
+	view, err := NewView(big, "Uint8Array", 2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := Serialize(Array([]Value{ArrayBuffer([]byte{9}), view, ArrayBuffer(nil)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Serve buffers of 8 bytes or more from an arena, leave the rest to Go.
+	pool := make([]byte, 64)
+	arena := pool
+	var sizes []int
+	alloc := func(n int) []byte {
+		sizes = append(sizes, n)
+		if n < 8 {
+			return nil
+		}
+		b := arena[:n:n]
+		arena = arena[n:]
+		return b
+	}
+
+	v, err := Deserialize(data, WithBufferAllocator(alloc))
+	if err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	if len(sizes) != 3 || sizes[0] != 1 || sizes[1] != 8 || sizes[2] != 0 {
+		t.Errorf("allocator called with %v, want [1 8 0]", sizes)
+	}
+	elems := v.AsArray()
+	if got := elems[0].Interface().([]byte); !bytes.Equal(got, []byte{9}) {
+		t.Errorf("small buffer = %v", got)
+	}
+	got := elems[1].Interface().(*ArrayBufferView)
+	if !bytes.Equal(got.Buffer, []byte{1, 2, 3, 4, 5, 6, 7, 8}) || got.ByteOffset != 2 || got.ByteLength != 4 {
+		t.Errorf("view = %+v", got)
+	}
+	if &got.Buffer[0] != &pool[0] {
+		t.Error("large buffer was not allocated from the arena")
+	}
+	if b := elems[2].Interface().([]byte); b == nil || len(b) != 0 {
+		t.Errorf("empty buffer = %#v, want empty non-nil", b)
+	}
+}
+
+func TestWithLogger(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    string
+	}{
+		{"array-with-properties", "ignored array property"},
+		{"array-sparse-with-props", "ignored array property"},
+		{"string-unpaired-high-surrogate", "unpaired surrogate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			binData, _ := loadFixture(t, tt.fixture)
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+			if _, err := Deserialize(binData, WithLogger(logger)); err != nil {
+				t.Fatalf("Deserialize failed: %v", err)
+			}
+			if !strings.Contains(buf.String(), tt.want) {
+				t.Errorf("expected log to contain %q, got:\n%s", tt.want, buf.String())
+			}
+		})
+	}
+
+	t.Run("clean-payload", func(t *testing.T) {
+		binData, _ := loadFixture(t, "object-nested")
+		var buf bytes.Buffer
+		logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+		if _, err := Deserialize(binData, WithLogger(logger)); err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("expected no anomalies, got:\n%s", buf.String())
+		}
+	})
 }
 
 func TestDeserializeSetOfObjects(t *testing.T) {
@@ -1555,11 +2425,9 @@ func TestDeserializeInvalidDate(t *testing.T) {
 		t.Fatalf("expected Date, got %s", v.Type())
 	}
 
-	// Invalid dates should have a time value of NaN, which in Go becomes Unix time 0
-	date := v.AsDate()
-	if !date.IsZero() {
-		// Or check if it's a very weird value
-		t.Logf("Invalid date deserialized to: %v", date)
+	// Invalid dates have a time value of NaN; AsDate gives the zero time
+	if !v.IsInvalidDate() || !v.AsDate().IsZero() {
+		t.Errorf("Invalid date deserialized to: %v", v.AsDate())
 	}
 }
 
@@ -1728,3 +2596,78 @@ func TestLatin1StringRoundTrip(t *testing.T) {
 		})
 	}
 }
+
+func TestWithTrace(t *testing.T) {
+	var buf bytes.Buffer
+	data := []byte{0xff, 0x0f, 0x6f, 0x22, 0x01, 0x61, 0x41, 0x01, 0x49, 0x02, 0x24, 0x00, 0x01, 0x7b, 0x01}
+	if _, err := Deserialize(data, WithTrace(&buf)); err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	want := `@0 depth=0 Version 15
+@2 depth=1 BeginJSObject
+@3 depth=2 OneByteString "a"
+@6 depth=2 BeginDenseArray
+@8 depth=3 Int32 1
+@10 depth=2 end BeginDenseArray Array[1]
+@13 depth=1 end BeginJSObject Object{1 properties}
+`
+	if buf.String() != want {
+		t.Errorf("trace:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if _, err := Deserialize(data[:9], WithTrace(&buf)); err == nil {
+		t.Fatal("expected error for truncated data")
+	}
+	if !strings.Contains(buf.String(), "@9 depth=3 error: ") {
+		t.Errorf("trace should end with the error:\n%s", buf.String())
+	}
+}
+
+func BenchmarkDeserializeIntArray(b *testing.B) {
+	elems := make([]Value, 10000)
+	for i := range elems {
+		elems[i] = Int32(int32(i * 7919))
+	}
+	data, err := Serialize(Array(elems))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Deserialize(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDeserializeLargeMap(b *testing.B) {
+	const n = 100_000
+	entries := make([]MapEntry, n)
+	for i := range entries {
+		entries[i] = MapEntry{Key: Int32(int32(i)), Value: Int32(int32(i * 7919))}
+	}
+	data, err := Serialize(Value{typ: TypeMap, data: &JSMap{Entries: entries}})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, bm := range []struct {
+		name string
+		opts []Option
+	}{
+		{"no-hint", nil},
+		{"hint", []Option{WithCollectionSizeHint(n)}},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := Deserialize(data, bm.opts...); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
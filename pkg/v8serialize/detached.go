@@ -0,0 +1,103 @@
+package v8serialize
+
+import "fmt"
+
+// DetachedArrayBuffer stands in for an ArrayBuffer that was transferred
+// alongside the payload (postMessage's transfer list) rather than copied
+// into it. The payload only carries the buffer's index in the transfer
+// list, so its contents are unavailable.
+type DetachedArrayBuffer struct {
+	TransferID uint32 // index of the buffer in the sender's transfer list
+
+	// View, ByteOffset and ByteLength describe the TypedArray or DataView
+	// ("Uint8Array", ...) encoded over the buffer, if any. View is empty
+	// for a bare ArrayBuffer.
+	View       string
+	ByteOffset int
+	ByteLength int
+}
+
+func (b *DetachedArrayBuffer) String() string {
+	if b.View == "" {
+		return fmt.Sprintf("DetachedArrayBuffer(#%d)", b.TransferID)
+	}
+	return fmt.Sprintf("%s(DetachedArrayBuffer(#%d), %d, %d)", b.View, b.TransferID, b.ByteOffset, b.ByteLength)
+}
+
+// Detached returns a placeholder for the ArrayBuffer at transferID in the
+// receiver's transfer list. The serializer writes it as a transfer
+// reference, so payloads decoded with WithDetachedArrayBuffers re-encode
+// unchanged.
+func Detached(b DetachedArrayBuffer) Value {
+	return Value{typ: TypeDetachedArrayBuffer, data: &b}
+}
+
+// WithDetachedArrayBuffers decodes transferred ArrayBuffers, and views over
+// them, as TypeDetachedArrayBuffer placeholders holding a
+// *DetachedArrayBuffer, so consumers can decide per field whether a missing
+// buffer matters. By default such payloads fail with ErrUnexpectedTag,
+// since their contents cannot be recovered.
+func WithDetachedArrayBuffers() Option {
+	return func(d *Deserializer) {
+		d.detachedBuffers = true
+	}
+}
+
+// readTransferredArrayBuffer reads a transfer ID and the native view that
+// may follow it.
+func (d *Deserializer) readTransferredArrayBuffer() (Value, error) {
+	id, err := d.reader.ReadVarint32()
+	if err != nil {
+		return Value{}, err
+	}
+	buf := Detached(DetachedArrayBuffer{TransferID: id})
+	d.objects = append(d.objects, buf)
+	return d.viewOf(buf)
+}
+
+// readDetachedView reads a native view over a transferred buffer.
+func (d *Deserializer) readDetachedView(buf *DetachedArrayBuffer) (Value, error) {
+	typeName, offset, length, err := d.readViewRecord()
+	if err != nil {
+		return Value{}, err
+	}
+	v := Detached(DetachedArrayBuffer{TransferID: buf.TransferID, View: typeName, ByteOffset: int(offset), ByteLength: int(length)})
+	d.objects = append(d.objects, v)
+	return v, nil
+}
+
+// transferKey identifies a transferred buffer in Serializer.objects, so a
+// buffer is written once and referenced afterwards, as V8 does. Views are
+// keyed by their *DetachedArrayBuffer, so a view Value written twice is
+// also referenced rather than repeated.
+type transferKey uint32
+
+// writeDetachedArrayBuffer writes a transfer reference, or a reference to
+// one written earlier, and for views the view record.
+func (s *Serializer) writeDetachedArrayBuffer(b *DetachedArrayBuffer) error {
+	var subTag byte
+	if b.View != "" {
+		var ok bool
+		if subTag, ok = viewSubTag(b.View); !ok {
+			return cloneError("TypedArray of type %q", b.View)
+		}
+		if id, ok := s.objects[b]; ok {
+			s.writeTag(tagObjectReference)
+			s.writer.WriteVarint32(id)
+			return nil
+		}
+	}
+	if id, ok := s.objects[transferKey(b.TransferID)]; ok {
+		s.writeTag(tagObjectReference)
+		s.writer.WriteVarint32(id)
+	} else {
+		s.objects[transferKey(b.TransferID)] = s.nextID
+		s.writeTag(tagArrayBufferTransfer)
+		s.writer.WriteVarint32(b.TransferID)
+	}
+	if b.View != "" {
+		s.objects[b] = s.nextID
+		s.writeViewRecord(subTag, b.ByteOffset, b.ByteLength)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package v8serialize
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestWithDetachedArrayBuffers(t *testing.T) {
+	// Node: s.transferArrayBuffer(3, ab);
+	// s.writeValue({a: ab, b: new Uint8Array(ab, 2, 4), c: ab})
+	data, _ := hex.DecodeString("ff0f6f22016174032201625e0156420204002201635e017b03")
+
+	if _, err := Deserialize(data); !errors.Is(err, ErrUnexpectedTag) {
+		t.Fatalf("default: expected ErrUnexpectedTag, got %v", err)
+	}
+
+	v, err := Deserialize(data, WithDetachedArrayBuffers())
+	if err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	obj := v.AsObject()
+	want := map[string]DetachedArrayBuffer{
+		"a": {TransferID: 3},
+		"b": {TransferID: 3, View: "Uint8Array", ByteOffset: 2, ByteLength: 4},
+		"c": {TransferID: 3},
+	}
+	for key, w := range want {
+		got := obj[key]
+		if got.Type() != TypeDetachedArrayBuffer {
+			t.Fatalf("%s: type %s, want DetachedArrayBuffer", key, got.Type())
+		}
+		if b := got.Interface().(*DetachedArrayBuffer); *b != w {
+			t.Errorf("%s = %+v, want %+v", key, *b, w)
+		}
+	}
+	if got, want := obj["b"].String(), "Uint8Array(DetachedArrayBuffer(#3), 2, 4)"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	// Placeholders re-encode to the same bytes.
+	out, err := Serialize(v)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if got := bytesToHex(out); got != bytesToHex(data) {
+		t.Errorf("re-encoded %s, want %s", got, bytesToHex(data))
+	}
+}
+
+func TestDetached(t *testing.T) {
+	// Node: s.transferArrayBuffer(1, ab); s.writeValue([new Uint8Array(ab, 2, 4)])
+	v := Array([]Value{Detached(DetachedArrayBuffer{TransferID: 1, View: "Uint8Array", ByteOffset: 2, ByteLength: 4})})
+	data, err := Serialize(v)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if got, want := bytesToHex(data), "ff0f410174015642020400240001"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	// Node: s.transferArrayBuffer(1, ab); two views over ab, then one
+	// view twice. The buffer is written once, and a repeated view is a
+	// reference to it.
+	first := Detached(DetachedArrayBuffer{TransferID: 1, View: "Uint8Array", ByteLength: 2})
+	second := Detached(DetachedArrayBuffer{TransferID: 1, View: "Uint8Array", ByteOffset: 2, ByteLength: 2})
+	for _, tt := range []struct {
+		elems []Value
+		want  string
+	}{
+		{[]Value{first, second}, "ff0f4102740156420002005e015642020200240002"},
+		{[]Value{first, first}, "ff0f4102740156420002005e02240002"},
+	} {
+		data, err := Serialize(Array(tt.elems))
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		if got := bytesToHex(data); got != tt.want {
+			t.Errorf("got %s, want %s", got, tt.want)
+		}
+		v, err := Deserialize(data, WithDetachedArrayBuffers())
+		if err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+		if out, _ := Serialize(v); bytesToHex(out) != tt.want {
+			t.Errorf("round trip: got %s, want %s", bytesToHex(out), tt.want)
+		}
+	}
+
+	if _, err := Serialize(Detached(DetachedArrayBuffer{View: "Int128Array"})); !errors.Is(err, ErrDataClone) {
+		t.Errorf("unknown view: expected ErrDataClone, got %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package v8serialize
+
+import "io"
+
+// Instruction is one tag of a disassembled payload with its operands.
+type Instruction struct {
+	Offset  int      // byte offset of the tag
+	End     int      // offset just past the instruction and any padding after it
+	Tag     byte     // wire tag (tagVersion for the header)
+	TagName string   // TagName(Tag)
+	Depth   int      // number of enclosing containers
+	Key     bool     // the value is an object property key or sparse array index
+	Operand Value    // decoded value of a scalar, key or view; for references, the referenced scalar
+	Args    []uint32 // numeric operands in wire order (see Disassemble)
+}
+
+// Disassemble decodes a payload into a flat list of instructions, one per
+// token of a Tokenizer plus one for the header, for tools that inspect or
+// rewrite payloads at the wire level. Containers appear as their begin and
+// end tags; every other value, including its nested parts such as an
+// Error's cause, is a single instruction with the decoded Operand.
+//
+// Args holds the numeric operands:
+//
+//	Version            [version]
+//	ObjectReference    [object ID]; for a view over the referenced
+//	                   ArrayBuffer, [buffer ID, byte offset, byte length]
+//	Begin*Array        [length]
+//	EndDenseArray      [count, length]
+//	EndSparseArray     [count, length]
+//	EndJSObject        [count]
+//	EndJSMap/EndJSSet  [count]
+//
+// Transferred and shared ArrayBuffers, whose memory is not in the payload,
+// appear as their placeholders (see WithDetachedArrayBuffers and
+// WithSharedArrayBuffers). On a decode error the instructions read so far
+// are returned with the error, so callers can see how far the payload was
+// readable.
+func Disassemble(data []byte) ([]Instruction, error) {
+	tok := NewTokenizer(data, WithDetachedArrayBuffers(), WithSharedArrayBuffers())
+	var out []Instruction
+	for {
+		end := tok.d.reader.Pos() // end of the previous instruction
+		t, err := tok.Next()
+		if err == io.EOF {
+			closeInstruction(out, len(data))
+			return out, nil
+		}
+		if err != nil {
+			closeInstruction(out, end)
+			return out, err
+		}
+		if len(out) == 0 && t.Offset > 0 {
+			out = append(out, Instruction{
+				Tag:     tagVersion,
+				TagName: TagName(tagVersion),
+				Args:    []uint32{tok.Version()},
+			})
+		}
+		closeInstruction(out, t.Offset)
+		out = append(out, instructionOf(t, tokenDepth(t, tok.Depth())))
+	}
+}
+
+// closeInstruction sets the end of the last instruction in out, if any.
+func closeInstruction(out []Instruction, end int) {
+	if len(out) > 0 {
+		out[len(out)-1].End = end
+	}
+}
+
+// tokenDepth returns the nesting level of t; Begin tokens have already been
+// pushed when the Tokenizer returns them.
+func tokenDepth(t Token, depth int) int {
+	switch t.Kind {
+	case TokenBeginObject, TokenBeginArray, TokenBeginMap, TokenBeginSet:
+		return depth - 1
+	}
+	return depth
+}
+
+func instructionOf(t Token, depth int) Instruction {
+	ins := Instruction{
+		Offset:  t.Offset,
+		Tag:     t.Tag,
+		TagName: TagName(t.Tag),
+		Depth:   depth,
+		Key:     t.Kind == TokenKey,
+		Operand: t.Value,
+	}
+	switch t.Kind {
+	case TokenReference:
+		ins.Args = []uint32{t.RefID}
+	case TokenView:
+		ins.Args = []uint32{t.RefID, 0, 0}
+		switch view := t.Value.data.(type) {
+		case *ArrayBufferView:
+			ins.Args[1], ins.Args[2] = uint32(view.ByteOffset), uint32(view.ByteLength)
+		case *DetachedArrayBuffer:
+			ins.Args[1], ins.Args[2] = uint32(view.ByteOffset), uint32(view.ByteLength)
+		case *SharedArrayBufferRef:
+			ins.Args[1], ins.Args[2] = uint32(view.ByteOffset), uint32(view.ByteLength)
+		}
+	case TokenBeginArray:
+		ins.Args = []uint32{t.Length}
+	case TokenEndArray:
+		ins.Args = []uint32{t.Count, t.Length}
+	case TokenEndObject, TokenEndMap, TokenEndSet:
+		ins.Args = []uint32{t.Count}
+	}
+	return ins
+}
@@ -0,0 +1,97 @@
+package v8serialize
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDisassemble(t *testing.T) {
+	// {a: [1, "x"], b: <ref to the array>}
+	data := []byte{0xff, 0x0f, 0x6f,
+		0x22, 0x01, 0x61, 0x41, 0x02, 0x49, 0x02, 0x22, 0x01, 0x78, 0x24, 0x00, 0x02,
+		0x22, 0x01, 0x62, 0x5e, 0x01,
+		0x7b, 0x02}
+
+	got, err := Disassemble(data)
+	if err != nil {
+		t.Fatalf("Disassemble failed: %v", err)
+	}
+
+	type row struct {
+		Offset, End int
+		TagName     string
+		Depth       int
+		Key         bool
+		Args        []uint32
+	}
+	want := []row{
+		{0, 2, "Version", 0, false, []uint32{15}},
+		{2, 3, "BeginJSObject", 0, false, nil},
+		{3, 6, "OneByteString", 1, true, nil},
+		{6, 8, "BeginDenseArray", 1, false, []uint32{2}},
+		{8, 10, "Int32", 2, false, nil},
+		{10, 13, "OneByteString", 2, false, nil},
+		{13, 16, "EndDenseArray", 1, false, []uint32{0, 2}},
+		{16, 19, "OneByteString", 1, true, nil},
+		{19, 21, "ObjectReference", 1, false, []uint32{1}},
+		{21, 23, "EndJSObject", 0, false, []uint32{2}},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d instructions, want %d: %+v", len(got), len(want), got)
+	}
+	for i, ins := range got {
+		r := row{ins.Offset, ins.End, ins.TagName, ins.Depth, ins.Key, ins.Args}
+		if !reflect.DeepEqual(r, want[i]) {
+			t.Errorf("instruction %d = %+v, want %+v", i, r, want[i])
+		}
+		if ins.TagName != TagName(ins.Tag) {
+			t.Errorf("instruction %d: TagName %q does not match tag 0x%02x", i, ins.TagName, ins.Tag)
+		}
+	}
+	if got[4].Operand.AsInt32() != 1 || got[7].Operand.AsString() != "b" {
+		t.Errorf("operands = %v, %v", got[4].Operand, got[7].Operand)
+	}
+}
+
+func TestDisassembleTruncated(t *testing.T) {
+	got, err := Disassemble([]byte{0xff, 0x0f, 0x41, 0x02, 0x54})
+	if !errors.Is(err, ErrMalformedData) {
+		t.Fatalf("expected decode error, got %v", err)
+	}
+	if len(got) != 3 || got[2].TagName != "True" || got[2].End != 5 {
+		t.Errorf("partial instructions = %+v", got)
+	}
+}
+
+func TestDisassemblePooledBuffers(t *testing.T) {
+	data, _ := loadFixture(t, "native-buffers-pooled")
+	got, err := Disassemble(data)
+	if err != nil {
+		t.Fatalf("Disassemble failed: %v", err)
+	}
+	if len(got) != 5 {
+		t.Fatalf("got %d instructions, want 5: %+v", len(got), got)
+	}
+	// The second Buffer refers to the pool (object 1) and views 4 bytes of it.
+	ref := got[3]
+	if ref.TagName != "ObjectReference" || !reflect.DeepEqual(ref.Args, []uint32{1, 5072, 4}) {
+		t.Errorf("reference = %s %v, want ObjectReference [1 5072 4]", ref.TagName, ref.Args)
+	}
+	if view, ok := ref.Operand.Interface().(*ArrayBufferView); !ok || string(view.Slice()) != "next" {
+		t.Errorf("operand = %v, want the view over \"next\"", ref.Operand)
+	}
+
+	tok := NewTokenizer(data)
+	var kinds []TokenKind
+	for {
+		tk, err := tok.Next()
+		if err != nil {
+			break
+		}
+		kinds = append(kinds, tk.Kind)
+	}
+	if want := []TokenKind{TokenBeginArray, TokenScalar, TokenView, TokenEndArray}; !reflect.DeepEqual(kinds, want) {
+		t.Errorf("token kinds = %v, want %v", kinds, want)
+	}
+}
@@ -0,0 +1,69 @@
+package v8serialize
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DumpAnnotated writes a human-readable listing of a payload to w, one line
+// per token: the offset, the bytes the token occupies and the tag name with
+// its decoded value, indented by nesting level:
+//
+//	0000  ff0f                     Version 15
+//	0002  6f                       BeginJSObject
+//	0003  220161                     OneByteString "a"
+//	0006  4902                       Int32 1
+//	0008  7b01                     EndJSObject count=1
+//
+// Padding bytes are shown on the line of the preceding token. Lines written
+// before a decode error are kept, so the listing shows how far the payload
+// was readable; the error is returned.
+func DumpAnnotated(data []byte, w io.Writer) error {
+	instructions, err := Disassemble(data)
+	for _, ins := range instructions {
+		line := strings.Repeat("  ", ins.Depth) + dumpInstruction(ins)
+		if _, werr := fmt.Fprintf(w, "%04x  %-24x %s\n", ins.Offset, data[ins.Offset:ins.End], line); werr != nil {
+			return werr
+		}
+	}
+	return err
+}
+
+func dumpInstruction(ins Instruction) string {
+	switch ins.Tag {
+	case tagVersion:
+		return fmt.Sprintf("%s %d", ins.TagName, ins.Args[0])
+	case tagObjectReference:
+		if len(ins.Args) == 3 {
+			return fmt.Sprintf("%s #%d %s", ins.TagName, ins.Args[0], dumpValue(ins.Operand))
+		}
+		return fmt.Sprintf("%s #%d", ins.TagName, ins.Args[0])
+	case tagBeginDenseArray, tagBeginSparseArray:
+		return fmt.Sprintf("%s length=%d", ins.TagName, ins.Args[0])
+	case tagEndDenseArray, tagEndSparseArray:
+		return fmt.Sprintf("%s count=%d length=%d", ins.TagName, ins.Args[0], ins.Args[1])
+	case tagEndJSObject, tagEndMap, tagEndSet:
+		return fmt.Sprintf("%s count=%d", ins.TagName, ins.Args[0])
+	case tagBeginJSObject, tagBeginMap, tagBeginSet:
+		return ins.TagName
+	}
+	return ins.TagName + " " + dumpValue(ins.Operand)
+}
+
+// dumpValue formats scalars without pointers, so dumps are stable.
+func dumpValue(v Value) string {
+	switch data := v.data.(type) {
+	case *RegExp:
+		return "/" + data.Pattern + "/" + data.Flags
+	case *JSError:
+		return fmt.Sprintf("%s: %q", data.Name, data.Message)
+	case *ArrayBufferView:
+		return fmt.Sprintf("%s %d bytes", data.Type, data.ByteLength)
+	case *BoxedPrimitive:
+		return dumpValue(data.Value)
+	case []byte:
+		return fmt.Sprintf("%d bytes", len(data))
+	}
+	return v.GoString()
+}
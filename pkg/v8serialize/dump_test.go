@@ -0,0 +1,73 @@
+package v8serialize
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDumpAnnotated(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"object", []byte{0xff, 0x0f, 0x6f, 0x22, 0x01, 0x61, 0x49, 0x02, 0x7b, 0x01},
+			"0000  ff0f                     Version 15\n" +
+				"0002  6f                       BeginJSObject\n" +
+				"0003  220161                     OneByteString \"a\"\n" +
+				"0006  4902                       Int32 1\n" +
+				"0008  7b01                     EndJSObject count=1\n"},
+		{"padding", []byte{0xff, 0x0f, 0x41, 0x02, 0x54, 0x00, 0x63, 0x02, 0xe9, 0x00, 0x24, 0x00, 0x02},
+			"0000  ff0f                     Version 15\n" +
+				"0002  4102                     BeginDenseArray length=2\n" +
+				"0004  5400                       True true\n" +
+				"0006  6302e900                   TwoByteString \"é\"\n" +
+				"000a  240002                   EndDenseArray count=0 length=2\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := DumpAnnotated(tt.data, &buf); err != nil {
+				t.Fatalf("DumpAnnotated failed: %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestDumpAnnotatedTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	err := DumpAnnotated([]byte{0xff, 0x0f, 0x41, 0x02, 0x54}, &buf)
+	if !errors.Is(err, ErrMalformedData) {
+		t.Fatalf("expected decode error, got %v", err)
+	}
+	want := "0000  ff0f                     Version 15\n" +
+		"0002  4102                     BeginDenseArray length=2\n" +
+		"0004  54                         True true\n"
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestDumpAnnotatedPooledBuffers(t *testing.T) {
+	// Node: [Buffer.from('pooled'), Buffer.from('next')] written natively:
+	// the 8 KiB pool, then a reference to it with a second view record.
+	data, _ := loadFixture(t, "native-buffers-pooled")
+	var buf bytes.Buffer
+	if err := DumpAnnotated(data, &buf); err != nil {
+		t.Fatalf("DumpAnnotated failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	want := []string{
+		"200d  5e015642d0270400           ObjectReference #1 Uint8Array 4 bytes",
+		"2015  240002                   EndDenseArray count=0 length=2",
+	}
+	if len(lines) != 5 || !reflect.DeepEqual(lines[3:], want) {
+		t.Errorf("got:\n%s\nwant last lines:\n%s", buf.String(), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,90 @@
+package v8serialize
+
+import "io"
+
+// Handler receives callbacks from DecodeEvents as the payload is read.
+// Returning an error from any callback stops decoding and DecodeEvents
+// returns that error unchanged.
+//
+// Embed NopHandler to implement only the callbacks you need.
+type Handler interface {
+	OnObjectStart() error
+	OnObjectEnd() error
+	OnArrayStart(length uint32) error
+	OnArrayEnd() error
+	OnMapStart() error
+	OnMapEnd() error
+	OnSetStart() error
+	OnSetEnd() error
+
+	// OnKey is called for object property keys and sparse array indices.
+	OnKey(key Value) error
+
+	// OnScalar is called for every value that is not a container.
+	OnScalar(v Value) error
+
+	// OnReference is called for back-references to earlier objects.
+	OnReference(id uint32) error
+}
+
+// NopHandler is a Handler whose callbacks do nothing.
+type NopHandler struct{}
+
+func (NopHandler) OnObjectStart() error      { return nil }
+func (NopHandler) OnObjectEnd() error        { return nil }
+func (NopHandler) OnArrayStart(uint32) error { return nil }
+func (NopHandler) OnArrayEnd() error         { return nil }
+func (NopHandler) OnMapStart() error         { return nil }
+func (NopHandler) OnMapEnd() error           { return nil }
+func (NopHandler) OnSetStart() error         { return nil }
+func (NopHandler) OnSetEnd() error           { return nil }
+func (NopHandler) OnKey(Value) error         { return nil }
+func (NopHandler) OnScalar(Value) error      { return nil }
+func (NopHandler) OnReference(uint32) error  { return nil }
+
+// DecodeEvents reads data and reports its structure to h, SAX-style,
+// without building a Value tree. It is built on Tokenizer and accepts the
+// same options as Deserialize.
+func DecodeEvents(data []byte, h Handler, opts ...Option) error {
+	tok := NewTokenizer(data, opts...)
+	for {
+		t, err := tok.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := dispatchToken(h, t); err != nil {
+			return err
+		}
+	}
+}
+
+// dispatchToken invokes the Handler callback for a single token.
+func dispatchToken(h Handler, t Token) error {
+	switch t.Kind {
+	case TokenBeginObject:
+		return h.OnObjectStart()
+	case TokenEndObject:
+		return h.OnObjectEnd()
+	case TokenBeginArray:
+		return h.OnArrayStart(t.Length)
+	case TokenEndArray:
+		return h.OnArrayEnd()
+	case TokenBeginMap:
+		return h.OnMapStart()
+	case TokenEndMap:
+		return h.OnMapEnd()
+	case TokenBeginSet:
+		return h.OnSetStart()
+	case TokenEndSet:
+		return h.OnSetEnd()
+	case TokenKey:
+		return h.OnKey(t.Value)
+	case TokenReference:
+		return h.OnReference(t.RefID)
+	default:
+		return h.OnScalar(t.Value)
+	}
+}
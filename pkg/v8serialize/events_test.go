@@ -0,0 +1,76 @@
+package v8serialize
+
+import (
+	"errors"
+	"testing"
+)
+
+// countingHandler counts objects and string scalars.
+type countingHandler struct {
+	NopHandler
+	objects int
+	keys    []string
+	strings int
+}
+
+func (h *countingHandler) OnObjectStart() error {
+	h.objects++
+	return nil
+}
+
+func (h *countingHandler) OnKey(key Value) error {
+	if key.IsString() {
+		h.keys = append(h.keys, key.AsString())
+	}
+	return nil
+}
+
+func (h *countingHandler) OnScalar(v Value) error {
+	if v.IsString() {
+		h.strings++
+	}
+	return nil
+}
+
+func TestDecodeEvents(t *testing.T) {
+	binData, _ := loadFixture(t, "object-nested")
+	h := &countingHandler{}
+
+	if err := DecodeEvents(binData, h); err != nil {
+		t.Fatalf("DecodeEvents failed: %v", err)
+	}
+
+	if h.objects != 2 {
+		t.Errorf("objects: got %d, want 2", h.objects)
+	}
+	if len(h.keys) != 2 || h.keys[0] != "nested" || h.keys[1] != "inner" {
+		t.Errorf("keys: got %v, want [nested inner]", h.keys)
+	}
+	if h.strings != 1 {
+		t.Errorf("string scalars: got %d, want 1", h.strings)
+	}
+}
+
+// stopHandler aborts at the first scalar.
+type stopHandler struct {
+	NopHandler
+}
+
+var errStop = errors.New("stop")
+
+func (stopHandler) OnScalar(Value) error { return errStop }
+
+func TestDecodeEventsAbort(t *testing.T) {
+	binData, _ := loadFixture(t, "array-dense")
+	if err := DecodeEvents(binData, stopHandler{}); err != errStop {
+		t.Fatalf("expected handler error to be returned unchanged, got %v", err)
+	}
+}
+
+func TestDecodeEventsMalformed(t *testing.T) {
+	data := []byte{0xff, 0x0f, 0x6f, 0x22, 0x01, 'a'} // object truncated after key
+	err := DecodeEvents(data, NopHandler{})
+	if !errors.Is(err, ErrMalformedData) {
+		t.Fatalf("expected ErrMalformedData, got %v", err)
+	}
+}
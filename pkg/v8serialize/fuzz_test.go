@@ -38,14 +38,9 @@ func FuzzDeserialize(f *testing.F) {
 			return // errors are expected for invalid input
 		}
 
-		// Try to convert to Go (may panic for unhashable map keys, which is expected)
-		func() {
-			defer func() {
-				// Recover from panics in ToGo (e.g., unhashable map keys)
-				_ = recover()
-			}()
-			_ = ToGo(val)
-		}()
+		// Converting to Go must not panic either, even for unhashable Map keys
+		_ = ToGo(val)
+		_, _ = ToGoSafe(val)
 
 		// Note: We intentionally skip re-serialization here because:
 		// 1. The deserializer can create circular references (via ObjectReference)
@@ -0,0 +1,260 @@
+package v8serialize
+
+import (
+	"fmt"
+	"go/format"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// goInitialisms are written in upper case in generated Go names, as golint
+// expects: "userId" becomes UserID.
+var goInitialisms = map[string]bool{
+	"Api": true, "Http": true, "Id": true, "Ip": true, "Json": true,
+	"Uri": true, "Url": true, "Uuid": true,
+}
+
+// ToGoStructs writes a Go source file in package pkg with struct
+// definitions for the payloads described by s, as a starting point for
+// typed handling of existing Node payloads. The root type is Payload and
+// nested objects become structs named after their path:
+//
+//	type Payload struct {
+//		ID   int32        `v8:"id"`
+//		User *PayloadUser `v8:"user,omitempty"`
+//	}
+//
+// Fields are tagged v8:"<property>", with omitempty for properties missing
+// from some samples. Optional and nullable fields of scalar or struct type
+// are pointers. Integers stay int32 or uint32 when every sample fit, numbers
+// that were ever fractional become float64, and positions that held several
+// unrelated types, or types with no Go equivalent, fall back to
+// v8serialize.Value.
+func (s *Schema) ToGoStructs(pkg string, w io.Writer) error {
+	g := &goGenerator{used: make(map[string]bool), imports: make(map[string]bool)}
+	var body strings.Builder
+	if len(s.Types) == 1 && s.Types[TypeObject] > 0 && len(s.Properties) > 0 {
+		g.declare("Payload", s)
+	} else {
+		g.used["Payload"] = true
+		fmt.Fprintf(&body, "type Payload %s\n", g.typeOf(s, "Payload", false))
+	}
+	for len(g.queue) > 0 {
+		d := g.queue[0]
+		g.queue = g.queue[1:]
+		body.WriteByte('\n')
+		g.writeStruct(&body, d.name, d.schema)
+	}
+
+	var src strings.Builder
+	fmt.Fprintf(&src, "package %s\n\n", pkg)
+	if len(g.imports) > 0 {
+		var std, other []string
+		for p := range g.imports {
+			if strings.Contains(strings.SplitN(p, "/", 2)[0], ".") {
+				other = append(other, strconv.Quote(p))
+			} else {
+				std = append(std, strconv.Quote(p))
+			}
+		}
+		sort.Strings(std)
+		sort.Strings(other)
+		groups := strings.Join(std, "\n")
+		if len(std) > 0 && len(other) > 0 {
+			groups += "\n\n"
+		}
+		groups += strings.Join(other, "\n")
+		fmt.Fprintf(&src, "import (\n%s\n)\n\n", groups)
+	}
+	src.WriteString(strings.TrimPrefix(body.String(), "\n"))
+
+	out, err := format.Source([]byte(src.String()))
+	if err != nil {
+		return fmt.Errorf("generated Go does not parse: %w", err)
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+type goGenerator struct {
+	used    map[string]bool
+	imports map[string]bool
+	queue   []schemaDecl
+}
+
+// declare reserves a unique struct name for an object schema and queues
+// its declaration.
+func (g *goGenerator) declare(name string, s *Schema) string {
+	unique := name
+	for n := 2; g.used[unique]; n++ {
+		unique = name + strconv.Itoa(n)
+	}
+	g.used[unique] = true
+	g.queue = append(g.queue, schemaDecl{unique, s})
+	return unique
+}
+
+func (g *goGenerator) writeStruct(b *strings.Builder, name string, s *Schema) {
+	keys := make([]string, 0, len(s.Properties))
+	for k := range s.Properties {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fields := make(map[string]bool)
+	fmt.Fprintf(b, "type %s struct {\n", name)
+	for _, k := range keys {
+		prop := s.Properties[k]
+		field := goFieldName(k)
+		for n := 2; fields[field]; n++ {
+			field = goFieldName(k) + strconv.Itoa(n)
+		}
+		fields[field] = true
+
+		tag := k
+		if prop.Optional {
+			tag += ",omitempty"
+		}
+		typ := g.typeOf(prop, name+field, prop.Optional)
+		fmt.Fprintf(b, "\t%s %s `v8:%s`\n", field, typ, strconv.Quote(tag))
+	}
+	b.WriteString("}\n")
+}
+
+// typeOf returns the Go type for the values observed in s. Positions that
+// may be absent or nullish get a pointer when the type itself has no nil.
+func (g *goGenerator) typeOf(s *Schema, name string, optional bool) string {
+	if s == nil || len(s.Types) == 0 {
+		return g.value()
+	}
+	nullable := optional
+	var kinds []Type
+	for _, t := range s.typesByCount() {
+		switch t {
+		case TypeNull, TypeUndefined, TypeHole:
+			nullable = true
+		default:
+			kinds = append(kinds, t)
+		}
+	}
+
+	typ, pointer := g.goType(kinds, s, name)
+	if nullable && pointer {
+		return "*" + typ
+	}
+	return typ
+}
+
+// goType maps the non-nullish types observed at one position to a single
+// Go type, reporting whether that type needs a pointer to express absence.
+func (g *goGenerator) goType(kinds []Type, s *Schema, name string) (string, bool) {
+	if len(kinds) == 0 {
+		return g.value(), false
+	}
+	numeric := true
+	for _, t := range kinds {
+		if t != TypeInt32 && t != TypeUint32 && t != TypeDouble {
+			numeric = false
+		}
+	}
+	if numeric {
+		switch {
+		case s.Types[TypeDouble] > 0:
+			return "float64", true
+		case s.Types[TypeInt32] > 0 && s.Types[TypeUint32] > 0:
+			return "int64", true
+		case s.Types[TypeUint32] > 0:
+			return "uint32", true
+		default:
+			return "int32", true
+		}
+	}
+	if len(kinds) > 1 {
+		return g.value(), false
+	}
+
+	switch kinds[0] {
+	case TypeBool:
+		return "bool", true
+	case TypeString:
+		return "string", true
+	case TypeBigInt:
+		g.imports["math/big"] = true
+		return "*big.Int", false
+	case TypeDate:
+		g.imports["time"] = true
+		return "time.Time", true
+	case TypeObject:
+		if len(s.Properties) == 0 {
+			return "map[string]" + g.value(), false
+		}
+		return g.declare(name, s), true
+	case TypeArray, TypeSet:
+		return "[]" + g.typeOf(s.Elements, name+"Item", false), false
+	case TypeMap:
+		key := g.typeOf(s.Keys, name+"Key", false)
+		switch key {
+		case "string", "int32", "uint32", "int64", "float64", "bool":
+		default:
+			return g.value(), false
+		}
+		return "map[" + key + "]" + g.typeOf(s.Values, name+"Value", false), false
+	case TypeArrayBuffer:
+		return "[]byte", false
+	}
+	return g.value(), false
+}
+
+func (g *goGenerator) value() string {
+	g.imports["github.com/acolita/v8wire/pkg/v8serialize"] = true
+	return "v8serialize.Value"
+}
+
+// goFieldName turns a property name into an exported Go identifier.
+func goFieldName(key string) string {
+	var b strings.Builder
+	for _, word := range splitWords(key) {
+		if goInitialisms[word] {
+			word = strings.ToUpper(word)
+		}
+		b.WriteString(word)
+	}
+	name := b.String()
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		name = "F" + name
+	}
+	return name
+}
+
+// splitWords splits a property name at separators and lower-to-upper case
+// changes, capitalizing each word: "user_id" and "userId" both give
+// ["User", "Id"].
+func splitWords(key string) []string {
+	var words []string
+	var cur []rune
+	flush := func() {
+		if len(cur) > 0 {
+			words = append(words, pascalCase(string(cur)))
+			cur = cur[:0]
+		}
+	}
+	prevLower := false
+	for _, r := range key {
+		isLetter := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+		isDigit := r >= '0' && r <= '9'
+		if !isLetter && !isDigit {
+			flush()
+			prevLower = false
+			continue
+		}
+		if r >= 'A' && r <= 'Z' && prevLower {
+			flush()
+		}
+		cur = append(cur, r)
+		prevLower = r >= 'a' && r <= 'z' || isDigit
+	}
+	flush()
+	return words
+}
@@ -0,0 +1,71 @@
+package v8serialize
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSchemaToGoStructs(t *testing.T) {
+	samples := []Value{
+		Object(map[string]Value{
+			"userId":  Int32(1),
+			"score":   Int32(3),
+			"created": Date(time.Unix(0, 0)),
+			"profile": Object(map[string]Value{"display-name": String("Ana"), "big": BigInt(big.NewInt(1))}),
+			"tags":    Array([]Value{String("a")}),
+			"nick":    Null(),
+			"extra":   String("x"),
+		}),
+		Object(map[string]Value{
+			"userId":  Int32(2),
+			"score":   Double(2.5),
+			"created": Date(time.Unix(1, 0)),
+			"tags":    Array(nil),
+			"nick":    String("bo"),
+			"extra":   Int32(1),
+		}),
+	}
+	var b strings.Builder
+	if err := InferSchema(samples...).ToGoStructs("models", &b); err != nil {
+		t.Fatal(err)
+	}
+	want := "package models\n\n" +
+		"import (\n" +
+		"\t\"math/big\"\n" +
+		"\t\"time\"\n\n" +
+		"\t\"github.com/acolita/v8wire/pkg/v8serialize\"\n" +
+		")\n\n" +
+		"type Payload struct {\n" +
+		"\tCreated time.Time         `v8:\"created\"`\n" +
+		"\tExtra   v8serialize.Value `v8:\"extra\"`\n" +
+		"\tNick    *string           `v8:\"nick\"`\n" +
+		"\tProfile *PayloadProfile   `v8:\"profile,omitempty\"`\n" +
+		"\tScore   float64           `v8:\"score\"`\n" +
+		"\tTags    []string          `v8:\"tags\"`\n" +
+		"\tUserID  int32             `v8:\"userId\"`\n" +
+		"}\n\n" +
+		"type PayloadProfile struct {\n" +
+		"\tBig         *big.Int `v8:\"big\"`\n" +
+		"\tDisplayName string   `v8:\"display-name\"`\n" +
+		"}\n"
+	if got := b.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestGoFieldName(t *testing.T) {
+	for key, want := range map[string]string{
+		"id":         "ID",
+		"user_url":   "UserURL",
+		"createdAt":  "CreatedAt",
+		"HTTPStatus": "HTTPStatus",
+		"2fa":        "F2fa",
+		"":           "F",
+	} {
+		if got := goFieldName(key); got != want {
+			t.Errorf("goFieldName(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
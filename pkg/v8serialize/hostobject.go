@@ -0,0 +1,116 @@
+package v8serialize
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Host objects (tag 0x5C) carry a body that V8 hands to the embedder's
+// delegate unparsed: V8 itself never knows where one ends. Node's
+// DefaultSerializer writes TypedArrays, DataViews and Buffers this way; a
+// Serializer subclass overriding _writeHostObject can write anything. The
+// Deserializer follows V8 in delegating the body, to a reader set with
+// WithHostObjectReader or, under profiles with NodeHostObjects, to Node's
+// own encoding.
+//
+// Before format version 13 V8 wrote host objects without a tag in some
+// embedders; those payloads are below MinVersion and are not supported.
+
+// HostObjectReader gives a host object reader access to the payload at
+// the start of a host object's body, mirroring the read methods V8 offers
+// its deserializer delegates (readUint32, readUint64, readDouble and
+// readRawBytes in Node's v8.Deserializer).
+type HostObjectReader struct {
+	d   *Deserializer
+	pos int
+}
+
+// Position returns the input offset of the host object's tag.
+func (h *HostObjectReader) Position() int {
+	return h.pos
+}
+
+// ReadUint32 reads a varint-encoded uint32.
+func (h *HostObjectReader) ReadUint32() (uint32, error) {
+	return h.d.reader.ReadVarint32()
+}
+
+// ReadUint64 reads a varint-encoded uint64.
+func (h *HostObjectReader) ReadUint64() (uint64, error) {
+	return h.d.reader.ReadVarint()
+}
+
+// ReadDouble reads a little-endian float64.
+func (h *HostObjectReader) ReadDouble() (float64, error) {
+	return h.d.reader.ReadDouble()
+}
+
+// ReadRawBytes reads n bytes. The returned slice is a copy, allocated
+// through WithBufferAllocator if one is set.
+func (h *HostObjectReader) ReadRawBytes(n int) ([]byte, error) {
+	data, err := h.d.reader.ReadBytes(n)
+	if err != nil {
+		return nil, err
+	}
+	return h.d.copyBuffer(data), nil
+}
+
+// ReadNodeView reads the body in Node's DefaultSerializer encoding of
+// TypedArrays, DataViews and Buffers, for readers that handle their own
+// host objects but defer to Node's for views. It fails with ErrHostObject
+// for a view type index Node does not define.
+func (h *HostObjectReader) ReadNodeView() (Value, error) {
+	return h.d.readNodeView(h.pos)
+}
+
+// WithHostObjectReader sets the function that reads host object bodies,
+// like a deserializer delegate's ReadHostObject in V8. It is called with
+// the input positioned just after the tag and must consume exactly the
+// bytes the producer's delegate wrote; its result becomes the decoded
+// value and can be referenced by later back-references. Errors are
+// returned from Deserialize with the host object's position. The reader
+// takes precedence over the profile's NodeHostObjects setting, but under
+// such a profile it can decline a body by returning an error wrapping
+// ErrHostObject: the input is rewound to just after the tag, however much
+// the reader consumed, and the body is read in Node's encoding.
+func WithHostObjectReader(read func(h *HostObjectReader) (Value, error)) Option {
+	return func(d *Deserializer) {
+		d.hostObjects = read
+	}
+}
+
+// readHostObject reads a host object through the configured reader or
+// the profile's host-object encoding.
+func (d *Deserializer) readHostObject() (Value, error) {
+	pos := d.reader.Pos() - 1
+	var v Value
+	var err error
+	switch {
+	case d.hostObjects != nil:
+		start := d.reader.Fork()
+		v, err = d.hostObjects(&HostObjectReader{d: d, pos: pos})
+		if errors.Is(err, ErrHostObject) && d.profile.NodeHostObjects {
+			*d.reader = *start
+			if v, err = d.readNodeView(pos); err != nil {
+				return Value{}, err
+			}
+			break
+		}
+		if err != nil {
+			return Value{}, fmt.Errorf("host object at position %d: %w", pos, err)
+		}
+		if err := d.checkType(v.Type(), pos); err != nil {
+			return Value{}, err
+		}
+	case d.profile.NodeHostObjects:
+		v, err = d.readNodeView(pos)
+		if err != nil {
+			return Value{}, err
+		}
+	default:
+		return Value{}, fmt.Errorf("%w: tag 0x%02X at position %d (profile %s)",
+			ErrHostObject, tagHostObject, pos, d.profile.Name)
+	}
+	d.objects = append(d.objects, v)
+	return v, nil
+}
@@ -0,0 +1,176 @@
+package v8serialize
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestNodeHostObjects(t *testing.T) {
+	tests := []struct {
+		name string
+		hex  string // Node: v8.serialize(...)
+		key  string
+		typ  string
+		data []byte
+	}{
+		{"buffer", "ff0f5c0a020102", "", "Buffer", []byte{1, 2}},
+		// {buf: Buffer.from("hi"), u16: new Uint16Array([1, 2])}
+		{"object-buffer", "ff0f6f22036275665c0a02686922037531365c0404010002007b02", "buf", "Buffer", []byte("hi")},
+		{"object-uint16", "ff0f6f22036275665c0a02686922037531365c0404010002007b02", "u16", "Uint16Array", []byte{1, 0, 2, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, _ := hex.DecodeString(tt.hex)
+			v, err := Deserialize(data)
+			if err != nil {
+				t.Fatalf("Deserialize failed: %v", err)
+			}
+			if tt.key != "" {
+				v = v.AsObject()[tt.key]
+			}
+			view, ok := v.Interface().(*ArrayBufferView)
+			if !ok {
+				t.Fatalf("got %s, want ArrayBufferView", v.Type())
+			}
+			if view.Type != tt.typ || !bytes.Equal(view.Buffer, tt.data) {
+				t.Errorf("got %s %x, want %s %x", view.Type, view.Buffer, tt.typ, tt.data)
+			}
+		})
+	}
+
+	t.Run("buffer-round-trip", func(t *testing.T) {
+		data, _ := hex.DecodeString("ff0f5c0a020102")
+		v, err := Deserialize(data)
+		if err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+		out, err := Serialize(v)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		if !bytes.Equal(out, data) {
+			t.Errorf("got %x, want %x", out, data)
+		}
+	})
+
+	t.Run("truncated-body", func(t *testing.T) {
+		// A Buffer declaring 5 bytes with 1 left in the input.
+		if _, err := Deserialize([]byte{0xff, 0x0f, 0x5c, 0x0a, 0x05, 0x01}); err == nil {
+			t.Error("expected an error for a body past the end of the input")
+		}
+	})
+
+	t.Run("unknown-view-type", func(t *testing.T) {
+		_, err := Deserialize([]byte{0xff, 0x0f, 0x5c, 0x63, 0x00})
+		if !errors.Is(err, ErrHostObject) {
+			t.Errorf("expected ErrHostObject, got %v", err)
+		}
+	})
+}
+
+func TestWithHostObjectReader(t *testing.T) {
+	// Node: a v8.Serializer subclass treating views as host objects, with
+	//   _writeHostObject(o) { this.writeUint32(7); this.writeDouble(o.byteLength);
+	//                         this.writeUint64(1, 2); this.writeRawBytes(o) }
+	// s.writeValue({a: new Uint16Array([1, 2]), b: "x", c: null})
+	data, _ := hex.DecodeString("ff0f6f2201615c070000000000001040828080801001000200220162220178220163307b03")
+
+	type custom struct {
+		tag    uint32
+		length float64
+		id     uint64
+		raw    []byte
+	}
+	var got []custom
+	read := func(h *HostObjectReader) (Value, error) {
+		var c custom
+		var err error
+		if c.tag, err = h.ReadUint32(); err != nil {
+			return Value{}, err
+		}
+		if c.length, err = h.ReadDouble(); err != nil {
+			return Value{}, err
+		}
+		if c.id, err = h.ReadUint64(); err != nil {
+			return Value{}, err
+		}
+		if c.raw, err = h.ReadRawBytes(int(c.length)); err != nil {
+			return Value{}, err
+		}
+		got = append(got, c)
+		return String(hex.EncodeToString(c.raw)), nil
+	}
+
+	v, err := Deserialize(data, WithHostObjectReader(read))
+	if err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	want := custom{tag: 7, length: 4, id: 1<<32 | 2, raw: []byte{1, 0, 2, 0}}
+	if len(got) != 1 || got[0].tag != want.tag || got[0].length != want.length ||
+		got[0].id != want.id || !bytes.Equal(got[0].raw, want.raw) {
+		t.Fatalf("read %+v, want %+v", got, want)
+	}
+	obj := v.AsObject()
+	if a := obj["a"].AsString(); a != "01000200" {
+		t.Errorf("a = %q, want %q", a, "01000200")
+	}
+	if b := obj["b"].AsString(); b != "x" {
+		t.Errorf("b = %q, want %q", b, "x")
+	}
+
+	// Node's view encoding reads the body as an empty Float32Array and
+	// then trips over the rest of it.
+	if _, err := Deserialize(data); err == nil {
+		t.Error("expected an error without the host object reader")
+	}
+
+	t.Run("node-views", func(t *testing.T) {
+		data, _ := hex.DecodeString("ff0f5c0a020102")
+		v, err := Deserialize(data, WithProfile(ProfileDeno), WithHostObjectReader(func(h *HostObjectReader) (Value, error) {
+			return h.ReadNodeView()
+		}))
+		if err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+		if view := v.Interface().(*ArrayBufferView); view.Type != "Buffer" {
+			t.Errorf("got %s, want Buffer", view.Type)
+		}
+	})
+
+	t.Run("declined", func(t *testing.T) {
+		// The reader reads part of a Node Buffer body before declining it;
+		// the body is then read from the start in Node's encoding.
+		data, _ := hex.DecodeString("ff0f5c0a020102")
+		decline := func(h *HostObjectReader) (Value, error) {
+			if _, err := h.ReadUint32(); err != nil {
+				return Value{}, err
+			}
+			return Value{}, fmt.Errorf("%w: not mine", ErrHostObject)
+		}
+		v, err := Deserialize(data, WithHostObjectReader(decline))
+		if err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+		if view := v.Interface().(*ArrayBufferView); view.Type != "Buffer" || !bytes.Equal(view.Buffer, []byte{1, 2}) {
+			t.Errorf("got %s %x, want Buffer 0102", view.Type, view.Buffer)
+		}
+
+		// Without Node's encoding to fall back on, the error stands.
+		if _, err := Deserialize(data, WithProfile(ProfileDeno), WithHostObjectReader(decline)); !errors.Is(err, ErrHostObject) {
+			t.Errorf("ProfileDeno: expected ErrHostObject, got %v", err)
+		}
+	})
+
+	t.Run("error-position", func(t *testing.T) {
+		errBad := errors.New("bad host object")
+		_, err := Deserialize(data, WithHostObjectReader(func(*HostObjectReader) (Value, error) {
+			return Value{}, errBad
+		}))
+		if !errors.Is(err, errBad) {
+			t.Errorf("expected errBad, got %v", err)
+		}
+	})
+}
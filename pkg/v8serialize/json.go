@@ -0,0 +1,318 @@
+package v8serialize
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// ErrJSONUnsupported is returned by ToJSON for values that have no JSON
+// representation under the configured options.
+var ErrJSONUnsupported = errors.New("v8serialize: value cannot be represented as JSON")
+
+// NonFinitePolicy controls how ToJSON encodes NaN, Infinity and -Infinity,
+// which encoding/json rejects.
+type NonFinitePolicy uint8
+
+const (
+	// NonFiniteError makes ToJSON fail with ErrJSONUnsupported (default).
+	NonFiniteError NonFinitePolicy = iota
+	// NonFiniteNull encodes non-finite numbers as null, like JSON.stringify.
+	NonFiniteNull
+	// NonFiniteString encodes them as the strings "NaN", "Infinity" and
+	// "-Infinity".
+	NonFiniteString
+)
+
+// JSONOption configures ToJSON.
+type JSONOption func(*jsonEncoder)
+
+// WithNonFinite sets the policy for NaN and ±Infinity.
+func WithNonFinite(p NonFinitePolicy) JSONOption {
+	return func(e *jsonEncoder) {
+		e.nonFinite = p
+	}
+}
+
+// jsonEncoder holds the state of a single ToJSON conversion.
+type jsonEncoder struct {
+	nonFinite NonFinitePolicy
+	active    map[uintptr]bool // containers on the current path, for cycle detection
+}
+
+// ToJSON encodes v as JSON. The mapping is lossy and follows
+// JSON.stringify where JSON has an equivalent:
+//   - undefined → omitted from objects, null elsewhere; holes → null
+//   - numbers → JSON numbers (NaN and ±Infinity per WithNonFinite)
+//   - BigInt → decimal string
+//   - Date → ISO 8601 string in UTC with milliseconds
+//   - RegExp → "/pattern/flags"
+//   - Map → array of [key, value] pairs; Set → array
+//   - ArrayBuffer, TypedArray, DataView → base64 string of the bytes
+//   - Error → object with name, message and, when present, stack and cause
+//   - BoxedPrimitive → its primitive value
+//
+// Object keys are sorted. Cyclic values yield ErrJSONUnsupported.
+//
+// The output is also the proto3 JSON form of google.protobuf.Value, so
+// gRPC services can expose payload contents as a *structpb.Value without
+// this package depending on protobuf:
+//
+//	data, err := v8serialize.ToJSON(v, v8serialize.WithNonFinite(v8serialize.NonFiniteNull))
+//	pv := new(structpb.Value)
+//	err = protojson.Unmarshal(data, pv)
+//
+// and back with protojson.Marshal and FromJSON.
+func ToJSON(v Value, opts ...JSONOption) ([]byte, error) {
+	e := &jsonEncoder{active: make(map[uintptr]bool)}
+	for _, opt := range opts {
+		opt(e)
+	}
+	tree, err := e.convert(v)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(tree)
+}
+
+// convert turns v into a tree of types encoding/json understands.
+func (e *jsonEncoder) convert(v Value) (interface{}, error) {
+	switch v.Type() {
+	case TypeUndefined, TypeNull, TypeHole:
+		return nil, nil
+	case TypeBool:
+		return v.AsBool(), nil
+	case TypeInt32:
+		return v.AsInt32(), nil
+	case TypeUint32:
+		return v.AsUint32(), nil
+	case TypeDouble:
+		return e.number(v.AsDouble())
+	case TypeBigInt:
+		return v.AsBigInt().String(), nil
+	case TypeString:
+		return v.AsString(), nil
+	case TypeDate:
+		if v.IsInvalidDate() {
+			return nil, nil // JSON.stringify(new Date(NaN)) is null
+		}
+		return v.AsDate().Format("2006-01-02T15:04:05.000Z07:00"), nil
+	case TypeRegExp:
+		re := v.Interface().(*RegExp)
+		return "/" + re.Pattern + "/" + re.Flags, nil
+	case TypeArrayBuffer:
+		return v.Interface().([]byte), nil
+	case TypeTypedArray, TypeDataView:
+		view := v.Interface().(*ArrayBufferView)
+		return view.Slice(), nil
+	case TypeBoxedPrimitive:
+		return e.convert(v.Interface().(*BoxedPrimitive).Value)
+	}
+
+	if err := e.enter(v); err != nil {
+		return nil, err
+	}
+	defer e.leave(v)
+
+	switch v.Type() {
+	case TypeObject:
+		obj := v.AsObject()
+		result := make(map[string]interface{}, len(obj))
+		for k, val := range obj {
+			if val.IsUndefined() {
+				continue
+			}
+			converted, err := e.convert(val)
+			if err != nil {
+				return nil, err
+			}
+			result[k] = converted
+		}
+		return result, nil
+	case TypeArray:
+		return e.convertList(v.AsArray())
+	case TypeSet:
+		return e.convertList(v.Interface().(*JSSet).Values)
+	case TypeMap:
+		m := v.Interface().(*JSMap)
+		result := make([]interface{}, len(m.Entries))
+		for i, entry := range m.Entries {
+			key, err := e.convert(entry.Key)
+			if err != nil {
+				return nil, err
+			}
+			val, err := e.convert(entry.Value)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = []interface{}{key, val}
+		}
+		return result, nil
+	case TypeError:
+		jsErr := v.Interface().(*JSError)
+		result := map[string]interface{}{
+			"name":    jsErr.Name,
+			"message": jsErr.Message,
+		}
+		if jsErr.Stack != "" {
+			result["stack"] = jsErr.Stack
+		}
+		if jsErr.Cause != nil {
+			cause, err := e.convert(*jsErr.Cause)
+			if err != nil {
+				return nil, err
+			}
+			result["cause"] = cause
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrJSONUnsupported, v.Type())
+	}
+}
+
+func (e *jsonEncoder) convertList(values []Value) (interface{}, error) {
+	result := make([]interface{}, len(values))
+	for i, val := range values {
+		converted, err := e.convert(val)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = converted
+	}
+	return result, nil
+}
+
+func (e *jsonEncoder) number(f float64) (interface{}, error) {
+	if f == 0 {
+		return 0, nil // JSON.stringify(-0) is "0"
+	}
+	if !math.IsNaN(f) && !math.IsInf(f, 0) {
+		return f, nil
+	}
+	switch e.nonFinite {
+	case NonFiniteNull:
+		return nil, nil
+	case NonFiniteString:
+		switch {
+		case math.IsNaN(f):
+			return "NaN", nil
+		case f > 0:
+			return "Infinity", nil
+		default:
+			return "-Infinity", nil
+		}
+	default:
+		return nil, fmt.Errorf("%w: non-finite number %v", ErrJSONUnsupported, f)
+	}
+}
+
+// enter marks a container as being converted and fails if it already is,
+// which means the value graph is cyclic.
+func (e *jsonEncoder) enter(v Value) error {
+	id, ok := containerID(v)
+	if !ok {
+		return nil
+	}
+	if e.active[id] {
+		return fmt.Errorf("%w: cyclic %s", ErrJSONUnsupported, v.Type())
+	}
+	e.active[id] = true
+	return nil
+}
+
+func (e *jsonEncoder) leave(v Value) {
+	if id, ok := containerID(v); ok {
+		delete(e.active, id)
+	}
+}
+
+// containerID returns an identity for the storage behind a container value.
+// Empty arrays have no storage of their own and cannot form cycles.
+func containerID(v Value) (uintptr, bool) {
+	rv := reflect.ValueOf(v.data)
+	switch rv.Kind() {
+	case reflect.Map, reflect.Ptr:
+		return rv.Pointer(), !rv.IsNil()
+	case reflect.Slice:
+		return rv.Pointer(), rv.Len() > 0
+	default:
+		return 0, false
+	}
+}
+
+// FromJSON decodes JSON into a Value. Integral numbers that fit in an int32
+// become Int32 values and all other numbers become Double values.
+func FromJSON(data []byte) (Value, error) {
+	var raw interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return Value{}, err
+	}
+	if dec.More() {
+		return Value{}, fmt.Errorf("v8serialize: trailing data after JSON value")
+	}
+	return fromJSONValue(raw)
+}
+
+func fromJSONValue(raw interface{}) (Value, error) {
+	switch x := raw.(type) {
+	case nil:
+		return Null(), nil
+	case bool:
+		return Bool(x), nil
+	case string:
+		return String(x), nil
+	case json.Number:
+		if n, err := x.Int64(); err == nil && n >= math.MinInt32 && n <= math.MaxInt32 {
+			return Int32(int32(n)), nil
+		}
+		f, err := x.Float64()
+		if err != nil {
+			return Value{}, err
+		}
+		return Double(f), nil
+	case []interface{}:
+		elements := make([]Value, len(x))
+		for i, elem := range x {
+			v, err := fromJSONValue(elem)
+			if err != nil {
+				return Value{}, err
+			}
+			elements[i] = v
+		}
+		return Array(elements), nil
+	case map[string]interface{}:
+		props := make(map[string]Value, len(x))
+		for k, elem := range x {
+			v, err := fromJSONValue(elem)
+			if err != nil {
+				return Value{}, err
+			}
+			props[k] = v
+		}
+		return Object(props), nil
+	default:
+		return Value{}, fmt.Errorf("v8serialize: unexpected JSON value %T", raw)
+	}
+}
+
+// MarshalJSON implements json.Marshaler using the ToJSON mapping, with NaN
+// and ±Infinity encoded as null as JSON.stringify does, so Values embedded
+// in larger structs can go through encoding/json. Cyclic values fail.
+func (v Value) MarshalJSON() ([]byte, error) {
+	return ToJSON(v, WithNonFinite(NonFiniteNull))
+}
+
+// UnmarshalJSON implements json.Unmarshaler using FromJSON.
+func (v *Value) UnmarshalJSON(data []byte) error {
+	decoded, err := FromJSON(data)
+	if err != nil {
+		return err
+	}
+	*v = decoded
+	return nil
+}
@@ -0,0 +1,185 @@
+package v8serialize
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestToJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		value Value
+		want  string
+	}{
+		{"null", Null(), `null`},
+		{"undefined root", Undefined(), `null`},
+		{"int32", Int32(-7), `-7`},
+		{"double", Double(1.5), `1.5`},
+		{"negative zero", Double(math.Copysign(0, -1)), `0`},
+		{"bigint", BigInt(big.NewInt(1 << 62)), `"4611686018427387904"`},
+		{"date", Date(time.Date(2024, 1, 2, 3, 4, 5, 6e6, time.UTC)), `"2024-01-02T03:04:05.006Z"`},
+		{"array with hole and undefined", Array([]Value{Int32(1), Hole(), Undefined()}), `[1,null,null]`},
+		{"object drops undefined", Object(map[string]Value{"b": Bool(true), "a": Undefined()}), `{"b":true}`},
+		{"arraybuffer", ArrayBuffer([]byte("hi")), `"aGk="`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ToJSON(tt.value)
+			if err != nil {
+				t.Fatalf("ToJSON failed: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToJSONFixtures(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    string
+	}{
+		{"object-nested", `{"nested":{"inner":"value"}}`},
+		{"map-non-string-keys", `[[1,"one"],[true,"bool"],[null,"null"]]`},
+		{"set-numbers", `[1,2,3]`},
+		{"regexp-flags", `"/pattern.*test/gi"`},
+		{"boxed-string", `"wrapped"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			binData, _ := loadFixture(t, tt.fixture)
+			v, err := Deserialize(binData)
+			if err != nil {
+				t.Fatalf("Deserialize failed: %v", err)
+			}
+			got, err := ToJSON(v)
+			if err != nil {
+				t.Fatalf("ToJSON failed: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToJSONNonFinite(t *testing.T) {
+	v := Array([]Value{Double(math.NaN()), Double(math.Inf(1)), Double(math.Inf(-1))})
+
+	if _, err := ToJSON(v); !errors.Is(err, ErrJSONUnsupported) {
+		t.Errorf("default policy: expected ErrJSONUnsupported, got %v", err)
+	}
+
+	tests := []struct {
+		policy NonFinitePolicy
+		want   string
+	}{
+		{NonFiniteError, ""},
+		{NonFiniteNull, `[null,null,null]`},
+		{NonFiniteString, `["NaN","Infinity","-Infinity"]`},
+	}
+	for _, tt := range tests {
+		got, err := ToJSON(v, WithNonFinite(tt.policy))
+		if tt.want == "" {
+			if !errors.Is(err, ErrJSONUnsupported) {
+				t.Errorf("policy %d: expected ErrJSONUnsupported, got %v", tt.policy, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("policy %d: ToJSON failed: %v", tt.policy, err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("policy %d: got %s, want %s", tt.policy, got, tt.want)
+		}
+	}
+}
+
+func TestToJSONCycle(t *testing.T) {
+	binData, _ := loadFixture(t, "circular-self")
+	v, err := Deserialize(binData)
+	if err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	if _, err := ToJSON(v); !errors.Is(err, ErrJSONUnsupported) {
+		t.Fatalf("expected ErrJSONUnsupported, got %v", err)
+	}
+}
+
+func TestFromJSON(t *testing.T) {
+	v, err := FromJSON([]byte(`{"n":1,"big":4294967296,"f":1.5,"s":"x","l":[true,null]}`))
+	if err != nil {
+		t.Fatalf("FromJSON failed: %v", err)
+	}
+	obj := v.AsObject()
+	if obj["n"].Type() != TypeInt32 || obj["n"].AsInt32() != 1 {
+		t.Errorf("n: got %#v", obj["n"])
+	}
+	if obj["big"].Type() != TypeDouble || obj["big"].AsDouble() != 4294967296 {
+		t.Errorf("big: got %#v", obj["big"])
+	}
+	if obj["f"].AsDouble() != 1.5 || obj["s"].AsString() != "x" {
+		t.Errorf("f/s: got %#v %#v", obj["f"], obj["s"])
+	}
+	l := obj["l"].AsArray()
+	if len(l) != 2 || !l[0].AsBool() || !l[1].IsNull() {
+		t.Errorf("l: got %#v", l)
+	}
+
+	if _, err := FromJSON([]byte(`1 2`)); err == nil {
+		t.Error("expected error for trailing data")
+	}
+}
+
+func TestValueJSONInterfaces(t *testing.T) {
+	type event struct {
+		ID      int    `json:"id"`
+		Payload Value  `json:"payload"`
+		Extra   *Value `json:"extra,omitempty"`
+	}
+
+	in := event{
+		ID: 1,
+		Payload: Object(map[string]Value{
+			"n":     Double(math.NaN()),
+			"big":   BigInt(big.NewInt(12)),
+			"skip":  Undefined(),
+			"items": Array([]Value{Int32(1), String("two")}),
+		}),
+	}
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatalf("json.Marshal failed: %v", err)
+	}
+	want := `{"id":1,"payload":{"big":"12","items":[1,"two"],"n":null}}`
+	if string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+
+	var out event
+	if err := json.Unmarshal([]byte(`{"id":2,"payload":{"a":[1,2.5]},"extra":"x"}`), &out); err != nil {
+		t.Fatalf("json.Unmarshal failed: %v", err)
+	}
+	arr := out.Payload.AsObject()["a"].AsArray()
+	if len(arr) != 2 || arr[0].AsInt32() != 1 || arr[1].AsDouble() != 2.5 {
+		t.Errorf("unexpected payload: %#v", out.Payload)
+	}
+	if out.Extra == nil || out.Extra.AsString() != "x" {
+		t.Errorf("unexpected extra: %v", out.Extra)
+	}
+
+	t.Run("cycle", func(t *testing.T) {
+		self := make(map[string]Value)
+		self["self"] = Object(self)
+		if _, err := json.Marshal(event{Payload: Object(self)}); !errors.Is(err, ErrJSONUnsupported) {
+			t.Errorf("expected ErrJSONUnsupported, got %v", err)
+		}
+	})
+}
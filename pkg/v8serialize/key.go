@@ -0,0 +1,182 @@
+package v8serialize
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrUnhashable is returned by Key for values that have no finite
+// structural form, i.e. cyclic values.
+var ErrUnhashable = errors.New("v8serialize: value cannot be used as a key")
+
+// ValueKey is a comparable stand-in for a Value, usable as a Go map key.
+// Two Values have equal keys when they are structurally equal: numbers
+// compare by value whatever their representation (with NaN equal to NaN
+// and +0 equal to -0, as in SameValueZero), and objects, arrays and other
+// containers compare by content rather than identity.
+type ValueKey struct {
+	Hash uint64 // FNV-1a hash of Form
+	Form string // normalized text form of the value
+}
+
+// String returns the normalized form.
+func (k ValueKey) String() string {
+	return k.Form
+}
+
+// Key returns the structural key of v. Object properties are normalized to
+// sorted order; Map entries and Set members keep their order, since it is
+// observable in JavaScript.
+func Key(v Value) (ValueKey, error) {
+	var b strings.Builder
+	if err := writeKeyForm(&b, v, make(map[uintptr]bool)); err != nil {
+		return ValueKey{}, err
+	}
+	h := fnv.New64a()
+	h.Write([]byte(b.String()))
+	return ValueKey{Hash: h.Sum64(), Form: b.String()}, nil
+}
+
+// writeKeyForm appends the normalized form of v. active holds the
+// containers on the current path, to detect cycles.
+func writeKeyForm(b *strings.Builder, v Value, active map[uintptr]bool) error {
+	switch v.typ {
+	case TypeUndefined:
+		b.WriteString("undefined")
+		return nil
+	case TypeNull:
+		b.WriteString("null")
+		return nil
+	case TypeHole:
+		b.WriteString("hole")
+		return nil
+	case TypeBool:
+		b.WriteString(strconv.FormatBool(v.data.(bool)))
+		return nil
+	case TypeInt32, TypeUint32, TypeDouble:
+		f := v.AsNumber()
+		switch {
+		case math.IsNaN(f):
+			b.WriteString("NaN")
+		case f == 0:
+			b.WriteString("0") // +0 and -0
+		default:
+			b.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+		}
+		return nil
+	case TypeBigInt:
+		b.WriteString(v.AsBigInt().String() + "n")
+		return nil
+	case TypeString:
+		b.WriteString(strconv.Quote(v.data.(string)))
+		return nil
+	case TypeDate:
+		b.WriteString("Date(" + strconv.FormatFloat(v.data.(float64), 'f', -1, 64) + ")")
+		return nil
+	case TypeRegExp:
+		re := v.data.(*RegExp)
+		fmt.Fprintf(b, "RegExp(%q,%q)", re.Pattern, re.Flags)
+		return nil
+	case TypeArrayBuffer:
+		fmt.Fprintf(b, "ArrayBuffer(%x)", v.data.([]byte))
+		return nil
+	case TypeTypedArray, TypeDataView:
+		view := v.data.(*ArrayBufferView)
+		fmt.Fprintf(b, "%s(%x)", view.Type, view.Slice())
+		return nil
+	case TypeDetachedArrayBuffer:
+		b.WriteString(v.data.(*DetachedArrayBuffer).String())
+		return nil
+	case TypeSharedArrayBuffer:
+		b.WriteString(v.data.(*SharedArrayBufferRef).String())
+		return nil
+	case TypeBoxedPrimitive:
+		b.WriteString("Object(")
+		if err := writeKeyForm(b, v.data.(*BoxedPrimitive).Value, active); err != nil {
+			return err
+		}
+		b.WriteByte(')')
+		return nil
+	}
+
+	// Containers: guard against cycles.
+	id := reflect.ValueOf(v.data).Pointer()
+	if active[id] {
+		return fmt.Errorf("%w: cyclic %s", ErrUnhashable, v.typ)
+	}
+	active[id] = true
+	defer delete(active, id)
+
+	switch v.typ {
+	case TypeObject:
+		obj := v.data.(*object).props
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(strconv.Quote(k) + ":")
+			if err := writeKeyForm(b, obj[k], active); err != nil {
+				return err
+			}
+		}
+		b.WriteByte('}')
+	case TypeArray:
+		return writeKeyList(b, "[", "]", v.data.([]Value), active)
+	case TypeSet:
+		return writeKeyList(b, "Set{", "}", v.data.(*JSSet).Values, active)
+	case TypeMap:
+		b.WriteString("Map{")
+		for i, e := range v.data.(*JSMap).Entries {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			if err := writeKeyForm(b, e.Key, active); err != nil {
+				return err
+			}
+			b.WriteString("=>")
+			if err := writeKeyForm(b, e.Value, active); err != nil {
+				return err
+			}
+		}
+		b.WriteByte('}')
+	case TypeError:
+		e := v.data.(*JSError)
+		fmt.Fprintf(b, "%s(%q,%q", e.Name, e.Message, e.Stack)
+		if e.Cause != nil {
+			b.WriteByte(',')
+			if err := writeKeyForm(b, *e.Cause, active); err != nil {
+				return err
+			}
+		}
+		b.WriteByte(')')
+	default:
+		return fmt.Errorf("%w: unsupported type %s", ErrUnhashable, v.typ)
+	}
+	return nil
+}
+
+func writeKeyList(b *strings.Builder, open, close string, values []Value, active map[uintptr]bool) error {
+	b.WriteString(open)
+	for i, elem := range values {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		if err := writeKeyForm(b, elem, active); err != nil {
+			return err
+		}
+	}
+	b.WriteString(close)
+	return nil
+}
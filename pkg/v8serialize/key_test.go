@@ -0,0 +1,92 @@
+package v8serialize
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestKey(t *testing.T) {
+	obj := func(kv ...interface{}) Value {
+		m := make(map[string]Value)
+		for i := 0; i < len(kv); i += 2 {
+			m[kv[i].(string)] = kv[i+1].(Value)
+		}
+		return Object(m)
+	}
+
+	equal := []struct {
+		name string
+		a, b Value
+	}{
+		{"int32-double", Int32(1), Double(1)},
+		{"uint32-double", Uint32(1 << 31), Double(1 << 31)},
+		{"nan", Double(math.NaN()), Double(math.NaN())},
+		{"zeros", Double(0), Double(math.Copysign(0, -1))},
+		{"object-order", obj("a", Int32(1), "b", String("x")), obj("b", String("x"), "a", Double(1))},
+		{"nested-array", Array([]Value{Array([]Value{Int32(1)})}), Array([]Value{Array([]Value{Double(1)})})},
+		{"bigint", BigInt(big.NewInt(5)), BigInt(big.NewInt(5))},
+	}
+	for _, tt := range equal {
+		t.Run("equal/"+tt.name, func(t *testing.T) {
+			ka, err := Key(tt.a)
+			if err != nil {
+				t.Fatal(err)
+			}
+			kb, err := Key(tt.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ka != kb {
+				t.Errorf("keys differ: %s vs %s", ka, kb)
+			}
+		})
+	}
+
+	different := []struct {
+		name string
+		a, b Value
+	}{
+		{"string-number", String("1"), Int32(1)},
+		{"bigint-number", BigInt(big.NewInt(1)), Int32(1)},
+		{"null-undefined", Null(), Undefined()},
+		{"array-order", Array([]Value{Int32(1), Int32(2)}), Array([]Value{Int32(2), Int32(1)})},
+		{"array-nesting", Array([]Value{String("a,b")}), Array([]Value{String("a"), String("b")})},
+		{"object-array", obj("0", Int32(1)), Array([]Value{Int32(1)})},
+	}
+	for _, tt := range different {
+		t.Run("different/"+tt.name, func(t *testing.T) {
+			ka, _ := Key(tt.a)
+			kb, _ := Key(tt.b)
+			if ka == kb {
+				t.Errorf("keys should differ: %s", ka)
+			}
+		})
+	}
+
+	t.Run("map-key", func(t *testing.T) {
+		m := map[ValueKey]string{}
+		k1, _ := Key(obj("id", Int32(7)))
+		m[k1] = "seven"
+		k2, _ := Key(obj("id", Double(7)))
+		if m[k2] != "seven" {
+			t.Error("structurally equal object should find the entry")
+		}
+	})
+
+	t.Run("cycle", func(t *testing.T) {
+		self := make(map[string]Value)
+		self["self"] = Object(self)
+		if _, err := Key(Object(self)); !errors.Is(err, ErrUnhashable) {
+			t.Errorf("expected ErrUnhashable, got %v", err)
+		}
+	})
+
+	t.Run("shared-not-cycle", func(t *testing.T) {
+		shared := Array([]Value{Int32(1)})
+		if _, err := Key(Array([]Value{shared, shared})); err != nil {
+			t.Errorf("shared subtree is not a cycle: %v", err)
+		}
+	})
+}
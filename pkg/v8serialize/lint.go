@@ -0,0 +1,204 @@
+package v8serialize
+
+import (
+	"fmt"
+
+	"github.com/acolita/v8wire/internal/wire"
+)
+
+// LintIssue is a finding reported by a LintRule.
+type LintIssue struct {
+	Rule    string // name of the rule, e.g. "duplicate-keys"
+	Offset  int    // byte offset of the offending tag
+	Message string
+}
+
+func (i LintIssue) String() string {
+	return fmt.Sprintf("%04x %s: %s", i.Offset, i.Rule, i.Message)
+}
+
+// LintRule inspects a disassembled payload and reports issues. Custom rules
+// can be written against the Instruction list returned by Disassemble.
+type LintRule func(ins []Instruction) []LintIssue
+
+// Default thresholds of the built-in rules, used by DefaultLintRules.
+const (
+	DefaultLintMinObjects      = 10000
+	DefaultLintMinStringBytes  = 64
+	DefaultLintMaxArrayDensity = 0.5
+)
+
+// DefaultLintRules returns the built-in rules with their default
+// thresholds. Lint uses them when called without rules.
+func DefaultLintRules() []LintRule {
+	return []LintRule{
+		LintReferenceTable(DefaultLintMinObjects),
+		LintTwoByteLatin1(DefaultLintMinStringBytes),
+		LintHoleyDenseArrays(DefaultLintMaxArrayDensity),
+		LintDuplicateKeys(),
+	}
+}
+
+// Lint checks a payload for encodings that are valid but wasteful or
+// suspicious, to help producers optimize what they send. Issues are
+// reported rule by rule, each rule in payload order. A payload that cannot
+// be decoded yields the issues found in its readable part plus one
+// "malformed" issue at the point decoding stopped.
+func Lint(data []byte, rules ...LintRule) []LintIssue {
+	if len(rules) == 0 {
+		rules = DefaultLintRules()
+	}
+	ins, err := Disassemble(data)
+	var issues []LintIssue
+	for _, rule := range rules {
+		issues = append(issues, rule(ins)...)
+	}
+	if err != nil {
+		offset := 0
+		if len(ins) > 0 {
+			offset = ins[len(ins)-1].End
+		}
+		issues = append(issues, LintIssue{Rule: "malformed", Offset: offset, Message: err.Error()})
+	}
+	return issues
+}
+
+// LintReferenceTable reports payloads that enter at least minObjects
+// objects in the reference table without ever referring back to one. The
+// receiver keeps every such object alive until decoding finishes, so large
+// flat record lists are better sent as arrays of primitives or split into
+// smaller payloads.
+func LintReferenceTable(minObjects int) LintRule {
+	return func(ins []Instruction) []LintIssue {
+		objects := 0
+		for _, in := range ins {
+			if in.Tag == tagObjectReference {
+				return nil
+			}
+			if isObjectTag(in.Tag) {
+				objects++
+			}
+		}
+		if objects == 0 || objects < minObjects {
+			return nil
+		}
+		return []LintIssue{{
+			Rule:    "reference-table",
+			Message: fmt.Sprintf("%d objects enter the reference table but none is referenced", objects),
+		}}
+	}
+}
+
+// LintTwoByteLatin1 reports two-byte strings of at least minBytes bytes
+// whose characters all fit Latin-1, which a one-byte string would store in
+// half the space. V8 writes strings in their in-memory representation, so
+// these usually come from strings built by concatenation or decoding.
+func LintTwoByteLatin1(minBytes int) LintRule {
+	return func(ins []Instruction) []LintIssue {
+		var issues []LintIssue
+		for _, in := range ins {
+			if in.Tag != tagTwoByteString {
+				continue
+			}
+			str := in.Operand.AsString()
+			needsUTF16, utf16Len, _ := wire.AnalyzeString(str)
+			if needsUTF16 || utf16Len*2 < minBytes {
+				continue
+			}
+			issues = append(issues, LintIssue{
+				Rule:    "two-byte-latin1",
+				Offset:  in.Offset,
+				Message: fmt.Sprintf("%d-byte two-byte string fits in %d bytes as one-byte", utf16Len*2, utf16Len),
+			})
+		}
+		return issues
+	}
+}
+
+// LintHoleyDenseArrays reports dense arrays whose fraction of present
+// elements is below maxDensity. The sparse encoding stores only the
+// present elements.
+func LintHoleyDenseArrays(maxDensity float64) LintRule {
+	type frame struct {
+		dense  bool
+		offset int
+		length uint32
+		holes  uint32
+	}
+	return func(ins []Instruction) []LintIssue {
+		var issues []LintIssue
+		var stack []frame
+		for _, in := range ins {
+			switch in.Tag {
+			case tagBeginJSObject, tagBeginSparseArray, tagBeginMap, tagBeginSet:
+				stack = append(stack, frame{})
+			case tagBeginDenseArray:
+				stack = append(stack, frame{dense: true, offset: in.Offset, length: in.Args[0]})
+			case tagHole:
+				if len(stack) > 0 {
+					stack[len(stack)-1].holes++
+				}
+			case tagEndJSObject, tagEndDenseArray, tagEndSparseArray, tagEndMap, tagEndSet:
+				if len(stack) == 0 {
+					continue
+				}
+				f := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				if !f.dense || f.holes == 0 {
+					continue
+				}
+				if density := float64(f.length-f.holes) / float64(f.length); density < maxDensity {
+					issues = append(issues, LintIssue{
+						Rule:    "holey-dense-array",
+						Offset:  f.offset,
+						Message: fmt.Sprintf("dense array of length %d has %d holes; the sparse encoding stores only present elements", f.length, f.holes),
+					})
+				}
+			}
+		}
+		return issues
+	}
+}
+
+// LintDuplicateKeys reports object properties that appear more than once
+// in the same object. Decoders keep the last value, so earlier ones are
+// dead weight or, more likely, a producer bug. Numeric keys count as the
+// property names they set, so 1 and "1" are duplicates.
+func LintDuplicateKeys() LintRule {
+	return func(ins []Instruction) []LintIssue {
+		var issues []LintIssue
+		var stack []map[string]bool // nil for containers other than objects
+		for _, in := range ins {
+			if in.Key {
+				if len(stack) == 0 || stack[len(stack)-1] == nil {
+					continue
+				}
+				name, ok := propertyName(in.Operand)
+				if !ok {
+					continue
+				}
+				if seen := stack[len(stack)-1]; seen[name] {
+					issues = append(issues, LintIssue{
+						Rule:    "duplicate-keys",
+						Offset:  in.Offset,
+						Message: fmt.Sprintf("property %q is set more than once", name),
+					})
+				} else {
+					seen[name] = true
+				}
+				continue
+			}
+			switch in.Tag {
+			case tagBeginJSObject:
+				stack = append(stack, make(map[string]bool))
+			case tagBeginDenseArray, tagBeginSparseArray, tagBeginMap, tagBeginSet:
+				stack = append(stack, nil)
+			case tagEndJSObject, tagEndDenseArray, tagEndSparseArray, tagEndMap, tagEndSet:
+				if len(stack) > 0 {
+					stack = stack[:len(stack)-1]
+				}
+			}
+		}
+		return issues
+	}
+}
@@ -0,0 +1,85 @@
+package v8serialize
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	holey, err := Serialize(Array([]Value{Int32(1), Hole(), Hole(), Hole()}), WithSparseArrayDensity(0))
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	records, err := SerializeGo([]interface{}{
+		map[string]interface{}{"id": 1},
+		map[string]interface{}{"id": 2},
+	})
+	if err != nil {
+		t.Fatalf("SerializeGo failed: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		rule LintRule
+		want []LintIssue
+	}{
+		{"duplicate-keys",
+			// {a: 1, 1: 2, a: 3, "1": 4}
+			[]byte{0xff, 0x0f, 0x6f, 0x22, 0x01, 0x61, 0x49, 0x02, 0x49, 0x02, 0x49, 0x04,
+				0x22, 0x01, 0x61, 0x49, 0x06, 0x22, 0x01, 0x31, 0x49, 0x08, 0x7b, 0x04},
+			LintDuplicateKeys(),
+			[]LintIssue{
+				{"duplicate-keys", 12, `property "a" is set more than once`},
+				{"duplicate-keys", 17, `property "1" is set more than once`},
+			}},
+		{"two-byte-latin1",
+			// ["ab" as two-byte, "é" as two-byte]
+			[]byte{0xff, 0x0f, 0x41, 0x02, 0x63, 0x04, 0x61, 0x00, 0x62, 0x00, 0x63, 0x02, 0xe9, 0x00, 0x24, 0x00, 0x02},
+			LintTwoByteLatin1(4),
+			[]LintIssue{{"two-byte-latin1", 4, "4-byte two-byte string fits in 2 bytes as one-byte"}}},
+		{"holey-dense-array", holey, LintHoleyDenseArrays(0.5),
+			[]LintIssue{{"holey-dense-array", 2, "dense array of length 4 has 3 holes; the sparse encoding stores only present elements"}}},
+		{"holey-dense-array-dense-enough", holey, LintHoleyDenseArrays(0.25), nil},
+		{"reference-table", records, LintReferenceTable(3),
+			[]LintIssue{{"reference-table", 0, "3 objects enter the reference table but none is referenced"}}},
+		{"reference-table-small", records, LintReferenceTable(4), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Lint(tt.data, tt.rule); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLintDefaults(t *testing.T) {
+	data, err := SerializeGo(map[string]interface{}{"name": "ok", "tags": []interface{}{"a"}})
+	if err != nil {
+		t.Fatalf("SerializeGo failed: %v", err)
+	}
+	if issues := Lint(data); len(issues) != 0 {
+		t.Errorf("clean payload: got %v", issues)
+	}
+
+	issues := Lint([]byte{0xff, 0x0f, 0x41, 0x02, 0x54})
+	if len(issues) != 1 || issues[0].Rule != "malformed" || issues[0].Offset != 5 {
+		t.Fatalf("truncated payload: got %v", issues)
+	}
+	if !strings.HasPrefix(issues[0].String(), "0005 malformed: v8serialize: malformed data") {
+		t.Errorf("String() = %q", issues[0].String())
+	}
+}
+
+func TestLintPooledBuffers(t *testing.T) {
+	for _, fixture := range []string{"native-buffer-pooled", "native-buffers-pooled", "buffer-pooled"} {
+		t.Run(fixture, func(t *testing.T) {
+			data, _ := loadFixture(t, fixture)
+			if issues := Lint(data); len(issues) != 0 {
+				t.Errorf("got %v", issues)
+			}
+		})
+	}
+}
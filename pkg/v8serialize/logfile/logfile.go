@@ -0,0 +1,284 @@
+// Package logfile implements an append-only log of serialized values, a
+// durable queue between producers and consumers of structured-clone
+// events. Each record is framed as
+//
+//	uint32 payload length, uint32 CRC-32 (IEEE) of the payload, payload
+//
+// with little-endian integers and no file header, so a Node producer
+// (20.15 or later, for zlib.crc32) appends records with:
+//
+//	const payload = v8.serialize(event);
+//	const frame = Buffer.alloc(8);
+//	frame.writeUInt32LE(payload.length, 0);
+//	frame.writeUInt32LE(zlib.crc32(payload), 4);
+//	fs.writeSync(fd, Buffer.concat([frame, payload]));
+//
+// A crash can leave a partly written record at the end of the file. Open
+// removes such a torn tail before appending, and Reader reports it as
+// ErrTornRecord, so consumers can stop there and retry later.
+package logfile
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"iter"
+	"os"
+	"sync"
+
+	"github.com/acolita/v8wire/pkg/v8serialize"
+)
+
+// frameSize is the size of a record's length and checksum.
+const frameSize = 8
+
+// DefaultMaxRecordSize is the default largest payload a Reader accepts.
+const DefaultMaxRecordSize = 64 << 20
+
+var (
+	// ErrTornRecord is returned for a record cut short by the end of the
+	// log, as a crash during an append leaves it.
+	ErrTornRecord = errors.New("logfile: torn record at end of log")
+	// ErrChecksum is returned for a complete record whose payload does not
+	// match its checksum.
+	ErrChecksum = errors.New("logfile: checksum mismatch")
+	// ErrRecordTooLarge is returned for a record longer than the Reader's
+	// maximum.
+	ErrRecordTooLarge = errors.New("logfile: record too large")
+)
+
+// Log appends records to a log file. Its methods are safe for concurrent
+// use.
+type Log struct {
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+	sync bool
+}
+
+// Option configures Open.
+type Option func(*Log)
+
+// WithSync makes every append call fsync before returning, so appended
+// records survive power loss. Without it, call Sync at the points that
+// need durability.
+func WithSync() Option {
+	return func(l *Log) {
+		l.sync = true
+	}
+}
+
+// Open opens the log at path for appending, creating it if needed. If the
+// log ends with a torn record, or with a last record whose checksum does
+// not match, Open truncates it away; a damaged record followed by others
+// is left in place and fails with ErrChecksum.
+func Open(path string, opts ...Option) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	l := &Log{f: f}
+	for _, opt := range opts {
+		opt(l)
+	}
+	if l.size, err = recoverTail(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(l.size, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// recoverTail scans f and truncates it after its last intact record,
+// returning the new size.
+func recoverTail(f *os.File) (int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	size := info.Size()
+	// No record is longer than the file; a longer length is a torn frame.
+	r := NewReader(io.NewSectionReader(f, 0, size), WithMaxRecordSize(int(size)))
+	for {
+		_, err := r.Next()
+		if err == io.EOF {
+			return size, nil
+		}
+		if err == nil {
+			continue
+		}
+		torn := errors.Is(err, ErrTornRecord) || errors.Is(err, ErrRecordTooLarge)
+		last := errors.Is(err, ErrChecksum) && r.next == size
+		if !torn && !last {
+			return 0, err
+		}
+		good := r.Offset()
+		if err := f.Truncate(good); err != nil {
+			return 0, err
+		}
+		return good, f.Sync()
+	}
+}
+
+// Append writes a record holding payload and returns its offset in the
+// log.
+func (l *Log) Append(payload []byte) (int64, error) {
+	record := make([]byte, frameSize, frameSize+len(payload))
+	binary.LittleEndian.PutUint32(record, uint32(len(payload)))
+	binary.LittleEndian.PutUint32(record[4:], crc32.ChecksumIEEE(payload))
+	record = append(record, payload...)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	offset := l.size
+	n, err := l.f.Write(record)
+	l.size += int64(n)
+	if err != nil {
+		return 0, err
+	}
+	if l.sync {
+		if err := l.f.Sync(); err != nil {
+			return 0, err
+		}
+	}
+	return offset, nil
+}
+
+// AppendValue serializes v and appends it.
+func (l *Log) AppendValue(v v8serialize.Value, opts ...v8serialize.SerializerOption) (int64, error) {
+	payload, err := v8serialize.Serialize(v, opts...)
+	if err != nil {
+		return 0, err
+	}
+	return l.Append(payload)
+}
+
+// Size returns the length of the log in bytes, which is the offset the
+// next record will be written at.
+func (l *Log) Size() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.size
+}
+
+// Sync commits the log to stable storage.
+func (l *Log) Sync() error {
+	return l.f.Sync()
+}
+
+// Close closes the log file.
+func (l *Log) Close() error {
+	return l.f.Close()
+}
+
+// Reader iterates over the records of a log.
+type Reader struct {
+	r       *bufio.Reader
+	offset  int64 // position after the last record read
+	maxSize int
+	next    int64 // position after the record being read
+}
+
+// ReaderOption configures NewReader.
+type ReaderOption func(*Reader)
+
+// WithMaxRecordSize sets the largest payload the Reader accepts (default
+// DefaultMaxRecordSize), bounding the memory a damaged length can claim.
+// Negative values remove the limit.
+func WithMaxRecordSize(n int) ReaderOption {
+	return func(r *Reader) {
+		r.maxSize = n
+	}
+}
+
+// NewReader returns a Reader over the records in r, which starts at a
+// record boundary (usually the start of the log, or an offset returned by
+// Log.Append or Reader.Offset).
+func NewReader(r io.Reader, opts ...ReaderOption) *Reader {
+	lr := &Reader{r: bufio.NewReader(r), maxSize: DefaultMaxRecordSize}
+	for _, opt := range opts {
+		opt(lr)
+	}
+	return lr
+}
+
+// Next returns the payload of the next record. It returns io.EOF at a
+// clean end of the log, ErrTornRecord if the log ends inside a record, and
+// ErrChecksum or ErrRecordTooLarge for damaged records. After an error,
+// Offset still reports the end of the last good record, where a new
+// Reader can resume, for example once a producer has finished writing.
+func (r *Reader) Next() ([]byte, error) {
+	r.next = r.offset
+	var frame [frameSize]byte
+	n, err := io.ReadFull(r.r, frame[:])
+	r.next += int64(n)
+	switch {
+	case err == io.EOF:
+		return nil, io.EOF
+	case err != nil:
+		return nil, r.torn(err)
+	}
+	length := binary.LittleEndian.Uint32(frame[:])
+	if r.maxSize >= 0 && uint64(length) > uint64(r.maxSize) {
+		return nil, fmt.Errorf("%w: %d bytes at offset %d", ErrRecordTooLarge, length, r.offset)
+	}
+	payload := make([]byte, length)
+	n, err = io.ReadFull(r.r, payload)
+	r.next += int64(n)
+	if err != nil {
+		return nil, r.torn(err)
+	}
+	if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(frame[4:]) {
+		return nil, fmt.Errorf("%w: record at offset %d", ErrChecksum, r.offset)
+	}
+	r.offset = r.next
+	return payload, nil
+}
+
+// torn converts an unexpected end of input into ErrTornRecord.
+func (r *Reader) torn(err error) error {
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: offset %d", ErrTornRecord, r.offset)
+	}
+	return err
+}
+
+// All returns an iterator over the remaining payloads for range loops. It
+// stops at the end of the log, or after yielding the first error other
+// than io.EOF:
+//
+//	for payload, err := range r.All() { ... }
+func (r *Reader) All() iter.Seq2[[]byte, error] {
+	return func(yield func([]byte, error) bool) {
+		for {
+			payload, err := r.Next()
+			if err == io.EOF {
+				return
+			}
+			if !yield(payload, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// NextValue decodes the payload of the next record.
+func (r *Reader) NextValue(opts ...v8serialize.Option) (v8serialize.Value, error) {
+	payload, err := r.Next()
+	if err != nil {
+		return v8serialize.Value{}, err
+	}
+	return v8serialize.Deserialize(payload, opts...)
+}
+
+// Offset returns the position after the last record read successfully,
+// relative to where the Reader started.
+func (r *Reader) Offset() int64 {
+	return r.offset
+}
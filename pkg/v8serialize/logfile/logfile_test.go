@@ -0,0 +1,190 @@
+package logfile
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/acolita/v8wire/pkg/v8serialize"
+)
+
+func appendValues(t *testing.T, path string, values ...string) []int64 {
+	t.Helper()
+	l, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer l.Close()
+	var offsets []int64
+	for _, s := range values {
+		off, err := l.AppendValue(v8serialize.String(s))
+		if err != nil {
+			t.Fatalf("AppendValue failed: %v", err)
+		}
+		offsets = append(offsets, off)
+	}
+	return offsets
+}
+
+func readAll(t *testing.T, path string) ([]string, error) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var got []string
+	for payload, err := range NewReader(f).All() {
+		if err != nil {
+			return got, err
+		}
+		v, err := v8serialize.Deserialize(payload)
+		if err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+		got = append(got, v.AsString())
+	}
+	return got, nil
+}
+
+func TestAppendAndRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+	offsets := appendValues(t, path, "a", "bb")
+	offsets = append(offsets, appendValues(t, path, "ccc")...) // reopened
+
+	got, err := readAll(t, path)
+	if err != nil || len(got) != 3 || got[0] != "a" || got[1] != "bb" || got[2] != "ccc" {
+		t.Fatalf("read %q, %v", got, err)
+	}
+
+	// Resume from an offset returned by Append.
+	f, _ := os.Open(path)
+	defer f.Close()
+	f.Seek(offsets[2], io.SeekStart)
+	r := NewReader(f)
+	if v, err := r.NextValue(); err != nil || v.AsString() != "ccc" {
+		t.Errorf("NextValue = %v, %v", v.GoString(), err)
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	tests := []struct {
+		name    string
+		damage  func(data []byte) []byte
+		want    []string
+		openErr error
+	}{
+		{"torn-payload", func(d []byte) []byte { return d[:len(d)-2] }, []string{"a", "bb"}, nil},
+		{"torn-frame", func(d []byte) []byte { return d[:len(d)-(frameSize+7)+3] }, []string{"a", "bb"}, nil},
+		{"torn-length", func(d []byte) []byte { return append(d, 0xff, 0xff, 0xff, 0x7f, 0, 0, 0, 0) }, []string{"a", "bb", "ccc"}, nil},
+		{"bad-last-checksum", func(d []byte) []byte { d[len(d)-1] ^= 0xff; return d }, []string{"a", "bb"}, nil},
+		{"bad-middle-checksum", func(d []byte) []byte { d[frameSize+3] ^= 0xff; return d }, nil, ErrChecksum},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "events.log")
+			appendValues(t, path, "a", "bb", "ccc")
+			data, _ := os.ReadFile(path)
+			os.WriteFile(path, tt.damage(data), 0o644)
+
+			if tt.openErr == nil {
+				if _, err := readAll(t, path); err == nil {
+					t.Fatal("damaged log read without error")
+				}
+			}
+			l, err := Open(path)
+			if !errors.Is(err, tt.openErr) {
+				t.Fatalf("Open: expected %v, got %v", tt.openErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if _, err := l.AppendValue(v8serialize.String("new")); err != nil {
+				t.Fatalf("AppendValue failed: %v", err)
+			}
+			l.Close()
+			got, err := readAll(t, path)
+			want := append(tt.want, "new")
+			if err != nil || len(got) != len(want) {
+				t.Fatalf("read %q, %v; want %q", got, err, want)
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Fatalf("read %q, want %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	var buf bytes.Buffer
+	path := filepath.Join(t.TempDir(), "events.log")
+	appendValues(t, path, "a", "bb")
+	data, _ := os.ReadFile(path)
+	buf.Write(data[:len(data)-1])
+
+	r := NewReader(&buf)
+	if _, err := r.Next(); err != nil {
+		t.Fatalf("Next failed: %v", err)
+	}
+	good := r.Offset()
+	if _, err := r.Next(); !errors.Is(err, ErrTornRecord) {
+		t.Errorf("expected ErrTornRecord, got %v", err)
+	}
+	if r.Offset() != good {
+		t.Errorf("Offset moved to %d after a torn record, want %d", r.Offset(), good)
+	}
+
+	r = NewReader(bytes.NewReader(data), WithMaxRecordSize(4))
+	if _, err := r.Next(); !errors.Is(err, ErrRecordTooLarge) {
+		t.Errorf("expected ErrRecordTooLarge, got %v", err)
+	}
+}
+
+func TestNodeProducer(t *testing.T) {
+	if _, err := exec.LookPath("node"); err != nil {
+		t.Skip("Node.js not available")
+	}
+
+	const script = `
+const fs = require('fs'), v8 = require('v8'), zlib = require('zlib');
+const fd = fs.openSync(process.argv[1], 'a');
+for (const event of [{type: 'start', n: 1}, {type: 'stop', n: 2}]) {
+  const payload = v8.serialize(event);
+  const frame = Buffer.alloc(8);
+  frame.writeUInt32LE(payload.length, 0);
+  frame.writeUInt32LE(zlib.crc32(payload), 4);
+  fs.writeSync(fd, Buffer.concat([frame, payload]));
+}`
+	path := filepath.Join(t.TempDir(), "events.log")
+	if output, err := exec.Command("node", "-e", script, path).CombinedOutput(); err != nil {
+		t.Fatalf("Node.js producer failed: %v\n%s", err, output)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r := NewReader(f)
+	for _, want := range []string{"start", "stop"} {
+		v, err := r.NextValue()
+		if err != nil {
+			t.Fatalf("NextValue failed: %v", err)
+		}
+		if got := v.Field("type").AsString(); got != want {
+			t.Errorf("type = %q, want %q", got, want)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+package v8serialize
+
+import (
+	"math/big"
+	"reflect"
+	"unsafe"
+)
+
+// Approximate heap costs on 64-bit platforms. Map entry costs include a
+// share of bucket overhead.
+const (
+	valueSize      = int(unsafe.Sizeof(Value{}))
+	stringHeader   = int(unsafe.Sizeof(""))
+	sliceHeader    = int(unsafe.Sizeof([]byte(nil)))
+	mapHeader      = 48
+	mapEntryExtra  = 8
+	minAllocSize   = 8 // smallest size class, used for boxed numbers
+	bigIntSize     = int(unsafe.Sizeof(big.Int{}))
+	mapEntrySize   = int(unsafe.Sizeof(MapEntry{}))
+	jsMapSize      = int(unsafe.Sizeof(JSMap{}))
+	jsSetSize      = int(unsafe.Sizeof(JSSet{}))
+	regExpSize     = int(unsafe.Sizeof(RegExp{}))
+	jsErrorSize    = int(unsafe.Sizeof(JSError{}))
+	boxedSize      = int(unsafe.Sizeof(BoxedPrimitive{}))
+	bufferViewSize = int(unsafe.Sizeof(ArrayBufferView{}))
+	detachedSize   = int(unsafe.Sizeof(DetachedArrayBuffer{}))
+	sharedRefSize  = int(unsafe.Sizeof(SharedArrayBufferRef{}))
+	wordSize       = int(unsafe.Sizeof(big.Word(0)))
+)
+
+// MemSize estimates the heap memory retained by v, in bytes, for caches
+// that evict decoded payloads by size. Containers and buffers reachable
+// more than once, through back-references or views sharing an
+// ArrayBuffer, are counted once, so cyclic values are fine. The result is
+// an estimate: allocator rounding and map growth are approximated.
+func MemSize(v Value) int {
+	m := memSizer{seen: make(map[uintptr]bool)}
+	return valueSize + m.data(v)
+}
+
+type memSizer struct {
+	seen map[uintptr]bool
+}
+
+// first reports whether the allocation at p has not been counted yet.
+// Empty allocations (p == 0) are always skipped.
+func (m *memSizer) first(p uintptr) bool {
+	if p == 0 || m.seen[p] {
+		return false
+	}
+	m.seen[p] = true
+	return true
+}
+
+// data returns the size of what v.data points to, excluding the Value
+// itself.
+func (m *memSizer) data(v Value) int {
+	switch v.typ {
+	case TypeInt32, TypeUint32, TypeDouble:
+		return minAllocSize
+	case TypeString:
+		return stringHeader + len(v.data.(string))
+	case TypeBigInt:
+		return bigIntSize + cap(v.data.(*big.Int).Bits())*wordSize
+	case TypeDate:
+		return minAllocSize // boxed float64 time value
+	case TypeObject:
+		obj := v.data.(*object)
+		if !m.first(reflect.ValueOf(obj).Pointer()) {
+			return 0
+		}
+		n := mapHeader + sliceHeader + cap(obj.order)*stringHeader
+		for k, prop := range obj.props {
+			n += stringHeader + len(k) + valueSize + mapEntryExtra + m.data(prop)
+		}
+		return n
+	case TypeArray:
+		return sliceHeader + m.values(v.data.([]Value))
+	case TypeArrayBuffer:
+		return sliceHeader + m.bytes(v.data.([]byte))
+	case TypeTypedArray:
+		view := v.data.(*ArrayBufferView)
+		if !m.first(uintptr(unsafe.Pointer(view))) {
+			return 0
+		}
+		return bufferViewSize + len(view.Type) + m.bytes(view.Buffer)
+	case TypeDetachedArrayBuffer:
+		b := v.data.(*DetachedArrayBuffer)
+		if !m.first(uintptr(unsafe.Pointer(b))) {
+			return 0
+		}
+		return detachedSize + len(b.View)
+	case TypeSharedArrayBuffer:
+		b := v.data.(*SharedArrayBufferRef)
+		if !m.first(uintptr(unsafe.Pointer(b))) {
+			return 0
+		}
+		return sharedRefSize + len(b.View)
+	case TypeMap:
+		jsMap := v.data.(*JSMap)
+		if !m.first(uintptr(unsafe.Pointer(jsMap))) {
+			return 0
+		}
+		n := jsMapSize + cap(jsMap.Entries)*mapEntrySize
+		for _, e := range jsMap.Entries {
+			n += m.data(e.Key) + m.data(e.Value)
+		}
+		return n
+	case TypeSet:
+		set := v.data.(*JSSet)
+		if !m.first(uintptr(unsafe.Pointer(set))) {
+			return 0
+		}
+		return jsSetSize + m.values(set.Values)
+	case TypeRegExp:
+		re := v.data.(*RegExp)
+		if !m.first(uintptr(unsafe.Pointer(re))) {
+			return 0
+		}
+		return regExpSize + len(re.Pattern) + len(re.Flags)
+	case TypeError:
+		e := v.data.(*JSError)
+		if !m.first(uintptr(unsafe.Pointer(e))) {
+			return 0
+		}
+		n := jsErrorSize + len(e.Name) + len(e.Message) + len(e.Stack)
+		if e.Cause != nil {
+			n += valueSize + m.data(*e.Cause)
+		}
+		return n
+	case TypeBoxedPrimitive:
+		boxed := v.data.(*BoxedPrimitive)
+		if !m.first(uintptr(unsafe.Pointer(boxed))) {
+			return 0
+		}
+		return boxedSize + m.data(boxed.Value)
+	}
+	return 0 // undefined, null, booleans and holes need no allocation
+}
+
+// values sizes the backing array of a []Value and the elements' data.
+func (m *memSizer) values(vs []Value) int {
+	if !m.first(uintptr(unsafe.Pointer(unsafe.SliceData(vs)))) {
+		return 0
+	}
+	n := cap(vs) * valueSize
+	for _, v := range vs {
+		n += m.data(v)
+	}
+	return n
+}
+
+// bytes sizes the backing array of a byte buffer.
+func (m *memSizer) bytes(b []byte) int {
+	if !m.first(uintptr(unsafe.Pointer(unsafe.SliceData(b)))) {
+		return 0
+	}
+	return cap(b)
+}
@@ -0,0 +1,43 @@
+package v8serialize
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMemSize(t *testing.T) {
+	if got, want := MemSize(String(strings.Repeat("x", 1000)))-MemSize(String("")), 1000; got != want {
+		t.Errorf("1000-byte string adds %d bytes, want %d", got, want)
+	}
+	if MemSize(Null()) != MemSize(Bool(true)) {
+		t.Error("null and booleans should cost only the Value itself")
+	}
+
+	shared := Object(map[string]Value{"name": String(strings.Repeat("y", 500))})
+	copyOf := Object(map[string]Value{"name": String(strings.Repeat("y", 500))})
+	once := MemSize(Array([]Value{shared, shared}))
+	twice := MemSize(Array([]Value{shared, copyOf}))
+	if once >= twice || twice-once < 500 {
+		t.Errorf("shared object counted twice: shared %d, distinct %d", once, twice)
+	}
+
+	buf := make([]byte, 4096)
+	view := Value{typ: TypeTypedArray, data: &ArrayBufferView{Buffer: buf, ByteLength: 4096, Type: "Uint8Array"}}
+	withView := MemSize(Array([]Value{ArrayBuffer(buf), view}))
+	if withView > MemSize(ArrayBuffer(buf))+1024 {
+		t.Errorf("view over a shared buffer counted its bytes again: %d", withView)
+	}
+}
+
+func TestMemSizeCyclic(t *testing.T) {
+	for _, name := range []string{"circular-self", "circular-mutual", "array-dense-circular-self", "set-circular"} {
+		data, _ := loadFixture(t, name)
+		v, err := Deserialize(data)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if n := MemSize(v); n <= valueSize {
+			t.Errorf("%s: MemSize = %d", name, n)
+		}
+	}
+}
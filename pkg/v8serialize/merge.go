@@ -0,0 +1,95 @@
+package v8serialize
+
+import (
+	"reflect"
+	"slices"
+)
+
+// MergeStrategy controls how Merge combines two arrays.
+type MergeStrategy uint8
+
+const (
+	// MergeReplaceArrays lets an array in src replace the one in dst.
+	MergeReplaceArrays MergeStrategy = iota
+	// MergeConcatArrays appends the elements of src's array to dst's.
+	MergeConcatArrays
+	// MergeArraysByIndex merges elements at the same index, like lodash's
+	// merge; holes in src keep dst's element.
+	MergeArraysByIndex
+)
+
+// Merge deep-merges src into dst, for overlaying a customized document on a
+// base one. Objects are merged property by property and Maps entry by entry
+// (keys compared with SameValueZero), recursively; arrays follow strategy;
+// anywhere else the value from src wins. Neither input is modified: the
+// result shares every subtree it does not need to change.
+func Merge(dst, src Value, strategy MergeStrategy) Value {
+	m := merger{strategy: strategy, active: make(map[[2]uintptr]bool)}
+	return m.merge(dst, src)
+}
+
+type merger struct {
+	strategy MergeStrategy
+	active   map[[2]uintptr]bool // container pairs being merged, to stop on cycles
+}
+
+func (m *merger) merge(dst, src Value) Value {
+	if dst.typ != src.typ {
+		return src
+	}
+	var pair [2]uintptr
+	switch dst.typ {
+	case TypeObject, TypeArray, TypeMap:
+		pair = [2]uintptr{reflect.ValueOf(dst.data).Pointer(), reflect.ValueOf(src.data).Pointer()}
+		if m.active[pair] {
+			return src
+		}
+		m.active[pair] = true
+		defer delete(m.active, pair)
+	}
+
+	switch dst.typ {
+	case TypeObject:
+		out := dst.data.(*object).clone()
+		src.Range(func(k string, sv Value) bool {
+			if dv, ok := out.props[k]; ok {
+				out.props[k] = m.merge(dv, sv)
+			} else {
+				out.set(k, sv)
+			}
+			return true
+		})
+		return Value{typ: TypeObject, data: out}
+
+	case TypeMap:
+		out := slices.Clone(dst.data.(*JSMap).Entries)
+		for _, se := range src.data.(*JSMap).Entries {
+			i := slices.IndexFunc(out, func(de MapEntry) bool { return SameValueZero(de.Key, se.Key) })
+			if i < 0 {
+				out = append(out, se)
+			} else {
+				out[i].Value = m.merge(out[i].Value, se.Value)
+			}
+		}
+		return Value{typ: TypeMap, data: &JSMap{Entries: out}}
+
+	case TypeArray:
+		d, s := dst.data.([]Value), src.data.([]Value)
+		switch m.strategy {
+		case MergeConcatArrays:
+			return Array(slices.Concat(d, s))
+		case MergeArraysByIndex:
+			out := slices.Clone(d)
+			for i, sv := range s {
+				switch {
+				case i >= len(out):
+					out = append(out, sv)
+				case sv.typ != TypeHole:
+					out[i] = m.merge(out[i], sv)
+				}
+			}
+			return Array(out)
+		}
+	}
+	return src
+}
@@ -0,0 +1,76 @@
+package v8serialize
+
+import "testing"
+
+func TestMerge(t *testing.T) {
+	base := Object(map[string]Value{
+		"name": String("svc"),
+		"db":   Object(map[string]Value{"host": String("localhost"), "port": Int32(5432)}),
+		"tags": Array([]Value{String("a"), String("b")}),
+	})
+	overlay := Object(map[string]Value{
+		"db":   Object(map[string]Value{"host": String("db.prod")}),
+		"tags": Array([]Value{Hole(), String("c")}),
+		"new":  Bool(true),
+	})
+
+	tests := []struct {
+		strategy MergeStrategy
+		tags     string
+	}{
+		{MergeReplaceArrays, "[<hole>, c]"},
+		{MergeConcatArrays, "[a, b, <hole>, c]"},
+		{MergeArraysByIndex, "[a, c]"},
+	}
+	for _, tt := range tests {
+		got := Merge(base, overlay, tt.strategy)
+		if got.Field("db").Field("host").AsString() != "db.prod" || got.Field("db").Field("port").AsInt32() != 5432 {
+			t.Errorf("strategy %d: db not deep-merged: %#v", tt.strategy, got.Field("db"))
+		}
+		if got.Field("name").AsString() != "svc" || !got.Field("new").AsBool() {
+			t.Errorf("strategy %d: top-level keys wrong: %#v", tt.strategy, got)
+		}
+		if s := formatElems(got.Field("tags")); s != tt.tags {
+			t.Errorf("strategy %d: tags = %s, want %s", tt.strategy, s, tt.tags)
+		}
+	}
+	if base.Field("db").Field("host").AsString() != "localhost" || base.Field("tags").Len() != 2 {
+		t.Error("Merge modified dst")
+	}
+
+	m1 := Value{typ: TypeMap, data: &JSMap{Entries: []MapEntry{{Key: Int32(1), Value: String("x")}}}}
+	m2 := Value{typ: TypeMap, data: &JSMap{Entries: []MapEntry{{Key: Double(1), Value: String("y")}, {Key: Int32(2), Value: String("z")}}}}
+	entries := Merge(m1, m2, MergeReplaceArrays).Interface().(*JSMap).Entries
+	if len(entries) != 2 || entries[0].Value.AsString() != "y" || entries[1].Value.AsString() != "z" {
+		t.Errorf("Map merge = %v, want 1 => y, 2 => z", entries)
+	}
+
+	if got := Merge(Int32(1), String("s"), MergeReplaceArrays); got.AsString() != "s" {
+		t.Errorf("mismatched types: got %#v, want src", got)
+	}
+}
+
+func TestMergeCyclic(t *testing.T) {
+	props := map[string]Value{}
+	self := Object(props)
+	props["self"] = self
+	got := Merge(self, self, MergeReplaceArrays)
+	if got.Type() != TypeObject {
+		t.Errorf("got %#v", got)
+	}
+}
+
+func formatElems(v Value) string {
+	s := "["
+	for i, e := range v.AsArray() {
+		if i > 0 {
+			s += ", "
+		}
+		if e.IsHole() {
+			s += "<hole>"
+		} else {
+			s += e.String()
+		}
+	}
+	return s + "]"
+}
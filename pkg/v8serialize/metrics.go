@@ -0,0 +1,38 @@
+package v8serialize
+
+import "time"
+
+// MetricsSink receives statistics about encode and decode operations.
+// Implementations are typically thin adapters over a metrics library
+// (Prometheus counters and histograms, expvar, etc.) and must be safe for
+// concurrent use if shared between goroutines.
+type MetricsSink interface {
+	// ObserveDecode is called once per Deserialize call, including failed ones.
+	ObserveDecode(s Stats)
+
+	// ObserveEncode is called once per Serialize or SerializeGo call,
+	// including failed ones.
+	ObserveEncode(s Stats)
+
+	// LimitExceeded is called when a configured limit rejects a payload.
+	// The limit is identified by a short name such as "max_depth",
+	// "max_size" or "max_array_len".
+	LimitExceeded(limit string)
+}
+
+// Stats describes a single encode or decode operation.
+type Stats struct {
+	// Bytes is the payload size: the input length for decodes and the
+	// number of bytes produced for encodes.
+	Bytes int
+
+	// Duration is the wall-clock time spent in the operation.
+	Duration time.Duration
+
+	// Tags counts the value tags read or written, keyed by tag byte.
+	// Use TagName to turn keys into labels.
+	Tags map[byte]int
+
+	// Err is the error returned by the operation, if any.
+	Err error
+}
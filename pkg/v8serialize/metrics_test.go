@@ -0,0 +1,96 @@
+package v8serialize
+
+import (
+	"errors"
+	"testing"
+)
+
+// recordingSink is a MetricsSink that stores everything it receives.
+type recordingSink struct {
+	decodes []Stats
+	encodes []Stats
+	limits  []string
+}
+
+func (r *recordingSink) ObserveDecode(s Stats)      { r.decodes = append(r.decodes, s) }
+func (r *recordingSink) ObserveEncode(s Stats)      { r.encodes = append(r.encodes, s) }
+func (r *recordingSink) LimitExceeded(limit string) { r.limits = append(r.limits, limit) }
+
+func TestWithMetricsDecode(t *testing.T) {
+	binData, _ := loadFixture(t, "array-mixed")
+	sink := &recordingSink{}
+
+	if _, err := Deserialize(binData, WithMetrics(sink)); err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+
+	if len(sink.decodes) != 1 {
+		t.Fatalf("expected 1 decode observation, got %d", len(sink.decodes))
+	}
+	stats := sink.decodes[0]
+	if stats.Bytes != len(binData) {
+		t.Errorf("Bytes: got %d, want %d", stats.Bytes, len(binData))
+	}
+	if stats.Err != nil {
+		t.Errorf("Err: got %v, want nil", stats.Err)
+	}
+	if stats.Tags[tagBeginDenseArray] != 1 {
+		t.Errorf("BeginDenseArray count: got %d, want 1", stats.Tags[tagBeginDenseArray])
+	}
+	if len(sink.limits) != 0 {
+		t.Errorf("expected no limit events, got %v", sink.limits)
+	}
+}
+
+func TestWithMetricsLimitExceeded(t *testing.T) {
+	binData, _ := loadFixture(t, "object-deep-100")
+	sink := &recordingSink{}
+
+	_, err := Deserialize(binData, WithMaxDepth(10), WithMetrics(sink))
+	if !errors.Is(err, ErrMaxDepthExceeded) {
+		t.Fatalf("expected ErrMaxDepthExceeded, got %v", err)
+	}
+
+	if len(sink.limits) != 1 || sink.limits[0] != "max_depth" {
+		t.Errorf("limits: got %v, want [max_depth]", sink.limits)
+	}
+	if len(sink.decodes) != 1 || !errors.Is(sink.decodes[0].Err, ErrMaxDepthExceeded) {
+		t.Errorf("expected failed decode to be observed, got %+v", sink.decodes)
+	}
+}
+
+func TestWithEncodeMetrics(t *testing.T) {
+	sink := &recordingSink{}
+
+	data, err := SerializeGo(map[string]interface{}{
+		"name": "Alice",
+		"tags": []interface{}{"a", "b"},
+	}, WithEncodeMetrics(sink))
+	if err != nil {
+		t.Fatalf("SerializeGo failed: %v", err)
+	}
+
+	if len(sink.encodes) != 1 {
+		t.Fatalf("expected 1 encode observation, got %d", len(sink.encodes))
+	}
+	stats := sink.encodes[0]
+	if stats.Bytes != len(data) {
+		t.Errorf("Bytes: got %d, want %d", stats.Bytes, len(data))
+	}
+	if stats.Tags[tagBeginJSObject] != 1 {
+		t.Errorf("BeginJSObject count: got %d, want 1", stats.Tags[tagBeginJSObject])
+	}
+	if stats.Tags[tagOneByteString] != 5 {
+		t.Errorf("OneByteString count: got %d, want 5", stats.Tags[tagOneByteString])
+	}
+
+	// Decoding the result should observe the same tag histogram.
+	if _, err := Deserialize(data, WithMetrics(sink)); err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	for tag, n := range stats.Tags {
+		if got := sink.decodes[0].Tags[tag]; got != n {
+			t.Errorf("%s: decode count %d, encode count %d", TagName(tag), got, n)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package v8serialize
+
+import (
+	"math"
+	"reflect"
+	"sort"
+)
+
+// NormalizeOption configures Normalize.
+type NormalizeOption func(*normalizer)
+
+// WithSetOrder keeps Set members in their original order instead of
+// sorting them. Use it when insertion order matters to the consumer.
+func WithSetOrder() NormalizeOption {
+	return func(n *normalizer) {
+		n.keepSetOrder = true
+	}
+}
+
+// Normalize returns a canonical copy of v, so trees from producers with
+// different habits compare and hash alike:
+//   - numbers use Int32 when they are integers in int32 range (other than
+//     -0) and Double otherwise, whatever their original representation
+//   - Set members are deduplicated (SameValueZero) and sorted by their Key
+//     form, unless WithSetOrder is given
+//   - Map entries with SameValueZero-equal keys are collapsed into one, at
+//     the first key's position with the last value, as new Map() would do
+//
+// Shared and cyclic structure is preserved. Sets containing cyclic values
+// cannot be sorted and keep their order.
+func Normalize(v Value, opts ...NormalizeOption) Value {
+	n := &normalizer{done: make(map[uintptr]Value)}
+	for _, opt := range opts {
+		opt(n)
+	}
+	return n.value(v)
+}
+
+type normalizer struct {
+	keepSetOrder bool
+	done         map[uintptr]Value // normalized containers, by input identity
+}
+
+// canonicalNumber returns f as an Int32 when exactly representable, else as
+// a Double.
+func canonicalNumber(f float64) Value {
+	if f >= math.MinInt32 && f <= math.MaxInt32 && f == math.Trunc(f) && (f != 0 || !math.Signbit(f)) {
+		return Int32(int32(f))
+	}
+	return Double(f)
+}
+
+func (n *normalizer) value(v Value) Value {
+	switch v.typ {
+	case TypeInt32, TypeUint32, TypeDouble:
+		return canonicalNumber(v.AsNumber())
+	case TypeObject, TypeArray, TypeMap, TypeSet, TypeError, TypeBoxedPrimitive:
+	default:
+		return v
+	}
+
+	// Empty arrays have no storage of their own to identify them by (and
+	// may sit at the start of another array's), so each gets a fresh copy.
+	if v.typ == TypeArray && len(v.data.([]Value)) == 0 {
+		return Value{typ: TypeArray, data: []Value{}}
+	}
+
+	// Containers are registered before their children are visited, so
+	// back-references resolve to the copy under construction.
+	id := reflect.ValueOf(v.data).Pointer()
+	if out, ok := n.done[id]; ok && id != 0 {
+		return out
+	}
+	switch v.typ {
+	case TypeObject:
+		in := v.data.(*object)
+		out := &object{props: make(map[string]Value, len(in.props)), order: in.keys()}
+		n.done[id] = Value{typ: TypeObject, data: out}
+		for k, prop := range in.props {
+			out.props[k] = n.value(prop)
+		}
+		return n.done[id]
+
+	case TypeArray:
+		in := v.data.([]Value)
+		out := make([]Value, len(in))
+		n.done[id] = Value{typ: TypeArray, data: out}
+		for i, e := range in {
+			out[i] = n.value(e)
+		}
+		return n.done[id]
+
+	case TypeMap:
+		out := &JSMap{}
+		n.done[id] = Value{typ: TypeMap, data: out}
+		index := make(map[svzKey]int)
+		for _, e := range v.data.(*JSMap).Entries {
+			key, val := n.value(e.Key), n.value(e.Value)
+			if k, ok := sameValueZeroKey(key); ok {
+				if i, dup := index[k]; dup {
+					out.Entries[i].Value = val
+					continue
+				}
+				index[k] = len(out.Entries)
+			}
+			out.Entries = append(out.Entries, MapEntry{Key: key, Value: val})
+		}
+		return n.done[id]
+
+	case TypeSet:
+		out := &JSSet{}
+		n.done[id] = Value{typ: TypeSet, data: out}
+		for _, e := range v.data.(*JSSet).Values {
+			out.Add(n.value(e))
+		}
+		if !n.keepSetOrder {
+			sortByKey(out.Values)
+		}
+		return n.done[id]
+
+	case TypeError:
+		in := v.data.(*JSError)
+		out := &JSError{Name: in.Name, Message: in.Message, Stack: in.Stack}
+		n.done[id] = Value{typ: TypeError, data: out}
+		if in.Cause != nil {
+			cause := n.value(*in.Cause)
+			out.Cause = &cause
+		}
+		return n.done[id]
+
+	default: // TypeBoxedPrimitive
+		in := v.data.(*BoxedPrimitive)
+		out := &BoxedPrimitive{PrimitiveType: in.PrimitiveType, Value: in.Value}
+		if in.Value.IsNumber() {
+			out.Value = Double(in.Value.AsNumber()) // Number objects always hold a double
+		}
+		n.done[id] = Value{typ: TypeBoxedPrimitive, data: out}
+		return n.done[id]
+	}
+}
+
+// sortByKey sorts values by their Key form, leaving them unchanged if any
+// of them has no key.
+func sortByKey(values []Value) {
+	forms := make([]string, len(values))
+	for i, v := range values {
+		k, err := Key(v)
+		if err != nil {
+			return
+		}
+		forms[i] = k.Form
+	}
+	sort.Sort(byForm{values, forms})
+}
+
+type byForm struct {
+	values []Value
+	forms  []string
+}
+
+func (b byForm) Len() int           { return len(b.values) }
+func (b byForm) Less(i, j int) bool { return b.forms[i] < b.forms[j] }
+func (b byForm) Swap(i, j int) {
+	b.values[i], b.values[j] = b.values[j], b.values[i]
+	b.forms[i], b.forms[j] = b.forms[j], b.forms[i]
+}
@@ -0,0 +1,96 @@
+package v8serialize
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestNormalizeNumbers(t *testing.T) {
+	tests := []struct {
+		in   Value
+		want Type
+	}{
+		{Double(3), TypeInt32},
+		{Uint32(7), TypeInt32},
+		{Uint32(math.MaxUint32), TypeDouble},
+		{Double(2.5), TypeDouble},
+		{Double(math.Copysign(0, -1)), TypeDouble},
+		{Double(math.NaN()), TypeDouble},
+		{Int32(-4), TypeInt32},
+	}
+	for _, tt := range tests {
+		got := Normalize(tt.in)
+		if got.Type() != tt.want || !SameValueZero(got, tt.in) {
+			t.Errorf("Normalize(%#v) = %#v, want a %s", tt.in, got, tt.want)
+		}
+	}
+	if !math.Signbit(Normalize(Double(math.Copysign(0, -1))).AsDouble()) {
+		t.Error("Normalize lost the sign of -0")
+	}
+}
+
+func TestNormalizeProducers(t *testing.T) {
+	// The same logical document from two producers with different habits.
+	a := Object(map[string]Value{
+		"n":   Double(1),
+		"set": Value{typ: TypeSet, data: &JSSet{Values: []Value{String("b"), Double(1), String("a")}}},
+		"map": Value{typ: TypeMap, data: &JSMap{Entries: []MapEntry{
+			{Key: Int32(1), Value: String("old")}, {Key: String("k"), Value: Null()}, {Key: Double(1), Value: String("new")},
+		}}},
+	})
+	b := Object(map[string]Value{
+		"n":   Uint32(1),
+		"set": Value{typ: TypeSet, data: &JSSet{Values: []Value{String("a"), Int32(1), String("b"), Uint32(1)}}},
+		"map": Value{typ: TypeMap, data: &JSMap{Entries: []MapEntry{
+			{Key: Uint32(1), Value: String("new")}, {Key: String("k"), Value: Null()},
+		}}},
+	})
+	ka, _ := Key(Normalize(a))
+	kb, _ := Key(Normalize(b))
+	if ka != kb {
+		t.Errorf("normalized forms differ:\n%s\n%s", ka.Form, kb.Form)
+	}
+	if _, ok := a.Field("n").Interface().(float64); !ok {
+		t.Error("Normalize modified its input")
+	}
+
+	kept := Normalize(a, WithSetOrder()).Field("set").Interface().(*JSSet).Values
+	if kept[0].AsString() != "b" {
+		t.Errorf("WithSetOrder reordered the Set: %v", kept)
+	}
+}
+
+func TestNormalizeEmptyArrays(t *testing.T) {
+	// An empty array sharing storage with a non-empty one, and two
+	// distinct empty arrays.
+	elems := []Value{Int32(1)}
+	in := Array([]Value{Array(elems), Array(elems[:0]), Array(nil), Array([]Value{})})
+	want, _ := Serialize(in)
+
+	out := Normalize(in)
+	if got, _ := Serialize(out); !bytes.Equal(got, want) {
+		t.Errorf("Serialize(Normalize(v)) = %x, want %x", got, want)
+	}
+	for i, e := range out.AsArray()[1:] {
+		if len(e.AsArray()) != 0 {
+			t.Errorf("element %d = %v, want []", i+1, e)
+		}
+	}
+}
+
+func TestNormalizeCyclic(t *testing.T) {
+	props := map[string]Value{"n": Double(2)}
+	self := Object(props)
+	props["self"] = self
+
+	got := Normalize(self)
+	inner := got.Field("self")
+	if inner.Field("n").Type() != TypeInt32 {
+		t.Errorf("nested number not normalized: %#v", inner.Field("n"))
+	}
+	inner.AsObject()["marker"] = Null()
+	if _, ok := got.AsObject()["marker"]; !ok {
+		t.Error("cycle not preserved: self does not point back to the copy")
+	}
+}
@@ -0,0 +1,217 @@
+package v8serialize
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+)
+
+var (
+	// ErrInvalidPath is returned for paths that cannot be parsed.
+	ErrInvalidPath = errors.New("v8serialize: invalid path")
+	// ErrPathNotFound is returned when a path leads through a missing
+	// property or element, or into a value that cannot contain others.
+	ErrPathNotFound = errors.New("v8serialize: path not found")
+)
+
+// pathSegment is one step of a path: an object key or Map string key, or
+// an index.
+type pathSegment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// parsePath parses the path syntax used by PositionIndex and serializer
+// errors: "items[5].name", ["a b"] for keys that are not identifiers, and
+// "" for the root.
+func parsePath(path string) ([]pathSegment, error) {
+	var segs []pathSegment
+	rest := path
+	for rest != "" {
+		switch {
+		case rest[0] == '[' && len(rest) > 1 && rest[1] == '"':
+			quoted, err := strconv.QuotedPrefix(rest[1:])
+			if err != nil || len(rest) < len(quoted)+2 || rest[1+len(quoted)] != ']' {
+				return nil, fmt.Errorf("%w: bad quoted key in %q", ErrInvalidPath, path)
+			}
+			key, _ := strconv.Unquote(quoted)
+			segs = append(segs, pathSegment{key: key})
+			rest = rest[len(quoted)+2:]
+		case rest[0] == '[':
+			end := 1
+			for end < len(rest) && rest[end] >= '0' && rest[end] <= '9' {
+				end++
+			}
+			if end == 1 || end >= len(rest) || rest[end] != ']' {
+				return nil, fmt.Errorf("%w: bad index in %q", ErrInvalidPath, path)
+			}
+			n, err := strconv.Atoi(rest[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("%w: bad index in %q", ErrInvalidPath, path)
+			}
+			segs = append(segs, pathSegment{index: n, isIndex: true})
+			rest = rest[end+1:]
+		default:
+			if rest[0] == '.' {
+				if len(segs) == 0 {
+					return nil, fmt.Errorf("%w: leading dot in %q", ErrInvalidPath, path)
+				}
+				rest = rest[1:]
+			} else if len(segs) > 0 {
+				return nil, fmt.Errorf("%w: missing dot in %q", ErrInvalidPath, path)
+			}
+			end := 0
+			for end < len(rest) && rest[end] != '.' && rest[end] != '[' {
+				end++
+			}
+			if !isIdentifier(rest[:end]) {
+				return nil, fmt.Errorf("%w: bad key %q in %q", ErrInvalidPath, rest[:end], path)
+			}
+			segs = append(segs, pathSegment{key: rest[:end]})
+			rest = rest[end:]
+		}
+	}
+	return segs, nil
+}
+
+// SetPath returns a copy of v with the value at path replaced by newVal.
+// Only the containers along the path are copied; everything else is shared
+// with v, which is left unchanged. A missing final object property or Map
+// key is added, and setting an array index past the end extends the array
+// with holes, as assignment does in JavaScript. Missing intermediate
+// values fail with ErrPathNotFound. The path "" replaces the root.
+func SetPath(v Value, path string, newVal Value) (Value, error) {
+	segs, err := parsePath(path)
+	if err != nil {
+		return Value{}, err
+	}
+	return updatePath(v, segs, path, &newVal)
+}
+
+// DeletePath returns a copy of v without the value at path, with the same
+// copy-on-write sharing as SetPath. Object properties and Map entries are
+// removed; array elements become holes, as with JavaScript's delete.
+func DeletePath(v Value, path string) (Value, error) {
+	segs, err := parsePath(path)
+	if err != nil {
+		return Value{}, err
+	}
+	if len(segs) == 0 {
+		return Value{}, fmt.Errorf("%w: cannot delete the root", ErrInvalidPath)
+	}
+	return updatePath(v, segs, path, nil)
+}
+
+// updatePath copies v with segs applied: newVal is stored at the end of
+// the path, or the target is deleted when newVal is nil.
+func updatePath(v Value, segs []pathSegment, path string, newVal *Value) (Value, error) {
+	if len(segs) == 0 {
+		return *newVal, nil
+	}
+	seg, last := segs[0], len(segs) == 1
+
+	// child returns the updated replacement for an existing child.
+	child := func(old Value) (Value, error) {
+		return updatePath(old, segs[1:], path, newVal)
+	}
+	notFound := func() (Value, error) {
+		return Value{}, fmt.Errorf("%w: %q", ErrPathNotFound, path)
+	}
+
+	switch v.typ {
+	case TypeObject:
+		key := seg.key
+		if seg.isIndex {
+			key = strconv.Itoa(seg.index)
+		}
+		old, ok := v.data.(*object).props[key]
+		if !ok && (!last || newVal == nil) {
+			return notFound()
+		}
+		out := v.data.(*object).clone()
+		switch {
+		case last && newVal == nil:
+			delete(out.props, key)
+		case last:
+			out.set(key, *newVal)
+		default:
+			updated, err := child(old)
+			if err != nil {
+				return Value{}, err
+			}
+			out.props[key] = updated
+		}
+		return Value{typ: TypeObject, data: out}, nil
+
+	case TypeArray:
+		elems := v.data.([]Value)
+		if !seg.isIndex {
+			return notFound()
+		}
+		i := seg.index
+		if i >= len(elems) || elems[i].typ == TypeHole {
+			if !last || newVal == nil {
+				return notFound()
+			}
+		}
+		if i >= DefaultMaxArrayLen {
+			return Value{}, fmt.Errorf("%w: index %d in %q exceeds the array length limit", ErrInvalidPath, i, path)
+		}
+		out := slices.Clone(elems)
+		for len(out) <= i {
+			out = append(out, Hole())
+		}
+		switch {
+		case last && newVal == nil:
+			out[i] = Hole()
+		case last:
+			out[i] = *newVal
+		default:
+			updated, err := child(elems[i])
+			if err != nil {
+				return Value{}, err
+			}
+			out[i] = updated
+		}
+		return Value{typ: TypeArray, data: out}, nil
+
+	case TypeMap:
+		entries := v.data.(*JSMap).Entries
+		found := slices.IndexFunc(entries, func(e MapEntry) bool {
+			if seg.isIndex {
+				return e.Key.IsNumber() && e.Key.AsNumber() == float64(seg.index)
+			}
+			return e.Key.typ == TypeString && e.Key.data.(string) == seg.key
+		})
+		if found < 0 && (!last || newVal == nil) {
+			return notFound()
+		}
+		out := slices.Clone(entries)
+		switch {
+		case last && newVal == nil:
+			out = slices.Delete(out, found, found+1)
+		case last && found < 0:
+			key := String(seg.key)
+			if seg.isIndex {
+				key = Double(float64(seg.index))
+				if seg.index <= math.MaxInt32 {
+					key = Int32(int32(seg.index))
+				}
+			}
+			out = append(out, MapEntry{Key: key, Value: *newVal})
+		case last:
+			out[found].Value = *newVal
+		default:
+			updated, err := child(entries[found].Value)
+			if err != nil {
+				return Value{}, err
+			}
+			out[found].Value = updated
+		}
+		return Value{typ: TypeMap, data: &JSMap{Entries: out}}, nil
+	}
+	return notFound()
+}
@@ -0,0 +1,117 @@
+package v8serialize
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParsePath(t *testing.T) {
+	segs, err := parsePath(`items[12].name["a b"].x`)
+	if err != nil {
+		t.Fatalf("parsePath failed: %v", err)
+	}
+	want := []pathSegment{{key: "items"}, {index: 12, isIndex: true}, {key: "name"}, {key: "a b"}, {key: "x"}}
+	if len(segs) != len(want) {
+		t.Fatalf("got %+v, want %+v", segs, want)
+	}
+	for i := range want {
+		if segs[i] != want[i] {
+			t.Errorf("segment %d = %+v, want %+v", i, segs[i], want[i])
+		}
+	}
+
+	for _, bad := range []string{".a", "a..b", "a[", "a[x]", `a["b`, "a b", "[1]b"} {
+		if _, err := parsePath(bad); !errors.Is(err, ErrInvalidPath) {
+			t.Errorf("parsePath(%q) = %v, want ErrInvalidPath", bad, err)
+		}
+	}
+}
+
+func TestSetPath(t *testing.T) {
+	orig := Object(map[string]Value{
+		"user":  Object(map[string]Value{"name": String("Ana")}),
+		"items": Array([]Value{Int32(1), Int32(2)}),
+		"meta":  Value{typ: TypeMap, data: &JSMap{Entries: []MapEntry{{Key: String("v"), Value: Int32(1)}}}},
+	})
+
+	got, err := SetPath(orig, "user.name", String("Bo"))
+	if err != nil {
+		t.Fatalf("SetPath failed: %v", err)
+	}
+	if got.Field("user").Field("name").AsString() != "Bo" {
+		t.Errorf("user.name not updated: %#v", got)
+	}
+	if orig.Field("user").Field("name").AsString() != "Ana" {
+		t.Error("SetPath modified the original")
+	}
+	if &got.Field("items").AsArray()[0] != &orig.Field("items").AsArray()[0] {
+		t.Error("untouched branches should be shared, not copied")
+	}
+
+	got, err = SetPath(orig, "items[3]", String("x"))
+	if err != nil {
+		t.Fatalf("SetPath failed: %v", err)
+	}
+	if arr := got.Field("items").AsArray(); len(arr) != 4 || !arr[2].IsHole() || arr[3].AsString() != "x" {
+		t.Errorf("items[3] = %v", arr)
+	}
+	if orig.Field("items").Len() != 2 {
+		t.Error("SetPath extended the original array")
+	}
+
+	got, err = SetPath(orig, "meta.w", Int32(2))
+	if err != nil {
+		t.Fatalf("SetPath failed: %v", err)
+	}
+	if got.Field("meta").Len() != 2 || orig.Field("meta").Len() != 1 {
+		t.Errorf("Map entry not added copy-on-write")
+	}
+
+	if got, _ := SetPath(orig, "", Null()); !got.IsNull() {
+		t.Error(`SetPath(v, "", x) should return x`)
+	}
+	for _, missing := range []string{"nope.name", "items[9].x", "user.name.first", "items.x"} {
+		if _, err := SetPath(orig, missing, Null()); !errors.Is(err, ErrPathNotFound) {
+			t.Errorf("SetPath(%q) = %v, want ErrPathNotFound", missing, err)
+		}
+	}
+}
+
+func TestDeletePath(t *testing.T) {
+	orig := Object(map[string]Value{
+		"a":    Int32(1),
+		"list": Array([]Value{Int32(1), Int32(2)}),
+		"m":    Value{typ: TypeMap, data: &JSMap{Entries: []MapEntry{{Key: Int32(7), Value: Int32(1)}}}},
+	})
+
+	got, err := DeletePath(orig, "a")
+	if err != nil {
+		t.Fatalf("DeletePath failed: %v", err)
+	}
+	if _, ok := got.AsObject()["a"]; ok || orig.Field("a").AsInt32() != 1 {
+		t.Error("property not deleted copy-on-write")
+	}
+
+	got, err = DeletePath(orig, "list[0]")
+	if err != nil {
+		t.Fatalf("DeletePath failed: %v", err)
+	}
+	if arr := got.Field("list").AsArray(); len(arr) != 2 || !arr[0].IsHole() {
+		t.Errorf("list[0] should become a hole, got %v", arr)
+	}
+
+	got, err = DeletePath(orig, "m[7]")
+	if err != nil {
+		t.Fatalf("DeletePath failed: %v", err)
+	}
+	if got.Field("m").Len() != 0 || orig.Field("m").Len() != 1 {
+		t.Error("Map entry not deleted copy-on-write")
+	}
+
+	if _, err := DeletePath(orig, "b"); !errors.Is(err, ErrPathNotFound) {
+		t.Errorf("deleting a missing property: %v", err)
+	}
+	if _, err := DeletePath(orig, ""); !errors.Is(err, ErrInvalidPath) {
+		t.Errorf("deleting the root: %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package v8serialize
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ByteRange is a half-open range [Start, End) of input bytes.
+type ByteRange struct {
+	Start int // offset of the value's tag
+	End   int // offset just past the value
+}
+
+// PositionIndex maps value paths to the bytes that encode them. Paths use
+// the same syntax as serializer errors: "" for the root, "items[5].name"
+// for nested values, ["a b"] for keys that are not identifiers, [i][0] and
+// [i][1] for the key and value of Map entry i, and .cause for an Error's
+// cause. Object keys, RegExp patterns and Error messages are part of their
+// containing value and have no entry of their own.
+//
+// The ranges can be used to extract or replace one field's raw bytes
+// without re-encoding the rest of the payload.
+type PositionIndex struct {
+	ranges map[string]ByteRange
+	paths  []string
+}
+
+// WithPositionIndex records the byte range of every value decoded by the
+// Deserializer in idx. When several values are read from one payload, each
+// root overwrites the entries of the previous one.
+func WithPositionIndex(idx *PositionIndex) Option {
+	return func(d *Deserializer) {
+		d.positions = idx
+	}
+}
+
+// Lookup returns the byte range of the value at path.
+func (p *PositionIndex) Lookup(path string) (ByteRange, bool) {
+	r, ok := p.ranges[path]
+	return r, ok
+}
+
+// Paths returns the recorded paths in the order their values end in the
+// input (children before their containers).
+func (p *PositionIndex) Paths() []string {
+	return append([]string(nil), p.paths...)
+}
+
+// Len returns the number of recorded values.
+func (p *PositionIndex) Len() int {
+	return len(p.paths)
+}
+
+func (p *PositionIndex) add(path string, r ByteRange) {
+	if p.ranges == nil {
+		p.ranges = make(map[string]ByteRange)
+	}
+	if _, ok := p.ranges[path]; !ok {
+		p.paths = append(p.paths, path)
+	}
+	p.ranges[path] = r
+}
+
+// recordPosition adds the value that started at start and ends at the
+// current position to the position index, unless it is a key or some other
+// part of its parent.
+func (d *Deserializer) recordPosition(start int) {
+	if path, ok := d.childPath(d.stack); ok {
+		d.positions.add(path, ByteRange{Start: start, End: d.reader.Pos()})
+	}
+}
+
+// childPath returns the path of the child the innermost of frames is
+// reading, or false if that child or one of its ancestors is not a value
+// in its own right.
+func (d *Deserializer) childPath(frames []decodeFrame) (string, bool) {
+	var b strings.Builder
+	for i := range frames {
+		if !d.childSegment(&b, &frames[i]) {
+			return "", false
+		}
+	}
+	return b.String(), true
+}
+
+// childSegment appends the path segment of the child f is currently
+// reading. It reports false if that child is not a value in its own right.
+func (d *Deserializer) childSegment(b *strings.Builder, f *decodeFrame) bool {
+	switch f.tag {
+	case tagBeginJSObject:
+		if !f.hasKey {
+			return false
+		}
+		appendKeySegment(b, f.name)
+	case tagBeginDenseArray:
+		if uint32(f.count) >= f.length {
+			return false // array property, which is dropped
+		}
+		fmt.Fprintf(b, "[%d]", f.count)
+	case tagBeginSparseArray:
+		if !f.hasKey || !f.key.IsNumber() {
+			return false
+		}
+		fmt.Fprintf(b, "[%d]", int(f.key.AsNumber()))
+	case tagBeginMap:
+		if f.hasKey {
+			fmt.Fprintf(b, "[%d][1]", len(f.entries))
+		} else {
+			fmt.Fprintf(b, "[%d][0]", len(f.entries))
+		}
+	case tagBeginSet:
+		fmt.Fprintf(b, "[%d]", len(f.elems))
+	case tagError:
+		if f.lead || f.subTag != errorTagCause {
+			return false
+		}
+		if b.Len() > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString("cause")
+	default: // RegExp pattern, boxed primitive contents
+		return false
+	}
+	return true
+}
+
+// appendKeySegment appends an object key to a path: .key for identifiers,
+// ["key"] otherwise. The leading dot is omitted at the root.
+func appendKeySegment(b *strings.Builder, key string) {
+	if !isIdentifier(key) {
+		b.WriteString("[" + strconv.Quote(key) + "]")
+		return
+	}
+	if b.Len() > 0 {
+		b.WriteByte('.')
+	}
+	b.WriteString(key)
+}
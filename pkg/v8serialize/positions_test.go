@@ -0,0 +1,76 @@
+package v8serialize
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWithPositionIndex(t *testing.T) {
+	m := &JSMap{Entries: []MapEntry{{Key: String("k"), Value: Int32(1)}}}
+	root := Object(map[string]Value{
+		"items": Array([]Value{Int32(7), Object(map[string]Value{"a b": Bool(true)})}),
+		"m":     {typ: TypeMap, data: m},
+		"err":   {typ: TypeError, data: &JSError{Name: "Error", Message: "outer", Cause: &Value{typ: TypeString, data: "inner"}}},
+	})
+	data, err := Serialize(root, WithSortedKeys())
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	var idx PositionIndex
+	if _, err := Deserialize(data, WithPositionIndex(&idx)); err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+
+	wantPaths := []string{
+		"err.cause", "err",
+		"items[0]", `items[1]["a b"]`, "items[1]", "items",
+		"m[0][0]", "m[0][1]", "m",
+		"",
+	}
+	if got := idx.Paths(); !reflect.DeepEqual(got, wantPaths) {
+		t.Fatalf("Paths() = %q, want %q", got, wantPaths)
+	}
+
+	// Every range must decode on its own to the value at that path.
+	tests := []struct {
+		path string
+		want Value
+	}{
+		{"items[0]", Int32(7)},
+		{`items[1]["a b"]`, Bool(true)},
+		{"m[0][0]", String("k")},
+		{"err.cause", String("inner")},
+	}
+	for _, tt := range tests {
+		r, ok := idx.Lookup(tt.path)
+		if !ok {
+			t.Errorf("Lookup(%q) not found", tt.path)
+			continue
+		}
+		payload := append([]byte{0xff, 0x0f}, data[r.Start:r.End]...)
+		got, err := Deserialize(payload)
+		if err != nil {
+			t.Errorf("%s: decoding range %v: %v", tt.path, r, err)
+			continue
+		}
+		if !valuesEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	if r, _ := idx.Lookup(""); r.Start != 2 || r.End != len(data) {
+		t.Errorf("root range = %v, want {2 %d}", r, len(data))
+	}
+
+	// Replacing one field's bytes leaves the rest of the payload valid.
+	r, _ := idx.Lookup("items[0]")
+	patched := append(append(append([]byte{}, data[:r.Start]...), 0x49, 0x10), data[r.End:]...)
+	v, err := Deserialize(patched)
+	if err != nil {
+		t.Fatalf("Deserialize patched failed: %v", err)
+	}
+	if got := v.AsObject()["items"].AsArray()[0]; got.AsInt32() != 8 {
+		t.Errorf("patched items[0] = %v, want 8", got)
+	}
+}
@@ -0,0 +1,45 @@
+package v8serialize
+
+import "errors"
+
+// ErrHostObject is returned for host objects the active Profile cannot
+// interpret.
+var ErrHostObject = errors.New("v8serialize: unsupported host object")
+
+// Profile describes the conventions of the runtime that produced a payload.
+// V8 writes the same core format everywhere, and every profile accepts
+// versions MinVersion..MaxVersion; embedders differ in how they encode host
+// objects (tag 0x5C), which V8 leaves entirely to the embedder.
+type Profile struct {
+	Name string
+
+	// NodeHostObjects interprets host objects as Node's TypedArray and
+	// Buffer encoding (type index, byte length, bytes). Without it, and
+	// without WithHostObjectReader, host objects fail with ErrHostObject.
+	NodeHostObjects bool
+}
+
+var (
+	// ProfileNode matches Node's v8.serialize (the default). TypedArrays and
+	// Buffers are written as host objects.
+	ProfileNode = Profile{Name: "node", NodeHostObjects: true}
+
+	// ProfileDeno matches Deno's core.serialize, which lets V8 write
+	// TypedArrays natively (an ArrayBuffer followed by a view) and uses host
+	// objects only for runtime-internal values that cannot be decoded
+	// outside Deno.
+	ProfileDeno = Profile{Name: "deno"}
+
+	// ProfileBun matches Bun's node:v8 compatibility layer, which follows
+	// Node's host-object encoding.
+	ProfileBun = Profile{Name: "bun", NodeHostObjects: true}
+)
+
+// WithProfile sets the producer profile (default ProfileNode). Natively
+// encoded TypedArrays are accepted under every profile, since any V8
+// embedder may emit them.
+func WithProfile(p Profile) Option {
+	return func(d *Deserializer) {
+		d.profile = p
+	}
+}
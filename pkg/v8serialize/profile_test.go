@@ -0,0 +1,184 @@
+package v8serialize
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestNativeArrayBufferViews(t *testing.T) {
+	tests := []struct {
+		fixture  string
+		wantType string
+		want     []byte
+	}{
+		{"native-uint8array", "Uint8Array", []byte{1, 2, 3}},
+		{"native-int16array", "Int16Array", []byte{0x01, 0x00, 0xff, 0xff}},
+		{"native-uint8array-offset", "Uint8Array", []byte{2, 3, 4}},
+		{"native-dataview", "DataView", []byte{1, 2, 3, 4}},
+	}
+
+	for _, tt := range tests {
+		for _, p := range []Profile{ProfileNode, ProfileDeno, ProfileBun} {
+			t.Run(tt.fixture+"/"+p.Name, func(t *testing.T) {
+				data, _ := loadFixture(t, tt.fixture)
+				v, err := Deserialize(data, WithProfile(p))
+				if err != nil {
+					t.Fatalf("Deserialize failed: %v", err)
+				}
+				view, ok := v.Interface().(*ArrayBufferView)
+				if !ok {
+					t.Fatalf("got %s, want TypedArray", v.Type())
+				}
+				if view.Type != tt.wantType {
+					t.Errorf("Type = %s, want %s", view.Type, tt.wantType)
+				}
+				if got := view.Buffer[view.ByteOffset : view.ByteOffset+view.ByteLength]; !bytes.Equal(got, tt.want) {
+					t.Errorf("bytes = %v, want %v", got, tt.want)
+				}
+			})
+		}
+	}
+
+	t.Run("reference", func(t *testing.T) {
+		data, _ := loadFixture(t, "native-view-reference")
+		v, err := Deserialize(data)
+		if err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+		elems := v.AsArray()
+		if len(elems) != 2 || elems[0].Interface() != elems[1].Interface() {
+			t.Errorf("both elements should be the same view, got %v", elems)
+		}
+	})
+
+	t.Run("version-13-without-flags", func(t *testing.T) {
+		// Node 16: ff0d, no flags varint after the view length.
+		v, err := Deserialize([]byte{0xff, 0x0d, 0x42, 0x03, 0x01, 0x02, 0x03, 0x56, 0x42, 0x00, 0x03})
+		if err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+		if view := v.Interface().(*ArrayBufferView); view.ByteLength != 3 {
+			t.Errorf("ByteLength = %d, want 3", view.ByteLength)
+		}
+	})
+
+	t.Run("view-out-of-range", func(t *testing.T) {
+		_, err := Deserialize([]byte{0xff, 0x0f, 0x42, 0x02, 0x01, 0x02, 0x56, 0x42, 0x01, 0x02, 0x00})
+		if !errors.Is(err, ErrMalformedData) {
+			t.Errorf("expected ErrMalformedData, got %v", err)
+		}
+	})
+
+	t.Run("serialize-offset-view", func(t *testing.T) {
+		data, _ := loadFixture(t, "native-uint8array-offset")
+		v, err := Deserialize(data)
+		if err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+		out, err := Serialize(v)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		// Node host-object form: '\' Uint8Array, 3 bytes.
+		if want := "ff0f5c0103020304"; bytesToHex(out) != want {
+			t.Errorf("got %s, want %s", bytesToHex(out), want)
+		}
+	})
+}
+
+func TestPooledBuffers(t *testing.T) {
+	// Node: Buffer.from('pooled') and Buffer.from('next'), views into the
+	// shared 8 KiB pool, written by the base v8.Serializer.
+	t.Run("native", func(t *testing.T) {
+		data, _ := loadFixture(t, "native-buffer-pooled")
+		v, err := Deserialize(data)
+		if err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+		view := v.Interface().(*ArrayBufferView)
+		if view.ByteOffset == 0 || len(view.Buffer) != 8192 {
+			t.Fatalf("view at %d of a %d-byte buffer, want a pooled Buffer", view.ByteOffset, len(view.Buffer))
+		}
+		if got := string(view.Slice()); got != "pooled" {
+			t.Errorf("Slice = %q, want %q", got, "pooled")
+		}
+
+		// Re-encoded in Node's host-object form, only the view's bytes remain.
+		out, err := Serialize(v)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		if want := "ff0f5c0106706f6f6c6564"; bytesToHex(out) != want {
+			t.Errorf("got %s, want %s", bytesToHex(out), want)
+		}
+	})
+
+	t.Run("shared-pool", func(t *testing.T) {
+		data, _ := loadFixture(t, "native-buffers-pooled")
+		v, err := Deserialize(data)
+		if err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+		elems := v.AsArray()
+		if len(elems) != 2 {
+			t.Fatalf("got %d elements, want 2", len(elems))
+		}
+		first := elems[0].Interface().(*ArrayBufferView)
+		second := elems[1].Interface().(*ArrayBufferView)
+		if &first.Buffer[0] != &second.Buffer[0] {
+			t.Error("views should share the pool")
+		}
+		if string(first.Slice()) != "pooled" || string(second.Slice()) != "next" {
+			t.Errorf("Slice = %q, %q", first.Slice(), second.Slice())
+		}
+	})
+
+	t.Run("host-object", func(t *testing.T) {
+		data, _ := loadFixture(t, "buffer-pooled")
+		v, err := Deserialize(data)
+		if err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+		view := v.Interface().(*ArrayBufferView)
+		if view.Type != "Buffer" || len(view.Buffer) != 6 || string(view.Slice()) != "pooled" {
+			t.Errorf("got %s %q over %d bytes", view.Type, view.Slice(), len(view.Buffer))
+		}
+	})
+
+	t.Run("out-of-range", func(t *testing.T) {
+		view := &ArrayBufferView{Buffer: make([]byte, 4), ByteOffset: 2, ByteLength: 4, Type: "Uint8Array"}
+		if got := view.Slice(); got != nil {
+			t.Errorf("Slice = %v, want nil", got)
+		}
+	})
+}
+
+func TestWithProfile(t *testing.T) {
+	hostObject, _ := loadFixture(t, "uint8array")
+
+	t.Run("node-host-objects", func(t *testing.T) {
+		if _, err := Deserialize(hostObject, WithProfile(ProfileNode)); err != nil {
+			t.Errorf("Deserialize failed: %v", err)
+		}
+	})
+
+	t.Run("deno-rejects-host-objects", func(t *testing.T) {
+		_, err := Deserialize(hostObject, WithProfile(ProfileDeno))
+		if !errors.Is(err, ErrHostObject) {
+			t.Errorf("expected ErrHostObject, got %v", err)
+		}
+	})
+
+	t.Run("version-range", func(t *testing.T) {
+		for _, p := range []Profile{ProfileNode, ProfileDeno, ProfileBun} {
+			if _, err := Deserialize([]byte{0xff, MinVersion, 0x30}, WithProfile(p)); err != nil {
+				t.Errorf("%s: version %d: %v", p.Name, MinVersion, err)
+			}
+			_, err := Deserialize([]byte{0xff, MinVersion - 1, 0x30}, WithProfile(p))
+			if !errors.Is(err, ErrUnsupportedVersion) {
+				t.Errorf("%s: expected ErrUnsupportedVersion, got %v", p.Name, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package v8serialize
+
+import "context"
+
+// Progress reports how far a decode has read into its input.
+type Progress struct {
+	Bytes int // bytes consumed so far
+	Total int // size of the input
+}
+
+// Percent returns the fraction of the input consumed, from 0 to 100.
+func (p Progress) Percent() float64 {
+	if p.Total == 0 {
+		return 100
+	}
+	return float64(p.Bytes) * 100 / float64(p.Total)
+}
+
+// progressStep is the number of input bytes between progress reports, and
+// contextStep the number of values between cancellation checks.
+const (
+	progressStep = 64 << 10
+	contextStep  = 1024
+)
+
+// WithProgress calls fn as decoding advances through the input, about every
+// 64 KiB, so command-line tools and GUIs can show progress for large
+// payloads. Deserialize and ReadValue also report once after each root
+// value. fn runs on the decoding goroutine and should return quickly.
+func WithProgress(fn func(Progress)) Option {
+	return func(d *Deserializer) {
+		d.progress = fn
+	}
+}
+
+// WithContext makes decoding stop with ctx.Err() once ctx is done. The
+// context is checked every 1024 values, so even huge payloads can be
+// abandoned promptly. Like WithProgress, it also applies to Tokenizer and
+// DecodeEvents.
+func WithContext(ctx context.Context) Option {
+	return func(d *Deserializer) {
+		d.ctx = ctx
+	}
+}
+
+// checkProgress reports progress and checks for cancellation; countValue
+// calls it for every value.
+func (d *Deserializer) checkProgress() error {
+	if d.ctx != nil && d.values%contextStep == 1 {
+		if err := d.ctx.Err(); err != nil {
+			return err
+		}
+	}
+	if d.progress != nil {
+		if pos := d.reader.Pos(); pos-d.reported >= progressStep {
+			d.reported = pos
+			d.progress(Progress{Bytes: pos, Total: d.reader.Len()})
+		}
+	}
+	return nil
+}
+
+// finishProgress reports the position after a complete root value.
+func (d *Deserializer) finishProgress() {
+	if d.progress != nil {
+		d.reported = d.reader.Pos()
+		d.progress(Progress{Bytes: d.reported, Total: d.reader.Len()})
+	}
+}
@@ -0,0 +1,73 @@
+package v8serialize
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWithProgress(t *testing.T) {
+	elems := make([]interface{}, 50_000)
+	for i := range elems {
+		elems[i] = strings.Repeat("x", 10)
+	}
+	data, err := SerializeGo(elems)
+	if err != nil {
+		t.Fatalf("SerializeGo failed: %v", err)
+	}
+
+	var reports []Progress
+	if _, err := Deserialize(data, WithProgress(func(p Progress) { reports = append(reports, p) })); err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	if want := len(data)/progressStep + 1; len(reports) != want {
+		t.Fatalf("got %d reports, want %d", len(reports), want)
+	}
+	for i, p := range reports {
+		if p.Total != len(data) {
+			t.Errorf("report %d: Total = %d, want %d", i, p.Total, len(data))
+		}
+		if i > 0 && p.Bytes <= reports[i-1].Bytes {
+			t.Errorf("report %d: Bytes %d did not advance from %d", i, p.Bytes, reports[i-1].Bytes)
+		}
+	}
+	if last := reports[len(reports)-1]; last.Bytes != len(data) || last.Percent() != 100 {
+		t.Errorf("last report = %+v (%.1f%%), want the whole input", last, last.Percent())
+	}
+}
+
+func TestWithContext(t *testing.T) {
+	elems := make([]interface{}, 50_000)
+	for i := range elems {
+		elems[i] = strings.Repeat("x", 10)
+	}
+	data, err := SerializeGo(elems)
+	if err != nil {
+		t.Fatalf("SerializeGo failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := Deserialize(data, WithContext(ctx)); err != nil {
+		t.Fatalf("live context: Deserialize failed: %v", err)
+	}
+
+	// Cancel from the progress callback, as a GUI's abort button would.
+	var stoppedAt int
+	_, err = Deserialize(data, WithContext(ctx), WithProgress(func(p Progress) {
+		stoppedAt = p.Bytes
+		cancel()
+	}))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if stoppedAt == 0 || stoppedAt >= len(data) {
+		t.Errorf("canceled at byte %d of %d, want mid-payload", stoppedAt, len(data))
+	}
+
+	tok := NewTokenizer(data, WithContext(ctx))
+	if _, err := tok.Next(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Tokenizer: expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,203 @@
+package v8serialize
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Schema describes the structure observed at one position across a corpus
+// of values, as built by InferSchema. Child schemas describe object
+// properties, array and Set members, and Map keys and values.
+type Schema struct {
+	Count    int          // values observed at this position
+	Types    map[Type]int // how many of them had each type
+	Optional bool         // an object property missing from some of the objects observed
+
+	Numbers *Range // range of numeric values, if any were numbers
+	Lengths *Range // range of string lengths (UTF-16) and array, Set and Map sizes
+
+	Properties map[string]*Schema // object properties
+	Elements   *Schema            // array and Set members (holes are skipped)
+	Keys       *Schema            // Map keys
+	Values     *Schema            // Map values
+}
+
+// Range is an inclusive range of observed numbers.
+type Range struct {
+	Min, Max float64
+}
+
+func (r *Range) add(f float64) *Range {
+	if r == nil {
+		return &Range{Min: f, Max: f}
+	}
+	r.Min, r.Max = math.Min(r.Min, f), math.Max(r.Max, f)
+	return r
+}
+
+// InferSchema merges the structure of values into one Schema, to document
+// message formats known only from samples. Values nested in themselves
+// are described down to the first repetition.
+func InferSchema(values ...Value) *Schema {
+	s := &Schema{}
+	active := make(map[uintptr]bool)
+	for _, v := range values {
+		s.observe(v, active)
+	}
+	s.markOptional()
+	return s
+}
+
+func (s *Schema) observe(v Value, active map[uintptr]bool) {
+	s.Count++
+	if s.Types == nil {
+		s.Types = make(map[Type]int)
+	}
+	s.Types[v.typ]++
+
+	switch v.typ {
+	case TypeInt32, TypeUint32, TypeDouble:
+		if f := v.AsNumber(); !math.IsNaN(f) {
+			s.Numbers = s.Numbers.add(f)
+		}
+		return
+	case TypeString:
+		s.Lengths = s.Lengths.add(float64(v.Len()))
+		return
+	case TypeObject, TypeArray, TypeMap, TypeSet:
+	default:
+		return
+	}
+
+	s.Lengths = s.Lengths.add(float64(v.Len()))
+	id := reflect.ValueOf(v.data).Pointer()
+	if active[id] {
+		return // cyclic: already being described further up
+	}
+	active[id] = true
+	defer delete(active, id)
+
+	switch v.typ {
+	case TypeObject:
+		if s.Properties == nil {
+			s.Properties = make(map[string]*Schema)
+		}
+		for k, prop := range v.data.(*object).props {
+			child := s.Properties[k]
+			if child == nil {
+				child = &Schema{}
+				s.Properties[k] = child
+			}
+			child.observe(prop, active)
+		}
+	case TypeArray, TypeSet:
+		for _, e := range v.elements() {
+			if e.typ == TypeHole {
+				continue
+			}
+			if s.Elements == nil {
+				s.Elements = &Schema{}
+			}
+			s.Elements.observe(e, active)
+		}
+	case TypeMap:
+		for _, e := range v.data.(*JSMap).Entries {
+			if s.Keys == nil {
+				s.Keys, s.Values = &Schema{}, &Schema{}
+			}
+			s.Keys.observe(e.Key, active)
+			s.Values.observe(e.Value, active)
+		}
+	}
+}
+
+// markOptional flags properties seen in fewer objects than their parent.
+func (s *Schema) markOptional() {
+	for _, child := range s.Properties {
+		child.Optional = child.Count < s.Types[TypeObject]
+		child.markOptional()
+	}
+	for _, child := range []*Schema{s.Elements, s.Keys, s.Values} {
+		if child != nil {
+			child.markOptional()
+		}
+	}
+}
+
+// TypeNames returns the observed types, most frequent first.
+func (s *Schema) TypeNames() []string {
+	types := s.typesByCount()
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = t.String()
+	}
+	return names
+}
+
+func (s *Schema) typesByCount() []Type {
+	types := make([]Type, 0, len(s.Types))
+	for t := range s.Types {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if s.Types[types[i]] != s.Types[types[j]] {
+			return s.Types[types[i]] > s.Types[types[j]]
+		}
+		return types[i] < types[j]
+	})
+	return types
+}
+
+// String describes the schema one path per line, e.g.
+//
+//	user.age: int32 range 18..77
+//	user.nick: string optional length 2..12
+//	tags[]: string length 1..8
+//
+// Array and Set members are written as [], Map keys and values as
+// .<key> and .<value>.
+func (s *Schema) String() string {
+	var b strings.Builder
+	s.describe(&b, "")
+	return b.String()
+}
+
+func (s *Schema) describe(b *strings.Builder, path string) {
+	name := path
+	if name == "" {
+		name = "<root>"
+	}
+	fmt.Fprintf(b, "%s: %s", name, strings.Join(s.TypeNames(), " | "))
+	if s.Optional {
+		b.WriteString(" optional")
+	}
+	if s.Numbers != nil {
+		fmt.Fprintf(b, " range %g..%g", s.Numbers.Min, s.Numbers.Max)
+	}
+	if s.Lengths != nil {
+		fmt.Fprintf(b, " length %g..%g", s.Lengths.Min, s.Lengths.Max)
+	}
+	b.WriteByte('\n')
+
+	keys := make([]string, 0, len(s.Properties))
+	for k := range s.Properties {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		var p strings.Builder
+		p.WriteString(path)
+		appendKeySegment(&p, k)
+		s.Properties[k].describe(b, p.String())
+	}
+	if s.Elements != nil {
+		s.Elements.describe(b, path+"[]")
+	}
+	if s.Keys != nil {
+		s.Keys.describe(b, path+".<key>")
+		s.Values.describe(b, path+".<value>")
+	}
+}
@@ -0,0 +1,56 @@
+package v8serialize
+
+import "testing"
+
+func TestInferSchema(t *testing.T) {
+	samples := []Value{
+		Object(map[string]Value{
+			"id":   Int32(1),
+			"name": String("Ana"),
+			"tags": Array([]Value{String("a"), String("bcd")}),
+		}),
+		Object(map[string]Value{
+			"id":   Double(2.5),
+			"tags": Array(nil),
+			"meta": Value{typ: TypeMap, data: &JSMap{Entries: []MapEntry{{Key: String("k"), Value: Null()}}}},
+		}),
+	}
+	s := InferSchema(samples...)
+
+	if s.Count != 2 || s.Types[TypeObject] != 2 {
+		t.Fatalf("root = %+v", s)
+	}
+	id := s.Properties["id"]
+	if id.Optional || id.Numbers == nil || id.Numbers.Min != 1 || id.Numbers.Max != 2.5 {
+		t.Errorf("id = %+v", id)
+	}
+	if !s.Properties["name"].Optional || !s.Properties["meta"].Optional {
+		t.Error("properties missing from one sample should be optional")
+	}
+	if el := s.Properties["tags"].Elements; el == nil || el.Count != 2 || el.Lengths.Max != 3 {
+		t.Errorf("tags elements = %+v", el)
+	}
+
+	want := `<root>: object length 3..3
+id: int32 | number range 1..2.5
+meta: Map optional length 1..1
+meta.<key>: string length 1..1
+meta.<value>: null
+name: string optional length 3..3
+tags: Array length 0..2
+tags[]: string length 1..3
+`
+	if got := s.String(); got != want {
+		t.Errorf("String() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestInferSchemaCyclic(t *testing.T) {
+	props := map[string]Value{"n": Int32(1)}
+	self := Object(props)
+	props["self"] = self
+	s := InferSchema(self)
+	if s.Properties["self"].Types[TypeObject] != 1 || s.Properties["self"].Properties != nil {
+		t.Errorf("cycle not cut at the first repetition: %+v", s.Properties["self"])
+	}
+}
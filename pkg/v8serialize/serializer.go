@@ -1,9 +1,14 @@
 package v8serialize
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"math/big"
+	"math/bits"
+	"reflect"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/acolita/v8wire/internal/wire"
@@ -15,26 +20,282 @@ const SerializeVersion = 15
 // Serializer serializes Go values to V8 Structured Clone format.
 //
 // LIMITATION: The current implementation does not support circular references.
-// Attempting to serialize an object graph with cycles will cause a stack overflow.
+// Attempting to serialize an object graph with cycles fails with
+// ErrMaxDepthExceeded (or never finishes if the depth limit is disabled).
 // Use the deserializer's circular reference support to read such data, but avoid
 // creating circular structures when serializing from Go.
 type Serializer struct {
 	writer  *wire.Writer
-	objects map[interface{}]uint32 // object identity → reference ID (reserved for future circular ref support)
-	nextID  uint32
+	objects map[interface{}]uint32 // object identity → reference ID; only buffers and SharedArrayBuffer views are shared so far
+	nextID  uint32                 // reference ID of the next object written, counted as V8 does
+
+	headerWritten bool
+	version       uint32
+	maxDepth      int
+	unsupported   UnsupportedValuePolicy
+	sortedKeys    bool
+	sparseDensity float64
+	holes         HolePolicy
+	timePrecision TimePrecisionPolicy
+	intOverflow   IntOverflowPolicy
+	maxStrings    int
+	maxBinary     int
+	strings       int // string values written by the current top-level value
+	binaryBytes   int // ArrayBuffer and view bytes written by the current top-level value
+	stack         []encodeFrame
+	metrics       MetricsSink
+	tagCounts     map[byte]int
+	sizeHint      int
+	estimate      bool
+}
+
+// SerializerOption configures the serializer.
+type SerializerOption func(*Serializer)
+
+// WithEncodeMaxDepth sets the maximum nesting depth the serializer will
+// write (default 1000, matching the deserializer). Encoding is iterative, so
+// the limit can be raised freely for deep structures; 0 disables it. Cyclic
+// values exceed any limit and fail with ErrMaxDepthExceeded.
+func WithEncodeMaxDepth(depth int) SerializerOption {
+	return func(s *Serializer) {
+		s.maxDepth = depth
+	}
+}
+
+// WithSortedKeys writes object properties in lexicographic order instead of
+// Keys order, so objects with the same properties encode identically
+// however they were built or decoded.
+func WithSortedKeys() SerializerOption {
+	return func(s *Serializer) {
+		s.sortedKeys = true
+	}
+}
+
+// DefaultSparseArrayDensity is the default for WithSparseArrayDensity: every
+// array is written densely, with one hole tag per missing element.
+const DefaultSparseArrayDensity = 0
+
+// WithSparseArrayDensity sets the fraction of present (non-hole) elements
+// below which an array is written with V8's sparse encoding, which stores
+// only the present elements, instead of one hole tag per missing element.
+// Both encodings decode to the same array. V8 chooses by elements kind
+// rather than density: Node 20 writes packed arrays densely and arrays with
+// holes, whether holey or dictionary-mode, sparsely. A density of 1 matches
+// that; the default, DefaultSparseArrayDensity, keeps every array dense.
+func WithSparseArrayDensity(density float64) SerializerOption {
+	return func(s *Serializer) {
+		s.sparseDensity = density
+	}
+}
+
+// HolePolicy controls how the serializer writes array holes.
+type HolePolicy uint8
+
+const (
+	// HoleAsHole writes holes as holes (the default).
+	HoleAsHole HolePolicy = iota
+	// HoleAsUndefined writes holes as undefined, for receivers that
+	// mishandle holes. Such arrays are always written densely.
+	HoleAsUndefined
+	// HoleAsError fails the encode with ErrHoleRejected at the first hole.
+	HoleAsError
+)
+
+// ErrHoleRejected is returned for array holes under HoleAsError.
+var ErrHoleRejected = errors.New("v8serialize: array hole rejected")
+
+// WithHoleAs sets how array holes are written.
+func WithHoleAs(p HolePolicy) SerializerOption {
+	return func(s *Serializer) {
+		s.holes = p
+	}
+}
+
+// TimePrecisionPolicy controls how SerializeGo writes time.Time values with
+// sub-millisecond precision, which a Date cannot hold.
+type TimePrecisionPolicy uint8
+
+const (
+	// TimeTruncate drops the sub-millisecond part (the default), like
+	// time.Time.UnixMilli.
+	TimeTruncate TimePrecisionPolicy = iota
+	// TimeRound rounds to the nearest millisecond like time.Time.Round,
+	// which rounds halfway values up, so before 1970 toward zero.
+	TimeRound
+	// TimeError fails the encode with ErrTimePrecision.
+	TimeError
+)
+
+// ErrTimePrecision is returned for time.Time values with sub-millisecond
+// precision under TimeError.
+var ErrTimePrecision = errors.New("v8serialize: time has sub-millisecond precision")
+
+// WithTimePrecisionPolicy sets how SerializeGo writes time.Time values that
+// a millisecond Date cannot represent exactly.
+func WithTimePrecisionPolicy(p TimePrecisionPolicy) SerializerOption {
+	return func(s *Serializer) {
+		s.timePrecision = p
+	}
+}
+
+// IntOverflowPolicy controls how SerializeGo writes Go integers that a
+// double cannot hold exactly, i.e. most beyond ±2^53. Integers outside the
+// int32 range are written as doubles; the policy only applies when that
+// double would not equal the integer.
+type IntOverflowPolicy uint8
+
+const (
+	// IntOverflowError fails the encode with ErrIntOverflow (the default).
+	IntOverflowError IntOverflowPolicy = iota
+	// IntOverflowRound writes the nearest double, as JavaScript does for
+	// such literals.
+	IntOverflowRound
+	// IntOverflowBigInt writes the integer as a BigInt. Receivers get a
+	// bigint rather than a number, so they must expect one.
+	IntOverflowBigInt
+)
+
+// ErrIntOverflow is returned for integers a double cannot represent exactly
+// under IntOverflowError.
+var ErrIntOverflow = errors.New("v8serialize: integer not exactly representable as a double")
+
+// WithIntOverflowPolicy sets how SerializeGo writes integers that would lose
+// precision as a double.
+func WithIntOverflowPolicy(p IntOverflowPolicy) SerializerOption {
+	return func(s *Serializer) {
+		s.intOverflow = p
+	}
+}
+
+// UnsupportedValuePolicy controls what SerializeGo does with Go values that
+// V8 cannot clone: functions, channels and unsafe pointers.
+type UnsupportedValuePolicy uint8
+
+const (
+	// UnsupportedError fails the encode (the default).
+	UnsupportedError UnsupportedValuePolicy = iota
+	// UnsupportedSkip omits object properties holding such values, like
+	// JSON.stringify does. Elsewhere (array elements, the root value) the
+	// value is written as undefined so indices are preserved.
+	UnsupportedSkip
+	// UnsupportedNull writes null in place of the value.
+	UnsupportedNull
+)
+
+// WithUnsupportedValuePolicy sets how SerializeGo handles values V8 cannot
+// clone. Other Go types the mapper does not know are always an error.
+func WithUnsupportedValuePolicy(p UnsupportedValuePolicy) SerializerOption {
+	return func(s *Serializer) {
+		s.unsupported = p
+	}
+}
+
+// WithMaxStrings caps the number of string values (not property names) a
+// single top-level value may contain. Exceeding it fails the encode with a
+// *LimitExceededError. 0 (the default) means unlimited.
+func WithMaxStrings(n int) SerializerOption {
+	return func(s *Serializer) {
+		s.maxStrings = n
+	}
+}
+
+// WithMaxBinaryBytes caps the total ArrayBuffer and TypedArray bytes a single
+// top-level value may contain. Exceeding it fails the encode with a
+// *LimitExceededError. 0 (the default) means unlimited.
+func WithMaxBinaryBytes(n int) SerializerOption {
+	return func(s *Serializer) {
+		s.maxBinary = n
+	}
+}
+
+// LimitExceededError reports an encode quota that was exceeded and where in
+// the value tree it happened. It matches ErrLimitExceeded with errors.Is.
+type LimitExceededError struct {
+	Limit string // "max_strings" or "max_binary_bytes"
+	Max   int    // the configured limit
+	Path  string // location of the offending value, e.g. "items[5].data"; empty for the root
+}
+
+func (e *LimitExceededError) Error() string {
+	msg := fmt.Sprintf("%v: %s %d", ErrLimitExceeded, e.Limit, e.Max)
+	if e.Path != "" {
+		msg += " at " + e.Path
+	}
+	return msg
+}
+
+// Unwrap returns ErrLimitExceeded.
+func (e *LimitExceededError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// ErrDataClone matches every *DataCloneError.
+var ErrDataClone = errors.New("v8serialize: DataCloneError")
+
+// DataCloneError reports a value the structured clone algorithm cannot
+// clone, such as a Go func or a boxed primitive of a non-primitive type.
+// It is named after the DOMException browsers and Node throw in the same
+// situation so clone failures can be reported in the same vocabulary.
+type DataCloneError struct {
+	Message string // e.g. "func() could not be cloned"
+}
+
+// Name returns "DataCloneError", the DOMException name.
+func (e *DataCloneError) Name() string {
+	return "DataCloneError"
+}
+
+func (e *DataCloneError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrDataClone, e.Message)
+}
+
+// Unwrap returns ErrDataClone.
+func (e *DataCloneError) Unwrap() error {
+	return ErrDataClone
+}
+
+func cloneError(format string, args ...interface{}) error {
+	return &DataCloneError{Message: fmt.Sprintf(format, args...) + " could not be cloned"}
+}
+
+// WithEncodeMetrics reports per-call encode statistics to m.
+func WithEncodeMetrics(m MetricsSink) SerializerOption {
+	return func(s *Serializer) {
+		s.metrics = m
+	}
+}
+
+// WithSerializeVersion sets the format version written in the header
+// (default SerializeVersion), e.g. one picked with NegotiateVersion for a
+// peer that only reads older versions. The values this package writes are
+// encoded the same way in every version from MinVersion up; versions outside
+// MinVersion..SerializeVersion make encoding fail with
+// ErrUnsupportedVersion.
+func WithSerializeVersion(version uint32) SerializerOption {
+	return func(s *Serializer) {
+		s.version = version
+	}
 }
 
 // NewSerializer creates a new serializer.
-func NewSerializer() *Serializer {
-	return &Serializer{
-		writer:  wire.NewWriter(256),
-		objects: make(map[interface{}]uint32),
+func NewSerializer(opts ...SerializerOption) *Serializer {
+	s := &Serializer{
+		writer:        wire.NewWriter(256),
+		objects:       make(map[interface{}]uint32),
+		version:       SerializeVersion,
+		maxDepth:      DefaultMaxDepth,
+		sparseDensity: DefaultSparseArrayDensity,
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	s.writer.Grow(s.sizeHint)
+	return s
 }
 
 // Serialize serializes a Value to V8 format.
-func Serialize(v Value) ([]byte, error) {
-	s := NewSerializer()
+func Serialize(v Value, opts ...SerializerOption) ([]byte, error) {
+	s := NewSerializer(opts...)
 	return s.Serialize(v)
 }
 
@@ -42,74 +303,499 @@ func Serialize(v Value) ([]byte, error) {
 // Supported types:
 //   - nil → null
 //   - bool → boolean
-//   - int, int32, int64 → int32 or double
-//   - uint, uint32, uint64 → uint32 or double
+//   - int, int32, int64 → int32 or double (see WithIntOverflowPolicy)
+//   - uint32 → int32, or uint32 from 2^31 up (see Uint32)
+//   - uint, uint8, uint16, uint64 → int32 or double
 //   - float32, float64 → double
 //   - string → string
 //   - *big.Int → BigInt
-//   - time.Time → Date
+//   - time.Time → Date (see WithTimePrecisionPolicy)
 //   - []interface{} → array
 //   - map[string]interface{} → object
 //   - []byte → ArrayBuffer
-func SerializeGo(v interface{}) ([]byte, error) {
-	s := NewSerializer()
+//   - RawValue → the pre-encoded value, spliced in verbatim
+//
+// Functions and channels fail unless WithUnsupportedValuePolicy says otherwise.
+func SerializeGo(v interface{}, opts ...SerializerOption) ([]byte, error) {
+	s := NewSerializer(opts...)
 	return s.SerializeGo(v)
 }
 
 // Serialize serializes a Value.
 func (s *Serializer) Serialize(v Value) ([]byte, error) {
-	s.writeHeader()
-	if err := s.writeValue(v); err != nil {
-		return nil, err
-	}
-	return s.writer.Bytes(), nil
+	return s.observe(func() error {
+		if err := s.writeHeader(); err != nil {
+			return err
+		}
+		return s.writeValue(v)
+	})
 }
 
 // SerializeGo serializes a Go value.
 func (s *Serializer) SerializeGo(v interface{}) ([]byte, error) {
-	s.writeHeader()
-	if err := s.writeGoValue(v); err != nil {
+	return s.observe(func() error {
+		if err := s.writeHeader(); err != nil {
+			return err
+		}
+		if s.estimate {
+			s.writer.Grow(estimateGoSize(v))
+		}
+		return s.writeGoValue(v)
+	})
+}
+
+// WriteHeader writes a version header at the current position. WriteValue
+// writes one automatically before the first value; call WriteHeader directly
+// to control exactly where headers appear, e.g. when embedding payloads in
+// custom framing. It fails only if WithSerializeVersion set an unsupported
+// version.
+func (s *Serializer) WriteHeader() error {
+	return s.writeHeader()
+}
+
+// WriteValue appends v to the payload, writing the header before the first
+// value. Calling it repeatedly produces a multi-root payload (one header,
+// several values) that Deserializer.ReadValue or Node's v8.Deserializer can
+// read back value by value. Use Bytes to retrieve the result.
+func (s *Serializer) WriteValue(v Value) error {
+	if !s.headerWritten {
+		if err := s.writeHeader(); err != nil {
+			return err
+		}
+	}
+	return s.writeValue(v)
+}
+
+// ErrInvalidFragment is returned by WriteRaw and for RawValue children that
+// do not hold exactly one value.
+var ErrInvalidFragment = errors.New("v8serialize: invalid raw fragment")
+
+// RawValue is an already-encoded payload that SerializeGo splices in
+// verbatim where it appears, e.g. a cached encoding of static config inside
+// a larger message. It follows the same rules as WriteRaw.
+type RawValue []byte
+
+// WriteRaw appends a pre-encoded value to the payload without re-encoding
+// it, writing the header before the first value like WriteValue. encoded
+// must be a complete payload as produced by Serialize: a header with the
+// serializer's version followed by exactly one value. Object IDs are
+// numbered per payload, so fragments containing object references,
+// including those inside an Error cause or a view over an earlier buffer,
+// are rejected. Violations fail with ErrInvalidFragment; nothing is written.
+func (s *Serializer) WriteRaw(encoded []byte) error {
+	if !s.headerWritten {
+		if err := s.writeHeader(); err != nil {
+			return err
+		}
+	}
+	return s.writeRaw(encoded)
+}
+
+// writeRaw validates a fragment and copies its value. The fragment's
+// objects take reference IDs in this payload as they did in their own.
+func (s *Serializer) writeRaw(encoded []byte) error {
+	start, version, objects, err := scanStandalone(encoded, ErrInvalidFragment)
+	if err != nil {
+		if !errors.Is(err, ErrInvalidFragment) {
+			err = fmt.Errorf("%w: %v", ErrInvalidFragment, err)
+		}
+		return err
+	}
+	if version != s.version {
+		return fmt.Errorf("%w: fragment has version %d, want %d", ErrInvalidFragment, version, s.version)
+	}
+	// Keep two-byte strings aligned as they were in the fragment.
+	if (s.writer.Len()-start)%2 != 0 {
+		s.writer.WriteByte(tagPadding)
+	}
+	s.writer.WriteBytes(encoded[start:])
+	s.nextID += uint32(objects)
+	return nil
+}
+
+// Bytes returns the payload written so far.
+func (s *Serializer) Bytes() []byte {
+	return s.writer.Bytes()
+}
+
+// observe runs an encode and reports it to the metrics sink, if any.
+func (s *Serializer) observe(encode func() error) ([]byte, error) {
+	if s.metrics == nil {
+		if err := encode(); err != nil {
+			return nil, err
+		}
+		return s.writer.Bytes(), nil
+	}
+
+	start := time.Now()
+	s.tagCounts = make(map[byte]int)
+	err := encode()
+	s.metrics.ObserveEncode(Stats{
+		Bytes:    s.writer.Len(),
+		Duration: time.Since(start),
+		Tags:     s.tagCounts,
+		Err:      err,
+	})
+	if err != nil {
 		return nil, err
 	}
 	return s.writer.Bytes(), nil
 }
 
-func (s *Serializer) writeHeader() {
+// writeTag writes a tag that starts a value, counting it for metrics and,
+// for objects, toward reference IDs.
+func (s *Serializer) writeTag(tag byte) {
+	if s.tagCounts != nil {
+		s.tagCounts[tag]++
+	}
+	if isObjectTag(tag) {
+		s.nextID++
+	}
+	s.writer.WriteByte(tag)
+}
+
+// isObjectTag reports whether tag starts a value that V8 assigns a
+// reference ID: every JavaScript object, but no primitives or strings.
+func isObjectTag(tag byte) bool {
+	switch tag {
+	case tagBeginJSObject, tagBeginDenseArray, tagBeginSparseArray, tagDate, tagRegExp,
+		tagBeginMap, tagBeginSet, tagArrayBuffer, tagArrayBufferTransfer, tagSharedArrayBuffer, tagArrayBufferView, tagHostObject, tagError,
+		tagNumberObject, tagBigIntObject, tagTrueObject, tagFalseObject, tagStringObject:
+		return true
+	}
+	return false
+}
+
+// bufferKey identifies the memory behind an ArrayBuffer, so buffers shared
+// by several Values are written once.
+type bufferKey struct {
+	data *byte
+	len  int
+}
+
+// bufferID returns the reference ID of buf if it was already written in
+// this payload. Empty buffers are never shared.
+func (s *Serializer) bufferID(buf []byte) (uint32, bool) {
+	if len(buf) == 0 {
+		return 0, false
+	}
+	id, ok := s.objects[bufferKey{&buf[0], len(buf)}]
+	return id, ok
+}
+
+// writeBufferRef writes a back-reference if buf was already written in
+// this payload, reporting whether it did.
+func (s *Serializer) writeBufferRef(buf []byte) bool {
+	id, ok := s.bufferID(buf)
+	if ok {
+		s.writeTag(tagObjectReference)
+		s.writer.WriteVarint32(id)
+	}
+	return ok
+}
+
+func (s *Serializer) writeHeader() error {
+	if s.version < MinVersion || s.version > SerializeVersion {
+		return fmt.Errorf("%w: cannot write version %d (supported: %d-%d)", ErrUnsupportedVersion, s.version, MinVersion, SerializeVersion)
+	}
 	s.writer.WriteByte(tagVersion)
-	s.writer.WriteVarint32(SerializeVersion)
+	s.writer.WriteVarint32(s.version)
+	s.headerWritten = true
+	s.nextID = 0 // reference IDs are per payload
+	clear(s.objects)
+	return nil
+}
+
+// encodeItem is a value waiting to be encoded: either a Value or a Go value
+// accepted by SerializeGo.
+type encodeItem struct {
+	value   Value
+	goValue interface{}
+	isGo    bool
+}
+
+// encodeFrame is a container whose children are still being written.
+// Containers are kept on an explicit stack instead of recursing, so deeply
+// nested values encode without growing the Go stack.
+type encodeFrame struct {
+	tag     byte   // tag that opened the container
+	next    int    // index of the next child
+	written uint32 // children actually written, for the end tag's count
+
+	values   []Value
+	goValues []interface{}
+	keys     []string
+	props    map[string]Value
+	goProps  map[string]interface{}
+	entries  []MapEntry
+	cause    *Value
 }
 
 func (s *Serializer) writeValue(v Value) error {
+	s.strings, s.binaryBytes = 0, 0
+	return s.encode(encodeItem{value: v})
+}
+
+func (s *Serializer) writeGoValue(v interface{}) error {
+	s.strings, s.binaryBytes = 0, 0
+	return s.encode(encodeItem{goValue: v, isGo: true})
+}
+
+// located adds the current path to err, e.g. "unsupported Go type func()
+// at items[5].callback". Errors at the root and LimitExceededErrors, which
+// carry their own Path, are returned unchanged.
+func (s *Serializer) located(err error) error {
+	var le *LimitExceededError
+	if errors.As(err, &le) {
+		return err
+	}
+	if path := s.path(); path != "" {
+		return fmt.Errorf("%w at %s", err, path)
+	}
+	return err
+}
+
+// countString charges one string value against WithMaxStrings.
+func (s *Serializer) countString() error {
+	s.strings++
+	if exceeds(s.strings, s.maxStrings) {
+		return &LimitExceededError{Limit: "max_strings", Max: s.maxStrings, Path: s.path()}
+	}
+	return nil
+}
+
+// countBinary charges n bytes against WithMaxBinaryBytes.
+func (s *Serializer) countBinary(n int) error {
+	s.binaryBytes += n
+	if exceeds(s.binaryBytes, s.maxBinary) {
+		return &LimitExceededError{Limit: "max_binary_bytes", Max: s.maxBinary, Path: s.path()}
+	}
+	return nil
+}
+
+// path describes the location of the value currently being written, using
+// JavaScript accessor syntax ("items[5].callback"). Map entries are written
+// as [i][0] for the key and [i][1] for the value, as in ToJSON.
+func (s *Serializer) path() string {
+	var b strings.Builder
+	for i := range s.stack {
+		f := &s.stack[i]
+		cur := f.next - 1
+		switch f.tag {
+		case tagBeginJSObject:
+			appendKeySegment(&b, f.keys[cur])
+		case tagBeginMap:
+			fmt.Fprintf(&b, "[%d][%d]", cur/2, cur%2)
+		case tagBeginDenseArray, tagBeginSparseArray, tagBeginSet:
+			fmt.Fprintf(&b, "[%d]", cur)
+		default: // tagError
+			if b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString("cause")
+		}
+	}
+	return b.String()
+}
+
+// isIdentifier reports whether key can be written after a dot in a path.
+func isIdentifier(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i, r := range key {
+		switch {
+		case r == '_' || r == '$' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z':
+		case i > 0 && r >= '0' && r <= '9':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// encode writes item and everything nested inside it.
+func (s *Serializer) encode(item encodeItem) error {
+	base := len(s.stack)
+	defer func() { s.stack = s.stack[:base] }()
+
+	for {
+		if exceeds(len(s.stack)-base+1, s.maxDepth) {
+			return fmt.Errorf("%w: encoding deeper than %d levels (is the value cyclic?)", ErrMaxDepthExceeded, s.maxDepth)
+		}
+
+		var err error
+		if item.isGo {
+			err = s.beginGoValue(item.goValue)
+		} else {
+			err = s.beginValue(item.value)
+		}
+		if err != nil {
+			return s.located(err)
+		}
+
+		// Find the next child, closing finished containers on the way.
+		for {
+			if len(s.stack) == base {
+				return nil
+			}
+			f := &s.stack[len(s.stack)-1]
+			next, ok, err := s.nextChild(f)
+			if err != nil {
+				return s.located(err)
+			}
+			if ok {
+				item = next
+				break
+			}
+			s.closeFrame(f)
+			s.stack = s.stack[:len(s.stack)-1]
+		}
+	}
+}
+
+// nextChild returns the next child of f, writing any framing that precedes
+// it (object keys, Error sub-tags). It reports false once f has no more
+// children.
+func (s *Serializer) nextChild(f *encodeFrame) (encodeItem, bool, error) {
+	switch f.tag {
+	case tagBeginJSObject:
+		// Skipped properties are dropped before their key is written.
+		for f.next < len(f.keys) && f.goProps != nil &&
+			s.unsupported == UnsupportedSkip && uncloneable(f.goProps[f.keys[f.next]]) {
+			f.next++
+		}
+		if f.next >= len(f.keys) {
+			return encodeItem{}, false, nil
+		}
+		key := f.keys[f.next]
+		f.next++
+		if err := s.writeString(key); err != nil {
+			return encodeItem{}, false, err
+		}
+		f.written++
+		if f.goProps != nil {
+			return encodeItem{goValue: f.goProps[key], isGo: true}, true, nil
+		}
+		return encodeItem{value: f.props[key]}, true, nil
+
+	case tagBeginDenseArray, tagBeginSet:
+		i := f.next
+		f.next++
+		if f.goValues != nil {
+			if i >= len(f.goValues) {
+				return encodeItem{}, false, nil
+			}
+			f.written++
+			return encodeItem{goValue: f.goValues[i], isGo: true}, true, nil
+		}
+		if i >= len(f.values) {
+			return encodeItem{}, false, nil
+		}
+		f.written++
+		return encodeItem{value: f.values[i]}, true, nil
+
+	case tagBeginSparseArray:
+		// Holes are left out; each present element is keyed by its index.
+		i := f.next
+		for i < len(f.values) && f.values[i].typ == TypeHole {
+			i++
+		}
+		if i >= len(f.values) {
+			return encodeItem{}, false, nil
+		}
+		f.next = i + 1
+		if err := s.writeInt(int64(i)); err != nil {
+			return encodeItem{}, false, err
+		}
+		f.written++
+		return encodeItem{value: f.values[i]}, true, nil
+
+	case tagBeginMap:
+		i := f.next
+		if i >= len(f.entries)*2 {
+			return encodeItem{}, false, nil
+		}
+		f.next++
+		entry := f.entries[i/2]
+		if i%2 == 0 {
+			return encodeItem{value: entry.Key}, true, nil
+		}
+		f.written++
+		return encodeItem{value: entry.Value}, true, nil
+
+	default: // tagError
+		if f.next > 0 || f.cause == nil {
+			return encodeItem{}, false, nil
+		}
+		f.next++
+		// Write cause if present
+		s.writer.WriteByte(errorTagCause)
+		return encodeItem{value: *f.cause}, true, nil
+	}
+}
+
+// closeFrame writes the end of a container.
+func (s *Serializer) closeFrame(f *encodeFrame) {
+	switch f.tag {
+	case tagBeginJSObject:
+		s.writer.WriteByte(tagEndJSObject)
+		s.writer.WriteVarint32(f.written)
+	case tagBeginDenseArray:
+		s.writer.WriteByte(tagEndDenseArray)
+		s.writer.WriteVarint32(0) // no extra properties
+		s.writer.WriteVarint32(f.written)
+	case tagBeginSparseArray:
+		s.writer.WriteByte(tagEndSparseArray)
+		s.writer.WriteVarint32(f.written)
+		s.writer.WriteVarint32(uint32(len(f.values)))
+	case tagBeginMap:
+		s.writer.WriteByte(tagEndMap)
+		s.writer.WriteVarint32(f.written * 2)
+	case tagBeginSet:
+		s.writer.WriteByte(tagEndSet)
+		s.writer.WriteVarint32(f.written)
+	default: // tagError
+		// End of error
+		s.writer.WriteByte(errorTagEnd)
+	}
+}
+
+// beginValue writes a scalar Value completely, or the start of a container.
+func (s *Serializer) beginValue(v Value) error {
 	switch v.Type() {
 	case TypeNull:
-		s.writer.WriteByte(tagNull)
+		s.writeTag(tagNull)
 	case TypeUndefined:
-		s.writer.WriteByte(tagUndefined)
+		s.writeTag(tagUndefined)
 	case TypeBool:
 		if v.AsBool() {
-			s.writer.WriteByte(tagTrue)
+			s.writeTag(tagTrue)
 		} else {
-			s.writer.WriteByte(tagFalse)
+			s.writeTag(tagFalse)
 		}
 	case TypeInt32:
-		s.writer.WriteByte(tagInt32)
+		s.writeTag(tagInt32)
 		s.writer.WriteZigZag32(v.AsInt32())
 	case TypeUint32:
-		s.writer.WriteByte(tagUint32)
+		s.writeTag(tagUint32)
 		s.writer.WriteVarint32(v.AsUint32())
 	case TypeDouble:
-		s.writer.WriteByte(tagDouble)
+		s.writeTag(tagDouble)
 		s.writer.WriteDouble(v.AsDouble())
 	case TypeBigInt:
 		return s.writeBigInt(v.AsBigInt())
 	case TypeString:
+		if err := s.countString(); err != nil {
+			return err
+		}
 		return s.writeString(v.AsString())
 	case TypeDate:
-		s.writer.WriteByte(tagDate)
-		ms := float64(v.AsDate().UnixMilli())
+		s.writeTag(tagDate)
+		ms := v.data.(float64) // NaN for an Invalid Date
 		s.writer.WriteDouble(ms)
 	case TypeObject:
-		return s.writeObject(v.AsObject())
+		return s.writeObject(v.data.(*object))
 	case TypeArray:
 		return s.writeArray(v.AsArray())
 	case TypeMap:
@@ -118,6 +804,10 @@ func (s *Serializer) writeValue(v Value) error {
 		return s.writeSet(v.Interface().(*JSSet))
 	case TypeArrayBuffer:
 		return s.writeArrayBuffer(v.Interface().([]byte))
+	case TypeDetachedArrayBuffer:
+		return s.writeDetachedArrayBuffer(v.data.(*DetachedArrayBuffer))
+	case TypeSharedArrayBuffer:
+		return s.writeSharedArrayBuffer(v.data.(*SharedArrayBufferRef))
 	case TypeRegExp:
 		return s.writeRegExp(v.Interface().(*RegExp))
 	case TypeError:
@@ -127,25 +817,44 @@ func (s *Serializer) writeValue(v Value) error {
 	case TypeBoxedPrimitive:
 		return s.writeBoxedPrimitive(v.Interface().(*BoxedPrimitive))
 	case TypeHole:
-		s.writer.WriteByte(tagHole)
+		switch s.holes {
+		case HoleAsUndefined:
+			s.writeTag(tagUndefined)
+		case HoleAsError:
+			return ErrHoleRejected
+		default:
+			s.writeTag(tagHole)
+		}
 	default:
-		return fmt.Errorf("v8serialize: unsupported type %s", v.Type())
+		return cloneError("%s value", v.Type())
 	}
 	return nil
 }
 
-func (s *Serializer) writeGoValue(v interface{}) error {
+// beginGoValue is beginValue for the Go values accepted by SerializeGo.
+func (s *Serializer) beginGoValue(v interface{}) error {
 	if v == nil {
-		s.writer.WriteByte(tagNull)
+		s.writeTag(tagNull)
 		return nil
 	}
+	if uncloneable(v) {
+		switch s.unsupported {
+		case UnsupportedSkip:
+			s.writeTag(tagUndefined)
+			return nil
+		case UnsupportedNull:
+			s.writeTag(tagNull)
+			return nil
+		}
+		return cloneError("%T", v)
+	}
 
 	switch val := v.(type) {
 	case bool:
 		if val {
-			s.writer.WriteByte(tagTrue)
+			s.writeTag(tagTrue)
 		} else {
-			s.writer.WriteByte(tagFalse)
+			s.writeTag(tagFalse)
 		}
 	case int:
 		return s.writeInt(int64(val))
@@ -154,7 +863,7 @@ func (s *Serializer) writeGoValue(v interface{}) error {
 	case int16:
 		return s.writeInt(int64(val))
 	case int32:
-		s.writer.WriteByte(tagInt32)
+		s.writeTag(tagInt32)
 		s.writer.WriteZigZag32(val)
 	case int64:
 		return s.writeInt(val)
@@ -165,28 +874,24 @@ func (s *Serializer) writeGoValue(v interface{}) error {
 	case uint16:
 		return s.writeUint(uint64(val))
 	case uint32:
-		if val <= math.MaxInt32 {
-			s.writer.WriteByte(tagInt32)
-			s.writer.WriteZigZag32(int32(val))
-		} else {
-			s.writer.WriteByte(tagDouble)
-			s.writer.WriteDouble(float64(val))
-		}
+		return s.writeUint(uint64(val))
 	case uint64:
 		return s.writeUint(val)
 	case float32:
-		s.writer.WriteByte(tagDouble)
+		s.writeTag(tagDouble)
 		s.writer.WriteDouble(float64(val))
 	case float64:
-		s.writer.WriteByte(tagDouble)
+		s.writeTag(tagDouble)
 		s.writer.WriteDouble(val)
 	case string:
+		if err := s.countString(); err != nil {
+			return err
+		}
 		return s.writeString(val)
 	case *big.Int:
 		return s.writeBigInt(val)
 	case time.Time:
-		s.writer.WriteByte(tagDate)
-		s.writer.WriteDouble(float64(val.UnixMilli()))
+		return s.writeTime(val)
 	case []byte:
 		return s.writeArrayBuffer(val)
 	case []interface{}:
@@ -194,55 +899,119 @@ func (s *Serializer) writeGoValue(v interface{}) error {
 	case map[string]interface{}:
 		return s.writeGoObject(val)
 	case Value:
-		return s.writeValue(val)
+		return s.beginValue(val)
+	case RawValue:
+		return s.writeRaw(val)
 	default:
 		return fmt.Errorf("v8serialize: unsupported Go type %T", v)
 	}
 	return nil
 }
 
+// uncloneable reports whether v is a Go value with no structured clone
+// equivalent, as opposed to one the mapper merely does not support.
+func uncloneable(v interface{}) bool {
+	if v == nil {
+		return false
+	}
+	switch reflect.TypeOf(v).Kind() {
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return true
+	}
+	return false
+}
+
 func (s *Serializer) writeInt(n int64) error {
 	if n >= math.MinInt32 && n <= math.MaxInt32 {
-		s.writer.WriteByte(tagInt32)
+		s.writeTag(tagInt32)
 		s.writer.WriteZigZag32(int32(n))
-	} else {
-		s.writer.WriteByte(tagDouble)
-		s.writer.WriteDouble(float64(n))
+		return nil
+	}
+	abs := uint64(n)
+	if n < 0 {
+		abs = -abs
 	}
+	if !exactDouble(abs) {
+		return s.writeInexact(big.NewInt(n))
+	}
+	s.writeTag(tagDouble)
+	s.writer.WriteDouble(float64(n))
 	return nil
 }
 
 func (s *Serializer) writeUint(n uint64) error {
 	if n <= math.MaxInt32 {
-		s.writer.WriteByte(tagInt32)
+		s.writeTag(tagInt32)
 		s.writer.WriteZigZag32(int32(n))
-	} else {
-		s.writer.WriteByte(tagDouble)
-		s.writer.WriteDouble(float64(n))
+		return nil
+	}
+	if n <= math.MaxUint32 {
+		s.writeTag(tagUint32)
+		s.writer.WriteVarint32(uint32(n))
+		return nil
+	}
+	if !exactDouble(n) {
+		return s.writeInexact(new(big.Int).SetUint64(n))
+	}
+	s.writeTag(tagDouble)
+	s.writer.WriteDouble(float64(n))
+	return nil
+}
+
+// exactDouble reports whether a float64 holds the integer n exactly: its
+// significant bits must fit in the 53-bit mantissa.
+func exactDouble(n uint64) bool {
+	return n == 0 || bits.Len64(n)-bits.TrailingZeros64(n) <= 53
+}
+
+// writeInexact writes an integer that a double cannot hold exactly,
+// according to the IntOverflowPolicy.
+func (s *Serializer) writeInexact(n *big.Int) error {
+	switch s.intOverflow {
+	case IntOverflowRound:
+		f, _ := new(big.Float).SetInt(n).Float64()
+		s.writeTag(tagDouble)
+		s.writer.WriteDouble(f)
+		return nil
+	case IntOverflowBigInt:
+		return s.writeBigInt(n)
+	}
+	return fmt.Errorf("%w: %s", ErrIntOverflow, n)
+}
+
+func (s *Serializer) writeTime(t time.Time) error {
+	if t.Nanosecond()%int(time.Millisecond) != 0 {
+		switch s.timePrecision {
+		case TimeRound:
+			t = t.Round(time.Millisecond)
+		case TimeError:
+			return fmt.Errorf("%w: %s", ErrTimePrecision, t.Format(time.RFC3339Nano))
+		}
 	}
+	s.writeTag(tagDate)
+	s.writer.WriteDouble(float64(t.UnixMilli()))
 	return nil
 }
 
 func (s *Serializer) writeString(str string) error {
-	if wire.NeedsUTF16(str) {
-		s.writer.WriteByte(tagTwoByteString)
-		utf16Len := wire.UTF16Length(str)
+	needsUTF16, utf16Len, latin1Len := wire.AnalyzeString(str)
+	if needsUTF16 {
+		s.writeTag(tagTwoByteString)
 		s.writer.WriteVarint32(uint32(utf16Len * 2)) // byte length
-		s.writer.WriteTwoByteString(str)
+		s.writer.WriteTwoByteStringN(str, utf16Len)
 	} else {
-		s.writer.WriteByte(tagOneByteString)
+		s.writeTag(tagOneByteString)
 		// For one-byte strings, the length is the number of Latin-1 characters.
 		// For valid UTF-8, this is the rune count (each rune <= 255 becomes one byte).
 		// For invalid UTF-8, this is the byte count (raw bytes are written).
-		length := wire.OneByteStringLength(str)
-		s.writer.WriteVarint32(uint32(length))
+		s.writer.WriteVarint32(uint32(latin1Len))
 		s.writer.WriteOneByteString(str)
 	}
 	return nil
 }
 
 func (s *Serializer) writeBigInt(n *big.Int) error {
-	s.writer.WriteByte(tagBigInt)
+	s.writeTag(tagBigInt)
 
 	if n.Sign() == 0 {
 		s.writer.WriteVarint(0) // bitfield: 0 digits, positive
@@ -269,112 +1038,97 @@ func (s *Serializer) writeBigInt(n *big.Int) error {
 	return nil
 }
 
-func (s *Serializer) writeObject(obj map[string]Value) error {
-	s.writer.WriteByte(tagBeginJSObject)
-
-	for key, val := range obj {
-		if err := s.writeString(key); err != nil {
-			return err
-		}
-		if err := s.writeValue(val); err != nil {
-			return err
-		}
+func (s *Serializer) writeObject(obj *object) error {
+	s.writeTag(tagBeginJSObject)
+	keys := obj.keys()
+	if s.sortedKeys {
+		sort.Strings(keys)
 	}
-
-	s.writer.WriteByte(tagEndJSObject)
-	s.writer.WriteVarint32(uint32(len(obj)))
+	s.stack = append(s.stack, encodeFrame{tag: tagBeginJSObject, keys: keys, props: obj.props})
 	return nil
 }
 
 func (s *Serializer) writeGoObject(obj map[string]interface{}) error {
-	s.writer.WriteByte(tagBeginJSObject)
-
-	for key, val := range obj {
-		if err := s.writeString(key); err != nil {
-			return err
-		}
-		if err := s.writeGoValue(val); err != nil {
-			return err
-		}
+	s.writeTag(tagBeginJSObject)
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
 	}
-
-	s.writer.WriteByte(tagEndJSObject)
-	s.writer.WriteVarint32(uint32(len(obj)))
+	if s.sortedKeys {
+		sort.Strings(keys)
+	}
+	s.stack = append(s.stack, encodeFrame{tag: tagBeginJSObject, keys: keys, goProps: obj})
 	return nil
 }
 
 func (s *Serializer) writeArray(arr []Value) error {
-	s.writer.WriteByte(tagBeginDenseArray)
-	s.writer.WriteVarint32(uint32(len(arr)))
-
-	for _, elem := range arr {
-		if err := s.writeValue(elem); err != nil {
-			return err
-		}
+	if s.sparse(arr) {
+		s.writeTag(tagBeginSparseArray)
+		s.writer.WriteVarint32(uint32(len(arr)))
+		s.stack = append(s.stack, encodeFrame{tag: tagBeginSparseArray, values: arr})
+		return nil
 	}
-
-	s.writer.WriteByte(tagEndDenseArray)
-	s.writer.WriteVarint32(0) // no extra properties
+	s.writeTag(tagBeginDenseArray)
 	s.writer.WriteVarint32(uint32(len(arr)))
+	s.stack = append(s.stack, encodeFrame{tag: tagBeginDenseArray, values: arr})
 	return nil
 }
 
 func (s *Serializer) writeGoArray(arr []interface{}) error {
-	s.writer.WriteByte(tagBeginDenseArray)
-	s.writer.WriteVarint32(uint32(len(arr)))
-
-	for _, elem := range arr {
-		if err := s.writeGoValue(elem); err != nil {
-			return err
-		}
-	}
-
-	s.writer.WriteByte(tagEndDenseArray)
-	s.writer.WriteVarint32(0) // no extra properties
+	s.writeTag(tagBeginDenseArray)
 	s.writer.WriteVarint32(uint32(len(arr)))
+	s.stack = append(s.stack, encodeFrame{tag: tagBeginDenseArray, goValues: arr})
 	return nil
 }
 
 func (s *Serializer) writeMap(m *JSMap) error {
-	s.writer.WriteByte(tagBeginMap)
-
-	for _, entry := range m.Entries {
-		if err := s.writeValue(entry.Key); err != nil {
-			return err
-		}
-		if err := s.writeValue(entry.Value); err != nil {
-			return err
-		}
-	}
-
-	s.writer.WriteByte(tagEndMap)
-	s.writer.WriteVarint32(uint32(len(m.Entries) * 2))
+	s.writeTag(tagBeginMap)
+	s.stack = append(s.stack, encodeFrame{tag: tagBeginMap, entries: m.Entries})
 	return nil
 }
 
-func (s *Serializer) writeSet(set *JSSet) error {
-	s.writer.WriteByte(tagBeginSet)
-
-	for _, val := range set.Values {
-		if err := s.writeValue(val); err != nil {
-			return err
+// sparse reports whether arr has holes and is less dense than
+// WithSparseArrayDensity allows.
+func (s *Serializer) sparse(arr []Value) bool {
+	if s.holes != HoleAsHole {
+		return false
+	}
+	present := 0
+	for _, v := range arr {
+		if v.typ != TypeHole {
+			present++
 		}
 	}
+	if present == len(arr) {
+		return false
+	}
+	return float64(present) < s.sparseDensity*float64(len(arr))
+}
 
-	s.writer.WriteByte(tagEndSet)
-	s.writer.WriteVarint32(uint32(len(set.Values)))
+func (s *Serializer) writeSet(set *JSSet) error {
+	s.writeTag(tagBeginSet)
+	s.stack = append(s.stack, encodeFrame{tag: tagBeginSet, values: set.Values})
 	return nil
 }
 
 func (s *Serializer) writeArrayBuffer(buf []byte) error {
-	s.writer.WriteByte(tagArrayBuffer)
+	if s.writeBufferRef(buf) {
+		return nil
+	}
+	if err := s.countBinary(len(buf)); err != nil {
+		return err
+	}
+	if len(buf) > 0 {
+		s.objects[bufferKey{&buf[0], len(buf)}] = s.nextID
+	}
+	s.writeTag(tagArrayBuffer)
 	s.writer.WriteVarint32(uint32(len(buf)))
 	s.writer.WriteBytes(buf)
 	return nil
 }
 
 func (s *Serializer) writeRegExp(re *RegExp) error {
-	s.writer.WriteByte(tagRegExp)
+	s.writeTag(tagRegExp)
 
 	// Write pattern as string
 	if err := s.writeString(re.Pattern); err != nil {
@@ -404,7 +1158,7 @@ func (s *Serializer) writeRegExp(re *RegExp) error {
 }
 
 func (s *Serializer) writeError(jsErr *JSError) error {
-	s.writer.WriteByte(tagError)
+	s.writeTag(tagError)
 
 	// Determine error type tag
 	switch jsErr.Name {
@@ -466,21 +1220,20 @@ func (s *Serializer) writeError(jsErr *JSError) error {
 		}
 	}
 
-	// Write cause if present
-	if jsErr.Cause != nil {
-		s.writer.WriteByte(errorTagCause)
-		if err := s.writeValue(*jsErr.Cause); err != nil {
-			return err
-		}
-	}
-
-	// End of error
-	s.writer.WriteByte(errorTagEnd)
+	// The cause, if present, and the end tag are written by the encode loop
+	s.stack = append(s.stack, encodeFrame{tag: tagError, cause: jsErr.Cause})
 	return nil
 }
 
 func (s *Serializer) writeTypedArray(view *ArrayBufferView) error {
-	s.writer.WriteByte(tagTypedArray)
+	// Views made by NewView are written natively: their buffer (or a
+	// reference to it) and then the view, so views sharing a buffer share it
+	// after decoding too. Others use Node's host-object form.
+	if view.shared {
+		return s.writeNativeView(view)
+	}
+
+	s.writeTag(tagHostObject)
 
 	// Determine type ID
 	var typeID byte
@@ -505,6 +1258,8 @@ func (s *Serializer) writeTypedArray(view *ArrayBufferView) error {
 		typeID = typedArrayFloat64
 	case "DataView":
 		typeID = typedArrayDataView
+	case "Buffer":
+		typeID = typedArrayBuffer
 	case "Float16Array":
 		typeID = typedArrayFloat16
 	case "BigInt64Array":
@@ -512,34 +1267,68 @@ func (s *Serializer) writeTypedArray(view *ArrayBufferView) error {
 	case "BigUint64Array":
 		typeID = typedArrayBigUint64
 	default:
-		return fmt.Errorf("v8serialize: unknown TypedArray type %s", view.Type)
+		return cloneError("TypedArray of type %q", view.Type)
+	}
+
+	// Views decoded from native encodings cover part of a larger buffer;
+	// Node's host-object format carries only the viewed bytes.
+	data := view.Slice()
+	if err := s.countBinary(len(data)); err != nil {
+		return err
 	}
+	s.writer.WriteVarint32(uint32(typeID))
+	s.writer.WriteVarint32(uint32(len(data)))
+	s.writer.WriteBytes(data)
+	return nil
+}
 
-	s.writer.WriteByte(typeID)
-	s.writer.WriteVarint32(uint32(len(view.Buffer)))
-	s.writer.WriteBytes(view.Buffer)
+// writeNativeView writes view in V8's own encoding: its whole buffer, or a
+// reference to it, followed by an ArrayBufferView record.
+func (s *Serializer) writeNativeView(view *ArrayBufferView) error {
+	subTag, ok := viewSubTag(view.Type)
+	if !ok {
+		return cloneError("TypedArray of type %q", view.Type)
+	}
+	if view.ByteOffset < 0 || view.ByteLength < 0 || view.ByteOffset+view.ByteLength > len(view.Buffer) {
+		return fmt.Errorf("%w: %s [%d, %d) outside %d-byte buffer", ErrInvalidView, view.Type, view.ByteOffset, view.ByteOffset+view.ByteLength, len(view.Buffer))
+	}
+	if err := s.writeArrayBuffer(view.Buffer); err != nil {
+		return err
+	}
+	s.writeViewRecord(subTag, view.ByteOffset, view.ByteLength)
 	return nil
 }
 
+// writeViewRecord writes a native view over the buffer written just before.
+func (s *Serializer) writeViewRecord(subTag byte, byteOffset, byteLength int) {
+	s.writeTag(tagArrayBufferView)
+	s.writer.WriteByte(subTag)
+	s.writer.WriteVarint32(uint32(byteOffset))
+	s.writer.WriteVarint32(uint32(byteLength))
+	if s.version >= 14 {
+		s.writer.WriteVarint32(0) // flags: not length-tracking, not backed by a resizable buffer
+	}
+}
+
 func (s *Serializer) writeBoxedPrimitive(boxed *BoxedPrimitive) error {
 	switch boxed.PrimitiveType {
 	case TypeDouble:
-		s.writer.WriteByte(tagNumberObject)
+		s.writeTag(tagNumberObject)
 		s.writer.WriteDouble(boxed.Value.AsDouble())
 	case TypeBool:
 		if boxed.Value.AsBool() {
-			s.writer.WriteByte(tagTrueObject)
+			s.writeTag(tagTrueObject)
 		} else {
-			s.writer.WriteByte(tagFalseObject)
+			s.writeTag(tagFalseObject)
 		}
 	case TypeString:
-		s.writer.WriteByte(tagStringObject)
+		s.writeTag(tagStringObject)
 		return s.writeString(boxed.Value.AsString())
 	case TypeBigInt:
-		s.writer.WriteByte(tagBigIntObject)
+		s.writeTag(tagBigIntObject)
 		return s.writeBigInt(boxed.Value.AsBigInt())
 	default:
-		return fmt.Errorf("v8serialize: unsupported boxed primitive type %s", boxed.PrimitiveType)
+		return cloneError("boxed %s", boxed.PrimitiveType)
 	}
 	return nil
 }
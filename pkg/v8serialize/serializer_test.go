@@ -2,8 +2,12 @@ package v8serialize
 
 import (
 	"bytes"
+	"encoding/hex"
+	"errors"
 	"math"
 	"math/big"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -154,7 +158,7 @@ func TestSerializeObjectRoundTrip(t *testing.T) {
 		"b": String("two"),
 		"c": Bool(true),
 	}
-	v := Value{typ: TypeObject, data: obj}
+	v := Object(obj)
 
 	data, err := Serialize(v)
 	if err != nil {
@@ -244,6 +248,435 @@ func TestSerializeGoValues(t *testing.T) {
 	}
 }
 
+func TestWithUnsupportedValuePolicy(t *testing.T) {
+	in := map[string]interface{}{
+		"fn":    func() {},
+		"ch":    make(chan int),
+		"name":  "x",
+		"items": []interface{}{1, func() {}, 3},
+	}
+
+	tests := []struct {
+		name   string
+		policy UnsupportedValuePolicy
+		want   interface{}
+	}{
+		{"skip", UnsupportedSkip, map[string]interface{}{
+			"name":  "x",
+			"items": []interface{}{int32(1), nil, int32(3)},
+		}},
+		{"null", UnsupportedNull, map[string]interface{}{
+			"fn":    nil,
+			"ch":    nil,
+			"name":  "x",
+			"items": []interface{}{int32(1), nil, int32(3)},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := SerializeGo(in, WithUnsupportedValuePolicy(tt.policy))
+			if err != nil {
+				t.Fatalf("SerializeGo failed: %v", err)
+			}
+			v, err := Deserialize(data)
+			if err != nil {
+				t.Fatalf("Deserialize failed: %v", err)
+			}
+			if got := ToGo(v); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("skip-keeps-undefined-elements", func(t *testing.T) {
+		data, err := SerializeGo([]interface{}{func() {}}, WithUnsupportedValuePolicy(UnsupportedSkip))
+		if err != nil {
+			t.Fatalf("SerializeGo failed: %v", err)
+		}
+		v, err := Deserialize(data)
+		if err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+		if elems := v.AsArray(); len(elems) != 1 || !elems[0].IsUndefined() {
+			t.Errorf("got %v, want [undefined]", v)
+		}
+	})
+
+	t.Run("skip-counts", func(t *testing.T) {
+		nested := map[string]interface{}{
+			"a":     map[string]interface{}{"fn": func() {}, "b": map[string]interface{}{"ch": make(chan int), "c": 1}},
+			"fn":    func() {},
+			"items": []interface{}{map[string]interface{}{"fn": func() {}}},
+		}
+		data, err := SerializeGo(nested, WithUnsupportedValuePolicy(UnsupportedSkip))
+		if err != nil {
+			t.Fatalf("SerializeGo failed: %v", err)
+		}
+		d := NewDeserializer(data)
+		if _, err := d.Deserialize(); err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+		if err := d.ValidateCounts(); err != nil {
+			t.Errorf("end-tag counts: %v", err)
+		}
+	})
+
+	t.Run("error-default", func(t *testing.T) {
+		if _, err := SerializeGo(in); err == nil {
+			t.Fatal("expected error for func value")
+		}
+	})
+
+	t.Run("other-types-still-error", func(t *testing.T) {
+		_, err := SerializeGo(struct{}{}, WithUnsupportedValuePolicy(UnsupportedNull))
+		if err == nil {
+			t.Fatal("expected error for struct value")
+		}
+	})
+}
+
+func TestDataCloneError(t *testing.T) {
+	tests := []struct {
+		name string
+		in   interface{}
+		msg  string
+	}{
+		{"go-func", map[string]interface{}{"cb": func() {}}, "v8serialize: DataCloneError: func() could not be cloned at cb"},
+		{"boxed", Value{typ: TypeBoxedPrimitive, data: &BoxedPrimitive{PrimitiveType: TypeObject, Value: Object(nil)}},
+			"v8serialize: DataCloneError: boxed object could not be cloned"},
+		{"typed-array", Value{typ: TypeTypedArray, data: &ArrayBufferView{Type: "Int128Array"}},
+			`v8serialize: DataCloneError: TypedArray of type "Int128Array" could not be cloned`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := SerializeGo(tt.in)
+			var dce *DataCloneError
+			if !errors.As(err, &dce) || !errors.Is(err, ErrDataClone) {
+				t.Fatalf("expected *DataCloneError, got %v", err)
+			}
+			if dce.Name() != "DataCloneError" {
+				t.Errorf("Name() = %q", dce.Name())
+			}
+			if err.Error() != tt.msg {
+				t.Errorf("error = %q, want %q", err.Error(), tt.msg)
+			}
+		})
+	}
+
+	_, err := SerializeGo(struct{}{})
+	if err == nil || errors.Is(err, ErrDataClone) {
+		t.Errorf("unmapped Go type should not be a DataCloneError, got %v", err)
+	}
+}
+
+func TestSerializeSparseArrays(t *testing.T) {
+	holes := func(n int) []Value {
+		arr := make([]Value, n)
+		for i := range arr {
+			arr[i] = Hole()
+		}
+		return arr
+	}
+	holey := []Value{Int32(1), Hole(), Int32(3)}
+
+	tests := []struct {
+		name string
+		arr  []Value
+		opts []SerializerOption
+		want string // hex, matching v8.serialize in Node where noted
+	}{
+		{"default-dense", holes(2), nil, "ff0f41022d2d240002"},
+		{"new Array(5)", holes(5), []SerializerOption{WithSparseArrayDensity(0.5)}, "ff0f6105400005"},
+		{"dense-above-threshold", holey, []SerializerOption{WithSparseArrayDensity(0.5)}, "ff0f410349022d4906240003"},
+		{"[1,,3] like V8", holey, []SerializerOption{WithSparseArrayDensity(1)}, "ff0f61034900490249044906400203"},
+		{"disabled", holes(2), []SerializerOption{WithSparseArrayDensity(0)}, "ff0f41022d2d240002"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Serialize(Array(tt.arr), tt.opts...)
+			if err != nil {
+				t.Fatalf("Serialize failed: %v", err)
+			}
+			if got := bytesToHex(data); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("round-trip", func(t *testing.T) {
+		big := holes(1 << 20)
+		big[7] = String("x")
+		data, err := Serialize(Array(big), WithSparseArrayDensity(0.5))
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		if len(data) > 32 {
+			t.Errorf("sparse encoding took %d bytes", len(data))
+		}
+		v, err := Deserialize(data)
+		if err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+		arr := v.AsArray()
+		if len(arr) != len(big) || arr[7].AsString() != "x" || !arr[6].IsHole() {
+			t.Errorf("round trip lost elements: len %d", len(arr))
+		}
+	})
+}
+
+func TestWithHoleAs(t *testing.T) {
+	arr := Array([]Value{Int32(1), Hole(), Hole(), Hole()})
+
+	data, err := Serialize(arr, WithHoleAs(HoleAsUndefined))
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if got, want := bytesToHex(data), "ff0f410449025f5f5f240004"; got != want {
+		t.Errorf("HoleAsUndefined: got %s, want %s", got, want)
+	}
+
+	_, err = Serialize(Object(map[string]Value{"list": arr}), WithHoleAs(HoleAsError))
+	if !errors.Is(err, ErrHoleRejected) {
+		t.Fatalf("HoleAsError: expected ErrHoleRejected, got %v", err)
+	}
+	if want := "v8serialize: array hole rejected at list[1]"; err.Error() != want {
+		t.Errorf("HoleAsError: error = %q, want %q", err.Error(), want)
+	}
+
+	data, err = Serialize(arr, WithHoleAs(HoleAsHole), WithSparseArrayDensity(1))
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if data[2] != tagBeginSparseArray {
+		t.Errorf("HoleAsHole: expected sparse encoding, got %s", bytesToHex(data))
+	}
+}
+
+func TestWithTimePrecisionPolicy(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 6_500_000, time.UTC) // 6.5ms
+
+	for _, tt := range []struct {
+		policy TimePrecisionPolicy
+		want   int64
+	}{
+		{TimeTruncate, ts.UnixMilli()},
+		{TimeRound, ts.UnixMilli() + 1},
+	} {
+		data, err := SerializeGo(ts, WithTimePrecisionPolicy(tt.policy))
+		if err != nil {
+			t.Fatalf("policy %d: SerializeGo failed: %v", tt.policy, err)
+		}
+		v, err := Deserialize(data)
+		if err != nil {
+			t.Fatalf("policy %d: Deserialize failed: %v", tt.policy, err)
+		}
+		if got := v.AsUnixMilli(); got != tt.want {
+			t.Errorf("policy %d: got %d ms, want %d", tt.policy, got, tt.want)
+		}
+	}
+
+	// Halfway values round up, also before 1970.
+	data, err := SerializeGo(time.Unix(0, -1_500_000), WithTimePrecisionPolicy(TimeRound))
+	if err != nil {
+		t.Fatalf("SerializeGo failed: %v", err)
+	}
+	if v, _ := Deserialize(data); v.AsUnixMilli() != -1 {
+		t.Errorf("-1.5ms rounded to %d ms, want -1", v.AsUnixMilli())
+	}
+
+	_, err = SerializeGo(map[string]interface{}{"created": ts}, WithTimePrecisionPolicy(TimeError))
+	if !errors.Is(err, ErrTimePrecision) {
+		t.Fatalf("TimeError: expected ErrTimePrecision, got %v", err)
+	}
+	if want := "v8serialize: time has sub-millisecond precision: 2024-01-02T03:04:05.0065Z at created"; err.Error() != want {
+		t.Errorf("TimeError: error = %q, want %q", err.Error(), want)
+	}
+
+	// Whole milliseconds are exact under every policy.
+	if _, err := SerializeGo(ts.Truncate(time.Millisecond), WithTimePrecisionPolicy(TimeError)); err != nil {
+		t.Errorf("TimeError rejected a whole-millisecond time: %v", err)
+	}
+}
+
+func TestSerializeGoUint32(t *testing.T) {
+	tests := []struct {
+		val  uint64
+		want string
+	}{
+		{math.MaxInt32, "ff0f49feffffff0f"},
+		{1 << 31, "ff0f558080808008"},
+		{math.MaxUint32, "ff0f55ffffffff0f"},
+		{1 << 32, "ff0f4e000000000000f041"}, // past uint32: a double
+	}
+	for _, tt := range tests {
+		vals := []interface{}{tt.val, uint(tt.val)}
+		if tt.val <= math.MaxUint32 {
+			vals = append(vals, uint32(tt.val))
+		}
+		for _, val := range vals {
+			data, err := SerializeGo(val)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(data); got != tt.want {
+				t.Errorf("SerializeGo(%T(%d)) = %s, want %s", val, tt.val, got, tt.want)
+			}
+			if v, _ := Deserialize(data); v.AsNumber() != float64(tt.val) {
+				t.Errorf("round trip of %d = %v", tt.val, v)
+			}
+		}
+	}
+}
+
+func TestWithIntOverflowPolicy(t *testing.T) {
+	tests := []struct {
+		name  string
+		val   interface{}
+		exact bool
+	}{
+		{"2^31-1", int64(math.MaxInt32), true},
+		{"2^31", int64(1 << 31), true},
+		{"-2^31-1", int64(math.MinInt32 - 1), true},
+		{"2^32", uint64(1 << 32), true},
+		{"2^53", int64(1 << 53), true},
+		{"-2^53", int64(-1 << 53), true},
+		{"2^53+1", int64(1<<53 + 1), false},
+		{"-2^53-1", int64(-1<<53 - 1), false},
+		{"2^53+2", uint64(1<<53 + 2), true},
+		{"2^60", int64(1 << 60), true},
+		{"MinInt64", int64(math.MinInt64), true},
+		{"MaxInt64", int64(math.MaxInt64), false},
+		{"MaxUint64", uint64(math.MaxUint64), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := new(big.Int)
+			if u, ok := tt.val.(uint64); ok {
+				want.SetUint64(u)
+			} else {
+				want.SetInt64(reflect.ValueOf(tt.val).Int())
+			}
+
+			data, err := SerializeGo(tt.val)
+			if tt.exact {
+				if err != nil {
+					t.Fatalf("SerializeGo failed: %v", err)
+				}
+				v, _ := Deserialize(data)
+				if got, _ := new(big.Float).SetFloat64(v.AsNumber()).Int(nil); got.Cmp(want) != 0 {
+					t.Errorf("round trip = %s, want %s", got, want)
+				}
+				return
+			}
+			if !errors.Is(err, ErrIntOverflow) {
+				t.Fatalf("expected ErrIntOverflow, got %v", err)
+			}
+
+			data, err = SerializeGo(tt.val, WithIntOverflowPolicy(IntOverflowRound))
+			if err != nil {
+				t.Fatalf("IntOverflowRound: SerializeGo failed: %v", err)
+			}
+			v, _ := Deserialize(data)
+			if f, _ := new(big.Float).SetInt(want).Float64(); v.AsNumber() != f {
+				t.Errorf("IntOverflowRound: got %v, want %v", v.AsNumber(), f)
+			}
+
+			data, err = SerializeGo(tt.val, WithIntOverflowPolicy(IntOverflowBigInt))
+			if err != nil {
+				t.Fatalf("IntOverflowBigInt: SerializeGo failed: %v", err)
+			}
+			v, _ = Deserialize(data)
+			if !v.IsBigInt() || v.AsBigInt().Cmp(want) != 0 {
+				t.Errorf("IntOverflowBigInt: got %v, want BigInt %s", v, want)
+			}
+		})
+	}
+}
+
+func TestSerializeQuotas(t *testing.T) {
+	tests := []struct {
+		name     string
+		val      interface{}
+		opt      SerializerOption
+		wantPath string
+	}{
+		{"strings", map[string]interface{}{
+			"items": []interface{}{"a", "b", "c"},
+		}, WithMaxStrings(2), "items[2]"},
+		{"binary", map[string]interface{}{
+			"blobs": []interface{}{[]byte{1, 2}, map[string]interface{}{"data-1": []byte{3, 4}}},
+		}, WithMaxBinaryBytes(3), `blobs[1]["data-1"]`},
+		{"binary-root", []byte{1, 2, 3}, WithMaxBinaryBytes(2), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := SerializeGo(tt.val, tt.opt)
+			var le *LimitExceededError
+			if !errors.As(err, &le) {
+				t.Fatalf("expected *LimitExceededError, got %v", err)
+			}
+			if !errors.Is(err, ErrLimitExceeded) {
+				t.Error("error should match ErrLimitExceeded")
+			}
+			if le.Path != tt.wantPath {
+				t.Errorf("path = %q, want %q", le.Path, tt.wantPath)
+			}
+		})
+	}
+
+	t.Run("zero-unlimited", func(t *testing.T) {
+		if _, err := SerializeGo([]interface{}{"a", []byte{1}}, WithMaxStrings(0), WithMaxBinaryBytes(0)); err != nil {
+			t.Fatalf("0 should mean unlimited, got %v", err)
+		}
+	})
+
+	t.Run("per-value", func(t *testing.T) {
+		s := NewSerializer(WithMaxStrings(1))
+		for i := 0; i < 3; i++ {
+			if err := s.WriteValue(String("x")); err != nil {
+				t.Fatalf("WriteValue %d: %v", i, err)
+			}
+		}
+	})
+
+	t.Run("map-and-typed-array", func(t *testing.T) {
+		m := &JSMap{Entries: []MapEntry{{Key: String("k"), Value: Value{typ: TypeTypedArray, data: &ArrayBufferView{Type: "Uint8Array", Buffer: []byte{1, 2}, ByteLength: 2}}}}}
+		_, err := Serialize(Value{typ: TypeMap, data: m}, WithMaxBinaryBytes(1))
+		var le *LimitExceededError
+		if !errors.As(err, &le) || le.Path != "[0][1]" || le.Limit != "max_binary_bytes" {
+			t.Fatalf("got %v", err)
+		}
+	})
+}
+
+func TestSerializeErrorPath(t *testing.T) {
+	tests := []struct {
+		name string
+		val  interface{}
+		want string
+	}{
+		{"root", func() {}, "v8serialize: DataCloneError: func() could not be cloned"},
+		{"nested", map[string]interface{}{
+			"items": []interface{}{0, 1, map[string]interface{}{"callback": func() {}}},
+		}, "v8serialize: DataCloneError: func() could not be cloned at items[2].callback"},
+		{"quoted-key", map[string]interface{}{"a b": struct{}{}}, `v8serialize: unsupported Go type struct {} at ["a b"]`},
+		{"error-cause", Value{typ: TypeError, data: &JSError{Name: "Error", Message: "m", Cause: &Value{typ: TypeTypedArray, data: &ArrayBufferView{Type: "Bogus"}}}},
+			`v8serialize: DataCloneError: TypedArray of type "Bogus" could not be cloned at cause`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := SerializeGo(tt.val)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("got %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
 func TestSerializeRegExp(t *testing.T) {
 	re := &RegExp{Pattern: "test.*pattern", Flags: "gi"}
 	v := Value{typ: TypeRegExp, data: re}
@@ -652,7 +1085,7 @@ func TestSerializeNestedStructures(t *testing.T) {
 		var v Value = Int32(42)
 		for i := 0; i < 50; i++ {
 			obj := map[string]Value{"nested": v}
-			v = Value{typ: TypeObject, data: obj}
+			v = Object(obj)
 		}
 
 		data, err := Serialize(v)
@@ -691,7 +1124,7 @@ func TestSerializeNestedStructures(t *testing.T) {
 				"index": Int32(int32(i)),
 				"name":  String("item"),
 			}
-			arr[i] = Value{typ: TypeObject, data: obj}
+			arr[i] = Object(obj)
 		}
 		v := Value{typ: TypeArray, data: arr}
 
@@ -751,3 +1184,259 @@ func valuesEqual(a, b Value) bool {
 		return false // complex types need deeper comparison
 	}
 }
+
+func TestSerializeVeryDeepNesting(t *testing.T) {
+	// A 100,000-element cons list: {value: i, next: {...}}.
+	const depth = 100_000
+	var list interface{}
+	for i := depth - 1; i >= 0; i-- {
+		list = map[string]interface{}{"value": i, "next": list}
+	}
+
+	if _, err := SerializeGo(list); !errors.Is(err, ErrMaxDepthExceeded) {
+		t.Fatalf("expected ErrMaxDepthExceeded with default limit, got %v", err)
+	}
+
+	data, err := SerializeGo(list, WithEncodeMaxDepth(0))
+	if err != nil {
+		t.Fatalf("SerializeGo failed: %v", err)
+	}
+	v, err := Deserialize(data, WithMaxDepth(Unlimited))
+	if err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	for i := 0; i < depth; i++ {
+		obj := v.AsObject()
+		if got := obj["value"].AsInt32(); got != int32(i) {
+			t.Fatalf("node %d: value = %d", i, got)
+		}
+		v = obj["next"]
+	}
+	if !v.IsNull() {
+		t.Errorf("list tail: expected null, got %s", v.Type())
+	}
+}
+
+func TestSerializeCycle(t *testing.T) {
+	obj := map[string]Value{}
+	v := Object(obj)
+	obj["self"] = v
+
+	if _, err := Serialize(v); !errors.Is(err, ErrMaxDepthExceeded) {
+		t.Fatalf("expected ErrMaxDepthExceeded, got %v", err)
+	}
+}
+
+func TestWriteValueMultiRoot(t *testing.T) {
+	s := NewSerializer()
+	values := []Value{
+		Int32(42),
+		String("second"),
+		Object(map[string]Value{"third": Bool(true)}),
+	}
+	for _, v := range values {
+		if err := s.WriteValue(v); err != nil {
+			t.Fatalf("WriteValue failed: %v", err)
+		}
+	}
+
+	// Matches Node's v8.Serializer writing the same values after one header.
+	binData, _ := loadFixture(t, "multi-root")
+	if !bytes.Equal(s.Bytes(), binData) {
+		t.Errorf("got %s, want %s", bytesToHex(s.Bytes()), bytesToHex(binData))
+	}
+}
+
+func TestWriteRaw(t *testing.T) {
+	frag, err := Serialize(Object(map[string]Value{"a": Int32(1)}))
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	// The fragment's object takes reference ID 1, so the repeated buffer
+	// refers to ID 2, matching Node for [{a: 1}, buf, buf].
+	buf := []byte{0, 0}
+	data, err := SerializeGo([]interface{}{RawValue(frag), buf, buf})
+	if err != nil {
+		t.Fatalf("SerializeGo failed: %v", err)
+	}
+	if got, want := bytesToHex(data), "ff0f41036f22016149027b01420200005e02240003"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	s := NewSerializer()
+	if err := s.WriteRaw(frag); err != nil {
+		t.Fatalf("WriteRaw failed: %v", err)
+	}
+	if err := s.WriteValue(Int32(7)); err != nil {
+		t.Fatalf("WriteValue failed: %v", err)
+	}
+	d := NewDeserializer(s.Bytes())
+	first, err := d.ReadValue()
+	if err != nil || first.AsObject()["a"].AsInt32() != 1 {
+		t.Fatalf("first root = %v, %v", first, err)
+	}
+	if second, err := d.ReadValue(); err != nil || second.AsInt32() != 7 {
+		t.Fatalf("second root = %v, %v", second, err)
+	}
+
+	shared, _ := hex.DecodeString("ff0f41026f7b005e01240002") // [o, o]
+	// Node: const e = new Error("x"); e.cause = e (no stack)
+	selfCause, _ := hex.DecodeString("ff0f726d220178635e002e")
+	// Node: [new Uint8Array(b, 0, 2), new Uint8Array(b, 2, 2)], written natively
+	views, _ := hex.DecodeString("ff0f410242040000000056420002005e015642020200240002")
+	old, _ := hex.DecodeString("ff0d4902")
+	tests := []struct {
+		name string
+		frag []byte
+		want string
+	}{
+		{"no-header", frag[2:], "invalid header"},
+		{"two-values", append(append([]byte(nil), frag...), 0x49, 0x02), "holds 2 values"},
+		{"reference", shared, "object reference"},
+		{"reference-in-cause", selfCause, "object reference"},
+		{"reference-in-view", views, "object reference"},
+		{"version", old, "fragment has version 13, want 15"},
+		{"truncated", frag[:len(frag)-1], "unexpected end of input"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSerializer()
+			err := s.WriteRaw(tt.frag)
+			if !errors.Is(err, ErrInvalidFragment) || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected ErrInvalidFragment mentioning %q, got %v", tt.want, err)
+			}
+			if len(s.Bytes()) != 2 {
+				t.Errorf("wrote %s after the header", bytesToHex(s.Bytes()[2:]))
+			}
+		})
+	}
+}
+
+func TestNewView(t *testing.T) {
+	buf := ArrayBuffer([]byte{1, 2, 3, 4, 5, 6, 7, 8})
+	bytes, err := NewView(buf, "Uint8Array", 0, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tail, err := NewView(buf, "DataView", 4, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One buffer, then view records referencing it (checked against Node).
+	data, err := Serialize(Array([]Value{buf, bytes, tail}))
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if got, want := bytesToHex(data), "ff0f4103420801020304050607085e0156420004005e01563f040400240003"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	v, err := Deserialize(data)
+	if err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	elems := v.AsArray()
+	first, second := elems[1].Interface().(*ArrayBufferView), elems[2].Interface().(*ArrayBufferView)
+	if &first.Buffer[0] != &second.Buffer[0] || &first.Buffer[0] != &elems[0].Interface().([]byte)[0] {
+		t.Error("decoded views do not share the buffer")
+	}
+	if first.ByteOffset != 0 || first.ByteLength != 4 || second.ByteOffset != 4 || second.Type != "DataView" {
+		t.Errorf("got views %+v and %+v", first, second)
+	}
+
+	for _, tt := range []struct {
+		buffer       Value
+		kind         string
+		offset, size int
+	}{
+		{String("x"), "Uint8Array", 0, 1},
+		{buf, "Int64Array", 0, 8},
+		{buf, "Uint8Array", 4, 5},
+		{buf, "Uint32Array", 2, 4},
+	} {
+		if _, err := NewView(tt.buffer, tt.kind, tt.offset, tt.size); !errors.Is(err, ErrInvalidView) {
+			t.Errorf("NewView(%v, %q, %d, %d): got %v, want ErrInvalidView", tt.buffer, tt.kind, tt.offset, tt.size, err)
+		}
+	}
+}
+
+func TestWithSerializeVersion(t *testing.T) {
+	data, err := Serialize(Int32(1), WithSerializeVersion(13))
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if got := bytesToHex(data); got != "ff0d4902" {
+		t.Errorf("got %s, want ff0d4902", got)
+	}
+	if v, err := DetectVersion(data); err != nil || v != 13 {
+		t.Errorf("DetectVersion = %d, %v", v, err)
+	}
+
+	for _, version := range []uint32{MinVersion - 1, SerializeVersion + 1} {
+		if _, err := Serialize(Int32(1), WithSerializeVersion(version)); !errors.Is(err, ErrUnsupportedVersion) {
+			t.Errorf("version %d: got %v, want ErrUnsupportedVersion", version, err)
+		}
+		if err := NewSerializer(WithSerializeVersion(version)).WriteValue(Null()); !errors.Is(err, ErrUnsupportedVersion) {
+			t.Errorf("WriteValue with version %d: got %v, want ErrUnsupportedVersion", version, err)
+		}
+	}
+}
+
+func TestWriteHeaderExplicit(t *testing.T) {
+	s := NewSerializer()
+	s.WriteHeader()
+	if err := s.WriteValue(Int32(1)); err != nil {
+		t.Fatalf("WriteValue failed: %v", err)
+	}
+	// A second header starts a new embedded payload in the same buffer.
+	s.WriteHeader()
+	if err := s.WriteValue(Int32(2)); err != nil {
+		t.Fatalf("WriteValue failed: %v", err)
+	}
+
+	if got, want := bytesToHex(s.Bytes()), "ff0f4902ff0f4904"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	d := NewDeserializer(s.Bytes())
+	for i, want := range []int32{1, 2} {
+		if err := d.ReadHeader(); err != nil {
+			t.Fatalf("ReadHeader %d failed: %v", i, err)
+		}
+		v, err := d.ReadValue()
+		if err != nil {
+			t.Fatalf("ReadValue %d failed: %v", i, err)
+		}
+		if v.AsInt32() != want {
+			t.Errorf("value %d: got %d, want %d", i, v.AsInt32(), want)
+		}
+	}
+	if d.More() {
+		t.Error("expected input to be fully consumed")
+	}
+}
+
+func TestReadHeaderResetsReferences(t *testing.T) {
+	// Two embedded payloads, [{a: 1}] and [o, o] with o = {b: 2}. Reference
+	// IDs restart with each header, so the second payload's reference #1 is
+	// its own object, not the first payload's.
+	data, _ := hex.DecodeString("ff0f41016f22016149027b01240001" + "ff0f41026f22016249047b015e01240002")
+
+	d := NewDeserializer(data)
+	var v Value
+	for i := range 2 {
+		if err := d.ReadHeader(); err != nil {
+			t.Fatalf("ReadHeader %d failed: %v", i, err)
+		}
+		var err error
+		if v, err = d.ReadValue(); err != nil {
+			t.Fatalf("ReadValue %d failed: %v", i, err)
+		}
+	}
+	elems := v.AsArray()
+	if got := elems[1]; got.Type() != TypeObject || got.AsObject()["b"].AsInt32() != 2 {
+		t.Errorf("reference resolved to %v, want {b: 2}", got)
+	}
+}
@@ -0,0 +1,111 @@
+package v8serialize
+
+import "fmt"
+
+// SharedArrayBufferRef stands in for a SharedArrayBuffer. V8 never copies
+// shared memory into a payload: the serializer's delegate assigns the
+// buffer an ID (Node's _getSharedArrayBufferId) and the payload carries
+// only that ID, which the receiving side maps back to its handle on the
+// same memory.
+type SharedArrayBufferRef struct {
+	ID uint32 // ID assigned by the sender's delegate
+
+	// View, ByteOffset and ByteLength describe the TypedArray or DataView
+	// ("Int32Array", ...) encoded over the buffer, if any. View is empty
+	// for a bare SharedArrayBuffer.
+	View       string
+	ByteOffset int
+	ByteLength int
+}
+
+func (b *SharedArrayBufferRef) String() string {
+	if b.View == "" {
+		return fmt.Sprintf("SharedArrayBuffer(#%d)", b.ID)
+	}
+	return fmt.Sprintf("%s(SharedArrayBuffer(#%d), %d, %d)", b.View, b.ID, b.ByteOffset, b.ByteLength)
+}
+
+// SharedArrayBuffer returns a reference to the SharedArrayBuffer the
+// receiver knows by ref.ID, or to a view over it. The serializer writes it
+// as V8 does, an ID with no contents, so Go can pass shared memory handles
+// through protocols that exchange them symbolically.
+func SharedArrayBuffer(ref SharedArrayBufferRef) Value {
+	return Value{typ: TypeSharedArrayBuffer, data: &ref}
+}
+
+// WithSharedArrayBuffers decodes SharedArrayBuffers, and views over them,
+// as TypeSharedArrayBuffer values holding a *SharedArrayBufferRef. By
+// default such payloads fail with ErrUnexpectedTag, since the shared memory
+// is not in the payload.
+func WithSharedArrayBuffers() Option {
+	return func(d *Deserializer) {
+		d.sharedBuffers = true
+	}
+}
+
+// WriteSharedArrayBufferID appends a reference to the SharedArrayBuffer
+// with the given ID, writing the header before the first value like
+// WriteValue. It is shorthand for
+// WriteValue(SharedArrayBuffer(SharedArrayBufferRef{ID: id})).
+func (s *Serializer) WriteSharedArrayBufferID(id uint32) error {
+	return s.WriteValue(SharedArrayBuffer(SharedArrayBufferRef{ID: id}))
+}
+
+// readSharedArrayBuffer reads a shared buffer ID and the native view that
+// may follow it.
+func (d *Deserializer) readSharedArrayBuffer() (Value, error) {
+	id, err := d.reader.ReadVarint32()
+	if err != nil {
+		return Value{}, err
+	}
+	buf := SharedArrayBuffer(SharedArrayBufferRef{ID: id})
+	d.objects = append(d.objects, buf)
+	return d.viewOf(buf)
+}
+
+// readSharedView reads a native view over a shared buffer.
+func (d *Deserializer) readSharedView(buf *SharedArrayBufferRef) (Value, error) {
+	typeName, offset, length, err := d.readViewRecord()
+	if err != nil {
+		return Value{}, err
+	}
+	v := SharedArrayBuffer(SharedArrayBufferRef{ID: buf.ID, View: typeName, ByteOffset: int(offset), ByteLength: int(length)})
+	d.objects = append(d.objects, v)
+	return v, nil
+}
+
+// sharedKey identifies a shared buffer in Serializer.objects, so a buffer
+// is written once and referenced afterwards, as V8 does. Views are keyed
+// by their *SharedArrayBufferRef, so a view Value written twice is also
+// referenced rather than repeated.
+type sharedKey uint32
+
+// writeSharedArrayBuffer writes a shared buffer ID, or a reference to one
+// written earlier, and for views the view record.
+func (s *Serializer) writeSharedArrayBuffer(b *SharedArrayBufferRef) error {
+	var subTag byte
+	if b.View != "" {
+		var ok bool
+		if subTag, ok = viewSubTag(b.View); !ok {
+			return cloneError("TypedArray of type %q", b.View)
+		}
+		if id, ok := s.objects[b]; ok {
+			s.writeTag(tagObjectReference)
+			s.writer.WriteVarint32(id)
+			return nil
+		}
+	}
+	if id, ok := s.objects[sharedKey(b.ID)]; ok {
+		s.writeTag(tagObjectReference)
+		s.writer.WriteVarint32(id)
+	} else {
+		s.objects[sharedKey(b.ID)] = s.nextID
+		s.writeTag(tagSharedArrayBuffer)
+		s.writer.WriteVarint32(b.ID)
+	}
+	if b.View != "" {
+		s.objects[b] = s.nextID
+		s.writeViewRecord(subTag, b.ByteOffset, b.ByteLength)
+	}
+	return nil
+}
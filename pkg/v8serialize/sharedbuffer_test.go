@@ -0,0 +1,115 @@
+package v8serialize
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestWithSharedArrayBuffers(t *testing.T) {
+	// Node: s._getSharedArrayBufferId = () => 7;
+	// s.writeValue({a: sab, b: new Int32Array(sab, 4, 2), c: sab})
+	data, _ := hex.DecodeString("ff0f6f22016175072201625e0156640408002201635e017b03")
+
+	if _, err := Deserialize(data); !errors.Is(err, ErrUnexpectedTag) {
+		t.Fatalf("default: expected ErrUnexpectedTag, got %v", err)
+	}
+
+	v, err := Deserialize(data, WithSharedArrayBuffers())
+	if err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	obj := v.AsObject()
+	want := map[string]SharedArrayBufferRef{
+		"a": {ID: 7},
+		"b": {ID: 7, View: "Int32Array", ByteOffset: 4, ByteLength: 8},
+		"c": {ID: 7},
+	}
+	for key, w := range want {
+		got := obj[key]
+		if got.Type() != TypeSharedArrayBuffer {
+			t.Fatalf("%s: type %s, want SharedArrayBuffer", key, got.Type())
+		}
+		if b := got.Interface().(*SharedArrayBufferRef); *b != w {
+			t.Errorf("%s = %+v, want %+v", key, *b, w)
+		}
+	}
+	if got, want := obj["b"].String(), "Int32Array(SharedArrayBuffer(#7), 4, 8)"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	// References re-encode to the same bytes.
+	out, err := Serialize(v)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if got := bytesToHex(out); got != bytesToHex(data) {
+		t.Errorf("re-encoded %s, want %s", got, bytesToHex(data))
+	}
+}
+
+func TestWriteSharedArrayBufferID(t *testing.T) {
+	// Node: s._getSharedArrayBufferId = () => 2; s.writeValue(sab); s.writeValue(sab)
+	s := NewSerializer()
+	for range 2 {
+		if err := s.WriteSharedArrayBufferID(2); err != nil {
+			t.Fatalf("WriteSharedArrayBufferID failed: %v", err)
+		}
+	}
+	if got, want := bytesToHex(s.Bytes()), "ff0f75025e00"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	// Node: const v = new Int32Array(sab); s.writeValue([v, v])
+	view := SharedArrayBuffer(SharedArrayBufferRef{ID: 3, View: "Int32Array", ByteLength: 8})
+	data, err := Serialize(Array([]Value{view, view}))
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if got, want := bytesToHex(data), "ff0f4102750356640008005e02240002"; got != want {
+		t.Errorf("repeated view: got %s, want %s", got, want)
+	}
+
+	if _, err := Serialize(SharedArrayBuffer(SharedArrayBufferRef{View: "Int128Array"})); !errors.Is(err, ErrDataClone) {
+		t.Errorf("unknown view: expected ErrDataClone, got %v", err)
+	}
+}
+
+func TestSharedArrayBuffersInTools(t *testing.T) {
+	// Node: {a: sab, b: new Int32Array(sab, 4, 2), c: sab}, IDs from 7
+	data, _ := hex.DecodeString("ff0f6f22016175072201625e0156640408002201635e017b03")
+
+	var buf bytes.Buffer
+	if err := DumpAnnotated(data, &buf); err != nil {
+		t.Fatalf("DumpAnnotated failed: %v", err)
+	}
+	if want := "0006  7507                       SharedArrayBuffer SharedArrayBuffer(#7)\n"; !strings.Contains(buf.String(), want) {
+		t.Errorf("dump lacks %q:\n%s", want, buf.String())
+	}
+	if issues := Lint(data); len(issues) != 0 {
+		t.Errorf("Lint: %v", issues)
+	}
+
+	// The Tokenizer rejects them unless asked, like Deserialize.
+	next := func(tok *Tokenizer) error {
+		for {
+			if _, err := tok.Next(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := next(NewTokenizer(data)); !errors.Is(err, ErrUnexpectedTag) {
+		t.Errorf("default: expected ErrUnexpectedTag, got %v", err)
+	}
+	if err := next(NewTokenizer(data, WithSharedArrayBuffers())); err != io.EOF {
+		t.Errorf("WithSharedArrayBuffers: expected io.EOF, got %v", err)
+	}
+
+	list, _ := Serialize(Array([]Value{SharedArrayBuffer(SharedArrayBufferRef{ID: 1}), Int32(2)}))
+	if parts, err := SplitArray(list, WithSharedArrayBuffers()); err != nil || bytesToHex(parts[0]) != "ff0f7501" {
+		t.Errorf("SplitArray = %x, %v", parts, err)
+	}
+}
@@ -0,0 +1,71 @@
+package v8serialize
+
+import (
+	"math/big"
+	"time"
+)
+
+// estimateBudget is the number of values SerializeGo visits when
+// estimating the size of its input under WithSizeEstimate.
+const estimateBudget = 4096
+
+// WithSizeHint reserves n bytes for the payload up front, so encoding a
+// message of about that size does not grow the buffer repeatedly. Larger
+// payloads still grow as needed. Callers on hot paths can pass the size of
+// the previous message of the same kind.
+func WithSizeHint(n int) SerializerOption {
+	return func(s *Serializer) {
+		s.sizeHint = n
+	}
+}
+
+// WithSizeEstimate makes SerializeGo walk its input before encoding and
+// reserve its estimated size. The walk visits at most the first 4096
+// values, counting string and byte slice lengths, so for small messages
+// made of large strings or buffers the estimate is close; larger inputs
+// reserve what the walk saw and grow from there.
+func WithSizeEstimate() SerializerOption {
+	return func(s *Serializer) {
+		s.estimate = true
+	}
+}
+
+// estimateGoSize estimates the encoded size of a Go value as SerializeGo
+// writes it, visiting at most estimateBudget values.
+func estimateGoSize(v interface{}) int {
+	size := 0
+	pending := []interface{}{v}
+	for visited := 0; len(pending) > 0 && visited < estimateBudget; visited++ {
+		v := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		switch val := v.(type) {
+		case nil, bool:
+			size++
+		case int, int8, int16, int32, uint, uint8, uint16, uint32:
+			size += 6 // tag and varint
+		case int64, uint64, float32, float64, time.Time:
+			size += 9 // tag and double
+		case string:
+			size += 6 + len(val)
+		case []byte:
+			size += 6 + len(val)
+		case RawValue:
+			size += len(val)
+		case *big.Int:
+			size += 6 + (val.BitLen()+63)/64*8
+		case []interface{}:
+			size += 12 // begin, length, end, property count, length
+			pending = append(pending, val...)
+		case map[string]interface{}:
+			size += 7 // begin, end, property count
+			for k, e := range val {
+				size += 6 + len(k)
+				pending = append(pending, e)
+			}
+		default:
+			size += 16
+		}
+	}
+	return size
+}
@@ -0,0 +1,56 @@
+package v8serialize
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithSizeHint(t *testing.T) {
+	data, err := SerializeGo("hello", WithSizeHint(64<<10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cap(data) < 64<<10 {
+		t.Errorf("cap = %d, want at least %d", cap(data), 64<<10)
+	}
+}
+
+func TestWithSizeEstimate(t *testing.T) {
+	in := map[string]interface{}{
+		"body":    strings.Repeat("x", 100<<10),
+		"blob":    make([]byte, 10<<10),
+		"n":       42,
+		"f":       1.5,
+		"big":     new(big.Int).Lsh(big.NewInt(1), 100),
+		"at":      time.UnixMilli(0),
+		"tags":    []interface{}{"a", "b", nil, true},
+		"headers": map[string]interface{}{"content-type": "text/plain"},
+	}
+	plain, err := SerializeGo(in, WithSortedKeys())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := estimateGoSize(in); n < len(plain) || n > 2*len(plain) {
+		t.Errorf("estimate = %d for a %d-byte payload", n, len(plain))
+	}
+
+	allocs := func(opts ...SerializerOption) float64 {
+		return testing.AllocsPerRun(10, func() {
+			SerializeGo(in, opts...)
+		})
+	}
+	if with, without := allocs(WithSizeEstimate()), allocs(); with >= without {
+		t.Errorf("allocations with estimate = %v, without = %v", with, without)
+	}
+
+	data, err := SerializeGo(in, WithSortedKeys(), WithSizeEstimate())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, plain) {
+		t.Error("estimate changed the encoding")
+	}
+}
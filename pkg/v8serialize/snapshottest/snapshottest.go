@@ -0,0 +1,99 @@
+// Package snapshottest checks serialized payloads against golden files so
+// accidental changes to the produced bytes are caught by ordinary tests.
+//
+// Each corpus entry is serialized and written as a tag-annotated hex dump
+// to <dir>/<name>.golden. On later runs the dump is compared with the
+// golden file and any difference is reported as a line diff:
+//
+//	func TestWireFormat(t *testing.T) {
+//		snapshottest.Run(t, "testdata/snapshots", []snapshottest.Case{
+//			{Name: "user", Value: map[string]interface{}{"name": "Alice"}},
+//			{Name: "empty-array", Value: v8serialize.Array(nil)},
+//		})
+//	}
+//
+// Set V8WIRE_UPDATE_SNAPSHOTS=1 to (re)write the golden files.
+package snapshottest
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/acolita/v8wire/pkg/v8serialize"
+)
+
+// UpdateEnv is the environment variable that makes Run rewrite golden files
+// instead of comparing against them.
+const UpdateEnv = "V8WIRE_UPDATE_SNAPSHOTS"
+
+// Case is one entry of a snapshot corpus.
+type Case struct {
+	Name  string      // golden file name, without extension
+	Value interface{} // a v8serialize.Value or any Go value accepted by SerializeGo
+}
+
+// Run serializes every case in corpus and compares the result with the
+// golden file in dir. Missing golden files are an error unless UpdateEnv
+// is set.
+func Run(t testing.TB, dir string, corpus []Case, opts ...v8serialize.SerializerOption) {
+	t.Helper()
+	update := os.Getenv(UpdateEnv) != ""
+
+	for _, c := range corpus {
+		got, err := Dump(c.Value, opts...)
+		if err != nil {
+			t.Errorf("%s: %v", c.Name, err)
+			continue
+		}
+
+		path := filepath.Join(dir, c.Name+".golden")
+		if update {
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				t.Fatalf("%s: %v", c.Name, err)
+			}
+			if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+				t.Fatalf("%s: %v", c.Name, err)
+			}
+			continue
+		}
+
+		want, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s: no golden file %s (run with %s=1 to create it)", c.Name, path, UpdateEnv)
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.Name, err)
+			continue
+		}
+		if d := diff(string(want), got); d != "" {
+			t.Errorf("%s: payload differs from %s (-golden +got):\n%s", c.Name, path, d)
+		}
+	}
+}
+
+// Dump serializes v and returns its v8serialize.DumpAnnotated listing, the
+// format stored in golden files. Object properties are written in sorted
+// order (see v8serialize.WithSortedKeys) so the dump does not depend on map
+// order.
+func Dump(v interface{}, opts ...v8serialize.SerializerOption) (string, error) {
+	opts = append([]v8serialize.SerializerOption{v8serialize.WithSortedKeys()}, opts...)
+	var data []byte
+	var err error
+	if val, ok := v.(v8serialize.Value); ok {
+		data, err = v8serialize.Serialize(val, opts...)
+	} else {
+		data, err = v8serialize.SerializeGo(v, opts...)
+	}
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := v8serialize.DumpAnnotated(data, &buf); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
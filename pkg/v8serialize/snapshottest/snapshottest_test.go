@@ -0,0 +1,92 @@
+package snapshottest
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/acolita/v8wire/pkg/v8serialize"
+)
+
+func corpus() []Case {
+	return []Case{
+		{Name: "primitives", Value: []interface{}{nil, true, 42, -1.5, "hello", "日本"}},
+		{Name: "object", Value: map[string]interface{}{
+			"name":  "Alice",
+			"age":   30,
+			"tags":  []interface{}{"a", "b"},
+			"owner": map[string]interface{}{"id": big.NewInt(7)},
+		}},
+		{Name: "values", Value: v8serialize.Array([]v8serialize.Value{
+			v8serialize.Date(time.UnixMilli(1700000000000).UTC()),
+			v8serialize.ArrayBuffer([]byte{1, 2, 3}),
+			v8serialize.Undefined(),
+		})},
+	}
+}
+
+func TestRun(t *testing.T) {
+	Run(t, "testdata", corpus())
+}
+
+func TestDumpDeterministic(t *testing.T) {
+	c := corpus()[1]
+	first, err := Dump(c.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		got, err := Dump(c.Value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != first {
+			t.Fatalf("dump changed between runs:\n%s", diff(first, got))
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name      string
+		want, got string
+		diff      string
+	}{
+		{"equal", "a\nb\n", "a\nb\n", ""},
+		{"changed", "a\nb\nc\n", "a\nx\nc\n", "  a\n- b\n+ x\n  c\n"},
+		{"added", "a\n", "a\nb\n", "  a\n+ b\n"},
+		{"removed", "a\nb\n", "b\n", "- a\n  b\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diff(tt.want, tt.got); got != tt.diff {
+				t.Errorf("diff = %q, want %q", got, tt.diff)
+			}
+		})
+	}
+}
+
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, format)
+}
+
+func TestRunReportsMismatch(t *testing.T) {
+	r := &recorder{TB: t}
+	Run(r, "testdata", []Case{{Name: "primitives", Value: []interface{}{nil, false}}})
+	if len(r.errors) != 1 {
+		t.Fatalf("expected one mismatch, got %d", len(r.errors))
+	}
+
+	r = &recorder{TB: t}
+	Run(r, "testdata", []Case{{Name: "does-not-exist", Value: 1}})
+	if len(r.errors) != 1 {
+		t.Fatalf("expected missing golden error, got %d", len(r.errors))
+	}
+}
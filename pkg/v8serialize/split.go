@@ -0,0 +1,151 @@
+package v8serialize
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/acolita/v8wire/internal/wire"
+)
+
+// ErrNotSplittable is returned by SplitArray and MergeArray when a payload
+// cannot be re-encoded without decoding it fully.
+var ErrNotSplittable = errors.New("v8serialize: payload cannot be split")
+
+// SplitArray splits a payload whose root value is a dense array into one
+// standalone payload per element, copying each element's encoded bytes
+// verbatim. Records are never materialized, so large bulk exports can be
+// fanned out cheaply.
+//
+// Object IDs are numbered per payload, so elements that contain object
+// references (shared or circular objects) cannot be split and yield
+// ErrNotSplittable. Array holes become undefined.
+func SplitArray(data []byte, opts ...Option) ([][]byte, error) {
+	tok := NewTokenizer(data, opts...)
+	root, err := tok.Next()
+	if err != nil {
+		return nil, err
+	}
+	if root.Kind != TokenBeginArray || root.Tag != tagBeginDenseArray {
+		return nil, fmt.Errorf("%w: root value is not a dense array", ErrNotSplittable)
+	}
+	header := data[:root.Offset]
+
+	parts := make([][]byte, 0, root.Length)
+	start := -1
+	for {
+		t, err := tok.Next()
+		if err != nil {
+			return nil, err
+		}
+		// References can also sit inside a scalar token, such as an Error
+		// cause or a view over an earlier buffer, so count them all.
+		switch {
+		case tok.d.refs > 0:
+			return nil, fmt.Errorf("%w: element %d contains an object reference", ErrNotSplittable, len(parts))
+		case t.Kind == TokenKey && tok.Depth() == 1:
+			return nil, fmt.Errorf("%w: array has non-index properties", ErrNotSplittable)
+		case t.Kind == TokenEndArray && tok.Depth() == 0:
+			if tok.d.More() {
+				return nil, fmt.Errorf("%w: trailing data after root value", ErrNotSplittable)
+			}
+			return parts, nil
+		}
+
+		if start < 0 {
+			start = t.Offset
+		}
+		if tok.Depth() > 1 {
+			continue
+		}
+
+		// The element is complete.
+		body := data[start:tok.d.reader.Pos()]
+		if t.Tag == tagTheHole {
+			body = []byte{tagUndefined}
+		}
+		parts = append(parts, joinPayload(header, start, body))
+		start = -1
+	}
+}
+
+// MergeArray is the inverse of SplitArray: it combines standalone payloads
+// into a single payload whose root is a dense array of their values. Each
+// payload must hold exactly one value without object references, and all
+// payloads must share the same format version.
+func MergeArray(payloads [][]byte, opts ...Option) ([]byte, error) {
+	w := wire.NewWriter(64)
+	var version uint32
+	for i, p := range payloads {
+		start, v, _, err := scanStandalone(p, ErrNotSplittable, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("payload %d: %w", i, err)
+		}
+		if i == 0 {
+			version = v
+			w.WriteBytes(p[:start])
+			w.WriteByte(tagBeginDenseArray)
+			w.WriteVarint32(uint32(len(payloads)))
+		} else if v != version {
+			return nil, fmt.Errorf("%w: payload %d has version %d, want %d", ErrNotSplittable, i, v, version)
+		}
+		// Keep two-byte strings aligned as V8 wrote them.
+		if (w.Len()-start)%2 != 0 {
+			w.WriteByte(tagPadding)
+		}
+		w.WriteBytes(p[start:])
+	}
+
+	if len(payloads) == 0 {
+		w.WriteByte(tagVersion)
+		w.WriteVarint32(SerializeVersion)
+		w.WriteByte(tagBeginDenseArray)
+		w.WriteVarint32(0)
+	}
+	w.WriteByte(tagEndDenseArray)
+	w.WriteVarint32(0) // no extra properties
+	w.WriteVarint32(uint32(len(payloads)))
+	return w.Bytes(), nil
+}
+
+// scanStandalone validates a single-value payload and returns the offset of
+// its root value, its format version and the number of object IDs its value
+// takes. Structural problems wrap sentinel.
+func scanStandalone(data []byte, sentinel error, opts ...Option) (start int, version uint32, objects int, err error) {
+	tok := NewTokenizer(data, opts...)
+	start, roots := -1, 0
+	for {
+		t, err := tok.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		if tok.d.refs > 0 { // including references inside scalars
+			return 0, 0, 0, fmt.Errorf("%w: payload contains an object reference", sentinel)
+		}
+		if start < 0 {
+			start = t.Offset
+		}
+		if tok.Depth() == 0 {
+			roots++
+		}
+	}
+	if roots != 1 {
+		return 0, 0, 0, fmt.Errorf("%w: payload holds %d values, want 1", sentinel, roots)
+	}
+	return start, tok.Version(), len(tok.d.objects), nil
+}
+
+// joinPayload prefixes body with header, inserting a padding byte when
+// needed so that body keeps the byte parity it had at offset in the
+// original payload.
+func joinPayload(header []byte, offset int, body []byte) []byte {
+	out := make([]byte, 0, len(header)+1+len(body))
+	out = append(out, header...)
+	if (len(header)-offset)%2 != 0 {
+		out = append(out, tagPadding)
+	}
+	return append(out, body...)
+}
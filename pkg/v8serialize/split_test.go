@@ -0,0 +1,189 @@
+package v8serialize
+
+import (
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSplitArray(t *testing.T) {
+	records := []Value{
+		Object(map[string]Value{"id": Int32(1), "name": String("alice")}),
+		Object(map[string]Value{"id": Int32(2), "name": String("日本")}),
+		Array([]Value{Bool(true), Null()}),
+		String("plain"),
+		Int32(42),
+	}
+	data, err := Serialize(Array(records))
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	parts, err := SplitArray(data)
+	if err != nil {
+		t.Fatalf("SplitArray failed: %v", err)
+	}
+	if len(parts) != len(records) {
+		t.Fatalf("got %d parts, want %d", len(parts), len(records))
+	}
+	for i, p := range parts {
+		got, err := Deserialize(p)
+		if err != nil {
+			t.Fatalf("part %d: Deserialize failed: %v", i, err)
+		}
+		if !reflect.DeepEqual(got, records[i]) {
+			t.Errorf("part %d: got %#v, want %#v", i, got, records[i])
+		}
+	}
+
+	merged, err := MergeArray(parts)
+	if err != nil {
+		t.Fatalf("MergeArray failed: %v", err)
+	}
+	if bytesToHex(merged) != bytesToHex(data) {
+		t.Errorf("merged payload differs:\n got %s\nwant %s", bytesToHex(merged), bytesToHex(data))
+	}
+}
+
+func TestSplitArrayFixtures(t *testing.T) {
+	tests := []struct {
+		fixture string
+		parts   int
+		err     error
+	}{
+		{"array-dense", 3, nil},
+		{"array-nested", 2, nil},
+		{"array-empty", 0, nil},
+		{"array-sparse", 0, ErrNotSplittable},
+		{"array-with-properties", 0, ErrNotSplittable},
+		{"circular-array", 0, ErrNotSplittable},
+		{"object-simple", 0, ErrNotSplittable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			binData, _ := loadFixture(t, tt.fixture)
+			parts, err := SplitArray(binData)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("expected %v, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SplitArray failed: %v", err)
+			}
+			if len(parts) != tt.parts {
+				t.Fatalf("got %d parts, want %d", len(parts), tt.parts)
+			}
+
+			want, err := Deserialize(binData)
+			if err != nil {
+				t.Fatalf("Deserialize failed: %v", err)
+			}
+			merged, err := MergeArray(parts)
+			if err != nil {
+				t.Fatalf("MergeArray failed: %v", err)
+			}
+			got, err := Deserialize(merged)
+			if err != nil {
+				t.Fatalf("Deserialize merged failed: %v", err)
+			}
+			if len(got.AsArray()) != len(want.AsArray()) {
+				t.Errorf("merged length: got %d, want %d", len(got.AsArray()), len(want.AsArray()))
+			}
+		})
+	}
+}
+
+func TestSplitArrayKeepsAlignment(t *testing.T) {
+	// [true, {k: "é€"}] as written by V8: the two-byte string is preceded
+	// by a padding byte so its characters start at an even offset. The
+	// object starts at an odd offset, so the split payload needs padding too.
+	data := []byte{
+		0xff, 0x0f, 0x41, 0x02, 0x54,
+		0x6f, 0x22, 0x01, 'k', 0x00, 0x63, 0x04, 0xe9, 0x00, 0xac, 0x20, 0x7b, 0x01,
+		0x24, 0x00, 0x02,
+	}
+	parts, err := SplitArray(data)
+	if err != nil {
+		t.Fatalf("SplitArray failed: %v", err)
+	}
+	want := "ff0f006f22016b006304e900ac207b01"
+	if got := bytesToHex(parts[1]); got != want {
+		t.Errorf("part 1: got %s, want %s", got, want)
+	}
+	v, err := Deserialize(parts[1])
+	if err != nil {
+		t.Fatalf("Deserialize part 1 failed: %v", err)
+	}
+	if got := v.AsObject()["k"].AsString(); got != "é€" {
+		t.Errorf("part 1 k: got %q, want %q", got, "é€")
+	}
+
+	merged, err := MergeArray(parts)
+	if err != nil {
+		t.Fatalf("MergeArray failed: %v", err)
+	}
+	if bytesToHex(merged) != bytesToHex(data) {
+		t.Errorf("merged payload differs:\n got %s\nwant %s", bytesToHex(merged), bytesToHex(data))
+	}
+}
+
+func TestSplitArrayNestedReferences(t *testing.T) {
+	// Node: const o = {}; [o, new Error("x", {cause: o})] (no stack)
+	errorCause, _ := hex.DecodeString("ff0f41026f7b00726d220178635e012e240002")
+
+	buf := ArrayBuffer([]byte{1, 2, 3, 4})
+	head, _ := NewView(buf, "Uint8Array", 0, 2)
+	tail, _ := NewView(buf, "Uint8Array", 2, 2)
+	views, err := Serialize(Array([]Value{head, tail}))
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	for name, data := range map[string][]byte{"error-cause": errorCause, "shared-buffer-view": views} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := SplitArray(data); !errors.Is(err, ErrNotSplittable) {
+				t.Fatalf("expected ErrNotSplittable, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMergeArrayRejects(t *testing.T) {
+	one, _ := Serialize(Int32(1))
+	circular, _ := loadFixture(t, "circular-self")
+	// Node: const e = new Error("x"); e.cause = e (no stack)
+	selfCause, _ := hex.DecodeString("ff0f726d220178635e002e")
+
+	tests := []struct {
+		name     string
+		payloads [][]byte
+	}{
+		{"reference", [][]byte{one, circular}},
+		{"reference-in-cause", [][]byte{one, selfCause}},
+		{"two roots", [][]byte{append(append([]byte{}, one...), 0x49, 0x02)}},
+		{"empty payload", [][]byte{{0xff, 0x0f}}},
+		{"version mismatch", [][]byte{one, {0xff, 0x0d, 0x49, 0x02}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := MergeArray(tt.payloads); !errors.Is(err, ErrNotSplittable) {
+				t.Fatalf("expected ErrNotSplittable, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMergeArrayEmpty(t *testing.T) {
+	merged, err := MergeArray(nil)
+	if err != nil {
+		t.Fatalf("MergeArray failed: %v", err)
+	}
+	if got := bytesToHex(merged); got != "ff0f4100240000" {
+		t.Errorf("got %s, want ff0f4100240000", got)
+	}
+}
@@ -0,0 +1,51 @@
+package v8serialize
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// StoredValue is a Value stored as a serialized payload in a BYTEA or BLOB
+// column. It implements driver.Valuer and sql.Scanner, so a struct field
+// of this type is written and read without explicit encoding:
+//
+//	type Session struct {
+//		ID   string
+//		Data v8serialize.StoredValue
+//	}
+//	row.Scan(&s.ID, &s.Data)
+//	obj := v8serialize.Value(s.Data).AsObject()
+//
+// SQL NULL corresponds to undefined, the zero Value, in both directions.
+type StoredValue Value
+
+// Value implements driver.Valuer by serializing the value.
+func (s StoredValue) Value() (driver.Value, error) {
+	if Value(s).IsUndefined() {
+		return nil, nil
+	}
+	return Serialize(Value(s))
+}
+
+// Scan implements sql.Scanner by deserializing a []byte or string column.
+// The decoded value does not share memory with src.
+func (s *StoredValue) Scan(src interface{}) error {
+	var data []byte
+	switch src := src.(type) {
+	case nil:
+		*s = StoredValue{}
+		return nil
+	case []byte:
+		data = src
+	case string:
+		data = []byte(src)
+	default:
+		return fmt.Errorf("v8serialize: cannot scan %T into StoredValue", src)
+	}
+	v, err := Deserialize(data)
+	if err != nil {
+		return err
+	}
+	*s = StoredValue(v)
+	return nil
+}
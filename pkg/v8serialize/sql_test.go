@@ -0,0 +1,67 @@
+package v8serialize
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+)
+
+var (
+	_ driver.Valuer = StoredValue{}
+	_ sql.Scanner   = (*StoredValue)(nil)
+)
+
+func TestStoredValue(t *testing.T) {
+	in := StoredValue(Object(map[string]Value{"name": String("Alice"), "blob": ArrayBuffer([]byte{1, 2, 3})}))
+	dv, err := in.Value()
+	if err != nil {
+		t.Fatalf("Value failed: %v", err)
+	}
+	column, ok := dv.([]byte)
+	if !ok {
+		t.Fatalf("Value returned %T, want []byte", dv)
+	}
+
+	var out StoredValue
+	if err := out.Scan(column); err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	// Drivers may reuse the column buffer after Scan returns.
+	for i := range column {
+		column[i] = 0
+	}
+	obj := Value(out).AsObject()
+	if obj["name"].AsString() != "Alice" || !bytes.Equal(obj["blob"].Interface().([]byte), []byte{1, 2, 3}) {
+		t.Errorf("scanned %v", Value(out))
+	}
+
+	data, _ := Serialize(Int32(7))
+	if err := out.Scan(string(data)); err != nil || Value(out).AsInt32() != 7 {
+		t.Errorf("Scan(string) = %v, %v", Value(out), err)
+	}
+}
+
+func TestStoredValueNull(t *testing.T) {
+	if dv, err := (StoredValue{}).Value(); dv != nil || err != nil {
+		t.Errorf("undefined Value() = %v, %v, want NULL", dv, err)
+	}
+	out := StoredValue(Int32(1))
+	if err := out.Scan(nil); err != nil || !Value(out).IsUndefined() {
+		t.Errorf("Scan(nil) = %v, %v, want undefined", Value(out), err)
+	}
+	if dv, _ := StoredValue(Null()).Value(); dv == nil {
+		t.Error("null stored as SQL NULL, want a payload")
+	}
+}
+
+func TestStoredValueScanErrors(t *testing.T) {
+	var out StoredValue
+	if err := out.Scan(int64(1)); err == nil {
+		t.Error("Scan(int64) succeeded")
+	}
+	if err := out.Scan([]byte{0x01, 0x02}); !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("Scan(not a payload) = %v, want ErrInvalidHeader", err)
+	}
+}
@@ -48,10 +48,11 @@ const (
 	tagArrayBufferTransfer  byte = 't' // 0x74 - transferred ArrayBuffer
 	tagSharedArrayBuffer    byte = 'u' // 0x75 - SharedArrayBuffer
 
-	// TypedArray tag (unified, type specified by sub-tag)
-	tagTypedArray byte = '\\' // 0x5C - followed by type ID, byte length, data
+	// Native view over the ArrayBuffer written just before it
+	tagArrayBufferView byte = 'V' // 0x56 - followed by sub-tag, byte offset, byte length, flags (v14+)
 
-	// TypedArray type identifiers (used after tagTypedArray)
+	// View type indexes of Node's host-object encoding of TypedArrays,
+	// DataViews and Buffers (after tagHostObject)
 	typedArrayInt8         byte = 0
 	typedArrayUint8        byte = 1
 	typedArrayUint8Clamped byte = 2
@@ -62,9 +63,25 @@ const (
 	typedArrayFloat32      byte = 7
 	typedArrayFloat64      byte = 8
 	typedArrayDataView     byte = 9
-	typedArrayFloat16      byte = 10 // V8 12.x+ (Node 22+)
+	typedArrayBuffer       byte = 10 // Node's Buffer (FastBuffer)
 	typedArrayBigInt64     byte = 11
 	typedArrayBigUint64    byte = 12
+	typedArrayFloat16      byte = 13 // where Node supports Float16Array
+
+	// ArrayBufferView sub-tags (used after tagArrayBufferView)
+	viewInt8         byte = 'b'
+	viewUint8        byte = 'B'
+	viewUint8Clamped byte = 'C'
+	viewInt16        byte = 'w'
+	viewUint16       byte = 'W'
+	viewInt32        byte = 'd'
+	viewUint32       byte = 'D'
+	viewFloat16      byte = 'h'
+	viewFloat32      byte = 'f'
+	viewFloat64      byte = 'F'
+	viewBigInt64     byte = 'q'
+	viewBigUint64    byte = 'Q'
+	viewDataView     byte = '?'
 
 	// Special object tags
 	tagRegExp       byte = 'R' // 0x52 - RegExp (pattern + flags)
@@ -77,6 +94,9 @@ const (
 	// Error tags (v15+)
 	tagError byte = 'r' // 0x72 - Error object
 
+	// Shared objects (v15+)
+	tagSharedObject byte = 'p' // 0x70 - shared struct or array, by conveyor index
+
 	// Internal/Host tags
 	tagHostObject byte = '\\' // 0x5C - host-defined object
 	tagTheHole    byte = '-'  // internal V8 "the hole" value
@@ -85,6 +105,181 @@ const (
 	tagPadding byte = '\x00' // 0x00 - alignment padding
 )
 
+// Tag is a tag byte of the wire format, identifying the record that
+// follows it.
+type Tag byte
+
+// Tags of the wire format, for tools that inspect payloads. TagTypedArray
+// and TagHostObject are the same byte: V8 writes host objects with it, and
+// Node.js writes its TypedArrays as host objects.
+const (
+	TagVersion              = Tag(tagVersion)
+	TagPadding              = Tag(tagPadding)
+	TagNull                 = Tag(tagNull)
+	TagUndefined            = Tag(tagUndefined)
+	TagTrue                 = Tag(tagTrue)
+	TagFalse                = Tag(tagFalse)
+	TagInt32                = Tag(tagInt32)
+	TagUint32               = Tag(tagUint32)
+	TagDouble               = Tag(tagDouble)
+	TagBigInt               = Tag(tagBigInt)
+	TagDate                 = Tag(tagDate)
+	TagOneByteString        = Tag(tagOneByteString)
+	TagTwoByteString        = Tag(tagTwoByteString)
+	TagBeginJSObject        = Tag(tagBeginJSObject)
+	TagEndJSObject          = Tag(tagEndJSObject)
+	TagBeginDenseArray      = Tag(tagBeginDenseArray)
+	TagEndDenseArray        = Tag(tagEndDenseArray)
+	TagBeginSparseArray     = Tag(tagBeginSparseArray)
+	TagEndSparseArray       = Tag(tagEndSparseArray)
+	TagHole                 = Tag(tagHole)
+	TagObjectReference      = Tag(tagObjectReference)
+	TagBeginMap             = Tag(tagBeginMap)
+	TagEndMap               = Tag(tagEndMap)
+	TagBeginSet             = Tag(tagBeginSet)
+	TagEndSet               = Tag(tagEndSet)
+	TagArrayBuffer          = Tag(tagArrayBuffer)
+	TagResizableArrayBuffer = Tag(tagResizableArrayBuffer)
+	TagArrayBufferTransfer  = Tag(tagArrayBufferTransfer)
+	TagSharedArrayBuffer    = Tag(tagSharedArrayBuffer)
+	TagArrayBufferView      = Tag(tagArrayBufferView)
+	TagTypedArray           = Tag(tagHostObject)
+	TagHostObject           = Tag(tagHostObject)
+	TagRegExp               = Tag(tagRegExp)
+	TagNumberObject         = Tag(tagNumberObject)
+	TagBigIntObject         = Tag(tagBigIntObject)
+	TagTrueObject           = Tag(tagTrueObject)
+	TagFalseObject          = Tag(tagFalseObject)
+	TagStringObject         = Tag(tagStringObject)
+	TagError                = Tag(tagError)
+	TagSharedObject         = Tag(tagSharedObject)
+)
+
+// String returns TagName(t).
+func (t Tag) String() string {
+	return TagName(byte(t))
+}
+
+// allTags lists every tag once, in byte order.
+var allTags = []Tag{
+	TagPadding, TagOneByteString, TagBeginSet, TagEndSet, TagHole, TagNull,
+	TagEndMap, TagBeginMap, TagEndSparseArray, TagBeginDenseArray, TagArrayBuffer,
+	TagDate, TagFalse, TagInt32, TagDouble, TagRegExp, TagTrue, TagUint32,
+	TagArrayBufferView, TagBigInt, TagTypedArray, TagObjectReference, TagUndefined,
+	TagBeginSparseArray, TagTwoByteString, TagNumberObject, TagBeginJSObject,
+	TagSharedObject, TagError, TagStringObject, TagArrayBufferTransfer,
+	TagSharedArrayBuffer, TagFalseObject, TagTrueObject, TagBigIntObject,
+	TagEndJSObject, TagResizableArrayBuffer, TagVersion,
+}
+
+// TagsForVersion returns the tags a payload of format version v may
+// contain, in byte order, or nil if this package does not support v.
+func TagsForVersion(v uint32) []Tag {
+	if v < MinVersion || v > MaxVersion {
+		return nil
+	}
+	var tags []Tag
+	for _, t := range allTags {
+		if since, ok := futureTags[byte(t)]; !ok || v >= since {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// nodeViewTypeNames maps the view type indexes of Node's host-object
+// encoding to ArrayBufferView.Type.
+var nodeViewTypeNames = map[uint32]string{
+	uint32(typedArrayInt8):         "Int8Array",
+	uint32(typedArrayUint8):        "Uint8Array",
+	uint32(typedArrayUint8Clamped): "Uint8ClampedArray",
+	uint32(typedArrayInt16):        "Int16Array",
+	uint32(typedArrayUint16):       "Uint16Array",
+	uint32(typedArrayInt32):        "Int32Array",
+	uint32(typedArrayUint32):       "Uint32Array",
+	uint32(typedArrayFloat32):      "Float32Array",
+	uint32(typedArrayFloat64):      "Float64Array",
+	uint32(typedArrayDataView):     "DataView",
+	uint32(typedArrayBuffer):       "Buffer",
+	uint32(typedArrayBigInt64):     "BigInt64Array",
+	uint32(typedArrayBigUint64):    "BigUint64Array",
+	uint32(typedArrayFloat16):      "Float16Array",
+}
+
+// viewTypeNames maps ArrayBufferView sub-tags to ArrayBufferView.Type.
+var viewTypeNames = map[byte]string{
+	viewInt8:         "Int8Array",
+	viewUint8:        "Uint8Array",
+	viewUint8Clamped: "Uint8ClampedArray",
+	viewInt16:        "Int16Array",
+	viewUint16:       "Uint16Array",
+	viewInt32:        "Int32Array",
+	viewUint32:       "Uint32Array",
+	viewFloat16:      "Float16Array",
+	viewFloat32:      "Float32Array",
+	viewFloat64:      "Float64Array",
+	viewBigInt64:     "BigInt64Array",
+	viewBigUint64:    "BigUint64Array",
+	viewDataView:     "DataView",
+}
+
+// tagTypes maps the tags that begin a value to the Type it decodes to.
+// Views are typed by viewType once their kind is read.
+var tagTypes = map[byte]Type{
+	tagNull:                TypeNull,
+	tagUndefined:           TypeUndefined,
+	tagTrue:                TypeBool,
+	tagFalse:               TypeBool,
+	tagHole:                TypeHole,
+	tagInt32:               TypeInt32,
+	tagUint32:              TypeUint32,
+	tagDouble:              TypeDouble,
+	tagBigInt:              TypeBigInt,
+	tagOneByteString:       TypeString,
+	tagTwoByteString:       TypeString,
+	tagDate:                TypeDate,
+	tagRegExp:              TypeRegExp,
+	tagBeginJSObject:       TypeObject,
+	tagBeginDenseArray:     TypeArray,
+	tagBeginSparseArray:    TypeArray,
+	tagBeginMap:            TypeMap,
+	tagBeginSet:            TypeSet,
+	tagArrayBuffer:         TypeArrayBuffer,
+	tagArrayBufferTransfer: TypeDetachedArrayBuffer,
+	tagSharedArrayBuffer:   TypeSharedArrayBuffer,
+	tagError:               TypeError,
+	tagNumberObject:        TypeBoxedPrimitive,
+	tagBigIntObject:        TypeBoxedPrimitive,
+	tagTrueObject:          TypeBoxedPrimitive,
+	tagFalseObject:         TypeBoxedPrimitive,
+	tagStringObject:        TypeBoxedPrimitive,
+}
+
+// viewType returns the Type of a view of the given kind.
+func viewType(typeName string) Type {
+	if typeName == "DataView" {
+		return TypeDataView
+	}
+	return TypeTypedArray
+}
+
+// viewSubTag returns the ArrayBufferView sub-tag for a view type name.
+func viewSubTag(typeName string) (byte, bool) {
+	for tag, name := range viewTypeNames {
+		if name == typeName {
+			return tag, true
+		}
+	}
+	return 0, false
+}
+
+// futureTags lists tags this package recognizes but cannot decode yet,
+// with the format version that introduced them.
+var futureTags = map[byte]uint32{
+	tagResizableArrayBuffer: 14,
+	tagSharedObject:         15,
+}
+
 // Minimum and maximum supported serialization format versions.
 const (
 	MinVersion = 13 // Node.js 18.x
@@ -156,8 +351,18 @@ func TagName(tag byte) string {
 		return "FalseObject"
 	case tagStringObject:
 		return "StringObject"
-	case tagTypedArray: // Also tagHostObject (same byte value 0x5C)
-		return "TypedArray"
+	case tagArrayBufferView:
+		return "ArrayBufferView"
+	case tagResizableArrayBuffer:
+		return "ResizableArrayBuffer"
+	case tagArrayBufferTransfer:
+		return "ArrayBufferTransfer"
+	case tagSharedArrayBuffer:
+		return "SharedArrayBuffer"
+	case tagSharedObject:
+		return "SharedObject"
+	case tagHostObject:
+		return "HostObject"
 	case tagError:
 		return "Error"
 	case tagPadding:
@@ -0,0 +1,29 @@
+package v8serialize
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTagsForVersion(t *testing.T) {
+	if TagsForVersion(MinVersion-1) != nil || TagsForVersion(MaxVersion+1) != nil {
+		t.Error("expected nil for unsupported versions")
+	}
+	for v := uint32(MinVersion); v <= MaxVersion; v++ {
+		tags := TagsForVersion(v)
+		if !slices.IsSorted(tags) {
+			t.Errorf("v%d: tags not in byte order", v)
+		}
+		for _, tag := range tags {
+			if tag.String() == "Unknown" {
+				t.Errorf("v%d: tag %#02x has no name", v, byte(tag))
+			}
+		}
+		if got := slices.Contains(tags, TagSharedObject); got != (v >= 15) {
+			t.Errorf("v%d: SharedObject listed = %v", v, got)
+		}
+	}
+	if got := TagsForVersion(15); len(got) != len(allTags) {
+		t.Errorf("v15: %d tags, want all %d", len(got), len(allTags))
+	}
+}
@@ -0,0 +1,314 @@
+package v8serialize
+
+import (
+	"fmt"
+	"io"
+)
+
+// TokenKind identifies the kind of a Token.
+type TokenKind uint8
+
+const (
+	TokenScalar      TokenKind = iota // a complete non-container value (Token.Value)
+	TokenKey                          // an object property key or sparse array index (Token.Value)
+	TokenReference                    // a back-reference to an earlier object (Token.RefID)
+	TokenBeginObject                  // start of a plain object
+	TokenEndObject                    // end of a plain object (Token.Count)
+	TokenBeginArray                   // start of a dense or sparse array (Token.Length)
+	TokenEndArray                     // end of an array (Token.Count, Token.Length)
+	TokenBeginMap                     // start of a Map
+	TokenEndMap                       // end of a Map (Token.Count)
+	TokenBeginSet                     // start of a Set
+	TokenEndSet                       // end of a Set (Token.Count)
+	TokenView                         // a native view over an earlier ArrayBuffer (Token.RefID, Token.Value)
+)
+
+// String returns the token kind name.
+func (k TokenKind) String() string {
+	switch k {
+	case TokenScalar:
+		return "Scalar"
+	case TokenKey:
+		return "Key"
+	case TokenReference:
+		return "Reference"
+	case TokenBeginObject:
+		return "BeginObject"
+	case TokenEndObject:
+		return "EndObject"
+	case TokenBeginArray:
+		return "BeginArray"
+	case TokenEndArray:
+		return "EndArray"
+	case TokenBeginMap:
+		return "BeginMap"
+	case TokenEndMap:
+		return "EndMap"
+	case TokenBeginSet:
+		return "BeginSet"
+	case TokenEndSet:
+		return "EndSet"
+	case TokenView:
+		return "View"
+	default:
+		return fmt.Sprintf("TokenKind(%d)", k)
+	}
+}
+
+// Token is a single event produced by a Tokenizer.
+type Token struct {
+	Kind   TokenKind
+	Tag    byte   // wire tag that produced the token (see TagName)
+	Offset int    // byte offset of the tag in the input
+	Value  Value  // decoded value for TokenScalar, TokenKey and TokenView
+	Length uint32 // array length for TokenBeginArray and TokenEndArray
+	Count  uint32 // property or entry count carried by end tags
+	RefID  uint32 // referenced object ID for TokenReference and TokenView
+}
+
+// Tokenizer reads a payload as a flat stream of tokens, similar to
+// json.Decoder.Token. Containers (objects, arrays, Maps and Sets) are
+// reported as Begin/End pairs and are never materialized, so arbitrarily
+// large payloads can be transformed or inspected without building a Value
+// tree. Every other value, including Dates, RegExps, binary data, Errors
+// and boxed primitives, is decoded and reported as a single TokenScalar.
+//
+// Object IDs are assigned exactly as the Deserializer assigns them, so
+// Token.RefID can be matched against earlier tokens. The Value of a
+// TokenReference holds the referenced value when it was a scalar and is
+// undefined when it was a container. A reference to an ArrayBuffer that is
+// followed by a native view record, as V8 writes a second view over a
+// buffer (such as two Node Buffers in one pool), is a single TokenView: a
+// new object whose RefID is the buffer's and whose Value is the view.
+type Tokenizer struct {
+	d     *Deserializer
+	stack []tokenFrame
+}
+
+// tokenFrame tracks an open container.
+type tokenFrame struct {
+	kind      TokenKind // the Begin kind that opened the frame
+	endTag    byte
+	remaining uint32 // dense array elements still to read
+	wantKey   bool   // next value is a property key (objects and arrays)
+	keys      int    // object properties read so far
+}
+
+// NewTokenizer creates a tokenizer for data. Limits such as WithMaxDepth
+// and WithMaxArrayLen apply as they do for Deserialize, and so do options
+// such as WithSharedArrayBuffers that accept otherwise rejected values.
+func NewTokenizer(data []byte, opts ...Option) *Tokenizer {
+	return &Tokenizer{d: NewDeserializer(data, opts...)}
+}
+
+// Version returns the serialization format version (valid after the first
+// call to Next).
+func (t *Tokenizer) Version() uint32 {
+	return t.d.version
+}
+
+// Depth returns the number of containers currently open.
+func (t *Tokenizer) Depth() int {
+	return len(t.stack)
+}
+
+// Next returns the next token. It returns io.EOF once all values in the
+// input have been consumed.
+func (t *Tokenizer) Next() (Token, error) {
+	tok, err := t.next()
+	if err != nil {
+		return Token{}, t.d.sizeError(err)
+	}
+	return tok, nil
+}
+
+func (t *Tokenizer) next() (Token, error) {
+	if err := t.d.begin(); err != nil {
+		return Token{}, err
+	}
+	if len(t.stack) == 0 && !t.d.More() {
+		return Token{}, io.EOF
+	}
+
+	if err := t.skipPadding(); err != nil {
+		return Token{}, err
+	}
+	offset := t.d.reader.Pos()
+	tag, err := t.d.reader.Peek()
+	if err != nil {
+		return Token{}, fmt.Errorf("%w: %w", ErrMalformedData, err)
+	}
+
+	if f := t.top(); f != nil && f.remaining == 0 && tag == f.endTag {
+		return t.readEnd(f, offset)
+	}
+
+	if f := t.top(); f != nil && f.remaining == 0 && f.wantKey &&
+		(f.kind == TokenBeginObject || f.kind == TokenBeginArray) {
+		if f.kind == TokenBeginObject {
+			f.keys++
+			if err := t.d.checkObjectKeys(f.keys); err != nil {
+				return Token{}, err
+			}
+		}
+		key, err := t.d.readValue()
+		if err != nil {
+			return Token{}, err
+		}
+		if !key.IsString() && !key.IsNumber() {
+			return Token{}, fmt.Errorf("%w: object key must be string or number, got %s", ErrMalformedData, key.Type())
+		}
+		t.valueDone()
+		return Token{Kind: TokenKey, Tag: tag, Offset: offset, Value: key}, nil
+	}
+
+	switch tag {
+	case tagBeginJSObject:
+		_, _ = t.d.reader.ReadByte()
+		return t.push(Token{Kind: TokenBeginObject, Tag: tag, Offset: offset},
+			tokenFrame{kind: TokenBeginObject, endTag: tagEndJSObject, wantKey: true})
+
+	case tagBeginDenseArray, tagBeginSparseArray:
+		_, _ = t.d.reader.ReadByte()
+		length, err := t.d.reader.ReadVarint32()
+		if err != nil {
+			return Token{}, err
+		}
+		if err := t.d.checkArrayLen(length); err != nil {
+			return Token{}, err
+		}
+		frame := tokenFrame{kind: TokenBeginArray, endTag: tagEndSparseArray, wantKey: true}
+		if tag == tagBeginDenseArray {
+			frame.endTag = tagEndDenseArray
+			frame.remaining = length
+		}
+		return t.push(Token{Kind: TokenBeginArray, Tag: tag, Offset: offset, Length: length}, frame)
+
+	case tagBeginMap:
+		_, _ = t.d.reader.ReadByte()
+		return t.push(Token{Kind: TokenBeginMap, Tag: tag, Offset: offset},
+			tokenFrame{kind: TokenBeginMap, endTag: tagEndMap})
+
+	case tagBeginSet:
+		_, _ = t.d.reader.ReadByte()
+		return t.push(Token{Kind: TokenBeginSet, Tag: tag, Offset: offset},
+			tokenFrame{kind: TokenBeginSet, endTag: tagEndSet})
+
+	case tagObjectReference:
+		_, _ = t.d.reader.ReadByte()
+		id, err := t.d.reader.ReadVarint32()
+		if err != nil {
+			return Token{}, err
+		}
+		if err := t.d.countValue(); err != nil {
+			return Token{}, err
+		}
+		if int(id) >= len(t.d.objects) {
+			return Token{}, fmt.Errorf("%w: reference %d (only %d objects seen)", ErrInvalidReference, id, len(t.d.objects))
+		}
+		if err := t.d.countReference(); err != nil {
+			return Token{}, err
+		}
+		viewPos := t.d.reader.Pos()
+		v, err := t.d.viewOf(t.d.objects[id])
+		if err != nil {
+			return Token{}, err
+		}
+		t.valueDone()
+		kind := TokenReference
+		if t.d.reader.Pos() != viewPos {
+			kind = TokenView
+		}
+		return Token{Kind: kind, Tag: tag, Offset: offset, RefID: id, Value: v}, nil
+	}
+
+	// Everything else is decoded in one piece. Nested values inside scalars
+	// (e.g. an Error cause) count against the depth limit from here.
+	t.d.depth = len(t.stack)
+	v, err := t.d.readValue()
+	if err != nil {
+		return Token{}, err
+	}
+	t.valueDone()
+	return Token{Kind: TokenScalar, Tag: tag, Offset: offset, Value: v}, nil
+}
+
+// top returns the innermost open container, or nil at the root.
+func (t *Tokenizer) top() *tokenFrame {
+	if len(t.stack) == 0 {
+		return nil
+	}
+	return &t.stack[len(t.stack)-1]
+}
+
+// push opens a container and reserves its object ID.
+func (t *Tokenizer) push(tok Token, frame tokenFrame) (Token, error) {
+	if exceedsStrict(len(t.stack)+1, t.d.limits.MaxDepth) {
+		t.d.limitExceeded("max_depth")
+		return Token{}, ErrMaxDepthExceeded
+	}
+	if err := t.d.countValue(); err != nil {
+		return Token{}, err
+	}
+	// Containers are not materialized; keep the ID sequence in step with
+	// the Deserializer so later references resolve to the right object.
+	t.d.objects = append(t.d.objects, Value{})
+	t.stack = append(t.stack, frame)
+	return tok, nil
+}
+
+// readEnd consumes the end tag of the innermost container.
+func (t *Tokenizer) readEnd(f *tokenFrame, offset int) (Token, error) {
+	tag, _ := t.d.reader.ReadByte()
+	count, err := t.d.reader.ReadVarint32()
+	if err != nil {
+		return Token{}, err
+	}
+
+	tok := Token{Tag: tag, Offset: offset, Count: count}
+	switch f.kind {
+	case TokenBeginObject:
+		tok.Kind = TokenEndObject
+	case TokenBeginArray:
+		tok.Kind = TokenEndArray
+		if tok.Length, err = t.d.reader.ReadVarint32(); err != nil {
+			return Token{}, err
+		}
+	case TokenBeginMap:
+		tok.Kind = TokenEndMap
+	case TokenBeginSet:
+		tok.Kind = TokenEndSet
+	}
+
+	t.stack = t.stack[:len(t.stack)-1]
+	t.valueDone()
+	return tok, nil
+}
+
+// valueDone advances the state of the enclosing container after a complete
+// value (or key) has been read.
+func (t *Tokenizer) valueDone() {
+	f := t.top()
+	if f == nil {
+		return
+	}
+	if f.remaining > 0 {
+		f.remaining--
+		return
+	}
+	f.wantKey = !f.wantKey
+}
+
+// skipPadding consumes alignment padding before the next tag.
+func (t *Tokenizer) skipPadding() error {
+	for {
+		tag, err := t.d.reader.Peek()
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrMalformedData, err)
+		}
+		if tag != tagPadding {
+			return nil
+		}
+		_, _ = t.d.reader.ReadByte()
+	}
+}
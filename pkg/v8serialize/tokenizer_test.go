@@ -0,0 +1,139 @@
+package v8serialize
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// tokenKinds drains a tokenizer and returns the token kinds as a string.
+func tokenKinds(t *testing.T, data []byte) string {
+	t.Helper()
+	tok := NewTokenizer(data)
+	var kinds []string
+	for {
+		token, err := tok.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next failed after %v: %v", kinds, err)
+		}
+		kinds = append(kinds, token.Kind.String())
+	}
+	return strings.Join(kinds, " ")
+}
+
+func TestTokenizerFixtures(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    string
+	}{
+		{"int32-positive", "Scalar"},
+		{"object-nested", "BeginObject Key BeginObject Key Scalar EndObject EndObject"},
+		{"array-dense", "BeginArray Scalar Scalar Scalar EndArray"},
+		{"array-sparse", "BeginArray Key Scalar Key Scalar Key Scalar Key Scalar EndArray"},
+		{"array-with-properties", "BeginArray Scalar Scalar Scalar Key Scalar Key Scalar EndArray"},
+		{"map-strings", "BeginMap Scalar Scalar Scalar Scalar EndMap"},
+		{"set-empty", "BeginSet EndSet"},
+		{"circular-self", "BeginObject Key Scalar Key Reference EndObject"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			binData, _ := loadFixture(t, tt.fixture)
+			if got := tokenKinds(t, binData); got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenizerTokenDetails(t *testing.T) {
+	binData, _ := loadFixture(t, "circular-self")
+	tok := NewTokenizer(binData)
+
+	var tokens []Token
+	for {
+		token, err := tok.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		tokens = append(tokens, token)
+	}
+
+	if tokens[0].Offset != 2 || tokens[0].Tag != tagBeginJSObject {
+		t.Errorf("first token: got offset %d tag %s", tokens[0].Offset, TagName(tokens[0].Tag))
+	}
+	if tokens[1].Value.AsString() != "name" {
+		t.Errorf("first key: got %#v, want \"name\"", tokens[1].Value)
+	}
+	ref := tokens[4]
+	if ref.Kind != TokenReference || ref.RefID != 0 {
+		t.Errorf("expected reference to object 0, got %+v", ref)
+	}
+	end := tokens[5]
+	if end.Kind != TokenEndObject || end.Count != 2 {
+		t.Errorf("expected EndObject with count 2, got %+v", end)
+	}
+	if tok.Version() != 15 {
+		t.Errorf("version: got %d, want 15", tok.Version())
+	}
+}
+
+// TestTokenizerAllFixtures checks that every fixture the Deserializer accepts
+// can also be tokenized to the end with balanced containers.
+func TestTokenizerAllFixtures(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "..", "testdata", "fixtures", "*.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".bin")
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Deserialize(data); err != nil {
+				t.Skipf("not decodable: %v", err)
+			}
+
+			tok := NewTokenizer(data)
+			for {
+				_, err := tok.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Next failed: %v", err)
+				}
+			}
+			if tok.Depth() != 0 {
+				t.Errorf("unbalanced containers: depth %d at EOF", tok.Depth())
+			}
+		})
+	}
+}
+
+func TestTokenizerMaxDepth(t *testing.T) {
+	binData, _ := loadFixture(t, "object-deep-100")
+	tok := NewTokenizer(binData, WithMaxDepth(10))
+	for {
+		_, err := tok.Next()
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, ErrMaxDepthExceeded) {
+			t.Fatalf("expected ErrMaxDepthExceeded, got %v", err)
+		}
+		break
+	}
+}
@@ -0,0 +1,399 @@
+// Package transform adapts the shape of decoded objects declaratively, so
+// code that turns Node payloads into internal shapes is configuration
+// rather than hand-written tree walks:
+//
+//	spec := &transform.Spec{
+//		Rename:       map[string]string{"userId": "user_id"},
+//		Drop:         []string{"__typename"},
+//		Cast:         map[string]transform.Kind{"user_id": transform.String},
+//		DefaultValue: map[string]v8serialize.Value{"tags": v8serialize.Array(nil)},
+//	}
+//	v, err := v8serialize.Deserialize(data, spec.Option())
+//
+// A Spec matches properties by name and, through Option, applies to every
+// plain object in a payload. Rules apply Specs to the objects at given
+// paths instead:
+//
+//	rules := transform.Rules{
+//		"":         rootSpec,
+//		"items[*]": itemSpec,
+//		"meta.*":   metaSpec,
+//	}
+//	v, err := v8serialize.Deserialize(data, rules.Option())
+//
+// Spec.Apply transforms a single object that is already decoded.
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/acolita/v8wire/pkg/v8serialize"
+)
+
+// ErrCast is returned when a property cannot be converted to the Kind its
+// Spec asks for.
+var ErrCast = errors.New("transform: cannot convert value")
+
+// Kind is a target type for Spec.Cast.
+type Kind uint8
+
+const (
+	// String converts numbers, BigInts, booleans and Dates to their
+	// v8serialize.Value String form.
+	String Kind = iota + 1
+	// Number converts to a double. Strings are parsed as decimal numbers,
+	// booleans become 0 or 1, BigInts are rounded and Dates become
+	// milliseconds since the Unix epoch.
+	Number
+	// Int32 converts to an int32. Numbers and strings must hold an
+	// integer in range.
+	Int32
+	// Bool converts strings accepted by strconv.ParseBool and numbers,
+	// which are true unless 0 or NaN.
+	Bool
+	// BigInt converts integral numbers and decimal strings.
+	BigInt
+	// Date converts numbers, as milliseconds since the Unix epoch, and
+	// RFC 3339 strings.
+	Date
+)
+
+// String returns the name of k.
+func (k Kind) String() string {
+	switch k {
+	case String:
+		return "String"
+	case Number:
+		return "Number"
+	case Int32:
+		return "Int32"
+	case Bool:
+		return "Bool"
+	case BigInt:
+		return "BigInt"
+	case Date:
+		return "Date"
+	}
+	return fmt.Sprintf("Kind(%d)", k)
+}
+
+// Spec describes how to reshape an object. The steps run in field order:
+// Rename and Drop use the property names of the payload, DefaultValue and
+// Cast the names after renaming.
+type Spec struct {
+	// Rename maps property names to new ones. A renamed property takes
+	// the place of any property already under the new name.
+	Rename map[string]string
+	// Drop lists properties to remove.
+	Drop []string
+	// DefaultValue supplies values for properties that are missing or
+	// undefined. Missing ones are added after the others, in sorted order.
+	DefaultValue map[string]v8serialize.Value
+	// Cast converts properties to a Kind. Missing, undefined and null
+	// properties are left as they are.
+	Cast map[string]Kind
+}
+
+// Option returns a v8serialize.Option that applies s to every plain
+// object while decoding.
+func (s *Spec) Option() v8serialize.Option {
+	return v8serialize.WithObjectHook(func(_ string, obj v8serialize.Value) (v8serialize.Value, error) {
+		return s.Apply(obj)
+	})
+}
+
+// Apply returns obj reshaped by s. Values other than plain objects are
+// returned unchanged, and so are objects s does not touch. Nested objects
+// are not transformed.
+func (s *Spec) Apply(obj v8serialize.Value) (v8serialize.Value, error) {
+	if obj.Type() != v8serialize.TypeObject || !s.touches(obj) {
+		return obj, nil
+	}
+	drop := make(map[string]bool, len(s.Drop))
+	for _, k := range s.Drop {
+		drop[k] = true
+	}
+
+	src := obj.AsObject()
+	props := make(map[string]v8serialize.Value, len(src))
+	keys := make([]string, 0, len(src))
+	for _, k := range obj.Keys() {
+		if drop[k] {
+			continue
+		}
+		name := k
+		if to, ok := s.Rename[k]; ok {
+			name = to
+		}
+		if _, ok := props[name]; !ok {
+			keys = append(keys, name)
+		} else if _, renamed := s.Rename[k]; !renamed {
+			continue // the renamed property wins
+		}
+		props[name] = src[k]
+	}
+
+	defaults := make([]string, 0, len(s.DefaultValue))
+	for k := range s.DefaultValue {
+		defaults = append(defaults, k)
+	}
+	sort.Strings(defaults)
+	for _, k := range defaults {
+		if v, ok := props[k]; !ok || v.IsUndefined() {
+			if !ok {
+				keys = append(keys, k)
+			}
+			props[k] = s.DefaultValue[k]
+		}
+	}
+
+	for k, kind := range s.Cast {
+		v, ok := props[k]
+		if !ok || v.IsNullish() {
+			continue
+		}
+		cast, err := Cast(v, kind)
+		if err != nil {
+			return v8serialize.Value{}, fmt.Errorf("property %q: %w", k, err)
+		}
+		props[k] = cast
+	}
+	return v8serialize.OrderedObject(keys, props), nil
+}
+
+// touches reports whether s may change obj, so untouched objects are
+// returned without copying.
+func (s *Spec) touches(obj v8serialize.Value) bool {
+	props := obj.AsObject()
+	for k := range s.Rename {
+		if _, ok := props[k]; ok {
+			return true
+		}
+	}
+	for _, k := range s.Drop {
+		if _, ok := props[k]; ok {
+			return true
+		}
+	}
+	for k := range s.DefaultValue {
+		if v, ok := props[k]; !ok || v.IsUndefined() {
+			return true
+		}
+	}
+	for k := range s.Cast {
+		if _, ok := props[k]; ok {
+			return true
+		}
+	}
+	for _, to := range s.Rename {
+		if _, ok := s.Cast[to]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Rules map path patterns to the Specs for the objects at matching paths.
+// Patterns use the path syntax of v8serialize.PositionIndex ("" for the
+// root, "items[2].owner"), where "[*]" matches any index and a "*" key
+// segment any property name. An object matching several patterns gets
+// each of their Specs, in lexicographic order of the patterns.
+type Rules map[string]*Spec
+
+// Option returns a v8serialize.Option that applies r while decoding.
+func (r Rules) Option() v8serialize.Option {
+	patterns := make([]string, 0, len(r))
+	for p := range r {
+		patterns = append(patterns, p)
+	}
+	sort.Strings(patterns)
+	compiled := make([]*regexp.Regexp, len(patterns))
+	for i, p := range patterns {
+		compiled[i] = compilePattern(p)
+	}
+	return v8serialize.WithObjectHook(func(path string, obj v8serialize.Value) (v8serialize.Value, error) {
+		for i, re := range compiled {
+			if !re.MatchString(path) {
+				continue
+			}
+			var err error
+			if obj, err = r[patterns[i]].Apply(obj); err != nil {
+				return v8serialize.Value{}, err
+			}
+		}
+		return obj, nil
+	})
+}
+
+// Path segments matching any property name: an identifier, after a dot
+// unless it starts the path, or a quoted key.
+const (
+	anyKey      = `(?:\.[A-Za-z_$][A-Za-z0-9_$]*|\["(?:[^"\\]|\\.)*"\])`
+	anyFirstKey = `(?:[A-Za-z_$][A-Za-z0-9_$]*|\["(?:[^"\\]|\\.)*"\])`
+)
+
+// compilePattern turns a Rules pattern into an anchored regular expression.
+func compilePattern(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for rest := pattern; rest != ""; {
+		switch {
+		case strings.HasPrefix(rest, "[*]"):
+			b.WriteString(`\[\d+\]`)
+			rest = rest[3:]
+		case strings.HasPrefix(rest, ".*"):
+			b.WriteString(anyKey)
+			rest = rest[2:]
+		case rest[0] == '*' && len(rest) == len(pattern):
+			b.WriteString(anyFirstKey)
+			rest = rest[1:]
+		default:
+			b.WriteString(regexp.QuoteMeta(rest[:1]))
+			rest = rest[1:]
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// Cast converts v to kind, following the rules documented on each Kind.
+// Values already of that kind are returned unchanged; other conversions
+// fail with ErrCast.
+func Cast(v v8serialize.Value, kind Kind) (v8serialize.Value, error) {
+	var out v8serialize.Value
+	ok := false
+	switch kind {
+	case String:
+		out, ok = toString(v)
+	case Number:
+		out, ok = toNumber(v)
+	case Int32:
+		out, ok = toInt32(v)
+	case Bool:
+		out, ok = toBool(v)
+	case BigInt:
+		out, ok = toBigInt(v)
+	case Date:
+		out, ok = toDate(v)
+	}
+	if !ok {
+		return v8serialize.Value{}, fmt.Errorf("%w: %s to %s", ErrCast, v.Type(), kind)
+	}
+	return out, nil
+}
+
+func toString(v v8serialize.Value) (v8serialize.Value, bool) {
+	switch v.Type() {
+	case v8serialize.TypeString:
+		return v, true
+	case v8serialize.TypeInt32, v8serialize.TypeUint32, v8serialize.TypeDouble,
+		v8serialize.TypeBigInt, v8serialize.TypeBool, v8serialize.TypeDate:
+		return v8serialize.String(v.String()), true
+	}
+	return v8serialize.Value{}, false
+}
+
+func toNumber(v v8serialize.Value) (v8serialize.Value, bool) {
+	switch v.Type() {
+	case v8serialize.TypeDouble:
+		return v, true
+	case v8serialize.TypeInt32, v8serialize.TypeUint32:
+		return v8serialize.Double(v.AsNumber()), true
+	case v8serialize.TypeBigInt:
+		f, _ := new(big.Float).SetInt(v.AsBigInt()).Float64()
+		return v8serialize.Double(f), true
+	case v8serialize.TypeBool:
+		if v.AsBool() {
+			return v8serialize.Double(1), true
+		}
+		return v8serialize.Double(0), true
+	case v8serialize.TypeDate:
+		return v8serialize.Double(float64(v.AsUnixMilli())), !v.IsInvalidDate()
+	case v8serialize.TypeString:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v.AsString()), 64)
+		return v8serialize.Double(f), err == nil
+	}
+	return v8serialize.Value{}, false
+}
+
+func toInt32(v v8serialize.Value) (v8serialize.Value, bool) {
+	var f float64
+	switch v.Type() {
+	case v8serialize.TypeInt32:
+		return v, true
+	case v8serialize.TypeUint32, v8serialize.TypeDouble:
+		f = v.AsNumber()
+	case v8serialize.TypeBigInt:
+		if !v.AsBigInt().IsInt64() {
+			return v8serialize.Value{}, false
+		}
+		f = float64(v.AsBigInt().Int64())
+	case v8serialize.TypeString:
+		n, err := strconv.ParseInt(strings.TrimSpace(v.AsString()), 10, 32)
+		return v8serialize.Int32(int32(n)), err == nil
+	default:
+		return v8serialize.Value{}, false
+	}
+	if f != math.Trunc(f) || f < math.MinInt32 || f > math.MaxInt32 {
+		return v8serialize.Value{}, false
+	}
+	return v8serialize.Int32(int32(f)), true
+}
+
+func toBool(v v8serialize.Value) (v8serialize.Value, bool) {
+	switch v.Type() {
+	case v8serialize.TypeBool:
+		return v, true
+	case v8serialize.TypeInt32, v8serialize.TypeUint32, v8serialize.TypeDouble, v8serialize.TypeBigInt:
+		return v8serialize.Bool(v.IsTruthy()), true
+	case v8serialize.TypeString:
+		b, err := strconv.ParseBool(strings.TrimSpace(v.AsString()))
+		return v8serialize.Bool(b), err == nil
+	}
+	return v8serialize.Value{}, false
+}
+
+func toBigInt(v v8serialize.Value) (v8serialize.Value, bool) {
+	switch v.Type() {
+	case v8serialize.TypeBigInt:
+		return v, true
+	case v8serialize.TypeInt32, v8serialize.TypeUint32, v8serialize.TypeDouble:
+		f := v.AsNumber()
+		if f != math.Trunc(f) || math.IsInf(f, 0) {
+			return v8serialize.Value{}, false
+		}
+		n, _ := big.NewFloat(f).Int(nil)
+		return v8serialize.BigInt(n), true
+	case v8serialize.TypeString:
+		n, ok := new(big.Int).SetString(strings.TrimSpace(v.AsString()), 10)
+		if !ok {
+			return v8serialize.Value{}, false
+		}
+		return v8serialize.BigInt(n), true
+	}
+	return v8serialize.Value{}, false
+}
+
+func toDate(v v8serialize.Value) (v8serialize.Value, bool) {
+	switch v.Type() {
+	case v8serialize.TypeDate:
+		return v, true
+	case v8serialize.TypeInt32, v8serialize.TypeUint32, v8serialize.TypeDouble:
+		return v8serialize.DateFromMillis(v.AsNumber()), true
+	case v8serialize.TypeString:
+		t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(v.AsString()))
+		if err != nil {
+			return v8serialize.Value{}, false
+		}
+		return v8serialize.Date(t), true
+	}
+	return v8serialize.Value{}, false
+}
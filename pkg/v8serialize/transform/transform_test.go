@@ -0,0 +1,185 @@
+package transform
+
+import (
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/acolita/v8wire/pkg/v8serialize"
+)
+
+// Node: v8.serialize({userId: 42, __typename: "User",
+//
+//	items: [{price: "9.5", qty: "2"}, {price: 3, tags: undefined}],
+//	meta: {a: {x: 1}, "b c": {x: "1"}}, created: "2024-01-01T00:00:00Z"})
+const payload = "ff0f6f22067573657249644954220a5f5f747970656e616d6522045573657222056974656d7341026f220570726963652203392e3522037174792201327b026f2205707269636549062204746167735f7b0224000222046d6574616f2201616f22017849027b0122036220636f2201782201317b017b022207637265617465642214323032342d30312d30315430303a30303a30305a7b05"
+
+func decode(t *testing.T, opts ...v8serialize.Option) v8serialize.Value {
+	t.Helper()
+	data, _ := hex.DecodeString(payload)
+	v, err := v8serialize.Deserialize(data, opts...)
+	if err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	return v
+}
+
+func TestRules(t *testing.T) {
+	rules := Rules{
+		"": {
+			Rename: map[string]string{"userId": "user_id"},
+			Drop:   []string{"__typename"},
+			Cast:   map[string]Kind{"user_id": String, "created": Date},
+		},
+		"items[*]": {
+			Cast:         map[string]Kind{"price": Number, "qty": Int32},
+			DefaultValue: map[string]v8serialize.Value{"qty": v8serialize.Int32(1), "tags": v8serialize.Array(nil)},
+		},
+		"meta.*": {Cast: map[string]Kind{"x": BigInt}},
+	}
+	v := decode(t, rules.Option())
+
+	if got, want := v.Keys(), []string{"user_id", "items", "meta", "created"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("root keys = %v, want %v", got, want)
+	}
+	if got := v.Field("user_id"); got.Type() != v8serialize.TypeString || got.AsString() != "42" {
+		t.Errorf("user_id = %#v", got)
+	}
+	if got := v.Field("created"); !got.IsDate() || got.AsUnixMilli() != 1704067200000 {
+		t.Errorf("created = %#v", got)
+	}
+
+	items := v.Field("items")
+	if got := items.Index(0).Field("price"); got.Type() != v8serialize.TypeDouble || got.AsDouble() != 9.5 {
+		t.Errorf("items[0].price = %#v", got)
+	}
+	if got := items.Index(0).Field("qty"); got.Type() != v8serialize.TypeInt32 || got.AsInt32() != 2 {
+		t.Errorf("items[0].qty = %#v", got)
+	}
+	if got := items.Index(1).Field("qty"); got.AsInt32() != 1 {
+		t.Errorf("items[1].qty = %#v, want the default", got)
+	}
+	if got := items.Index(1).Field("tags"); !got.IsArray() {
+		t.Errorf("items[1].tags = %#v, want the default for undefined", got)
+	}
+
+	for _, key := range []string{"a", "b c"} {
+		got := v.Field("meta").Field(key).Field("x")
+		if !got.IsBigInt() || got.AsBigInt().Cmp(big.NewInt(1)) != 0 {
+			t.Errorf("meta[%q].x = %#v", key, got)
+		}
+	}
+}
+
+func TestSpecOption(t *testing.T) {
+	// Without Rules a Spec applies to every object.
+	spec := &Spec{Rename: map[string]string{"x": "y"}}
+	v := decode(t, spec.Option())
+	if got := v.Field("meta").Field("a").Field("y"); got.AsInt32() != 1 {
+		t.Errorf("meta.a.y = %#v", got)
+	}
+}
+
+func TestSpecApply(t *testing.T) {
+	obj := v8serialize.OrderedObject([]string{"a", "b", "c"}, map[string]v8serialize.Value{
+		"a": v8serialize.Int32(1),
+		"b": v8serialize.Int32(2),
+		"c": v8serialize.Null(),
+	})
+
+	t.Run("rename-wins", func(t *testing.T) {
+		got, err := (&Spec{Rename: map[string]string{"b": "a"}}).Apply(obj)
+		if err != nil {
+			t.Fatalf("Apply failed: %v", err)
+		}
+		if keys := got.Keys(); !reflect.DeepEqual(keys, []string{"a", "c"}) || got.Field("a").AsInt32() != 2 {
+			t.Errorf("got %v = %#v", keys, got)
+		}
+	})
+
+	t.Run("default-order", func(t *testing.T) {
+		spec := &Spec{DefaultValue: map[string]v8serialize.Value{
+			"z": v8serialize.Null(), "d": v8serialize.Null(), "m": v8serialize.Null(), "e": v8serialize.Null(),
+		}}
+		want := []string{"a", "b", "c", "d", "e", "m", "z"}
+		for range 20 {
+			got, err := spec.Apply(obj)
+			if err != nil {
+				t.Fatalf("Apply failed: %v", err)
+			}
+			if keys := got.Keys(); !reflect.DeepEqual(keys, want) {
+				t.Fatalf("keys = %v, want %v", keys, want)
+			}
+		}
+	})
+
+	t.Run("untouched", func(t *testing.T) {
+		got, err := (&Spec{Drop: []string{"z"}}).Apply(obj)
+		if err != nil {
+			t.Fatalf("Apply failed: %v", err)
+		}
+		if !v8serialize.Same(got, obj) {
+			t.Error("untouched object was copied")
+		}
+	})
+
+	t.Run("cast-skips-null", func(t *testing.T) {
+		got, err := (&Spec{Cast: map[string]Kind{"c": Number}}).Apply(obj)
+		if err != nil || !got.Field("c").IsNull() {
+			t.Errorf("got %#v, %v", got, err)
+		}
+	})
+
+	t.Run("cast-error", func(t *testing.T) {
+		_, err := (&Spec{Cast: map[string]Kind{"a": Date, "b": Bool}}).Apply(v8serialize.Object(map[string]v8serialize.Value{
+			"a": v8serialize.String("yesterday"),
+		}))
+		if !errors.Is(err, ErrCast) {
+			t.Errorf("expected ErrCast, got %v", err)
+		}
+	})
+
+	t.Run("decode-error", func(t *testing.T) {
+		rules := Rules{"items[*]": {Cast: map[string]Kind{"price": Bool}}}
+		data, _ := hex.DecodeString(payload)
+		_, err := v8serialize.Deserialize(data, rules.Option())
+		if !errors.Is(err, ErrCast) {
+			t.Errorf("expected ErrCast, got %v", err)
+		}
+	})
+}
+
+func TestCast(t *testing.T) {
+	tests := []struct {
+		in   v8serialize.Value
+		kind Kind
+		want string // GoString of the result, or "" for ErrCast
+	}{
+		{v8serialize.Double(1.5), String, v8serialize.String("1.5").GoString()},
+		{v8serialize.Bool(true), String, v8serialize.String("true").GoString()},
+		{v8serialize.String(" 12 "), Number, v8serialize.Double(12).GoString()},
+		{v8serialize.String("x"), Number, ""},
+		{v8serialize.Double(3), Int32, v8serialize.Int32(3).GoString()},
+		{v8serialize.Double(3.5), Int32, ""},
+		{v8serialize.String("99999999999"), Int32, ""},
+		{v8serialize.Int32(0), Bool, v8serialize.Bool(false).GoString()},
+		{v8serialize.String("true"), Bool, v8serialize.Bool(true).GoString()},
+		{v8serialize.Double(1e20), BigInt, v8serialize.BigInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(20), nil)).GoString()},
+		{v8serialize.Double(0), Date, v8serialize.DateFromMillis(0).GoString()},
+		{v8serialize.Object(nil), String, ""},
+	}
+	for _, tt := range tests {
+		got, err := Cast(tt.in, tt.kind)
+		if tt.want == "" {
+			if !errors.Is(err, ErrCast) {
+				t.Errorf("Cast(%#v, %s): expected ErrCast, got %#v, %v", tt.in, tt.kind, got, err)
+			}
+			continue
+		}
+		if err != nil || got.GoString() != tt.want {
+			t.Errorf("Cast(%#v, %s) = %#v, %v; want %s", tt.in, tt.kind, got, err, tt.want)
+		}
+	}
+}
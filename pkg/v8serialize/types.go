@@ -1,9 +1,17 @@
 package v8serialize
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/acolita/v8wire/internal/wire"
 )
 
 // Type represents the type of a JavaScript value.
@@ -27,9 +35,11 @@ const (
 	TypeArrayBuffer
 	TypeTypedArray
 	TypeDataView
-	TypeHole           // Sparse array hole
-	TypeError          // JavaScript Error object
-	TypeBoxedPrimitive // Number/Boolean/String/BigInt object wrappers
+	TypeHole                // Sparse array hole
+	TypeError               // JavaScript Error object
+	TypeBoxedPrimitive      // Number/Boolean/String/BigInt object wrappers
+	TypeDetachedArrayBuffer // transferred ArrayBuffer whose memory is not in the payload
+	TypeSharedArrayBuffer   // SharedArrayBuffer passed by ID; its memory is not in the payload
 )
 
 // String returns the type name.
@@ -75,6 +85,10 @@ func (t Type) String() string {
 		return "Error"
 	case TypeBoxedPrimitive:
 		return "BoxedPrimitive"
+	case TypeDetachedArrayBuffer:
+		return "DetachedArrayBuffer"
+	case TypeSharedArrayBuffer:
+		return "SharedArrayBuffer"
 	default:
 		return fmt.Sprintf("Type(%d)", t)
 	}
@@ -102,18 +116,56 @@ func Bool(b bool) Value {
 	return Value{typ: TypeBool, data: b}
 }
 
+// Numbers in [internMin, internMax) are boxed once at startup and shared,
+// like math/big's small-int cache, so the constructors (and so the decoder)
+// do not allocate an interface box for the most common values.
+const (
+	internMin = -128
+	internMax = 1024
+)
+
+var (
+	internedInt32s  [internMax - internMin]interface{}
+	internedUint32s [internMax]interface{}
+	internedDoubles [internMax - internMin]interface{}
+)
+
+func init() {
+	for i := range internedInt32s {
+		internedInt32s[i] = int32(i + internMin)
+		internedDoubles[i] = float64(i + internMin)
+	}
+	for i := range internedUint32s {
+		internedUint32s[i] = uint32(i)
+	}
+}
+
 // Int32 returns a Value representing a JavaScript number (int32 range).
 func Int32(n int32) Value {
+	if n >= internMin && n < internMax {
+		return Value{typ: TypeInt32, data: internedInt32s[n-internMin]}
+	}
 	return Value{typ: TypeInt32, data: n}
 }
 
 // Uint32 returns a Value representing a JavaScript number (uint32 range).
+// It is written with the Uint32 tag, which V8 itself never writes but
+// every reader decodes to the same number, so values in [2^31, 2^32)
+// travel as exact integers in five bytes or fewer instead of as doubles.
+// SerializeGo writes Go unsigned integers in that range the same way.
 func Uint32(n uint32) Value {
+	if n < internMax {
+		return Value{typ: TypeUint32, data: internedUint32s[n]}
+	}
 	return Value{typ: TypeUint32, data: n}
 }
 
 // Double returns a Value representing a JavaScript number (double).
 func Double(f float64) Value {
+	// -0 compares equal to 0 but must keep its sign.
+	if f >= internMin && f < internMax && f == math.Trunc(f) && (f != 0 || !math.Signbit(f)) {
+		return Value{typ: TypeDouble, data: internedDoubles[int(f)-internMin]}
+	}
 	return Value{typ: TypeDouble, data: f}
 }
 
@@ -122,14 +174,69 @@ func BigInt(n *big.Int) Value {
 	return Value{typ: TypeBigInt, data: n}
 }
 
+// BigIntFromString parses a BigInt literal the way JavaScript's BigInt()
+// does: decimal digits with an optional sign, or an unsigned 0x, 0o or 0b
+// prefixed literal. Surrounding whitespace is ignored. Unlike BigInt(),
+// which reads "" as 0n, it rejects empty and all-whitespace strings.
+func BigIntFromString(s string) (Value, error) {
+	lit := strings.TrimSpace(s)
+	if lit == "" {
+		return Value{}, fmt.Errorf("v8serialize: invalid BigInt %q", s)
+	}
+	base := 10
+	if len(lit) > 2 && lit[0] == '0' {
+		switch lit[1] {
+		case 'x', 'X':
+			base = 16
+		case 'o', 'O':
+			base = 8
+		case 'b', 'B':
+			base = 2
+		}
+		if base != 10 {
+			lit = lit[2:]
+		}
+	}
+	if base != 10 && (lit[0] == '+' || lit[0] == '-') {
+		return Value{}, fmt.Errorf("v8serialize: invalid BigInt %q", s)
+	}
+
+	n, ok := new(big.Int).SetString(lit, base)
+	if !ok {
+		return Value{}, fmt.Errorf("v8serialize: invalid BigInt %q", s)
+	}
+	return BigInt(n), nil
+}
+
 // String returns a Value representing a JavaScript string.
 func String(s string) Value {
 	return Value{typ: TypeString, data: s}
 }
 
-// Date returns a Value representing a JavaScript Date.
+// Date returns a Value representing a JavaScript Date. JavaScript Dates
+// count whole milliseconds, so any sub-millisecond part of t is dropped.
 func Date(t time.Time) Value {
-	return Value{typ: TypeDate, data: t}
+	return DateFromMillis(float64(t.UnixMilli()))
+}
+
+// maxDateMillis bounds the JavaScript Date range: ±100,000,000 days
+// around the epoch.
+const maxDateMillis = 8.64e15
+
+// invalidDateMillis is the time value of an Invalid Date, with the quiet
+// NaN bit pattern V8 writes (math.NaN sets a payload bit).
+var invalidDateMillis = math.Float64frombits(0x7ff8000000000000)
+
+// DateFromMillis returns a Value representing the JavaScript Date
+// new Date(ms): ms is truncated to whole milliseconds, and NaN, infinities
+// and values beyond the Date range give an Invalid Date.
+func DateFromMillis(ms float64) Value {
+	if math.IsNaN(ms) || math.Abs(ms) > maxDateMillis {
+		ms = invalidDateMillis
+	} else {
+		ms = math.Trunc(ms) + 0 // +0 turns -0 into 0, as TimeClip does
+	}
+	return Value{typ: TypeDate, data: ms}
 }
 
 // Hole returns a Value representing an array hole.
@@ -138,12 +245,88 @@ func Hole() Value {
 }
 
 // Object returns a Value representing a JavaScript object.
-// If props is nil, creates an empty object.
+// If props is nil, creates an empty object. Go maps have no order, so the
+// properties are iterated in lexicographic order; use OrderedObject to keep
+// an insertion order.
 func Object(props map[string]Value) Value {
 	if props == nil {
 		props = make(map[string]Value)
 	}
-	return Value{typ: TypeObject, data: props}
+	o := &object{props: props}
+	o.order = o.keys()
+	return Value{typ: TypeObject, data: o}
+}
+
+// OrderedObject returns a Value representing a JavaScript object whose
+// properties are iterated in the order of keys. Properties of props not
+// listed in keys follow in lexicographic order; keys not in props are
+// ignored.
+func OrderedObject(keys []string, props map[string]Value) Value {
+	if props == nil {
+		props = make(map[string]Value)
+	}
+	o := &object{props: props, order: make([]string, 0, len(keys))}
+	seen := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		if _, ok := props[k]; ok && !seen[k] {
+			seen[k] = true
+			o.order = append(o.order, k)
+		}
+	}
+	o.order = o.keys()
+	return Value{typ: TypeObject, data: o}
+}
+
+// object holds the properties of a JavaScript object along with the order
+// they were decoded or inserted in. order may miss keys added through the
+// map returned by AsObject, and may list keys deleted through it.
+type object struct {
+	props map[string]Value
+	order []string
+}
+
+// set adds or replaces a property, appending new keys to the order.
+func (o *object) set(key string, v Value) {
+	if _, ok := o.props[key]; !ok {
+		o.order = append(o.order, key)
+	}
+	o.props[key] = v
+}
+
+// keys returns the property names in iteration order: the recorded order
+// for properties that still exist, then any others lexicographically.
+func (o *object) keys() []string {
+	keys := make([]string, 0, len(o.props))
+	for _, k := range o.order {
+		if _, ok := o.props[k]; ok {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == len(o.props) {
+		return keys
+	}
+	ordered := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		ordered[k] = true
+	}
+	n := len(keys)
+	for k := range o.props {
+		if !ordered[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys[n:])
+	return keys
+}
+
+// clone returns a copy of o with its own map and order, for copy-on-write
+// updates.
+func (o *object) clone() *object {
+	c := &object{props: make(map[string]Value, len(o.props)+1), order: o.keys()}
+	for k, v := range o.props {
+		c.props[k] = v
+	}
+	return c
 }
 
 // Array returns a Value representing a JavaScript array.
@@ -163,6 +346,40 @@ func ArrayBuffer(data []byte) Value {
 	return Value{typ: TypeArrayBuffer, data: data}
 }
 
+// ErrInvalidView is returned for views that do not fit their buffer.
+var ErrInvalidView = errors.New("v8serialize: invalid ArrayBuffer view")
+
+// NewView returns a TypedArray or DataView of the given kind ("Uint8Array",
+// "Float64Array", "DataView", ...) over byteLength bytes of buffer, an
+// ArrayBuffer Value, starting at byteOffset. The view shares buffer's
+// memory, and the serializer writes views sharing one buffer as that buffer
+// plus view records referencing it, as V8 does, instead of copying the bytes
+// per view. It fails with ErrInvalidView if buffer is not an ArrayBuffer,
+// kind is unknown, or the range is outside the buffer or not aligned to the
+// element size.
+func NewView(buffer Value, kind string, byteOffset, byteLength int) (Value, error) {
+	if buffer.typ != TypeArrayBuffer {
+		return Value{}, fmt.Errorf("%w: buffer is %s, not ArrayBuffer", ErrInvalidView, buffer.typ)
+	}
+	if _, ok := viewSubTag(kind); !ok {
+		return Value{}, fmt.Errorf("%w: unknown view type %q", ErrInvalidView, kind)
+	}
+	buf := buffer.data.([]byte)
+	if byteOffset < 0 || byteLength < 0 || byteOffset+byteLength > len(buf) {
+		return Value{}, fmt.Errorf("%w: %s [%d, %d) outside %d-byte buffer", ErrInvalidView, kind, byteOffset, byteOffset+byteLength, len(buf))
+	}
+	if size := typedArrayElementSize(kind); size > 1 && (byteOffset%size != 0 || byteLength%size != 0) {
+		return Value{}, fmt.Errorf("%w: %s offset %d and length %d must be multiples of %d", ErrInvalidView, kind, byteOffset, byteLength, size)
+	}
+	return Value{typ: TypeTypedArray, data: &ArrayBufferView{
+		Buffer:     buf,
+		ByteOffset: byteOffset,
+		ByteLength: byteLength,
+		Type:       kind,
+		shared:     true,
+	}}, nil
+}
+
 // Type returns the JavaScript type of this value.
 func (v Value) Type() Type {
 	return v.typ
@@ -223,6 +440,26 @@ func (v Value) IsHole() bool {
 	return v.typ == TypeHole
 }
 
+// IsTruthy reports whether v is truthy under JavaScript's ToBoolean: false,
+// 0, -0, NaN, 0n, "", null, undefined and holes are falsy; everything else,
+// including boxed primitives and empty objects, is truthy.
+func (v Value) IsTruthy() bool {
+	switch v.typ {
+	case TypeUndefined, TypeNull, TypeHole:
+		return false
+	case TypeBool:
+		return v.data.(bool)
+	case TypeInt32, TypeUint32, TypeDouble:
+		n := v.AsNumber()
+		return n != 0 && !math.IsNaN(n)
+	case TypeBigInt:
+		return v.data.(*big.Int).Sign() != 0
+	case TypeString:
+		return v.data.(string) != ""
+	}
+	return true
+}
+
 // AsBool returns the boolean value. Panics if not a boolean.
 func (v Value) AsBool() bool {
 	if v.typ != TypeBool {
@@ -278,6 +515,37 @@ func (v Value) AsBigInt() *big.Int {
 	return v.data.(*big.Int)
 }
 
+// AsBigIntString returns the BigInt as a decimal string. Panics if not a
+// BigInt.
+func (v Value) AsBigIntString() string {
+	return v.AsBigInt().String()
+}
+
+// Int64 returns the value as an int64 if it is a BigInt or an integral
+// number that fits exactly. It reports false for other values.
+func (v Value) Int64() (int64, bool) {
+	switch v.typ {
+	case TypeInt32:
+		return int64(v.data.(int32)), true
+	case TypeUint32:
+		return int64(v.data.(uint32)), true
+	case TypeDouble:
+		f := v.data.(float64)
+		if f != math.Trunc(f) || f < -(1<<63) || f >= 1<<63 {
+			return 0, false
+		}
+		return int64(f), true
+	case TypeBigInt:
+		n := v.data.(*big.Int)
+		if !n.IsInt64() {
+			return 0, false
+		}
+		return n.Int64(), true
+	default:
+		return 0, false
+	}
+}
+
 // AsString returns the string value. Panics if not a string.
 func (v Value) AsString() string {
 	if v.typ != TypeString {
@@ -286,12 +554,46 @@ func (v Value) AsString() string {
 	return v.data.(string)
 }
 
-// AsDate returns the time.Time value. Panics if not a Date.
+// AsDate returns the Date as a time.Time in UTC, or the zero time for an
+// Invalid Date. Panics if not a Date.
 func (v Value) AsDate() time.Time {
 	if v.typ != TypeDate {
 		panic(fmt.Sprintf("Value.AsDate: expected Date, got %s", v.typ))
 	}
-	return v.data.(time.Time)
+	ms := v.data.(float64)
+	if math.IsNaN(ms) {
+		return time.Time{}
+	}
+	return time.UnixMilli(int64(ms)).UTC()
+}
+
+// AsUnixMilli returns the Date's time value, milliseconds since the Unix
+// epoch, exactly as JavaScript's getTime() does, or 0 for an Invalid Date.
+// Panics if not a Date.
+func (v Value) AsUnixMilli() int64 {
+	if v.typ != TypeDate {
+		panic(fmt.Sprintf("Value.AsUnixMilli: expected Date, got %s", v.typ))
+	}
+	ms := v.data.(float64)
+	if math.IsNaN(ms) {
+		return 0
+	}
+	return int64(ms)
+}
+
+// In returns the Date as a time.Time in loc, or the zero time for an
+// Invalid Date. Panics if not a Date.
+func (v Value) In(loc *time.Location) time.Time {
+	if v.IsInvalidDate() {
+		return time.Time{}
+	}
+	return v.AsDate().In(loc)
+}
+
+// IsInvalidDate reports whether v is a Date whose time value is NaN, as
+// with new Date("garbage").
+func (v Value) IsInvalidDate() bool {
+	return v.typ == TypeDate && math.IsNaN(v.data.(float64))
 }
 
 // AsObject returns the object as map[string]Value. Panics if not an object.
@@ -299,7 +601,7 @@ func (v Value) AsObject() map[string]Value {
 	if v.typ != TypeObject {
 		panic(fmt.Sprintf("Value.AsObject: expected object, got %s", v.typ))
 	}
-	return v.data.(map[string]Value)
+	return v.data.(*object).props
 }
 
 // AsArray returns the array as []Value. Panics if not an array.
@@ -310,12 +612,239 @@ func (v Value) AsArray() []Value {
 	return v.data.([]Value)
 }
 
+// Field returns the named property of an object, or the entry with that
+// string key in a Map. It returns undefined for anything else, so templates
+// can navigate decoded payloads ({{.Field "user"}}) without panics.
+func (v Value) Field(name string) Value {
+	switch v.typ {
+	case TypeObject:
+		if prop, ok := v.data.(*object).props[name]; ok {
+			return prop
+		}
+	case TypeMap:
+		for _, e := range v.data.(*JSMap).Entries {
+			if e.Key.typ == TypeString && e.Key.data.(string) == name {
+				return e.Value
+			}
+		}
+	}
+	return Undefined()
+}
+
+// Index returns element i of an array or member i of a Set. It returns
+// undefined for holes, out-of-range indices and other types.
+func (v Value) Index(i int) Value {
+	values := v.elements()
+	if i < 0 || i >= len(values) || values[i].typ == TypeHole {
+		return Undefined()
+	}
+	return values[i]
+}
+
+// Len returns the JavaScript length or size of v: array length, string
+// length in UTF-16 code units, Map and Set size, number of object
+// properties, ArrayBuffer byte length or TypedArray element count. It
+// returns 0 for other types.
+func (v Value) Len() int {
+	switch v.typ {
+	case TypeArray:
+		return len(v.data.([]Value))
+	case TypeString:
+		return wire.UTF16Length(v.data.(string))
+	case TypeObject:
+		return len(v.data.(*object).props)
+	case TypeMap:
+		return len(v.data.(*JSMap).Entries)
+	case TypeSet:
+		return len(v.data.(*JSSet).Values)
+	case TypeArrayBuffer:
+		return len(v.data.([]byte))
+	case TypeTypedArray, TypeDataView:
+		view := v.data.(*ArrayBufferView)
+		if size := typedArrayElementSize(view.Type); size > 0 {
+			return view.ByteLength / size
+		}
+		return view.ByteLength
+	}
+	return 0
+}
+
+// Keys returns the property names of an object in a stable order, or nil
+// for any other type. Decoded objects keep the order properties appeared in
+// on the wire, which is JavaScript's own property order; objects built with
+// Object from a Go map are ordered lexicographically. Properties added
+// through the map returned by AsObject come last, lexicographically.
+func (v Value) Keys() []string {
+	if v.typ != TypeObject {
+		return nil
+	}
+	return v.data.(*object).keys()
+}
+
+// Range calls fn for each property of an object in Keys order, stopping
+// early if fn returns false. It does nothing for other types. Range has the
+// shape of an iter.Seq2, so it can be used directly in a range loop:
+//
+//	for k, prop := range v.Range { ... }
+func (v Value) Range(fn func(key string, value Value) bool) {
+	if v.typ != TypeObject {
+		return
+	}
+	o := v.data.(*object)
+	for _, k := range o.keys() {
+		if !fn(k, o.props[k]) {
+			return
+		}
+	}
+}
+
+// elements returns the members of an array or Set, or nil for other types.
+func (v Value) elements() []Value {
+	switch v.typ {
+	case TypeArray:
+		return v.data.([]Value)
+	case TypeSet:
+		return v.data.(*JSSet).Values
+	}
+	return nil
+}
+
+// MapValues returns a new array or Set holding fn applied to each member of
+// v. Array holes are kept as holes without calling fn, and a mapped Set
+// drops results that are SameValueZero-equal to an earlier one. It returns
+// undefined for other types.
+func (v Value) MapValues(fn func(Value) Value) Value {
+	switch v.typ {
+	case TypeArray:
+		src := v.data.([]Value)
+		out := make([]Value, len(src))
+		for i, e := range src {
+			if e.typ == TypeHole {
+				out[i] = e
+				continue
+			}
+			out[i] = fn(e)
+		}
+		return Array(out)
+	case TypeSet:
+		src := v.data.(*JSSet).Values
+		out := make([]Value, 0, len(src))
+		seen := make(map[svzKey]bool, len(src))
+		for _, e := range src {
+			e = fn(e)
+			if k, ok := sameValueZeroKey(e); ok {
+				if seen[k] {
+					continue
+				}
+				seen[k] = true
+			}
+			out = append(out, e)
+		}
+		return Value{typ: TypeSet, data: &JSSet{Values: out}}
+	}
+	return Undefined()
+}
+
+// Filter returns a new array or Set holding the members of v for which fn
+// reports true. Array holes are skipped. It returns undefined for other
+// types.
+func (v Value) Filter(fn func(Value) bool) Value {
+	var out []Value
+	for _, e := range v.elements() {
+		if e.typ != TypeHole && fn(e) {
+			out = append(out, e)
+		}
+	}
+	switch v.typ {
+	case TypeArray:
+		return Array(out)
+	case TypeSet:
+		return Value{typ: TypeSet, data: &JSSet{Values: out}}
+	}
+	return Undefined()
+}
+
+// Find returns the first member of an array or Set for which fn reports
+// true. Array holes are skipped. It returns undefined and false when
+// nothing matches or v is neither an array nor a Set.
+func (v Value) Find(fn func(Value) bool) (Value, bool) {
+	for _, e := range v.elements() {
+		if e.typ != TypeHole && fn(e) {
+			return e, true
+		}
+	}
+	return Undefined(), false
+}
+
+// String formats v for display: strings as-is, numbers and BigInts in
+// decimal, Dates in ISO 8601, and short summaries such as Map(2) for
+// containers, which are never printed recursively. It lets templates print
+// Values directly.
+func (v Value) String() string {
+	switch v.typ {
+	case TypeString:
+		return v.data.(string)
+	case TypeInt32, TypeUint32, TypeDouble:
+		f := v.AsNumber()
+		switch abs := math.Abs(f); {
+		case math.IsNaN(f):
+			return "NaN"
+		case math.IsInf(f, 1):
+			return "Infinity"
+		case math.IsInf(f, -1):
+			return "-Infinity"
+		case abs == 0:
+			return "0" // including -0, as in JavaScript
+		case abs >= 1e-6 && abs < 1e21:
+			return strconv.FormatFloat(f, 'f', -1, 64)
+		}
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	case TypeBigInt:
+		return v.data.(*big.Int).String()
+	case TypeDate:
+		if v.IsInvalidDate() {
+			return "Invalid Date"
+		}
+		return v.AsDate().Format("2006-01-02T15:04:05.000Z07:00")
+	case TypeRegExp:
+		re := v.data.(*RegExp)
+		return "/" + re.Pattern + "/" + re.Flags
+	case TypeMap:
+		return fmt.Sprintf("Map(%d)", len(v.data.(*JSMap).Entries))
+	case TypeSet:
+		return fmt.Sprintf("Set(%d)", len(v.data.(*JSSet).Values))
+	case TypeArrayBuffer:
+		return fmt.Sprintf("ArrayBuffer(%d)", len(v.data.([]byte)))
+	case TypeTypedArray, TypeDataView:
+		view := v.data.(*ArrayBufferView)
+		return fmt.Sprintf("%s(%d)", view.Type, v.Len())
+	case TypeDetachedArrayBuffer:
+		return v.data.(*DetachedArrayBuffer).String()
+	case TypeSharedArrayBuffer:
+		return v.data.(*SharedArrayBufferRef).String()
+	case TypeError:
+		e := v.data.(*JSError)
+		return e.Name + ": " + e.Message
+	case TypeBoxedPrimitive:
+		return v.data.(*BoxedPrimitive).Value.String()
+	}
+	// Remaining types (null, undefined, booleans, holes, objects, arrays)
+	// have short GoString forms that do not print nested values.
+	return v.GoString()
+}
+
 // Interface returns the underlying Go value.
 // Returns nil for undefined and null.
 func (v Value) Interface() interface{} {
 	if v.typ == TypeUndefined || v.typ == TypeNull || v.typ == TypeHole {
 		return nil
 	}
+	switch v.typ {
+	case TypeObject:
+		return v.data.(*object).props
+	case TypeDate:
+		return v.AsDate()
+	}
 	return v.data
 }
 
@@ -342,13 +871,20 @@ func (v Value) GoString() string {
 	case TypeString:
 		return fmt.Sprintf("%q", v.data.(string))
 	case TypeDate:
-		return fmt.Sprintf("Date(%s)", v.data.(time.Time).Format(time.RFC3339Nano))
+		if v.IsInvalidDate() {
+			return "Date(Invalid Date)"
+		}
+		return fmt.Sprintf("Date(%s)", v.AsDate().Format(time.RFC3339Nano))
 	case TypeHole:
 		return "<hole>"
 	case TypeObject:
-		return fmt.Sprintf("Object{%d properties}", len(v.data.(map[string]Value)))
+		return fmt.Sprintf("Object{%d properties}", len(v.data.(*object).props))
 	case TypeArray:
 		return fmt.Sprintf("Array[%d]", len(v.data.([]Value)))
+	case TypeDetachedArrayBuffer:
+		return v.data.(*DetachedArrayBuffer).String()
+	case TypeSharedArrayBuffer:
+		return v.data.(*SharedArrayBufferRef).String()
 	default:
 		return fmt.Sprintf("%s(%v)", v.typ, v.data)
 	}
@@ -377,12 +913,131 @@ type JSSet struct {
 	Values []Value
 }
 
+// Add appends v unless the set already contains a value that is
+// SameValueZero to it, and reports whether v was added.
+func (s *JSSet) Add(v Value) bool {
+	if key, ok := sameValueZeroKey(v); ok {
+		for _, existing := range s.Values {
+			if k, ok := sameValueZeroKey(existing); ok && k == key {
+				return false
+			}
+		}
+	}
+	s.Values = append(s.Values, v)
+	return true
+}
+
+// SameValueZero reports whether a and b are equal under JavaScript's
+// SameValueZero algorithm, which Sets and Maps use for membership: numbers
+// compare by value regardless of representation, NaN equals NaN, +0 equals
+// -0, and BigInts and strings compare by value. Objects compare by identity,
+// i.e. a and b must share the same underlying data. Dates and empty arrays
+// or ArrayBuffers carry no identity in a Value and are never equal.
+func SameValueZero(a, b Value) bool {
+	ka, ok := sameValueZeroKey(a)
+	if !ok {
+		return false
+	}
+	kb, ok := sameValueZeroKey(b)
+	return ok && ka == kb
+}
+
+// Same reports whether a and b refer to the same JavaScript object, as
+// Values reached through several back-references in a payload do.
+// Primitives, including strings and BigInts, are never the same, and
+// neither are Dates and empty arrays or ArrayBuffers, which carry no
+// identity in a Value.
+func Same(a, b Value) bool {
+	id := a.RefID()
+	return id != 0 && id == b.RefID() && a.typ == b.typ
+}
+
+// RefID returns an identifier of the object v refers to, equal for Values
+// that are the Same and 0 for values without identity. It is derived from
+// memory addresses: stable for as long as the decoded tree is alive, so it
+// can key visited sets in graph algorithms, but meaningless across decodes
+// and not to be persisted.
+func (v Value) RefID() uintptr {
+	switch v.typ {
+	case TypeObject, TypeArray, TypeMap, TypeSet, TypeError, TypeRegExp, TypeBoxedPrimitive,
+		TypeArrayBuffer, TypeTypedArray, TypeDataView, TypeDetachedArrayBuffer, TypeSharedArrayBuffer:
+	default:
+		return 0
+	}
+	k, ok := sameValueZeroKey(v)
+	if !ok {
+		return 0
+	}
+	return k.ptr
+}
+
+// svzKey is a comparable key that is equal for SameValueZero values.
+type svzKey struct {
+	class Type // TypeDouble for all numbers
+	num   float64
+	str   string
+	ptr   uintptr
+}
+
+// sameValueZeroKey returns the SameValueZero key of v. It reports false for
+// values without identity.
+func sameValueZeroKey(v Value) (svzKey, bool) {
+	switch v.typ {
+	case TypeUndefined, TypeNull, TypeHole:
+		return svzKey{class: v.typ}, true
+	case TypeBool:
+		if v.data.(bool) {
+			return svzKey{class: TypeBool, num: 1}, true
+		}
+		return svzKey{class: TypeBool}, true
+	case TypeInt32, TypeUint32, TypeDouble:
+		f := v.AsNumber()
+		switch {
+		case math.IsNaN(f):
+			return svzKey{class: TypeDouble, str: "NaN"}, true
+		case f == 0:
+			return svzKey{class: TypeDouble}, true // +0 and -0
+		}
+		return svzKey{class: TypeDouble, num: f}, true
+	case TypeBigInt:
+		return svzKey{class: TypeBigInt, str: v.data.(*big.Int).String()}, true
+	case TypeString:
+		return svzKey{class: TypeString, str: v.data.(string)}, true
+	}
+
+	rv := reflect.ValueOf(v.data)
+	switch rv.Kind() {
+	case reflect.Map, reflect.Ptr:
+		return svzKey{class: v.typ, ptr: rv.Pointer()}, !rv.IsNil()
+	case reflect.Slice:
+		// Empty slices may share storage, so they cannot be told apart.
+		return svzKey{class: v.typ, ptr: rv.Pointer()}, rv.Cap() > 0
+	default:
+		return svzKey{}, false
+	}
+}
+
 // ArrayBufferView represents a typed view into an ArrayBuffer.
 type ArrayBufferView struct {
 	Buffer     []byte
 	ByteOffset int
 	ByteLength int
 	Type       string // "Int8Array", "Uint8Array", etc.
+
+	shared bool // made by NewView: written natively so Buffer stays shared
+}
+
+// Slice returns the bytes the view covers, sharing Buffer's memory. Views
+// decoded from V8's native encoding keep their whole ArrayBuffer in
+// Buffer: for a small Node Buffer that is the shared 8 KiB pool, holding
+// other Buffers' bytes around the view, so read the view's contents
+// through Slice rather than Buffer. Slice returns nil if the view does not
+// fit Buffer.
+func (v *ArrayBufferView) Slice() []byte {
+	if v.ByteOffset < 0 || v.ByteLength < 0 || v.ByteOffset+v.ByteLength > len(v.Buffer) {
+		return nil
+	}
+	return v.Buffer[v.ByteOffset : v.ByteOffset+v.ByteLength]
 }
 
 // JSError represents a JavaScript Error object.
@@ -0,0 +1,466 @@
+package v8serialize
+
+import (
+	"encoding/hex"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+)
+
+func TestBigIntFromString(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"0", "0", true},
+		{"-42", "-42", true},
+		{"+7", "7", true},
+		{" 340282366920938463463374607431768211455 ", "340282366920938463463374607431768211455", true},
+		{"0x10", "16", true},
+		{"0o17", "15", true},
+		{"0b101", "5", true},
+		{"010", "10", true},
+		{"", "", false},
+		{" \t", "", false},
+		{"1.5", "", false},
+		{"-0x10", "", false},
+		{"1_000", "", false},
+		{"12n", "", false},
+	}
+
+	for _, tt := range tests {
+		v, err := BigIntFromString(tt.in)
+		if !tt.ok {
+			if err == nil {
+				t.Errorf("BigIntFromString(%q): expected error, got %s", tt.in, v.AsBigIntString())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("BigIntFromString(%q): %v", tt.in, err)
+			continue
+		}
+		if got := v.AsBigIntString(); got != tt.want {
+			t.Errorf("BigIntFromString(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestValueInt64(t *testing.T) {
+	huge, _ := new(big.Int).SetString("18446744073709551616", 10)
+
+	tests := []struct {
+		name  string
+		value Value
+		want  int64
+		ok    bool
+	}{
+		{"int32", Int32(-5), -5, true},
+		{"uint32", Uint32(math.MaxUint32), math.MaxUint32, true},
+		{"integral double", Double(1 << 40), 1 << 40, true},
+		{"fractional double", Double(1.5), 0, false},
+		{"double out of range", Double(1 << 63), 0, false},
+		{"NaN", Double(math.NaN()), 0, false},
+		{"bigint", BigInt(big.NewInt(math.MinInt64)), math.MinInt64, true},
+		{"bigint out of range", BigInt(huge), 0, false},
+		{"string", String("1"), 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.value.Int64()
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("got (%d, %v), want (%d, %v)", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestBigIntFixturesAsString(t *testing.T) {
+	binData, _ := loadFixture(t, "bigint-u128-max")
+	v, err := Deserialize(binData)
+	if err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	if got := v.AsBigIntString(); got != "340282366920938463463374607431768211455" {
+		t.Errorf("got %s", got)
+	}
+	if _, ok := v.Int64(); ok {
+		t.Error("expected u128 max not to fit in int64")
+	}
+}
+
+func TestSameValueZero(t *testing.T) {
+	obj := Object(map[string]Value{})
+	arr := Array([]Value{Int32(1)})
+
+	tests := []struct {
+		name string
+		a, b Value
+		want bool
+	}{
+		{"int32 and double", Int32(1), Double(1), true},
+		{"uint32 and double", Uint32(1 << 31), Double(1 << 31), true},
+		{"NaN", Double(math.NaN()), Double(math.NaN()), true},
+		{"signed zeros", Double(0), Double(math.Copysign(0, -1)), true},
+		{"different numbers", Int32(1), Int32(2), false},
+		{"number and string", Int32(1), String("1"), false},
+		{"number and bigint", Int32(1), BigInt(big.NewInt(1)), false},
+		{"bigints", BigInt(big.NewInt(7)), BigInt(big.NewInt(7)), true},
+		{"strings", String("a"), String("a"), true},
+		{"null and undefined", Null(), Undefined(), false},
+		{"same object", obj, obj, true},
+		{"equal objects", obj, Object(map[string]Value{}), false},
+		{"same array", arr, arr, true},
+		{"empty arrays", Array([]Value{}), Array([]Value{}), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SameValueZero(tt.a, tt.b); got != tt.want {
+				t.Errorf("SameValueZero = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSame(t *testing.T) {
+	// const b = [1, 2]; [b, b, {}, {}]
+	data, _ := hex.DecodeString("ff0f41044102490249042400025e016f7b006f7b00240004")
+	v, err := Deserialize(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	arr := v.AsArray()
+	if !Same(arr[0], arr[1]) || arr[0].RefID() != arr[1].RefID() {
+		t.Error("back-reference: expected the same array")
+	}
+	if Same(arr[2], arr[3]) || arr[2].RefID() == arr[3].RefID() {
+		t.Error("equal objects: expected different identities")
+	}
+	if Same(arr[0], arr[2]) {
+		t.Error("array and object: expected different identities")
+	}
+	for _, p := range []Value{String("a"), Int32(1), BigInt(big.NewInt(1)), Date(time.Now()), Array([]Value{})} {
+		if p.RefID() != 0 || Same(p, p) {
+			t.Errorf("%s: expected no identity", p.Type())
+		}
+	}
+}
+
+func TestJSSetAdd(t *testing.T) {
+	s := &JSSet{}
+	obj := Object(map[string]Value{})
+
+	adds := []struct {
+		v    Value
+		want bool
+	}{
+		{Int32(1), true},
+		{Double(1), false},
+		{Double(math.NaN()), true},
+		{Double(math.NaN()), false},
+		{obj, true},
+		{obj, false},
+		{Object(map[string]Value{}), true},
+	}
+	for i, a := range adds {
+		if got := s.Add(a.v); got != a.want {
+			t.Errorf("add %d (%s): got %v, want %v", i, a.v.GoString(), got, a.want)
+		}
+	}
+	if len(s.Values) != 4 {
+		t.Errorf("expected 4 values, got %d", len(s.Values))
+	}
+}
+
+func TestValueNavigation(t *testing.T) {
+	m := Value{typ: TypeMap, data: &JSMap{Entries: []MapEntry{{Key: String("k"), Value: Int32(5)}}}}
+	root := Object(map[string]Value{
+		"user":  Object(map[string]Value{"name": String("Ana")}),
+		"items": Array([]Value{Int32(1), Hole(), String("x")}),
+		"m":     m,
+		"s":     String("日本🌍"),
+	})
+
+	tests := []struct {
+		name string
+		got  Value
+		want string
+	}{
+		{"field", root.Field("user").Field("name"), "Ana"},
+		{"missing-field", root.Field("nope").Field("deeper"), "undefined"},
+		{"map-field", root.Field("m").Field("k"), "5"},
+		{"index", root.Field("items").Index(2), "x"},
+		{"hole", root.Field("items").Index(1), "undefined"},
+		{"out-of-range", root.Field("items").Index(9), "undefined"},
+		{"index-on-object", root.Index(0), "undefined"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got.String() != tt.want {
+				t.Errorf("got %s, want %s", tt.got, tt.want)
+			}
+		})
+	}
+
+	lens := map[string]int{"items": 3, "m": 1, "s": 4, "user": 1, "nope": 0}
+	for field, want := range lens {
+		if got := root.Field(field).Len(); got != want {
+			t.Errorf("Field(%q).Len() = %d, want %d", field, got, want)
+		}
+	}
+}
+
+func TestValueString(t *testing.T) {
+	tests := []struct {
+		v    Value
+		want string
+	}{
+		{String("plain"), "plain"},
+		{Int32(-3), "-3"},
+		{Double(1.5), "1.5"},
+		{Double(1e6), "1000000"},
+		{Double(1e21), "1e+21"},
+		{Double(math.Copysign(0, -1)), "0"},
+		{Double(math.Inf(-1)), "-Infinity"},
+		{Double(math.NaN()), "NaN"},
+		{BigInt(big.NewInt(99)), "99"},
+		{Date(time.UnixMilli(1700000000123)), "2023-11-14T22:13:20.123Z"},
+		{Null(), "null"},
+		{Bool(true), "true"},
+	}
+	for _, tt := range tests {
+		if got := tt.v.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestValueInTemplate(t *testing.T) {
+	root := Object(map[string]Value{
+		"user":  Object(map[string]Value{"name": String("Ana")}),
+		"items": Array([]Value{Int32(1), Int32(2)}),
+	})
+	tmpl := template.Must(template.New("t").Parse(
+		`{{(.Field "user").Field "name"}} has {{(.Field "items").Len}} items, first {{(.Field "items").Index 0}}, {{.Field "missing"}}`))
+	var b strings.Builder
+	if err := tmpl.Execute(&b, root); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if want := "Ana has 2 items, first 1, undefined"; b.String() != want {
+		t.Errorf("got %q, want %q", b.String(), want)
+	}
+}
+
+func TestValueKeysRange(t *testing.T) {
+	obj := Object(map[string]Value{"b": Int32(2), "a": Int32(1), "c": Int32(3)})
+	if got := strings.Join(obj.Keys(), ","); got != "a,b,c" {
+		t.Errorf("Keys() = %q, want a,b,c", got)
+	}
+	var seen []string
+	obj.Range(func(k string, v Value) bool {
+		seen = append(seen, k+"="+v.String())
+		return k != "b"
+	})
+	if got := strings.Join(seen, ","); got != "a=1,b=2" {
+		t.Errorf("Range visited %q, want a=1,b=2", got)
+	}
+	if keys := Array([]Value{Int32(1)}).Keys(); keys != nil {
+		t.Errorf("Keys() on array = %v, want nil", keys)
+	}
+	String("x").Range(func(string, Value) bool {
+		t.Error("Range called fn for a string")
+		return true
+	})
+}
+
+func TestObjectOrder(t *testing.T) {
+	obj := OrderedObject([]string{"z", "a", "m"}, map[string]Value{
+		"a": Int32(1), "m": Int32(2), "z": Int32(3), "b": Int32(4),
+	})
+	if got := strings.Join(obj.Keys(), ","); got != "z,a,m,b" {
+		t.Fatalf("OrderedObject Keys() = %q, want z,a,m,b", got)
+	}
+
+	// The wire order survives a round trip.
+	data, err := Serialize(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := Deserialize(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var seen []string
+	for k := range decoded.Range {
+		seen = append(seen, k)
+	}
+	if got := strings.Join(seen, ","); got != "z,a,m,b" {
+		t.Errorf("decoded order = %q, want z,a,m,b", got)
+	}
+
+	// Updates keep the order and append new keys.
+	updated, err := SetPath(decoded, "c", Null())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated, err = DeletePath(updated, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(updated.Keys(), ","); got != "z,m,b,c" {
+		t.Errorf("after SetPath/DeletePath = %q, want z,m,b,c", got)
+	}
+	merged := Merge(decoded, OrderedObject([]string{"y", "a"}, map[string]Value{"y": Null(), "a": Null()}), MergeReplaceArrays)
+	if got := strings.Join(merged.Keys(), ","); got != "z,a,m,b,y" {
+		t.Errorf("after Merge = %q, want z,a,m,b,y", got)
+	}
+
+	// Properties added through AsObject come last, lexicographically.
+	decoded.AsObject()["d"] = Null()
+	decoded.AsObject()["c"] = Null()
+	delete(decoded.AsObject(), "m")
+	if got := strings.Join(decoded.Keys(), ","); got != "z,a,b,c,d" {
+		t.Errorf("after map updates = %q, want z,a,b,c,d", got)
+	}
+}
+
+func TestValueArrayHelpers(t *testing.T) {
+	arr := Array([]Value{Int32(1), Hole(), Int32(2), Int32(3)})
+	double := func(v Value) Value { return Double(v.AsNumber() * 2) }
+	odd := func(v Value) bool { return int(v.AsNumber())%2 == 1 }
+
+	mapped := arr.MapValues(double)
+	if got := mapped.AsArray(); len(got) != 4 || got[0].AsNumber() != 2 || !got[1].IsHole() || got[3].AsNumber() != 6 {
+		t.Errorf("MapValues = %#v", mapped)
+	}
+	if got := arr.Filter(odd).AsArray(); len(got) != 2 || got[0].AsNumber() != 1 || got[1].AsNumber() != 3 {
+		t.Errorf("Filter = %v", got)
+	}
+	if v, ok := arr.Find(func(v Value) bool { return v.AsNumber() > 1 }); !ok || v.AsNumber() != 2 {
+		t.Errorf("Find = %v, %v", v, ok)
+	}
+	if v, ok := arr.Find(func(Value) bool { return false }); ok || !v.IsUndefined() {
+		t.Errorf("Find miss = %v, %v", v, ok)
+	}
+
+	set := Value{typ: TypeSet, data: &JSSet{Values: []Value{Int32(1), Int32(2), Int32(3)}}}
+	collapsed := set.MapValues(func(Value) Value { return String("x") })
+	if collapsed.Type() != TypeSet || collapsed.Len() != 1 {
+		t.Errorf("MapValues on Set = %#v, want a one-member Set", collapsed)
+	}
+	halved := set.MapValues(func(v Value) Value { return Double(float64(int(v.AsNumber()) / 2)) })
+	if got := halved.elements(); len(got) != 2 || got[0].AsNumber() != 0 || got[1].AsNumber() != 1 {
+		t.Errorf("MapValues on Set = %v, want [0 1]", got)
+	}
+	empties := set.MapValues(func(Value) Value { return Array(nil) })
+	if empties.Len() != 3 {
+		t.Errorf("MapValues to empty arrays kept %d members, want 3", empties.Len())
+	}
+	if got := set.Filter(odd); got.Type() != TypeSet || got.Len() != 2 {
+		t.Errorf("Filter on Set = %#v", got)
+	}
+
+	if !String("abc").MapValues(double).IsUndefined() || !Null().Filter(odd).IsUndefined() {
+		t.Error("helpers on non-collections should return undefined")
+	}
+}
+
+func TestDateMillis(t *testing.T) {
+	tests := []struct {
+		ms      float64
+		want    int64
+		invalid bool
+	}{
+		{1700000000123, 1700000000123, false},
+		{1.9, 1, false},
+		{-1.9, -1, false},
+		{math.Copysign(0, -1), 0, false},
+		{-8.64e15, -8.64e15, false},
+		{8.64e15 + 1, 0, true},
+		{math.Inf(1), 0, true},
+		{math.NaN(), 0, true},
+	}
+	for _, tt := range tests {
+		v := DateFromMillis(tt.ms)
+		if got := v.AsUnixMilli(); got != tt.want || v.IsInvalidDate() != tt.invalid {
+			t.Errorf("DateFromMillis(%v): AsUnixMilli() = %d, invalid %v; want %d, %v", tt.ms, got, v.IsInvalidDate(), tt.want, tt.invalid)
+		}
+	}
+
+	d := DateFromMillis(1700000000123)
+	tokyo := time.FixedZone("JST", 9*3600)
+	if got := d.In(tokyo); got.Location() != tokyo || got.UnixMilli() != 1700000000123 || got.Hour() != 7 {
+		t.Errorf("In(JST) = %v", got)
+	}
+	if !Date(time.Date(2024, 1, 2, 3, 4, 5, 6_999_999, time.UTC)).AsDate().Equal(time.Date(2024, 1, 2, 3, 4, 5, 6e6, time.UTC)) {
+		t.Error("Date should drop sub-millisecond precision")
+	}
+
+	// Invalid Dates survive a round trip (Node: v8.serialize(new Date(NaN))).
+	invalid := DateFromMillis(math.NaN())
+	data, err := Serialize(invalid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := bytesToHex(data); got != "ff0f44000000000000f87f" {
+		t.Errorf("Serialize(Invalid Date) = %s", got)
+	}
+	back, err := Deserialize(data)
+	if err != nil || !back.IsInvalidDate() || !back.AsDate().IsZero() || back.String() != "Invalid Date" {
+		t.Errorf("round trip = %#v, %v", back, err)
+	}
+	if j, err := ToJSON(invalid); err != nil || string(j) != "null" {
+		t.Errorf("ToJSON(Invalid Date) = %s, %v", j, err)
+	}
+}
+
+func TestValueIsTruthy(t *testing.T) {
+	falsy := []Value{
+		Undefined(), Null(), Hole(), Bool(false), Int32(0), Uint32(0),
+		Double(math.Copysign(0, -1)), Double(math.NaN()), BigInt(big.NewInt(0)), String(""),
+	}
+	for _, v := range falsy {
+		if v.IsTruthy() {
+			t.Errorf("%#v.IsTruthy() = true, want false", v)
+		}
+	}
+	truthy := []Value{
+		Bool(true), Int32(-1), Double(math.Inf(1)), BigInt(big.NewInt(-3)), String("0"),
+		Object(map[string]Value{}), Array(nil), Date(time.Unix(0, 0)),
+		Value{typ: TypeBoxedPrimitive, data: &BoxedPrimitive{Value: Bool(false)}},
+	}
+	for _, v := range truthy {
+		if !v.IsTruthy() {
+			t.Errorf("%#v.IsTruthy() = false, want true", v)
+		}
+	}
+}
+
+func TestInternedNumbers(t *testing.T) {
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = Int32(-100)
+		_ = Int32(1000)
+		_ = Uint32(700)
+		_ = Double(512)
+	})
+	if allocs != 0 {
+		t.Errorf("small numbers allocated %v times", allocs)
+	}
+
+	if got := Double(math.Copysign(0, -1)).AsDouble(); !math.Signbit(got) {
+		t.Error("Double(-0) lost its sign")
+	}
+	if got := Double(2.5).AsDouble(); got != 2.5 {
+		t.Errorf("Double(2.5) = %v", got)
+	}
+	for _, n := range []int32{internMin, -1, 0, internMax - 1, internMax, math.MinInt32} {
+		if Int32(n).AsInt32() != n || Double(float64(n)).AsDouble() != float64(n) {
+			t.Errorf("round trip of %d changed the value", n)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package v8serialize
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ToTypeScript writes TypeScript declarations for the payloads described by
+// s, so the Node side of a SerializeGo contract can be type-checked against
+// what the Go side actually sends. The root is declared as Payload; nested
+// objects become interfaces named after their path:
+//
+//	export interface Payload {
+//	  id: number;
+//	  user?: PayloadUser;
+//	  tags: string[];
+//	}
+//
+//	export interface PayloadUser {
+//	  name: string;
+//	}
+//
+// Properties missing from some samples are optional. Objects observed
+// without properties, including those cut off at a cycle, are typed
+// Record<string, unknown>.
+func (s *Schema) ToTypeScript(w io.Writer) error {
+	g := &tsGenerator{used: make(map[string]bool)}
+	if len(s.Types) == 1 && s.Types[TypeObject] > 0 && len(s.Properties) > 0 {
+		g.declare("Payload", s)
+	} else {
+		g.used["Payload"] = true
+		fmt.Fprintf(&g.out, "export type Payload = %s;\n", g.union(s, "Payload"))
+	}
+	for len(g.queue) > 0 {
+		d := g.queue[0]
+		g.queue = g.queue[1:]
+		g.out.WriteByte('\n')
+		g.writeInterface(d.name, d.schema)
+	}
+	out := strings.TrimPrefix(g.out.String(), "\n")
+	_, err := io.WriteString(w, out)
+	return err
+}
+
+type schemaDecl struct {
+	name   string
+	schema *Schema
+}
+
+type tsGenerator struct {
+	out   strings.Builder
+	used  map[string]bool
+	queue []schemaDecl
+}
+
+// declare reserves a unique interface name for an object schema and queues
+// its declaration.
+func (g *tsGenerator) declare(name string, s *Schema) string {
+	unique := name
+	for n := 2; g.used[unique]; n++ {
+		unique = name + strconv.Itoa(n)
+	}
+	g.used[unique] = true
+	g.queue = append(g.queue, schemaDecl{unique, s})
+	return unique
+}
+
+func (g *tsGenerator) writeInterface(name string, s *Schema) {
+	keys := make([]string, 0, len(s.Properties))
+	for k := range s.Properties {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintf(&g.out, "export interface %s {\n", name)
+	for _, k := range keys {
+		prop := s.Properties[k]
+		key := k
+		if !isIdentifier(k) {
+			key = strconv.Quote(k)
+		}
+		opt := ""
+		if prop.Optional {
+			opt = "?"
+		}
+		fmt.Fprintf(&g.out, "  %s%s: %s;\n", key, opt, g.union(prop, name+pascalCase(k)))
+	}
+	g.out.WriteString("}\n")
+}
+
+// union returns the TypeScript type for every type observed in s; name is
+// used for interfaces declared for nested objects.
+func (g *tsGenerator) union(s *Schema, name string) string {
+	if s == nil || len(s.Types) == 0 {
+		return "unknown"
+	}
+	var parts []string
+	seen := make(map[string]bool)
+	for _, t := range s.typesByCount() {
+		ts := g.typeOf(t, s, name)
+		if ts != "" && !seen[ts] {
+			seen[ts] = true
+			parts = append(parts, ts)
+		}
+	}
+	if len(parts) == 0 {
+		return "unknown"
+	}
+	return strings.Join(parts, " | ")
+}
+
+func (g *tsGenerator) typeOf(t Type, s *Schema, name string) string {
+	switch t {
+	case TypeUndefined:
+		return "undefined"
+	case TypeNull:
+		return "null"
+	case TypeBool:
+		return "boolean"
+	case TypeInt32, TypeUint32, TypeDouble:
+		return "number"
+	case TypeBigInt:
+		return "bigint"
+	case TypeString:
+		return "string"
+	case TypeDate:
+		return "Date"
+	case TypeRegExp:
+		return "RegExp"
+	case TypeObject:
+		if len(s.Properties) == 0 {
+			return "Record<string, unknown>"
+		}
+		return g.declare(name, s)
+	case TypeArray:
+		return tsArray(g.union(s.Elements, name+"Item"))
+	case TypeSet:
+		return "Set<" + g.union(s.Elements, name+"Item") + ">"
+	case TypeMap:
+		return "Map<" + g.union(s.Keys, name+"Key") + ", " + g.union(s.Values, name+"Value") + ">"
+	case TypeArrayBuffer:
+		return "ArrayBuffer"
+	case TypeTypedArray:
+		return "ArrayBufferView"
+	case TypeDataView:
+		return "DataView"
+	case TypeDetachedArrayBuffer:
+		return "ArrayBuffer | ArrayBufferView"
+	case TypeSharedArrayBuffer:
+		return "SharedArrayBuffer | ArrayBufferView"
+	case TypeError:
+		return "Error"
+	case TypeBoxedPrimitive:
+		return "Number | String | Boolean | BigInt"
+	}
+	return "" // holes are absence, not a type
+}
+
+func tsArray(elem string) string {
+	if strings.ContainsAny(elem, " |<") {
+		return "Array<" + elem + ">"
+	}
+	return elem + "[]"
+}
+
+// pascalCase turns a property name into a type or field name fragment:
+// "user_name" and "user-name" become "UserName".
+func pascalCase(key string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range key {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
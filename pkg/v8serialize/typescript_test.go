@@ -0,0 +1,59 @@
+package v8serialize
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSchemaToTypeScript(t *testing.T) {
+	samples := []Value{
+		Object(map[string]Value{
+			"id":   Int32(1),
+			"user": Object(map[string]Value{"name": String("Ana"), "last-seen": Date(time.Unix(0, 0))}),
+			"tags": Array([]Value{String("a")}),
+			"ids":  Array([]Value{Int32(1), Null()}),
+		}),
+		Object(map[string]Value{
+			"id":   Double(2.5),
+			"tags": Array(nil),
+			"ids":  Array(nil),
+		}),
+	}
+	var b strings.Builder
+	if err := InferSchema(samples...).ToTypeScript(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `export interface Payload {
+  id: number;
+  ids: Array<null | number>;
+  tags: string[];
+  user?: PayloadUser;
+}
+
+export interface PayloadUser {
+  "last-seen": Date;
+  name: string;
+}
+`
+	if got := b.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSchemaToTypeScriptNonObjectRoot(t *testing.T) {
+	s := InferSchema(Array([]Value{Object(map[string]Value{"x": Int32(1)})}), String("s"))
+	var b strings.Builder
+	if err := s.ToTypeScript(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `export type Payload = string | PayloadItem[];
+
+export interface PayloadItem {
+  x: number;
+}
+`
+	if got := b.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
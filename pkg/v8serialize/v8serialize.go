@@ -34,8 +34,8 @@
 // # Limitations
 //
 // The serializer does not currently support circular references. Attempting to
-// serialize an object graph with cycles will cause a stack overflow. The deserializer
-// fully supports circular references.
+// serialize an object graph with cycles fails with ErrMaxDepthExceeded. The
+// deserializer fully supports circular references.
 //
 // ResizableArrayBuffer (V8 v14+) is not yet supported.
 //
@@ -45,11 +45,129 @@
 package v8serialize
 
 import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"reflect"
+	"slices"
+
+	"github.com/acolita/v8wire/internal/wire"
+)
+
+// JSUndefined and JSHole are returned by ToGo in place of nil for undefined
+// values and array holes when WithNullishSentinels is set, so that the three
+// nullish states (null, undefined, hole) can be told apart.
+var (
+	JSUndefined interface{} = jsUndefined{}
+	JSHole      interface{} = jsHole{}
 )
 
+type jsUndefined struct{}
+
+func (jsUndefined) String() string { return "undefined" }
+
+type jsHole struct{}
+
+func (jsHole) String() string { return "<hole>" }
+
+// ToGoOption configures ToGo.
+type ToGoOption func(*goConverter)
+
+// WithNullishSentinels makes ToGo return JSUndefined for undefined and
+// JSHole for array holes instead of nil. null still converts to nil.
+func WithNullishSentinels() ToGoOption {
+	return func(c *goConverter) {
+		c.sentinels = true
+	}
+}
+
+// WithTypedArraySlices makes ToGo return native Go slices for TypedArrays
+// instead of *ArrayBufferView, decoding the little-endian elements:
+//   - Int8Array → []int8
+//   - Uint8Array, Uint8ClampedArray, Buffer → []uint8
+//   - Int16Array → []int16, Uint16Array → []uint16
+//   - Int32Array → []int32, Uint32Array → []uint32
+//   - Float32Array → []float32, Float64Array → []float64
+//   - BigInt64Array → []int64, BigUint64Array → []uint64
+//
+// DataViews, Float16Arrays and views whose length is not a multiple of the
+// element size are still returned as *ArrayBufferView.
+func WithTypedArraySlices() ToGoOption {
+	return func(c *goConverter) {
+		c.typedSlices = true
+	}
+}
+
+// MapEntryGo is a converted Map entry, as returned by ToGo with
+// WithOrderedMaps.
+type MapEntryGo struct {
+	Key   interface{}
+	Value interface{}
+}
+
+// WithOrderedMaps makes ToGo return Maps as []MapEntryGo in insertion
+// order instead of map[interface{}]interface{}. Keys of any type are kept,
+// including objects and arrays that cannot be Go map keys.
+func WithOrderedMaps() ToGoOption {
+	return WithMapFunc(func(entries []MapEntryGo) interface{} {
+		return entries
+	})
+}
+
+// WithMapFunc makes ToGo pass the entries of each Map, in insertion order,
+// to build and use its return value as the converted Map. Use it to produce
+// an ordered-map type of your choice.
+func WithMapFunc(build func(entries []MapEntryGo) interface{}) ToGoOption {
+	return func(c *goConverter) {
+		c.buildMap = build
+	}
+}
+
+// CyclePolicy controls how ToGo converts a reference from a container
+// back to one of its own ancestors.
+type CyclePolicy uint8
+
+const (
+	// CycleShare converts back-references to the Go map or slice under
+	// construction, so the result is cyclic like the input (the default).
+	// Maps converted by a WithMapFunc builder do not exist until all their
+	// entries are converted; back-references to them become nil.
+	CycleShare CyclePolicy = iota
+	// CycleNil converts back-references to nil, so the result is a tree
+	// that can be walked or encoded without cycle detection.
+	CycleNil
+	// CycleError makes ToGoSafe fail with ErrCyclic. ToGo, which cannot
+	// fail, converts back-references to nil as with CycleNil.
+	CycleError
+)
+
+// ErrCyclic is returned by ToGoSafe for cyclic values under CycleError.
+var ErrCyclic = errors.New("v8serialize: cyclic value")
+
+// WithCyclePolicy sets how ToGo converts cycles.
+func WithCyclePolicy(p CyclePolicy) ToGoOption {
+	return func(c *goConverter) {
+		c.cycles = p
+	}
+}
+
+// goConverter holds the state of a single ToGo conversion.
+type goConverter struct {
+	done        map[uintptr]interface{} // converted containers, by identity
+	active      map[uintptr]bool        // containers being converted
+	cycles      CyclePolicy
+	sentinels   bool
+	typedSlices bool
+	buildMap    func([]MapEntryGo) interface{}
+	strict      bool  // fail on unhashable Map keys instead of falling back
+	err         error // first error in strict mode
+}
+
 // ToGo converts a Value to its closest Go equivalent:
-//   - null, undefined, hole → nil
+//   - null, undefined, hole → nil (see WithNullishSentinels)
 //   - boolean → bool
 //   - int32 → int32
 //   - uint32 → uint32
@@ -59,19 +177,123 @@ import (
 //   - Date → time.Time
 //   - Array → []interface{}
 //   - Object → map[string]interface{}
-//   - Map → map[interface{}]interface{} (note: non-string keys)
+//   - Map → map[interface{}]interface{} (note: non-string keys; see WithOrderedMaps),
+//     or []MapEntryGo if a key converts to an unhashable Go value such as a
+//     map or slice (see ToGoSafe)
 //   - Set → []interface{}
 //   - ArrayBuffer → []byte
-//   - TypedArray → *ArrayBufferView
+//   - TypedArray → *ArrayBufferView (see WithTypedArraySlices)
 //   - RegExp → *RegExp
 //   - BoxedPrimitive → *BoxedPrimitive
-func ToGo(v Value) interface{} {
-	return toGo(v, make(map[*Value]interface{}))
+//
+// A container reached through several references, as back-references in a
+// payload produce, is converted once and the result shared, so payloads
+// that reuse one large value many times do not expand. Cycles are kept as
+// cycles between the converted maps and slices; see WithCyclePolicy.
+func ToGo(v Value, opts ...ToGoOption) interface{} {
+	c := newGoConverter()
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c.toGo(v)
+}
+
+// ToGoSafe is ToGo for callers that need a map[interface{}]interface{} for
+// every Map: instead of falling back to []MapEntryGo, it fails with
+// ErrUnhashable when a Map key converts to an unhashable Go value (an
+// object, array, Set or binary key). Under CycleError it also fails with
+// ErrCyclic for cyclic values.
+func ToGoSafe(v Value, opts ...ToGoOption) (interface{}, error) {
+	c := newGoConverter()
+	c.strict = true
+	for _, opt := range opts {
+		opt(c)
+	}
+	result := c.toGo(v)
+	if c.err != nil {
+		return nil, c.err
+	}
+	return result, nil
+}
+
+func newGoConverter() *goConverter {
+	return &goConverter{
+		done:   make(map[uintptr]interface{}),
+		active: make(map[uintptr]bool),
+	}
+}
+
+// mapEntries converts m to an entry list, for Maps whose keys cannot all be
+// Go map keys.
+func (c *goConverter) mapEntries(m *JSMap) interface{} {
+	entries := make([]MapEntryGo, len(m.Entries))
+	for j, entry := range m.Entries {
+		entries[j] = MapEntryGo{Key: c.toGo(entry.Key), Value: c.toGo(entry.Value)}
+	}
+	return entries
+}
+
+// hashable reports whether k can be used as a Go map key without panicking.
+func hashable(k interface{}) bool {
+	return k == nil || reflect.TypeOf(k).Comparable()
+}
+
+// toGo converts v, sharing the result between references to the same
+// container. Maps and slices are registered by convert before their
+// children are converted, so a back-reference met while a container is
+// active finds the result under construction.
+func (c *goConverter) toGo(v Value) interface{} {
+	id, ok := containerID(v)
+	if !ok {
+		return c.convert(v, 0)
+	}
+	if c.active[id] {
+		return c.cycle(v, id)
+	}
+	if result, ok := c.done[id]; ok {
+		return result
+	}
+	c.active[id] = true
+	result := c.convert(v, id)
+	delete(c.active, id)
+	c.done[id] = result
+	return result
+}
+
+// cycle converts a back-reference to the active container id.
+func (c *goConverter) cycle(v Value, id uintptr) interface{} {
+	switch c.cycles {
+	case CycleShare:
+		return c.done[id] // nil if the container is not built yet
+	case CycleError:
+		if c.strict && c.err == nil {
+			c.err = fmt.Errorf("%w: %s refers to itself", ErrCyclic, v.Type())
+		}
+	}
+	return nil
+}
+
+// register records result as the conversion of container id before its
+// children are converted.
+func (c *goConverter) register(id uintptr, result interface{}) {
+	if id != 0 {
+		c.done[id] = result
+	}
 }
 
-func toGo(v Value, seen map[*Value]interface{}) interface{} {
+func (c *goConverter) convert(v Value, id uintptr) interface{} {
 	switch v.Type() {
-	case TypeUndefined, TypeNull, TypeHole:
+	case TypeNull:
+		return nil
+	case TypeUndefined:
+		if c.sentinels {
+			return JSUndefined
+		}
+		return nil
+	case TypeHole:
+		if c.sentinels {
+			return JSHole
+		}
 		return nil
 	case TypeBool:
 		return v.AsBool()
@@ -90,41 +312,63 @@ func toGo(v Value, seen map[*Value]interface{}) interface{} {
 	case TypeObject:
 		obj := v.AsObject()
 		result := make(map[string]interface{}, len(obj))
+		c.register(id, result)
 		for k, val := range obj {
-			result[k] = toGo(val, seen)
+			result[k] = c.toGo(val)
 		}
 		return result
 	case TypeArray:
 		arr := v.AsArray()
 		result := make([]interface{}, len(arr))
+		c.register(id, result)
 		for i, val := range arr {
-			if val.IsHole() {
-				result[i] = nil // or could use a sentinel
-			} else {
-				result[i] = toGo(val, seen)
-			}
+			result[i] = c.toGo(val)
 		}
 		return result
 	case TypeMap:
 		m := v.Interface().(*JSMap)
+		if c.buildMap != nil {
+			entries := make([]MapEntryGo, len(m.Entries))
+			for i, entry := range m.Entries {
+				entries[i] = MapEntryGo{Key: c.toGo(entry.Key), Value: c.toGo(entry.Value)}
+			}
+			return c.buildMap(entries)
+		}
 		result := make(map[interface{}]interface{}, len(m.Entries))
+		c.register(id, result)
 		for _, entry := range m.Entries {
-			k := toGo(entry.Key, seen)
-			val := toGo(entry.Value, seen)
-			result[k] = val
+			k := c.toGo(entry.Key)
+			if !hashable(k) {
+				if c.strict {
+					if c.err == nil {
+						c.err = fmt.Errorf("%w: Map key of type %s", ErrUnhashable, entry.Key.Type())
+					}
+					return nil
+				}
+				delete(c.done, id) // later back-references cannot reach the entry list
+				return c.mapEntries(m)
+			}
+			result[k] = c.toGo(entry.Value)
 		}
 		return result
 	case TypeSet:
 		s := v.Interface().(*JSSet)
 		result := make([]interface{}, len(s.Values))
+		c.register(id, result)
 		for i, val := range s.Values {
-			result[i] = toGo(val, seen)
+			result[i] = c.toGo(val)
 		}
 		return result
 	case TypeArrayBuffer:
 		return v.Interface().([]byte)
 	case TypeTypedArray:
-		return v.Interface().(*ArrayBufferView)
+		view := v.Interface().(*ArrayBufferView)
+		if c.typedSlices {
+			if slice, ok := typedArraySlice(view); ok {
+				return slice
+			}
+		}
+		return view
 	case TypeRegExp:
 		return v.Interface().(*RegExp)
 	case TypeBoxedPrimitive:
@@ -134,6 +378,93 @@ func toGo(v Value, seen map[*Value]interface{}) interface{} {
 	}
 }
 
+// typedArraySlice decodes the elements of a TypedArray into a Go slice.
+func typedArraySlice(view *ArrayBufferView) (interface{}, bool) {
+	data := view.Slice()
+	size := typedArrayElementSize(view.Type)
+	if size == 0 || len(data)%size != 0 {
+		return nil, false
+	}
+	n := len(data) / size
+	le := binary.LittleEndian
+
+	switch view.Type {
+	case "Int8Array":
+		out := make([]int8, n)
+		for i := range out {
+			out[i] = int8(data[i])
+		}
+		return out, true
+	case "Uint8Array", "Uint8ClampedArray", "Buffer":
+		return append([]uint8(nil), data...), true
+	case "Int16Array":
+		out := make([]int16, n)
+		for i := range out {
+			out[i] = int16(le.Uint16(data[i*2:]))
+		}
+		return out, true
+	case "Uint16Array":
+		out := make([]uint16, n)
+		for i := range out {
+			out[i] = le.Uint16(data[i*2:])
+		}
+		return out, true
+	case "Int32Array":
+		out := make([]int32, n)
+		for i := range out {
+			out[i] = int32(le.Uint32(data[i*4:]))
+		}
+		return out, true
+	case "Uint32Array":
+		out := make([]uint32, n)
+		for i := range out {
+			out[i] = le.Uint32(data[i*4:])
+		}
+		return out, true
+	case "Float32Array":
+		out := make([]float32, n)
+		for i := range out {
+			out[i] = math.Float32frombits(le.Uint32(data[i*4:]))
+		}
+		return out, true
+	case "Float64Array":
+		out := make([]float64, n)
+		for i := range out {
+			out[i] = math.Float64frombits(le.Uint64(data[i*8:]))
+		}
+		return out, true
+	case "BigInt64Array":
+		out := make([]int64, n)
+		for i := range out {
+			out[i] = int64(le.Uint64(data[i*8:]))
+		}
+		return out, true
+	default: // BigUint64Array
+		out := make([]uint64, n)
+		for i := range out {
+			out[i] = le.Uint64(data[i*8:])
+		}
+		return out, true
+	}
+}
+
+// typedArrayElementSize returns the element size in bytes of a TypedArray
+// kind that typedArraySlice can decode, or 0.
+func typedArrayElementSize(typeName string) int {
+	switch typeName {
+	case "Int8Array", "Uint8Array", "Uint8ClampedArray", "Buffer":
+		return 1
+	case "Int16Array", "Uint16Array", "Float16Array":
+		return 2
+	case "Int32Array", "Uint32Array", "Float32Array":
+		return 4
+	case "Float64Array", "BigInt64Array", "BigUint64Array":
+		return 8
+	default:
+		return 0
+	}
+}
+
 // MustDeserialize deserializes V8 data and panics on error.
 // Use this only when you're certain the data is valid.
 func MustDeserialize(data []byte) Value {
@@ -144,6 +475,43 @@ func MustDeserialize(data []byte) Value {
 	return v
 }
 
+// DetectVersion reads the serialization format version from the header of
+// data without decoding the payload. Unlike Deserialize it does not reject
+// versions outside MinVersion..MaxVersion, so callers can route legacy or
+// newer payloads themselves; only a missing or malformed header is an error.
+func DetectVersion(data []byte) (uint32, error) {
+	return readVersionHeader(wire.NewReader(data))
+}
+
+// SupportedVersions returns the format versions this package can decode,
+// in ascending order.
+func SupportedVersions() []uint32 {
+	versions := make([]uint32, 0, MaxVersion-MinVersion+1)
+	for v := uint32(MinVersion); v <= MaxVersion; v++ {
+		versions = append(versions, v)
+	}
+	return versions
+}
+
+// NegotiateVersion returns the highest format version listed in both mine
+// and theirs, so two peers exchanging their supported versions (e.g. from
+// SupportedVersions) can agree on one to write with WithSerializeVersion.
+// It fails with ErrUnsupportedVersion if the lists have no version in
+// common.
+func NegotiateVersion(mine, theirs []uint32) (uint32, error) {
+	var best uint32
+	found := false
+	for _, v := range mine {
+		if (!found || v > best) && slices.Contains(theirs, v) {
+			best, found = v, true
+		}
+	}
+	if !found {
+		return 0, fmt.Errorf("%w: no common version between %v and %v", ErrUnsupportedVersion, mine, theirs)
+	}
+	return best, nil
+}
+
 // IsValidV8Data checks if the data starts with a valid V8 serialization header.
 // This is a quick check and doesn't validate the entire payload.
 func IsValidV8Data(data []byte) bool {
@@ -161,3 +529,67 @@ func IsValidV8Data(data []byte) bool {
 	}
 	return version >= MinVersion && version <= MaxVersion
 }
+
+// Format identifies the encoding of a payload, as reported by DetectFormat.
+type Format uint8
+
+const (
+	FormatUnknown Format = iota // none of the formats below
+	FormatV8                    // V8 structured clone (v8.serialize, "advanced" IPC payloads)
+	FormatJSON                  // a single JSON document
+	FormatNodeIPC               // Node's child_process "json" IPC framing: newline-terminated JSON messages
+)
+
+// String returns the format name.
+func (f Format) String() string {
+	switch f {
+	case FormatV8:
+		return "v8"
+	case FormatJSON:
+		return "json"
+	case FormatNodeIPC:
+		return "node-ipc"
+	default:
+		return "unknown"
+	}
+}
+
+// DetectFormat reports how data is encoded, so endpoints that accept more
+// than one format can route a payload with a single call. Any V8 header is
+// FormatV8, whatever its version (see DetectVersion). Data made of one or
+// more single-line JSON values, each terminated by a newline, is
+// FormatNodeIPC; note that this includes a single compact JSON document
+// with a trailing newline. Any other valid JSON document is FormatJSON. The
+// JSON checks validate the whole input, so DetectFormat is linear in its
+// size.
+func DetectFormat(data []byte) Format {
+	if len(data) > 0 && data[0] == tagVersion {
+		if _, err := DetectVersion(data); err == nil {
+			return FormatV8
+		}
+		return FormatUnknown
+	}
+	if isNodeIPC(data) {
+		return FormatNodeIPC
+	}
+	if json.Valid(data) {
+		return FormatJSON
+	}
+	return FormatUnknown
+}
+
+// isNodeIPC reports whether data is a sequence of newline-terminated
+// single-line JSON messages, as written by child_process.send with the
+// default "json" serialization.
+func isNodeIPC(data []byte) bool {
+	if len(data) == 0 || data[len(data)-1] != '\n' {
+		return false
+	}
+	for _, line := range bytes.Split(data[:len(data)-1], []byte{'\n'}) {
+		line = bytes.TrimSuffix(line, []byte{'\r'})
+		if len(bytes.TrimSpace(line)) == 0 || !json.Valid(line) {
+			return false
+		}
+	}
+	return true
+}
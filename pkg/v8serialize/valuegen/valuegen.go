@@ -0,0 +1,309 @@
+// Package valuegen generates random valid Value trees for property-based
+// tests of code that encodes, decodes or transforms payloads:
+//
+//	func TestCodec(t *testing.T) {
+//		g := valuegen.New(rand.New(rand.NewSource(1)), valuegen.Config{MaxDepth: 3})
+//		for i := 0; i < 1000; i++ {
+//			v := g.Value()
+//			// encode v with your codec, decode it, compare with v8serialize.Key
+//		}
+//	}
+//
+// Arbitrary adapts a Generator to testing/quick.
+//
+// Only types with exported constructors are generated: primitives,
+// BigInts, strings, Dates, objects, arrays with holes, ArrayBuffers and
+// views (some sharing a buffer). Maps, Sets, RegExps, Errors and boxed
+// primitives only come from decoding and are not generated.
+package valuegen
+
+import (
+	"math"
+	"math/big"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/acolita/v8wire/pkg/v8serialize"
+)
+
+// Config controls the shape of generated values. Zero fields take their
+// defaults.
+type Config struct {
+	// MaxDepth is the maximum container nesting (default 4). Containers
+	// are not generated below it.
+	MaxDepth int
+	// MaxLen bounds array lengths, property counts, string lengths and
+	// buffer sizes (default 8).
+	MaxLen int
+	// Weights sets the relative frequency of each type; types with zero
+	// weight are never generated. Unsupported types are ignored. The
+	// default weighs every supported type equally.
+	Weights map[v8serialize.Type]int
+}
+
+// Types lists the types a Generator can produce.
+var Types = []v8serialize.Type{
+	v8serialize.TypeUndefined,
+	v8serialize.TypeNull,
+	v8serialize.TypeBool,
+	v8serialize.TypeInt32,
+	v8serialize.TypeUint32,
+	v8serialize.TypeDouble,
+	v8serialize.TypeBigInt,
+	v8serialize.TypeString,
+	v8serialize.TypeDate,
+	v8serialize.TypeObject,
+	v8serialize.TypeArray,
+	v8serialize.TypeArrayBuffer,
+	v8serialize.TypeTypedArray,
+}
+
+// Generator produces random Values. It is not safe for concurrent use.
+type Generator struct {
+	r        *rand.Rand
+	maxDepth int
+	maxLen   int
+	types    []v8serialize.Type // leaf and container types with positive weight
+	weights  []int
+	total    int
+	buffers  []v8serialize.Value // ArrayBuffers of the current tree, for shared views
+}
+
+// New returns a Generator drawing from r.
+func New(r *rand.Rand, cfg Config) *Generator {
+	g := &Generator{r: r, maxDepth: cfg.MaxDepth, maxLen: cfg.MaxLen}
+	if g.maxDepth <= 0 {
+		g.maxDepth = 4
+	}
+	if g.maxLen <= 0 {
+		g.maxLen = 8
+	}
+	for _, typ := range Types {
+		w := 1
+		if cfg.Weights != nil {
+			w = cfg.Weights[typ]
+		}
+		if w > 0 {
+			g.types = append(g.types, typ)
+			g.weights = append(g.weights, w)
+			g.total += w
+		}
+	}
+	return g
+}
+
+// Value returns a new random value tree.
+func (g *Generator) Value() v8serialize.Value {
+	g.buffers = g.buffers[:0]
+	return g.value(0)
+}
+
+func (g *Generator) value(depth int) v8serialize.Value {
+	typ := g.pick(depth < g.maxDepth)
+	switch typ {
+	case v8serialize.TypeNull:
+		return v8serialize.Null()
+	case v8serialize.TypeBool:
+		return v8serialize.Bool(g.r.Intn(2) == 0)
+	case v8serialize.TypeInt32:
+		return v8serialize.Int32(g.int32())
+	case v8serialize.TypeUint32:
+		return v8serialize.Uint32(g.r.Uint32())
+	case v8serialize.TypeDouble:
+		return v8serialize.Double(g.double())
+	case v8serialize.TypeBigInt:
+		n := new(big.Int).Rand(g.r, new(big.Int).Lsh(big.NewInt(1), uint(g.r.Intn(130))))
+		if g.r.Intn(2) == 0 {
+			n.Neg(n)
+		}
+		return v8serialize.BigInt(n)
+	case v8serialize.TypeString:
+		return v8serialize.String(g.string())
+	case v8serialize.TypeDate:
+		if g.r.Intn(8) == 0 {
+			return v8serialize.DateFromMillis(math.NaN())
+		}
+		return v8serialize.DateFromMillis(float64(g.r.Int63n(2*8.64e15+1) - 8.64e15))
+	case v8serialize.TypeObject:
+		return g.object(depth)
+	case v8serialize.TypeArray:
+		return g.array(depth)
+	case v8serialize.TypeArrayBuffer:
+		return g.buffer()
+	case v8serialize.TypeTypedArray:
+		return g.view()
+	}
+	return v8serialize.Undefined()
+}
+
+// pick chooses a type by weight, skipping containers when they are not
+// allowed. It falls back to undefined if no allowed type has weight.
+func (g *Generator) pick(containers bool) v8serialize.Type {
+	total := g.total
+	if !containers {
+		for i, typ := range g.types {
+			if isContainer(typ) {
+				total -= g.weights[i]
+			}
+		}
+	}
+	if total <= 0 {
+		return v8serialize.TypeUndefined
+	}
+	n := g.r.Intn(total)
+	for i, typ := range g.types {
+		if !containers && isContainer(typ) {
+			continue
+		}
+		if n < g.weights[i] {
+			return typ
+		}
+		n -= g.weights[i]
+	}
+	return v8serialize.TypeUndefined
+}
+
+func isContainer(typ v8serialize.Type) bool {
+	return typ == v8serialize.TypeObject || typ == v8serialize.TypeArray
+}
+
+// int32 favors small values and the edges of the range.
+func (g *Generator) int32() int32 {
+	switch g.r.Intn(4) {
+	case 0:
+		return int32(g.r.Intn(201) - 100)
+	case 1:
+		return []int32{0, -1, math.MaxInt32, math.MinInt32}[g.r.Intn(4)]
+	}
+	return int32(g.r.Uint32())
+}
+
+// double includes the values codecs tend to get wrong.
+func (g *Generator) double() float64 {
+	switch g.r.Intn(4) {
+	case 0:
+		special := []float64{0, math.Copysign(0, -1), math.NaN(), math.Inf(1), math.Inf(-1),
+			math.MaxFloat64, math.SmallestNonzeroFloat64, 1 << 53, -(1 << 53)}
+		return special[g.r.Intn(len(special))]
+	case 1:
+		return float64(g.r.Intn(2001) - 1000)
+	}
+	return math.Float64frombits(g.r.Uint64())
+}
+
+// string mixes ASCII, Latin-1, BMP and astral characters, so both one-byte
+// and two-byte encodings (with surrogate pairs) are exercised.
+func (g *Generator) string() string {
+	ranges := [][2]rune{{0x20, 0x7e}, {0x00, 0xff}, {0x100, 0xd7ff}, {0xe000, 0xfffd}, {0x10000, 0x10ffff}}
+	kinds := ranges[:1+g.r.Intn(len(ranges))]
+	var b strings.Builder
+	for n := g.r.Intn(g.maxLen + 1); n > 0; n-- {
+		k := kinds[g.r.Intn(len(kinds))]
+		b.WriteRune(k[0] + rune(g.r.Intn(int(k[1]-k[0]+1))))
+	}
+	return b.String()
+}
+
+// key returns a property name, sometimes an array-index-like one.
+func (g *Generator) key() string {
+	if g.r.Intn(4) == 0 {
+		return []string{"0", "1", "42", "4294967294", "-1", "01"}[g.r.Intn(6)]
+	}
+	return g.string()
+}
+
+func (g *Generator) object(depth int) v8serialize.Value {
+	props := make(map[string]v8serialize.Value)
+	var keys []string
+	for n := g.r.Intn(g.maxLen + 1); n > 0; n-- {
+		k := g.key()
+		if _, ok := props[k]; !ok {
+			keys = append(keys, k)
+		}
+		props[k] = g.value(depth + 1)
+	}
+	if g.r.Intn(2) == 0 {
+		sort.Strings(keys)
+	}
+	return v8serialize.OrderedObject(keys, props)
+}
+
+func (g *Generator) array(depth int) v8serialize.Value {
+	elems := make([]v8serialize.Value, g.r.Intn(g.maxLen+1))
+	holes := g.r.Intn(3) == 0
+	for i := range elems {
+		if holes && g.r.Intn(2) == 0 {
+			elems[i] = v8serialize.Hole()
+			continue
+		}
+		elems[i] = g.value(depth + 1)
+	}
+	return v8serialize.Array(elems)
+}
+
+func (g *Generator) buffer() v8serialize.Value {
+	data := make([]byte, g.r.Intn(g.maxLen+1))
+	g.r.Read(data)
+	buf := v8serialize.ArrayBuffer(data)
+	g.buffers = append(g.buffers, buf)
+	return buf
+}
+
+var viewKinds = []string{
+	"Int8Array", "Uint8Array", "Uint8ClampedArray", "Int16Array", "Uint16Array",
+	"Int32Array", "Uint32Array", "Float32Array", "Float64Array",
+	"BigInt64Array", "BigUint64Array", "DataView",
+}
+
+// view returns a view over a new buffer or, sometimes, one already in the
+// tree, so shared buffers are exercised.
+func (g *Generator) view() v8serialize.Value {
+	kind := viewKinds[g.r.Intn(len(viewKinds))]
+	size := elementSizes[kind]
+
+	var buf v8serialize.Value
+	if len(g.buffers) > 0 && g.r.Intn(2) == 0 {
+		buf = g.buffers[g.r.Intn(len(g.buffers))]
+	} else {
+		data := make([]byte, size*g.r.Intn(g.maxLen+1))
+		g.r.Read(data)
+		buf = v8serialize.ArrayBuffer(data)
+		g.buffers = append(g.buffers, buf)
+	}
+
+	elems := len(buf.Interface().([]byte)) / size
+	start := g.r.Intn(elems + 1)
+	end := start + g.r.Intn(elems-start+1)
+	v, err := v8serialize.NewView(buf, kind, start*size, (end-start)*size)
+	if err != nil {
+		panic("valuegen: " + err.Error()) // offsets are aligned and in range
+	}
+	return v
+}
+
+// elementSizes gives the element size of each view kind.
+var elementSizes = map[string]int{
+	"Int8Array": 1, "Uint8Array": 1, "Uint8ClampedArray": 1, "DataView": 1,
+	"Int16Array": 2, "Uint16Array": 2,
+	"Int32Array": 4, "Uint32Array": 4, "Float32Array": 4,
+	"Float64Array": 8, "BigInt64Array": 8, "BigUint64Array": 8,
+}
+
+// Arbitrary is a Value that implements quick.Generator, for use with
+// testing/quick:
+//
+//	quick.Check(func(a valuegen.Arbitrary) bool { ... a.Value ... }, nil)
+//
+// The size hint bounds MaxLen, up to its default; other settings take
+// their defaults.
+type Arbitrary struct {
+	Value v8serialize.Value
+}
+
+// Generate implements quick.Generator.
+func (Arbitrary) Generate(r *rand.Rand, size int) reflect.Value {
+	g := New(r, Config{MaxLen: min(size, 8)})
+	return reflect.ValueOf(Arbitrary{Value: g.Value()})
+}
@@ -0,0 +1,80 @@
+package valuegen
+
+import (
+	"math/rand"
+	"testing"
+	"testing/quick"
+
+	"github.com/acolita/v8wire/pkg/v8serialize"
+)
+
+// roundTrips reports whether v survives Serialize and Deserialize with the
+// same structural key.
+func roundTrips(t *testing.T, v v8serialize.Value) bool {
+	t.Helper()
+	data, err := v8serialize.Serialize(v)
+	if err != nil {
+		t.Logf("Serialize(%#v) failed: %v", v, err)
+		return false
+	}
+	got, err := v8serialize.Deserialize(data)
+	if err != nil {
+		t.Logf("Deserialize(%x) failed: %v", data, err)
+		return false
+	}
+	want, _ := v8serialize.Key(v)
+	have, _ := v8serialize.Key(got)
+	if want != have {
+		t.Logf("round trip changed the value:\n want %s\n  got %s", want, have)
+		return false
+	}
+	return true
+}
+
+func TestRoundTrip(t *testing.T) {
+	g := New(rand.New(rand.NewSource(1)), Config{})
+	for i := 0; i < 2000; i++ {
+		if !roundTrips(t, g.Value()) {
+			t.Fatalf("value %d did not round-trip", i)
+		}
+	}
+}
+
+func TestRoundTripQuick(t *testing.T) {
+	f := func(a Arbitrary) bool { return roundTrips(t, a.Value) }
+	if err := quick.Check(f, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWeights(t *testing.T) {
+	g := New(rand.New(rand.NewSource(1)), Config{
+		Weights: map[v8serialize.Type]int{v8serialize.TypeArray: 1, v8serialize.TypeString: 3},
+	})
+	for i := 0; i < 100; i++ {
+		v := g.Value()
+		if v.IsString() {
+			continue
+		}
+		if !v.IsArray() {
+			t.Fatalf("value %d has type %s, want array or string", i, v.Type())
+		}
+		for _, elem := range v.AsArray() {
+			if !elem.IsArray() && !elem.IsString() && elem.Type() != v8serialize.TypeHole {
+				t.Fatalf("element of value %d has type %s", i, elem.Type())
+			}
+		}
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	a := New(rand.New(rand.NewSource(7)), Config{})
+	b := New(rand.New(rand.NewSource(7)), Config{})
+	for i := 0; i < 50; i++ {
+		ka, _ := v8serialize.Key(a.Value())
+		kb, _ := v8serialize.Key(b.Value())
+		if ka != kb {
+			t.Fatalf("value %d differs for the same seed", i)
+		}
+	}
+}
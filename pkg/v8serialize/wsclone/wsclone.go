@@ -0,0 +1,191 @@
+// Package wsclone exchanges structured-clone payloads over WebSocket
+// connections, one value per binary message, so Go backends and browser
+// clients using structuredClone/postMessage-style data agree on framing.
+//
+// A message is either a plain payload, starting with the 0xFF version tag,
+// or a zlib stream of one, starting with 0x78. Receivers tell them apart
+// by the first byte, so compression needs no negotiation; browsers can
+// inflate with DecompressionStream("deflate").
+//
+// Conn wraps any connection with gorilla/websocket's ReadMessage and
+// WriteMessage methods; other libraries, such as nhooyr.io/websocket, need
+// a two-method adapter:
+//
+//	ws, _, err := websocket.DefaultDialer.Dial(url, http.Header{
+//		"Sec-WebSocket-Protocol": {wsclone.Subprotocol},
+//	})
+//	conn := wsclone.New(ws, wsclone.WithCompression(1024),
+//		wsclone.WithDecodeOptions(v8serialize.WithLimits(v8serialize.DefaultLimits())))
+//	err = conn.WriteValue(v)
+//	v, err = conn.ReadValue()
+package wsclone
+
+import (
+	"compress/zlib"
+	"errors"
+	"io"
+
+	"github.com/acolita/v8wire/internal/msgcodec"
+	"github.com/acolita/v8wire/pkg/v8serialize"
+)
+
+// Subprotocol is the WebSocket subprotocol name for connections carrying
+// structured-clone messages.
+const Subprotocol = "v8-structured-clone"
+
+// Message types, as defined by RFC 6455 and used by gorilla/websocket and
+// nhooyr.io/websocket.
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+)
+
+// zlibMagic is the first byte of a zlib stream with the default window,
+// which a payload never starts with.
+const zlibMagic = 0x78
+
+// DefaultMaxMessageSize is the size limit applied to incoming messages
+// unless WithMaxMessageSize sets another.
+const DefaultMaxMessageSize = msgcodec.DefaultMaxSize
+
+var (
+	// ErrTextMessage is returned when a text message arrives; payloads are
+	// only sent as binary messages.
+	ErrTextMessage = errors.New("wsclone: text message")
+
+	// ErrMessageTooLarge is returned when a message, or its decompressed
+	// payload, exceeds the size set by WithMaxMessageSize.
+	ErrMessageTooLarge = errors.New("wsclone: message too large")
+
+	// ErrBadCompression is returned for messages that start like a zlib
+	// stream but do not inflate.
+	ErrBadCompression = errors.New("wsclone: invalid compressed message")
+)
+
+// MessageConn is the subset of a WebSocket connection Conn needs.
+// *websocket.Conn from gorilla/websocket implements it.
+type MessageConn interface {
+	ReadMessage() (messageType int, p []byte, err error)
+	WriteMessage(messageType int, data []byte) error
+}
+
+// Conn reads and writes Values as WebSocket messages. Like the
+// connections it wraps, it supports one concurrent reader and one
+// concurrent writer.
+type Conn struct {
+	conn  MessageConn
+	codec msgcodec.Codec
+}
+
+var zlibCompression = msgcodec.Compression{
+	NewWriter: func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) },
+	NewReader: zlib.NewReader,
+}
+
+// Option configures a Conn.
+type Option func(*Conn)
+
+// WithCompression compresses outgoing payloads of at least minSize bytes.
+// Compressed messages are always accepted on read; this only affects
+// writes. A minSize of zero or less disables compression (the default).
+func WithCompression(minSize int) Option {
+	return func(c *Conn) {
+		c.codec.CompressMin = minSize
+	}
+}
+
+// WithMaxMessageSize rejects incoming messages larger than n bytes, both
+// as received and after decompression, with ErrMessageTooLarge (default
+// DefaultMaxMessageSize). Zero or less means unlimited.
+func WithMaxMessageSize(n int) Option {
+	return func(c *Conn) {
+		c.codec.MaxSize = n
+	}
+}
+
+// WithDecodeOptions sets the options each incoming payload is decoded
+// with, such as WithLimits. They apply per message.
+func WithDecodeOptions(opts ...v8serialize.Option) Option {
+	return func(c *Conn) {
+		c.codec.Decode = opts
+	}
+}
+
+// WithEncodeOptions sets the options each outgoing value is serialized
+// with.
+func WithEncodeOptions(opts ...v8serialize.SerializerOption) Option {
+	return func(c *Conn) {
+		c.codec.Encode = opts
+	}
+}
+
+// New returns a Conn exchanging values over conn.
+func New(conn MessageConn, opts ...Option) *Conn {
+	c := &Conn{conn: conn, codec: msgcodec.Codec{
+		Compression: zlibCompression,
+		MaxSize:     DefaultMaxMessageSize,
+		ErrTooLarge: ErrMessageTooLarge,
+		ErrCorrupt:  ErrBadCompression,
+	}}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// ReadValue reads the next message and decodes it.
+func (c *Conn) ReadValue() (v8serialize.Value, error) {
+	data, err := c.ReadPayload()
+	if err != nil {
+		return v8serialize.Value{}, err
+	}
+	return c.codec.Deserialize(data)
+}
+
+// ReadPayload reads the next message and returns its payload,
+// decompressed but not decoded.
+func (c *Conn) ReadPayload() ([]byte, error) {
+	typ, msg, err := c.conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	if typ != BinaryMessage {
+		return nil, ErrTextMessage
+	}
+	if err := c.codec.CheckSize(len(msg)); err != nil {
+		return nil, err
+	}
+	if len(msg) == 0 || msg[0] != zlibMagic {
+		return msg, nil
+	}
+	return c.codec.Decompress(msg)
+}
+
+// WriteValue serializes v and sends it as one binary message.
+func (c *Conn) WriteValue(v v8serialize.Value) error {
+	data, err := c.codec.Serialize(v)
+	if err != nil {
+		return err
+	}
+	return c.WritePayload(data)
+}
+
+// WriteGo serializes a Go value, as SerializeGo does, and sends it as one
+// binary message.
+func (c *Conn) WriteGo(v interface{}) error {
+	data, err := c.codec.SerializeGo(v)
+	if err != nil {
+		return err
+	}
+	return c.WritePayload(data)
+}
+
+// WritePayload sends an already serialized payload as one binary message,
+// compressing it if it is large enough.
+func (c *Conn) WritePayload(data []byte) error {
+	data, _, err := c.codec.Compress(data)
+	if err != nil {
+		return err
+	}
+	return c.conn.WriteMessage(BinaryMessage, data)
+}
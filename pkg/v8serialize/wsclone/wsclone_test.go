@@ -0,0 +1,119 @@
+package wsclone
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/acolita/v8wire/pkg/v8serialize"
+)
+
+type message struct {
+	typ  int
+	data []byte
+}
+
+// pipe is a MessageConn whose writes are read back in order.
+type pipe struct {
+	messages []message
+}
+
+func (p *pipe) ReadMessage() (int, []byte, error) {
+	if len(p.messages) == 0 {
+		return 0, nil, errors.New("no message")
+	}
+	m := p.messages[0]
+	p.messages = p.messages[1:]
+	return m.typ, m.data, nil
+}
+
+func (p *pipe) WriteMessage(typ int, data []byte) error {
+	p.messages = append(p.messages, message{typ, append([]byte(nil), data...)})
+	return nil
+}
+
+func TestRoundTrip(t *testing.T) {
+	big := v8serialize.String(strings.Repeat("structured clone ", 100))
+	small := v8serialize.Int32(7)
+
+	p := &pipe{}
+	c := New(p, WithCompression(256))
+	for _, v := range []v8serialize.Value{big, small} {
+		if err := c.WriteValue(v); err != nil {
+			t.Fatalf("WriteValue: %v", err)
+		}
+	}
+	if p.messages[0].data[0] != zlibMagic || len(p.messages[0].data) >= 1700 {
+		t.Errorf("large payload not compressed: %d bytes starting %#x", len(p.messages[0].data), p.messages[0].data[0])
+	}
+	if p.messages[1].data[0] != 0xff || p.messages[1].typ != BinaryMessage {
+		t.Errorf("small payload: type %d starting %#x", p.messages[1].typ, p.messages[1].data[0])
+	}
+
+	// Compressed messages are read whether or not the reader compresses.
+	r := New(p)
+	for _, want := range []v8serialize.Value{big, small} {
+		got, err := r.ReadValue()
+		if err != nil {
+			t.Fatalf("ReadValue: %v", err)
+		}
+		gotKey, _ := v8serialize.Key(got)
+		wantKey, _ := v8serialize.Key(want)
+		if gotKey != wantKey {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	payload, _ := v8serialize.SerializeGo(strings.Repeat("x", 100))
+	var bomb bytes.Buffer
+	zw := zlib.NewWriter(&bomb)
+	zw.Write(payload)
+	zw.Close()
+
+	// Inflates to one byte over the default limit.
+	var big bytes.Buffer
+	zw, _ = zlib.NewWriterLevel(&big, zlib.BestSpeed)
+	zw.Write(make([]byte, DefaultMaxMessageSize+1))
+	zw.Close()
+
+	tests := []struct {
+		name string
+		msg  message
+		opts []Option
+		want error
+	}{
+		{"text", message{TextMessage, payload}, nil, ErrTextMessage},
+		{"too large", message{BinaryMessage, payload}, []Option{WithMaxMessageSize(50)}, ErrMessageTooLarge},
+		{"inflates too large", message{BinaryMessage, bomb.Bytes()}, []Option{WithMaxMessageSize(50)}, ErrMessageTooLarge},
+		{"default limit", message{BinaryMessage, big.Bytes()}, nil, ErrMessageTooLarge},
+		{"bad zlib", message{BinaryMessage, []byte{0x78, 0x00, 0x01}}, nil, ErrBadCompression},
+		{"decode limits", message{BinaryMessage, payload}, []Option{WithDecodeOptions(v8serialize.WithMaxSize(10))}, v8serialize.ErrMaxSizeExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(&pipe{messages: []message{tt.msg}}, tt.opts...)
+			if _, err := c.ReadValue(); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteGo(t *testing.T) {
+	p := &pipe{}
+	c := New(p, WithEncodeOptions(v8serialize.WithSortedKeys()))
+	if err := c.WriteGo(map[string]interface{}{"b": 1, "a": 2}); err != nil {
+		t.Fatalf("WriteGo: %v", err)
+	}
+	v, err := New(p).ReadValue()
+	if err != nil {
+		t.Fatalf("ReadValue: %v", err)
+	}
+	if keys := v.Keys(); len(keys) != 2 || keys[0] != "a" {
+		t.Errorf("keys = %v, want sorted", keys)
+	}
+}
@@ -120,11 +120,18 @@ function safeStringify(val, replacer) {
  * Encode a value and save both .bin and .json fixtures
  */
 function encode(val, filename, description) {
+  encodeWith(() => v8.serialize(val), val, filename, description);
+}
+
+/**
+ * Save fixtures for a buffer produced by a custom serialization routine
+ */
+function encodeWith(serialize, val, filename, description) {
   const binPath = path.join(FIXTURES_DIR, `${filename}.bin`);
   const jsonPath = path.join(FIXTURES_DIR, `${filename}.json`);
 
   try {
-    const buf = v8.serialize(val);
+    const buf = serialize();
     fs.writeFileSync(binPath, buf);
 
     // Create metadata object - use safe stringify to handle circular refs
@@ -331,6 +338,7 @@ encode(new Uint8Array([1, 2, 3, 4]).buffer, 'arraybuffer-data', 'ArrayBuffer wit
 
 encode(new Uint8Array([255, 0, 128]), 'uint8array', 'Uint8Array');
 encode(new Int8Array([-128, 0, 127]), 'int8array', 'Int8Array');
+encode(new Uint8ClampedArray([0, 128, 255]), 'uint8clampedarray', 'Uint8ClampedArray');
 encode(new Uint16Array([0, 65535]), 'uint16array', 'Uint16Array');
 encode(new Int16Array([-32768, 32767]), 'int16array', 'Int16Array');
 encode(new Uint32Array([0, 4294967295]), 'uint32array', 'Uint32Array');
@@ -590,6 +598,51 @@ try {
   console.log('[SKIP] ResizableArrayBuffer: not supported in this Node version');
 }
 
+// ============================================================================
+// Multi-root payloads (one header, several values)
+// ============================================================================
+console.log('\n--- Multi-root ---');
+
+const multiRootValues = [42, 'second', { third: true }];
+encodeWith(() => {
+  const ser = new v8.DefaultSerializer();
+  ser.writeHeader();
+  for (const v of multiRootValues) ser.writeValue(v);
+  return ser.releaseBuffer();
+}, multiRootValues, 'multi-root', 'three values written after a single header');
+
+// ============================================================================
+// Native ArrayBufferViews (Deno core.serialize style)
+// ============================================================================
+console.log('\n--- Native views ---');
+
+// The base v8.Serializer does not treat views as host objects, so V8 writes
+// them natively ('B' buffer + 'V' view), as Deno's core.serialize does.
+function encodeNative(val, filename, description) {
+  encodeWith(() => {
+    const ser = new v8.Serializer();
+    ser.writeHeader();
+    ser.writeValue(val);
+    return ser.releaseBuffer();
+  }, val, filename, description);
+}
+
+encodeNative(new Uint8Array([1, 2, 3]), 'native-uint8array', 'native Uint8Array view');
+encodeNative(new Int16Array([1, -1]), 'native-int16array', 'native Int16Array view');
+encodeNative(new Uint8Array(new Uint8Array([0, 1, 2, 3, 4, 5, 6, 7]).buffer, 2, 3), 'native-uint8array-offset', 'native Uint8Array view at an offset');
+encodeNative(new DataView(new Uint8Array([1, 2, 3, 4]).buffer), 'native-dataview', 'native DataView');
+const nativeShared = new Uint8Array([9]);
+encodeNative([nativeShared, nativeShared], 'native-view-reference', 'native view referenced twice');
+
+// Small Buffers are views into a shared 8 KiB pool, so written natively they
+// carry the whole pool, other Buffers' bytes included, and a nonzero offset.
+Buffer.from('first');
+const pooled = Buffer.from('pooled');
+const pooledNext = Buffer.from('next');
+encodeNative(pooled, 'native-buffer-pooled', 'native pooled Buffer view');
+encodeNative([pooled, pooledNext], 'native-buffers-pooled', 'two native Buffer views into one pool');
+encode(pooled, 'buffer-pooled', 'pooled Buffer as a Node host object');
+
 // ============================================================================
 // Summary
 // ============================================================================